@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/service"
+)
+
+type AccountHandler struct {
+	cfg            *config.Config
+	authService    service.AuthService
+	accountService service.AccountService
+}
+
+func NewAccountHandler(cfg *config.Config, authService service.AuthService, accountService service.AccountService) *AccountHandler {
+	return &AccountHandler{
+		cfg:            cfg,
+		authService:    authService,
+		accountService: accountService,
+	}
+}
+
+func (h *AccountHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := currentSession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	account, err := h.accountService.GetAccount(ctx, *session.UserGuid)
+	if err != nil {
+		if errors.Is(err, service.ErrNoUserFound) {
+			utils.WriteResponse(w, 404, err.Error())
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteJson(w, 200, account)
+}
+
+func (h *AccountHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := currentSession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.accountService.ListSessions(ctx, session)
+	if err != nil {
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteJson(w, 200, sessions)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"slices"
+	"strings"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+
+	"github.com/google/uuid"
+)
+
+// checks admin bearer token from config and writes 401/403 on failure
+func requireAdmin(w http.ResponseWriter, r *http.Request, cfg *config.Config) bool {
+	if cfg.Admin.Token == "" {
+		utils.WriteResponse(w, 403, "admin api is disabled")
+		return false
+	}
+
+	token, ok := utils.GetBearerToken(r)
+	if !ok {
+		utils.WriteResponse(w, 401, "incorrect token format")
+		return false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Admin.Token)) != 1 {
+		utils.WriteResponse(w, 403, "admin access required")
+		return false
+	}
+	return true
+}
+
+// accepts static admin token as well as access tokens and api keys of users holding admin permission.
+// Returns tenant whose users the caller may manage, static token manages default tenant
+func requireUserAdmin(ctx context.Context, w http.ResponseWriter, r *http.Request, cfg *config.Config, introspectionService service.IntrospectionService, roleService service.RoleService) (string, bool) {
+	token, ok := utils.GetBearerToken(r)
+	if !ok {
+		utils.WriteResponse(w, 401, "incorrect token format")
+		return "", false
+	}
+
+	if cfg.Admin.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Admin.Token)) == 1 {
+		return entities.DefaultTenantID, true
+	}
+
+	resp, err := introspectionService.Inspect(ctx, token)
+	if err != nil {
+		utils.WriteResponse(w, 500, "something went wrong")
+		return "", false
+	}
+	if !resp.Active {
+		utils.WriteResponse(w, 401, "token is invalid or expired")
+		return "", false
+	}
+	// tokens meant for other services and exchanged or impersonation tokens don't act for the user themselves
+	if len(resp.Audience) > 0 || resp.Act != nil {
+		utils.WriteResponse(w, 403, "admin access required")
+		return "", false
+	}
+	// user delegated access to a client or api key, delegation has to include admin scope
+	if resp.ClientID != "" || resp.TokenType != "Bearer" {
+		if cfg.Admin.Scope == "" || !slices.Contains(strings.Fields(resp.Scope), cfg.Admin.Scope) {
+			utils.WriteResponse(w, 403, "admin scope required")
+			return "", false
+		}
+	}
+
+	userGuid, err := uuid.Parse(resp.Subject)
+	if err != nil || cfg.Admin.Permission == "" {
+		utils.WriteResponse(w, 403, "admin access required")
+		return "", false
+	}
+	allowed, err := roleService.HasPermission(ctx, userGuid, cfg.Admin.Permission)
+	if err != nil {
+		utils.WriteResponse(w, 500, "something went wrong")
+		return "", false
+	}
+	if !allowed {
+		utils.WriteResponse(w, 403, "admin access required")
+		return "", false
+	}
+
+	if resp.Tenant == "" {
+		return entities.DefaultTenantID, true
+	}
+	return resp.Tenant, true
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/api/middleware"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+
+	"github.com/google/uuid"
+)
+
+type APIKeyHandler struct {
+	cfg           *config.Config
+	apiKeyService service.APIKeyService
+}
+
+func NewAPIKeyHandler(cfg *config.Config, apiKeyService service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		cfg:           cfg,
+		apiKeyService: apiKeyService,
+	}
+}
+
+// plain key is returned only in this response
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	owner, ok := h.owner(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	var req entities.APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	key, err := h.apiKeyService.CreateKey(ctx, owner, &req)
+	if err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.WriteJson(w, 201, key)
+}
+
+func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	owner, ok := h.owner(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := h.apiKeyService.ListKeys(ctx, owner)
+	if err != nil {
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteJson(w, 200, keys)
+}
+
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	owner, ok := h.owner(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.WriteResponse(w, 400, "incorrect key id")
+		return
+	}
+
+	if err := h.apiKeyService.RevokeKey(ctx, owner, id); err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+
+	utils.WriteResponse(w, 200, "api key has been revoked")
+}
+
+// describes key the request was authenticated with, lets integrations check their key
+func (h *APIKeyHandler) Me(w http.ResponseWriter, r *http.Request) {
+	key, ok := middleware.APIKeyFromContext(r.Context())
+	if !ok {
+		utils.WriteResponse(w, 401, "api key is required")
+		return
+	}
+	utils.WriteJson(w, 200, key)
+}
+
+func (h *APIKeyHandler) owner(ctx context.Context, w http.ResponseWriter, r *http.Request) (*entities.APIKeyOwner, bool) {
+	token, ok := utils.GetBearerToken(r)
+	if !ok {
+		utils.WriteResponse(w, 401, "incorrect token format")
+		return nil, false
+	}
+
+	owner, err := h.apiKeyService.Owner(ctx, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidAccessToken):
+			utils.WriteResponse(w, 401, err.Error())
+		case errors.Is(err, service.ErrAPIKeyOwnerNotAllowed):
+			utils.WriteResponse(w, 403, err.Error())
+		default:
+			utils.WriteResponse(w, 500, "something went wrong")
+		}
+		return nil, false
+	}
+	return owner, true
+}
+
+func writeAPIKeyError(w http.ResponseWriter, err error) {
+	var reqErr *service.InvalidAPIKeyRequestError
+	switch {
+	case errors.As(err, &reqErr):
+		utils.WriteResponse(w, 400, reqErr.Error())
+	case errors.Is(err, service.ErrTooManyAPIKeys):
+		utils.WriteResponse(w, 409, err.Error())
+	case errors.Is(err, service.ErrAPIKeyNotFound):
+		utils.WriteResponse(w, 404, err.Error())
+	default:
+		utils.WriteResponse(w, 500, "something went wrong")
+	}
+}
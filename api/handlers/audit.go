@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+
+	"github.com/google/uuid"
+)
+
+type AuditHandler struct {
+	cfg                  *config.Config
+	auditService         service.AuditService
+	introspectionService service.IntrospectionService
+	roleService          service.RoleService
+}
+
+func NewAuditHandler(cfg *config.Config, auditService service.AuditService, introspectionService service.IntrospectionService, roleService service.RoleService) *AuditHandler {
+	return &AuditHandler{
+		cfg:                  cfg,
+		auditService:         auditService,
+		introspectionService: introspectionService,
+		roleService:          roleService,
+	}
+}
+
+func (h *AuditHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	tenantID, ok := requireUserAdmin(ctx, w, r, h.cfg, h.introspectionService, h.roleService)
+	if !ok {
+		return
+	}
+	// audit log isn't split by tenant, only administrators of default tenant may read it
+	if tenantID != entities.DefaultTenantID {
+		utils.WriteResponse(w, 403, "admin access required")
+		return
+	}
+
+	query := r.URL.Query()
+	var filter entities.AuditFilter
+	if v := query.Get("user"); v != "" {
+		userGuid, err := uuid.Parse(v)
+		if err != nil {
+			utils.WriteResponse(w, 400, "incorrect user guid")
+			return
+		}
+		filter.UserGuid = &userGuid
+	}
+	var err error
+	if v := query.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			utils.WriteResponse(w, 400, "incorrect limit")
+			return
+		}
+	}
+	if v := query.Get("offset"); v != "" {
+		if filter.Offset, err = strconv.Atoi(v); err != nil {
+			utils.WriteResponse(w, 400, "incorrect offset")
+			return
+		}
+	}
+
+	events, err := h.auditService.ListEvents(ctx, &filter)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPage) {
+			utils.WriteResponse(w, 400, err.Error())
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteJson(w, 200, events)
+}
@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	utils "testovoe_medods/api/handlers/authutils"
 	"testovoe_medods/config"
@@ -31,14 +34,62 @@ func (h *AuthHandler) Authenticate(w http.ResponseWriter, r *http.Request) {
 	url := strings.Split(r.URL.String(), "/")
 	guid := url[len(url) - 1]
 	ipAddr := utils.GetUserIp(r)
-	authReq := entities.AuthenticateRequest{Guid: guid, IpAddr: ipAddr}
+	authReq := entities.AuthenticateRequest{Guid: guid, IpAddr: ipAddr, Method: entities.LoginMethodGuid}
 	tokenPair, err := h.authService.ReleaseTokens(ctx, &authReq)
 
 	if err != nil {
+		if writeLockoutError(w, err) {
+			return
+		}
+		if writeTenantAccessError(w, err) {
+			return
+		}
+		if writeUserDisabledError(w, err) {
+			return
+		}
 		if errors.Is(err, service.ErrNoUserFound) {
 			utils.WriteResponse(w, 403, err.Error())
 			return
 		}
+		var mfaErr *service.MFARequiredError
+		if errors.As(err, &mfaErr) {
+			utils.WriteJson(w, 401, mfaErr.Challenge)
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteJson(w, 200, tokenPair)
+}
+
+func (h *AuthHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	var req entities.MFAVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+	req.IpAddr = utils.GetUserIp(r)
+
+	tokenPair, err := h.authService.CompleteMFA(ctx, &req)
+
+	if err != nil {
+		if writeLockoutError(w, err) {
+			return
+		}
+		if writeTenantAccessError(w, err) {
+			return
+		}
+		if writeUserDisabledError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidMFAChallenge) || errors.Is(err, service.ErrInvalidMFACode) {
+			utils.WriteResponse(w, 401, err.Error())
+			return
+		}
 		utils.WriteResponse(w, 500, "something went wrong")
 		return
 	}
@@ -60,9 +111,18 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 
 	token := bearerSlc[1]
 	
-	tokenPair, err := h.authService.RefreshToken(ctx, token)
+	tokenPair, err := h.authService.RefreshToken(ctx, token, utils.GetUserIp(r))
 
 	if err != nil {
+		if writeLockoutError(w, err) {
+			return
+		}
+		if writeTenantAccessError(w, err) {
+			return
+		}
+		if writeUserDisabledError(w, err) {
+			return
+		}
 		if errors.Is(err, jwt.ErrTokenMalformed) ||  errors.Is(err, service.ErrInvalidTokenClaims) {
 			utils.WriteResponse(w, 403, err.Error())
 			return
@@ -72,3 +132,40 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 	}
 	utils.WriteJson(w, 200, tokenPair)
 }
+
+// authenticates request by access token from Authorization header and writes 401 on failure
+func currentSession(ctx context.Context, w http.ResponseWriter, r *http.Request, authService service.AuthService) (*entities.UserAuthInfo, bool) {
+	token, ok := utils.GetBearerToken(r)
+	if !ok {
+		utils.WriteResponse(w, 401, "incorrect token format")
+		return nil, false
+	}
+
+	session, err := authService.AuthenticateAccessToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAccessToken) {
+			utils.WriteResponse(w, 401, err.Error())
+			return nil, false
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return nil, false
+	}
+	return session, true
+}
+
+// writes 423 for lockouts and 429 for progressive delays, both with Retry-After
+func writeLockoutError(w http.ResponseWriter, err error) bool {
+	var lockErr *service.LockedError
+	if !errors.As(err, &lockErr) {
+		return false
+	}
+
+	retryAfter := int64(math.Ceil(lockErr.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+	if lockErr.Locked {
+		utils.WriteResponse(w, 423, lockErr.Error())
+		return true
+	}
+	utils.WriteResponse(w, 429, lockErr.Error())
+	return true
+}
@@ -1,11 +1,25 @@
 package utils
 
 import (
+	"net"
 	"net/http"
 	"strings"
 )
 
+// extracts token from "Authorization: Bearer <token>" header
+func GetBearerToken(r *http.Request) (string, bool) {
+	bearerSlc := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(bearerSlc) != 2 || !strings.EqualFold(bearerSlc[0], "Bearer") || bearerSlc[1] == "" {
+		return "", false
+	}
+	return bearerSlc[1], true
+}
+
+// address of the client without port, ipv6 addresses come without brackets
 func GetUserIp(r *http.Request) string {
-	ip := r.RemoteAddr
-  return strings.Split(ip, ":")[0]
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
\ No newline at end of file
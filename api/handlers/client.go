@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+)
+
+type OAuthClientHandler struct {
+	cfg           *config.Config
+	clientService service.OAuthClientService
+}
+
+func NewOAuthClientHandler(cfg *config.Config, clientService service.OAuthClientService) *OAuthClientHandler {
+	return &OAuthClientHandler{
+		cfg:           cfg,
+		clientService: clientService,
+	}
+}
+
+func (h *OAuthClientHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	var req entities.OAuthClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	client, err := h.clientService.CreateClient(ctx, &req)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 201, client)
+}
+
+func (h *OAuthClientHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	clients, err := h.clientService.ListClients(ctx)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, clients)
+}
+
+func (h *OAuthClientHandler) Get(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	client, err := h.clientService.GetClient(ctx, r.PathValue("id"))
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, client)
+}
+
+func (h *OAuthClientHandler) Update(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	var req entities.OAuthClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	client, err := h.clientService.UpdateClient(ctx, r.PathValue("id"), &req)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, client)
+}
+
+func (h *OAuthClientHandler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	client, err := h.clientService.RotateSecret(ctx, r.PathValue("id"))
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, client)
+}
+
+func (h *OAuthClientHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	if err := h.clientService.DeleteClient(ctx, r.PathValue("id")); err != nil {
+		writeClientError(w, err)
+		return
+	}
+
+	utils.WriteResponse(w, 200, "client deleted")
+}
+
+func writeClientError(w http.ResponseWriter, err error) {
+	var metadataErr *service.InvalidClientMetadataError
+	if errors.As(err, &metadataErr) {
+		utils.WriteResponse(w, 400, err.Error())
+		return
+	}
+	if errors.Is(err, service.ErrClientNotFound) {
+		utils.WriteResponse(w, 404, err.Error())
+		return
+	}
+	if errors.Is(err, service.ErrClientAlreadyExists) {
+		utils.WriteResponse(w, 409, err.Error())
+		return
+	}
+	utils.WriteResponse(w, 500, "something went wrong")
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+)
+
+type ConsentHandler struct {
+	cfg            *config.Config
+	authService    service.AuthService
+	consentService service.ConsentService
+}
+
+func NewConsentHandler(cfg *config.Config, authService service.AuthService, consentService service.ConsentService) *ConsentHandler {
+	return &ConsentHandler{
+		cfg:            cfg,
+		authService:    authService,
+		consentService: consentService,
+	}
+}
+
+// describes client and scopes for consent screen
+func (h *ConsentHandler) Describe(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := firstPartySession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	info, err := h.consentService.Describe(ctx, *session.UserGuid, &entities.ConsentRequest{
+		ClientID: r.URL.Query().Get("client_id"),
+		Scope:    r.URL.Query().Get("scope"),
+	})
+	if err != nil {
+		writeConsentError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, info)
+}
+
+func (h *ConsentHandler) Grant(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := firstPartySession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	var req entities.ConsentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	if err := h.consentService.Grant(ctx, *session.UserGuid, &req); err != nil {
+		writeConsentError(w, err)
+		return
+	}
+
+	utils.WriteResponse(w, 200, "consent has been granted")
+}
+
+func (h *ConsentHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := firstPartySession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	consents, err := h.consentService.ListConsents(ctx, *session.UserGuid)
+	if err != nil {
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteJson(w, 200, consents)
+}
+
+func (h *ConsentHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := firstPartySession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	if err := h.consentService.Revoke(ctx, *session.UserGuid, r.PathValue("client_id")); err != nil {
+		writeConsentError(w, err)
+		return
+	}
+
+	utils.WriteResponse(w, 200, "consent has been revoked")
+}
+
+func writeConsentError(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrClientNotFound) || errors.Is(err, service.ErrConsentNotFound) {
+		utils.WriteResponse(w, 404, err.Error())
+		return
+	}
+	var oauthErr *service.OAuthError
+	if errors.As(err, &oauthErr) {
+		utils.WriteJson(w, 400, oauthErr)
+		return
+	}
+	utils.WriteResponse(w, 500, "something went wrong")
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+)
+
+type DeviceHandler struct {
+	cfg           *config.Config
+	authService   service.AuthService
+	deviceService service.DeviceService
+}
+
+func NewDeviceHandler(cfg *config.Config, authService service.AuthService, deviceService service.DeviceService) *DeviceHandler {
+	return &DeviceHandler{
+		cfg:           cfg,
+		authService:   authService,
+		deviceService: deviceService,
+	}
+}
+
+func (h *DeviceHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := r.ParseForm(); err != nil {
+		utils.WriteJson(w, 400, &service.OAuthError{Code: "invalid_request"})
+		return
+	}
+
+	req := entities.DeviceAuthorizationRequest{
+		Scope:  r.PostForm.Get("scope"),
+		Client: clientAuthentication(r),
+	}
+
+	resp, err := h.deviceService.Authorize(ctx, &req)
+	if err != nil {
+		writeOAuthError(w, err, req.Client.Method == entities.ClientAuthSecretBasic)
+		return
+	}
+
+	utils.WriteJson(w, 200, resp)
+}
+
+func (h *DeviceHandler) Lookup(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := firstPartySession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	info, err := h.deviceService.Lookup(ctx, *session.UserGuid, r.URL.Query().Get("user_code"), utils.GetUserIp(r))
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, info)
+}
+
+func (h *DeviceHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := firstPartySession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	var req entities.DeviceVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+	req.IpAddr = utils.GetUserIp(r)
+
+	if err := h.deviceService.Verify(ctx, *session.UserGuid, &req); err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+
+	if req.Approve {
+		utils.WriteResponse(w, 200, "device has been approved")
+		return
+	}
+	utils.WriteResponse(w, 200, "device has been denied")
+}
+
+// devices can be approved only from sessions of our own login, not with tokens issued to clients
+func firstPartySession(ctx context.Context, w http.ResponseWriter, r *http.Request, authService service.AuthService) (*entities.UserAuthInfo, bool) {
+	session, ok := currentSession(ctx, w, r, authService)
+	if !ok {
+		return nil, false
+	}
+	if session.ClientID != nil || session.ImpersonatorGuid != nil {
+		utils.WriteResponse(w, 403, "first-party session required")
+		return nil, false
+	}
+	return session, true
+}
+
+func writeDeviceError(w http.ResponseWriter, err error) {
+	if writeLockoutError(w, err) {
+		return
+	}
+	if errors.Is(err, service.ErrInvalidUserCode) {
+		utils.WriteResponse(w, 404, err.Error())
+		return
+	}
+	utils.WriteResponse(w, 500, "something went wrong")
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+
+	"github.com/google/uuid"
+)
+
+const federationStateCookie = "federation_state"
+
+type FederationHandler struct {
+	cfg               *config.Config
+	federationService service.FederationService
+	authService       service.AuthService
+}
+
+func NewFederationHandler(cfg *config.Config, federationService service.FederationService, authService service.AuthService) *FederationHandler {
+	return &FederationHandler{
+		cfg:               cfg,
+		federationService: federationService,
+		authService:       authService,
+	}
+}
+
+func (h *FederationHandler) Login(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout+h.cfg.HTTPServer.WriteTimeout)
+	defer cancel()
+
+	// signed in users link the provider to their own account
+	var linkUser *uuid.UUID
+	if _, ok := utils.GetBearerToken(r); ok {
+		session, ok := firstPartySession(ctx, w, r, h.authService)
+		if !ok {
+			return
+		}
+		linkUser = session.UserGuid
+	}
+
+	provider := r.PathValue("provider")
+	redirectURL, state, err := h.federationService.Begin(ctx, provider, linkUser)
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownProvider) {
+			utils.WriteResponse(w, 404, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrFederationFailed) {
+			utils.WriteResponse(w, 502, err.Error())
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     federationStateCookie,
+		Value:    state,
+		Path:     "/api/federation/" + provider,
+		MaxAge:   int(h.cfg.Federation.StateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   h.cfg.Federation.SecureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+func (h *FederationHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout+h.cfg.HTTPServer.WriteTimeout)
+	defer cancel()
+
+	provider := r.PathValue("provider")
+	query := r.URL.Query()
+	if idpErr := query.Get("error"); idpErr != "" {
+		utils.WriteResponse(w, 401, "identity provider returned error: "+idpErr)
+		return
+	}
+
+	var cookieState string
+	if cookie, err := r.Cookie(federationStateCookie); err == nil {
+		cookieState = cookie.Value
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     federationStateCookie,
+		Value:    "",
+		Path:     "/api/federation/" + provider,
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	tokenPair, err := h.federationService.Callback(ctx, &entities.FederationCallbackRequest{
+		Provider:    provider,
+		State:       query.Get("state"),
+		CookieState: cookieState,
+		Code:        query.Get("code"),
+		IpAddr:      utils.GetUserIp(r),
+	})
+	if err != nil {
+		if writeLockoutError(w, err) {
+			return
+		}
+		if writeTenantAccessError(w, err) {
+			return
+		}
+		if writeUserDisabledError(w, err) {
+			return
+		}
+		var mfaErr *service.MFARequiredError
+		if errors.As(err, &mfaErr) {
+			utils.WriteJson(w, 401, mfaErr.Challenge)
+			return
+		}
+		if errors.Is(err, service.ErrUnknownProvider) {
+			utils.WriteResponse(w, 404, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrFederationConflict) {
+			utils.WriteResponse(w, 409, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrInvalidFederationState) || errors.Is(err, service.ErrFederationFailed) || errors.Is(err, service.ErrSignupNotAllowed) {
+			utils.WriteResponse(w, 401, err.Error())
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteJson(w, 200, tokenPair)
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+
+	"github.com/google/uuid"
+)
+
+type GroupHandler struct {
+	cfg          *config.Config
+	groupService service.GroupService
+}
+
+func NewGroupHandler(cfg *config.Config, groupService service.GroupService) *GroupHandler {
+	return &GroupHandler{
+		cfg:          cfg,
+		groupService: groupService,
+	}
+}
+
+func (h *GroupHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	var req entities.GroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	group, err := h.groupService.CreateGroup(ctx, &req)
+	if err != nil {
+		writeGroupError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 201, group)
+}
+
+func (h *GroupHandler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	groups, err := h.groupService.ListGroups(ctx)
+	if err != nil {
+		writeGroupError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, groups)
+}
+
+func (h *GroupHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	id, ok := groupID(w, r)
+	if !ok {
+		return
+	}
+
+	group, err := h.groupService.GetGroup(ctx, id)
+	if err != nil {
+		writeGroupError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, group)
+}
+
+func (h *GroupHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	id, ok := groupID(w, r)
+	if !ok {
+		return
+	}
+
+	var req entities.GroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	group, err := h.groupService.UpdateGroup(ctx, id, &req)
+	if err != nil {
+		writeGroupError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, group)
+}
+
+func (h *GroupHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	id, ok := groupID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.groupService.DeleteGroup(ctx, id); err != nil {
+		writeGroupError(w, err)
+		return
+	}
+
+	utils.WriteResponse(w, 200, "group deleted")
+}
+
+func (h *GroupHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	id, ok := groupID(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := h.groupService.ListMembers(ctx, id)
+	if err != nil {
+		writeGroupError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, members)
+}
+
+func (h *GroupHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	id, ok := groupID(w, r)
+	if !ok {
+		return
+	}
+	userGuid, err := uuid.Parse(r.PathValue("guid"))
+	if err != nil {
+		utils.WriteResponse(w, 400, "incorrect user guid")
+		return
+	}
+
+	if err := h.groupService.AddMember(ctx, id, userGuid); err != nil {
+		writeGroupError(w, err)
+		return
+	}
+
+	utils.WriteResponse(w, 200, "member added")
+}
+
+func (h *GroupHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	id, ok := groupID(w, r)
+	if !ok {
+		return
+	}
+	userGuid, err := uuid.Parse(r.PathValue("guid"))
+	if err != nil {
+		utils.WriteResponse(w, 400, "incorrect user guid")
+		return
+	}
+
+	if err := h.groupService.RemoveMember(ctx, id, userGuid); err != nil {
+		writeGroupError(w, err)
+		return
+	}
+
+	utils.WriteResponse(w, 200, "member removed")
+}
+
+func (h *GroupHandler) ListUserGroups(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	userGuid, err := uuid.Parse(r.PathValue("guid"))
+	if err != nil {
+		utils.WriteResponse(w, 400, "incorrect user guid")
+		return
+	}
+
+	groups, err := h.groupService.ListUserGroups(ctx, userGuid)
+	if err != nil {
+		writeGroupError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, groups)
+}
+
+func groupID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.WriteResponse(w, 404, service.ErrGroupNotFound.Error())
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func writeGroupError(w http.ResponseWriter, err error) {
+	var reqErr *service.InvalidGroupRequestError
+	switch {
+	case errors.As(err, &reqErr):
+		utils.WriteResponse(w, 400, err.Error())
+	case errors.Is(err, service.ErrGroupNotFound), errors.Is(err, service.ErrGroupMemberNotFound), errors.Is(err, service.ErrNoUserFound):
+		utils.WriteResponse(w, 404, err.Error())
+	case errors.Is(err, service.ErrGroupAlreadyExists):
+		utils.WriteResponse(w, 409, err.Error())
+	default:
+		utils.WriteResponse(w, 500, "something went wrong")
+	}
+}
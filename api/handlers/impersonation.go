@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+)
+
+type ImpersonationHandler struct {
+	cfg                  *config.Config
+	authService          service.AuthService
+	impersonationService service.ImpersonationService
+}
+
+func NewImpersonationHandler(cfg *config.Config, authService service.AuthService, impersonationService service.ImpersonationService) *ImpersonationHandler {
+	return &ImpersonationHandler{
+		cfg:                  cfg,
+		authService:          authService,
+		impersonationService: impersonationService,
+	}
+}
+
+// administrator authenticates with own first-party session, permission comes from their roles
+func (h *ImpersonationHandler) Impersonate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	session, ok := firstPartySession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+	// otherwise impersonated user's roles would decide whether impersonation goes on
+	if session.ImpersonatorGuid != nil {
+		utils.WriteResponse(w, 403, "impersonation sessions can't impersonate")
+		return
+	}
+
+	guid, ok := pathUserGuid(w, r)
+	if !ok {
+		return
+	}
+
+	var req entities.ImpersonationRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteResponse(w, 400, "incorrect request body")
+			return
+		}
+	}
+	req.AdminGuid = *session.UserGuid
+	req.UserGuid = guid
+	req.IpAddr = utils.GetUserIp(r)
+
+	token, err := h.impersonationService.Impersonate(ctx, &req)
+	if err != nil {
+		writeImpersonationError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, token)
+}
+
+func writeImpersonationError(w http.ResponseWriter, err error) {
+	var reqErr *service.InvalidImpersonationRequestError
+	switch {
+	case errors.As(err, &reqErr):
+		utils.WriteResponse(w, 400, err.Error())
+	case errors.Is(err, service.ErrImpersonationForbidden), errors.Is(err, service.ErrImpersonationTargetForbidden),
+		errors.Is(err, service.ErrUserDisabled):
+		utils.WriteResponse(w, 403, err.Error())
+	case errors.Is(err, service.ErrNoUserFound):
+		utils.WriteResponse(w, 404, err.Error())
+	default:
+		utils.WriteResponse(w, 500, "something went wrong")
+	}
+}
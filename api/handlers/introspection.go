@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+)
+
+type IntrospectionHandler struct {
+	cfg                  *config.Config
+	introspectionService service.IntrospectionService
+}
+
+func NewIntrospectionHandler(cfg *config.Config, introspectionService service.IntrospectionService) *IntrospectionHandler {
+	return &IntrospectionHandler{
+		cfg:                  cfg,
+		introspectionService: introspectionService,
+	}
+}
+
+func (h *IntrospectionHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := r.ParseForm(); err != nil {
+		utils.WriteJson(w, 400, &service.OAuthError{Code: "invalid_request"})
+		return
+	}
+
+	auth := clientAuthentication(r)
+	resp, err := h.introspectionService.Introspect(ctx, &auth, r.PostForm.Get("token"))
+	if err != nil {
+		writeOAuthError(w, err, auth.Method == entities.ClientAuthSecretBasic)
+		return
+	}
+
+	utils.WriteJson(w, 200, resp)
+}
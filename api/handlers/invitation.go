@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+
+	"github.com/google/uuid"
+)
+
+type InvitationHandler struct {
+	cfg               *config.Config
+	authService       service.AuthService
+	invitationService service.InvitationService
+}
+
+func NewInvitationHandler(cfg *config.Config, authService service.AuthService, invitationService service.InvitationService) *InvitationHandler {
+	return &InvitationHandler{
+		cfg:               cfg,
+		authService:       authService,
+		invitationService: invitationService,
+	}
+}
+
+func (h *InvitationHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := firstPartySession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	var req entities.InvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	invitation, err := h.invitationService.CreateInvitation(ctx, *session.UserGuid, r.PathValue("id"), &req)
+	if err != nil {
+		writeInvitationError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 201, invitation)
+}
+
+func (h *InvitationHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := firstPartySession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.invitationService.ListInvitations(ctx, *session.UserGuid, r.PathValue("id"))
+	if err != nil {
+		writeInvitationError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, invitations)
+}
+
+func (h *InvitationHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := firstPartySession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("invitation"))
+	if err != nil {
+		utils.WriteResponse(w, 404, service.ErrInvitationNotFound.Error())
+		return
+	}
+
+	if err := h.invitationService.RevokeInvitation(ctx, *session.UserGuid, r.PathValue("id"), id); err != nil {
+		writeInvitationError(w, err)
+		return
+	}
+
+	utils.WriteResponse(w, 200, "invitation revoked")
+}
+
+// token from the emailed link is the only credential, invited user doesn't need an account yet
+func (h *InvitationHandler) Accept(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	var req entities.AcceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	member, err := h.invitationService.AcceptInvitation(ctx, req.Token)
+	if err != nil {
+		writeInvitationError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, member)
+}
+
+func writeInvitationError(w http.ResponseWriter, err error) {
+	var reqErr *service.InvalidInvitationRequestError
+	switch {
+	case errors.As(err, &reqErr), errors.Is(err, service.ErrInvalidInvitation):
+		utils.WriteResponse(w, 400, err.Error())
+	case errors.Is(err, service.ErrNotTenantAdmin):
+		utils.WriteResponse(w, 403, err.Error())
+	case errors.Is(err, service.ErrTenantNotFound), errors.Is(err, service.ErrInvitationNotFound):
+		utils.WriteResponse(w, 404, err.Error())
+	default:
+		utils.WriteResponse(w, 500, "something went wrong")
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/service"
+
+	"github.com/google/uuid"
+)
+
+type LockoutHandler struct {
+	cfg            *config.Config
+	lockoutService service.LockoutService
+}
+
+func NewLockoutHandler(cfg *config.Config, lockoutService service.LockoutService) *LockoutHandler {
+	return &LockoutHandler{
+		cfg:            cfg,
+		lockoutService: lockoutService,
+	}
+}
+
+func (h *LockoutHandler) UserStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+	key, ok := userLockoutKey(w, r)
+	if !ok {
+		return
+	}
+	h.status(w, key)
+}
+
+func (h *LockoutHandler) IPStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+	key, ok := ipLockoutKey(w, r)
+	if !ok {
+		return
+	}
+	h.status(w, key)
+}
+
+func (h *LockoutHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+	key, ok := userLockoutKey(w, r)
+	if !ok {
+		return
+	}
+	h.unlock(w, key)
+}
+
+func (h *LockoutHandler) UnlockIP(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+	key, ok := ipLockoutKey(w, r)
+	if !ok {
+		return
+	}
+	h.unlock(w, key)
+}
+
+func (h *LockoutHandler) status(w http.ResponseWriter, key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	status, err := h.lockoutService.Status(ctx, key)
+	if err != nil {
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+	utils.WriteJson(w, 200, status)
+}
+
+func (h *LockoutHandler) unlock(w http.ResponseWriter, key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if err := h.lockoutService.Unlock(ctx, key); err != nil {
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+	utils.WriteResponse(w, 200, "unlocked")
+}
+
+func userLockoutKey(w http.ResponseWriter, r *http.Request) (string, bool) {
+	guid, err := uuid.Parse(r.PathValue("guid"))
+	if err != nil {
+		utils.WriteResponse(w, 400, "incorrect user guid")
+		return "", false
+	}
+	return service.UserLockoutKey(guid.String()), true
+}
+
+func ipLockoutKey(w http.ResponseWriter, r *http.Request) (string, bool) {
+	ip := net.ParseIP(r.PathValue("ip"))
+	if ip == nil {
+		utils.WriteResponse(w, 400, "incorrect ip address")
+		return "", false
+	}
+	return service.IPLockoutKey(ip.String()), true
+}
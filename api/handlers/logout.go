@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/service"
+)
+
+type LogoutHandler struct {
+	cfg           *config.Config
+	authService   service.AuthService
+	logoutService service.LogoutService
+}
+
+func NewLogoutHandler(cfg *config.Config, authService service.AuthService, logoutService service.LogoutService) *LogoutHandler {
+	return &LogoutHandler{
+		cfg:           cfg,
+		authService:   authService,
+		logoutService: logoutService,
+	}
+}
+
+// ends the session of presented access token, relying parties that took part in it get back-channel logout
+func (h *LogoutHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := currentSession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	if err := h.logoutService.Logout(ctx, session); err != nil {
+		if errors.Is(err, service.ErrInvalidAccessToken) {
+			utils.WriteResponse(w, 401, err.Error())
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	clearSSOCookie(w, h.cfg)
+	utils.WriteResponse(w, 200, "logged out")
+}
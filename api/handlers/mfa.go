@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+)
+
+type MFAHandler struct {
+	cfg         *config.Config
+	authService service.AuthService
+	mfaService  service.MFAService
+}
+
+func NewMFAHandler(cfg *config.Config, authService service.AuthService, mfaService service.MFAService) *MFAHandler {
+	return &MFAHandler{
+		cfg:         cfg,
+		authService: authService,
+		mfaService:  mfaService,
+	}
+}
+
+func (h *MFAHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := currentSession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.mfaService.EnrollTOTP(ctx, *session.UserGuid)
+	if err != nil {
+		if errors.Is(err, service.ErrMFAAlreadyEnabled) {
+			utils.WriteResponse(w, 409, err.Error())
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteJson(w, 200, enrollment)
+}
+
+func (h *MFAHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := currentSession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	var req entities.TOTPCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	err := h.mfaService.ConfirmTOTP(ctx, *session.UserGuid, req.Code)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMFACode) || errors.Is(err, service.ErrMFANotEnrolled) {
+			utils.WriteResponse(w, 400, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrMFAAlreadyEnabled) {
+			utils.WriteResponse(w, 409, err.Error())
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteResponse(w, 200, "totp has been enabled")
+}
+
+func (h *MFAHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := currentSession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	var req entities.TOTPCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	err := h.mfaService.DisableTOTP(ctx, *session.UserGuid, req.Code)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMFACode) {
+			utils.WriteResponse(w, 400, err.Error())
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteResponse(w, 200, "totp has been disabled")
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := currentSession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(ctx, *session.UserGuid)
+	if err != nil {
+		if errors.Is(err, service.ErrMFANotEnabled) {
+			utils.WriteResponse(w, 409, err.Error())
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteJson(w, 200, codes)
+}
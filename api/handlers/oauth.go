@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/lib/oidc"
+	"testovoe_medods/service"
+)
+
+// browser session cookie holding first-party access token, read only by /authorize
+const ssoSessionCookie = "sso_session"
+
+type OAuthHandler struct {
+	cfg          *config.Config
+	oauthService service.OAuthService
+}
+
+func NewOAuthHandler(cfg *config.Config, oauthService service.OAuthService) *OAuthHandler {
+	return &OAuthHandler{
+		cfg:          cfg,
+		oauthService: oauthService,
+	}
+}
+
+func (h *OAuthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if err := r.ParseForm(); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request")
+		return
+	}
+
+	sessionToken, ok := utils.GetBearerToken(r)
+	if !ok {
+		if cookie, err := r.Cookie(ssoSessionCookie); err == nil {
+			sessionToken = cookie.Value
+		}
+	}
+
+	redirectURI, err := h.oauthService.Authorize(ctx, &entities.AuthorizeRequest{
+		ResponseType:        r.Form.Get("response_type"),
+		ClientID:            r.Form.Get("client_id"),
+		RedirectURI:         r.Form.Get("redirect_uri"),
+		Scope:               r.Form.Get("scope"),
+		State:               r.Form.Get("state"),
+		Nonce:               r.Form.Get("nonce"),
+		CodeChallenge:       r.Form.Get("code_challenge"),
+		CodeChallengeMethod: r.Form.Get("code_challenge_method"),
+		Prompt:              r.Form.Get("prompt"),
+		RequestURI:          r.Form.Get("request_uri"),
+		SessionToken:        sessionToken,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidOAuthClient) || errors.Is(err, service.ErrInvalidRequestURI) {
+			utils.WriteResponse(w, 400, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrLoginRequired) {
+			if h.cfg.OIDC.LoginURL == "" {
+				utils.WriteResponse(w, 401, err.Error())
+				return
+			}
+			// login page sends the browser back here once sso session is established
+			h.redirectReturningHere(w, r, h.cfg.OIDC.LoginURL, url.Values{})
+			return
+		}
+		var consentErr *service.ConsentRequiredError
+		if errors.As(err, &consentErr) {
+			if h.cfg.OIDC.ConsentURL == "" {
+				utils.WriteResponse(w, 403, err.Error())
+				return
+			}
+			h.redirectReturningHere(w, r, h.cfg.OIDC.ConsentURL, url.Values{
+				"client_id": {consentErr.ClientID},
+				"scope":     {consentErr.Scope},
+			})
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	http.Redirect(w, r, redirectURI, http.StatusFound)
+}
+
+// redirects browser to frontend page with return_to pointing back to this authorization request
+func (h *OAuthHandler) redirectReturningHere(w http.ResponseWriter, r *http.Request, pageURL string, params url.Values) {
+	params.Set("return_to", strings.TrimSuffix(h.cfg.OIDC.Issuer, "/")+"/authorize?"+r.Form.Encode())
+	sep := "?"
+	if strings.Contains(pageURL, "?") {
+		sep = "&"
+	}
+	http.Redirect(w, r, pageURL+sep+params.Encode(), http.StatusFound)
+}
+
+func (h *OAuthHandler) PushAuthorizationRequest(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := r.ParseForm(); err != nil {
+		utils.WriteJson(w, 400, &service.OAuthError{Code: "invalid_request"})
+		return
+	}
+
+	auth := clientAuthentication(r)
+	resp, err := h.oauthService.PushAuthorizationRequest(ctx, &auth, &entities.AuthorizeRequest{
+		ResponseType:        r.PostForm.Get("response_type"),
+		ClientID:            r.PostForm.Get("client_id"),
+		RedirectURI:         r.PostForm.Get("redirect_uri"),
+		Scope:               r.PostForm.Get("scope"),
+		State:               r.PostForm.Get("state"),
+		Nonce:               r.PostForm.Get("nonce"),
+		CodeChallenge:       r.PostForm.Get("code_challenge"),
+		CodeChallengeMethod: r.PostForm.Get("code_challenge_method"),
+		Prompt:              r.PostForm.Get("prompt"),
+		RequestURI:          r.PostForm.Get("request_uri"),
+	})
+	if err != nil {
+		writeOAuthError(w, err, auth.Method == entities.ClientAuthSecretBasic)
+		return
+	}
+
+	utils.WriteJson(w, 201, resp)
+}
+
+func (h *OAuthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if err := r.ParseForm(); err != nil {
+		utils.WriteJson(w, 400, &service.OAuthError{Code: "invalid_request"})
+		return
+	}
+
+	req := entities.TokenRequest{
+		GrantType:    r.PostForm.Get("grant_type"),
+		Code:         r.PostForm.Get("code"),
+		RedirectURI:  r.PostForm.Get("redirect_uri"),
+		CodeVerifier: r.PostForm.Get("code_verifier"),
+		RefreshToken: r.PostForm.Get("refresh_token"),
+		DeviceCode:   r.PostForm.Get("device_code"),
+		Assertion:    r.PostForm.Get("assertion"),
+		Scope:        r.PostForm.Get("scope"),
+		Client:       clientAuthentication(r),
+		IpAddr:       utils.GetUserIp(r),
+
+		SubjectToken:       r.PostForm.Get("subject_token"),
+		SubjectTokenType:   r.PostForm.Get("subject_token_type"),
+		ActorToken:         r.PostForm.Get("actor_token"),
+		ActorTokenType:     r.PostForm.Get("actor_token_type"),
+		RequestedTokenType: r.PostForm.Get("requested_token_type"),
+		Audience:           slices.Concat(r.PostForm["audience"], r.PostForm["resource"]),
+	}
+
+	tokens, err := h.oauthService.Token(ctx, &req)
+	if err != nil {
+		writeOAuthError(w, err, req.Client.Method == entities.ClientAuthSecretBasic)
+		return
+	}
+
+	utils.WriteJson(w, 200, tokens)
+}
+
+// writes RFC 6749 error response, invalid_client is 401 with challenge for basic auth clients
+func writeOAuthError(w http.ResponseWriter, err error, basicAuth bool) {
+	if writeLockoutError(w, err) {
+		return
+	}
+	var oauthErr *service.OAuthError
+	if errors.As(err, &oauthErr) {
+		if oauthErr.Code == "invalid_client" {
+			if basicAuth {
+				w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+			}
+			utils.WriteJson(w, 401, oauthErr)
+			return
+		}
+		utils.WriteJson(w, 400, oauthErr)
+		return
+	}
+	utils.WriteJson(w, 500, &service.OAuthError{Code: "server_error"})
+}
+
+// detects how client sent its credentials, mixing several methods in one request is rejected later
+// because detected method won't match the registered one
+func clientAuthentication(r *http.Request) entities.ClientAuthentication {
+	auth := entities.ClientAuthentication{
+		ClientID: r.PostForm.Get("client_id"),
+	}
+
+	// client_secret_basic credentials are form-encoded before being put into header (RFC 6749 2.3.1)
+	if id, secret, ok := r.BasicAuth(); ok {
+		auth.Method = entities.ClientAuthSecretBasic
+		auth.ClientID, _ = url.QueryUnescape(id)
+		auth.ClientSecret, _ = url.QueryUnescape(secret)
+		if formID := r.PostForm.Get("client_id"); formID != "" && formID != auth.ClientID {
+			auth.Method = ""
+		}
+		return auth
+	}
+
+	if assertion := r.PostForm.Get("client_assertion"); assertion != "" {
+		if r.PostForm.Get("client_assertion_type") == oidc.ClientAssertionTypeJWTBearer {
+			auth.Method = entities.ClientAuthPrivateKeyJWT
+		}
+		auth.ClientAssertion = assertion
+		return auth
+	}
+
+	if secret := r.PostForm.Get("client_secret"); secret != "" {
+		auth.Method = entities.ClientAuthSecretPost
+		auth.ClientSecret = secret
+		return auth
+	}
+
+	auth.Method = entities.ClientAuthNone
+	return auth
+}
+
+func (h *OAuthHandler) UserInfo(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	token, ok := utils.GetBearerToken(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer`)
+		utils.WriteResponse(w, 401, "incorrect token format")
+		return
+	}
+
+	info, err := h.oauthService.UserInfo(ctx, token)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAccessToken) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			utils.WriteResponse(w, 401, err.Error())
+			return
+		}
+		var oauthErr *service.OAuthError
+		if errors.As(err, &oauthErr) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="`+oauthErr.Code+`"`)
+			utils.WriteJson(w, 403, oauthErr)
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteJson(w, 200, info)
+}
+
+func (h *OAuthHandler) Discovery(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJson(w, 200, h.oauthService.Discovery())
+}
+
+func (h *OAuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	jwks, err := h.oauthService.JWKS()
+	if err != nil {
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+	utils.WriteJson(w, 200, jwks)
+}
+
+func (h *OAuthHandler) TenantDiscovery(w http.ResponseWriter, r *http.Request) {
+	discovery, err := h.oauthService.TenantDiscovery(r.PathValue("id"))
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+	utils.WriteJson(w, 200, discovery)
+}
+
+func (h *OAuthHandler) TenantJWKS(w http.ResponseWriter, r *http.Request) {
+	jwks, err := h.oauthService.TenantJWKS(r.PathValue("id"))
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+	utils.WriteJson(w, 200, jwks)
+}
+
+// stores access token of the first-party login in a cookie so /authorize recognises the browser
+func (h *OAuthHandler) CreateSSOSession(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	token, ok := utils.GetBearerToken(r)
+	if !ok {
+		utils.WriteResponse(w, 401, "incorrect token format")
+		return
+	}
+
+	if _, err := h.oauthService.AuthenticateSSOSession(ctx, token); err != nil {
+		if errors.Is(err, service.ErrInvalidAccessToken) {
+			utils.WriteResponse(w, 401, err.Error())
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoSessionCookie,
+		Value:    token,
+		Path:     "/authorize",
+		MaxAge:   int(h.cfg.Token.AccessTokenTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   h.cfg.OIDC.SecureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *OAuthHandler) DeleteSSOSession(w http.ResponseWriter, r *http.Request) {
+	clearSSOCookie(w, h.cfg)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func clearSSOCookie(w http.ResponseWriter, cfg *config.Config) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoSessionCookie,
+		Value:    "",
+		Path:     "/authorize",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   cfg.OIDC.SecureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
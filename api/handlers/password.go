@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+)
+
+type PasswordHandler struct {
+	cfg             *config.Config
+	passwordService service.PasswordService
+}
+
+func NewPasswordHandler(cfg *config.Config, passwordService service.PasswordService) *PasswordHandler {
+	return &PasswordHandler{
+		cfg:             cfg,
+		passwordService: passwordService,
+	}
+}
+
+func (h *PasswordHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	var req entities.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		utils.WriteResponse(w, 400, "email is required")
+		return
+	}
+
+	req.IpAddr = utils.GetUserIp(r)
+	if err := h.passwordService.ForgotPassword(ctx, &req); err != nil {
+		if writeLockoutError(w, err) {
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteResponse(w, 202, "if the email is registered, a reset link has been sent")
+}
+
+func (h *PasswordHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	var req entities.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	err := h.passwordService.ResetPassword(ctx, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidResetToken) || errors.Is(err, service.ErrWeakPassword) {
+			utils.WriteResponse(w, 400, err.Error())
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteResponse(w, 200, "password has been reset")
+}
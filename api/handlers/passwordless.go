@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+)
+
+const passwordlessStateCookie = "passwordless_state"
+
+type PasswordlessHandler struct {
+	cfg                 *config.Config
+	passwordlessService service.PasswordlessService
+}
+
+func NewPasswordlessHandler(cfg *config.Config, passwordlessService service.PasswordlessService) *PasswordlessHandler {
+	return &PasswordlessHandler{
+		cfg:                 cfg,
+		passwordlessService: passwordlessService,
+	}
+}
+
+func (h *PasswordlessHandler) Start(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	var req entities.PasswordlessStartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		utils.WriteResponse(w, 400, "email is required")
+		return
+	}
+
+	req.IpAddr = utils.GetUserIp(r)
+	state, err := h.passwordlessService.Start(ctx, &req)
+	if err != nil {
+		if writeLockoutError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidPasswordlessMethod) {
+			utils.WriteResponse(w, 400, err.Error())
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	// SameSite=Lax keeps the cookie on top-level navigation from the email client
+	http.SetCookie(w, &http.Cookie{
+		Name:     passwordlessStateCookie,
+		Value:    state,
+		Path:     "/api/passwordless",
+		MaxAge:   int(h.cfg.Passwordless.TTL.Seconds()),
+		HttpOnly: true,
+		Secure:   h.cfg.Passwordless.SecureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+	utils.WriteResponse(w, 202, "if the email is registered, a sign-in link or code has been sent")
+}
+
+func (h *PasswordlessHandler) VerifyLink(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	tokenPair, err := h.passwordlessService.VerifyLink(ctx, stateFromCookie(r), r.URL.Query().Get("token"), utils.GetUserIp(r))
+	h.writeResult(w, tokenPair, err)
+}
+
+func (h *PasswordlessHandler) VerifyCode(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	var req entities.PasswordlessCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	tokenPair, err := h.passwordlessService.VerifyCode(ctx, stateFromCookie(r), req.Code, utils.GetUserIp(r))
+	h.writeResult(w, tokenPair, err)
+}
+
+func (h *PasswordlessHandler) writeResult(w http.ResponseWriter, tokenPair *entities.TokenPair, err error) {
+	if err != nil {
+		if writeLockoutError(w, err) {
+			return
+		}
+		if writeTenantAccessError(w, err) {
+			return
+		}
+		if writeUserDisabledError(w, err) {
+			return
+		}
+		var mfaErr *service.MFARequiredError
+		if errors.As(err, &mfaErr) {
+			clearStateCookie(w)
+			utils.WriteJson(w, 401, mfaErr.Challenge)
+			return
+		}
+		if errors.Is(err, service.ErrInvalidPasswordlessLogin) || errors.Is(err, service.ErrNoUserFound) {
+			utils.WriteResponse(w, 401, service.ErrInvalidPasswordlessLogin.Error())
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	clearStateCookie(w)
+	utils.WriteJson(w, 200, tokenPair)
+}
+
+func stateFromCookie(r *http.Request) string {
+	cookie, err := r.Cookie(passwordlessStateCookie)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func clearStateCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     passwordlessStateCookie,
+		Value:    "",
+		Path:     "/api/passwordless",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+
+	"github.com/google/uuid"
+)
+
+type RoleHandler struct {
+	cfg         *config.Config
+	roleService service.RoleService
+}
+
+func NewRoleHandler(cfg *config.Config, roleService service.RoleService) *RoleHandler {
+	return &RoleHandler{
+		cfg:         cfg,
+		roleService: roleService,
+	}
+}
+
+func (h *RoleHandler) CreatePermission(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	var req entities.PermissionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	permission, err := h.roleService.CreatePermission(ctx, &req)
+	if err != nil {
+		writeRoleError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 201, permission)
+}
+
+func (h *RoleHandler) ListPermissions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	permissions, err := h.roleService.ListPermissions(ctx)
+	if err != nil {
+		writeRoleError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, permissions)
+}
+
+func (h *RoleHandler) DeletePermission(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	if err := h.roleService.DeletePermission(ctx, r.PathValue("name")); err != nil {
+		writeRoleError(w, err)
+		return
+	}
+
+	utils.WriteResponse(w, 200, "permission deleted")
+}
+
+func (h *RoleHandler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	var req entities.RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	role, err := h.roleService.CreateRole(ctx, &req)
+	if err != nil {
+		writeRoleError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 201, role)
+}
+
+func (h *RoleHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	roles, err := h.roleService.ListRoles(ctx)
+	if err != nil {
+		writeRoleError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, roles)
+}
+
+func (h *RoleHandler) GetRole(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	role, err := h.roleService.GetRole(ctx, r.PathValue("name"))
+	if err != nil {
+		writeRoleError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, role)
+}
+
+func (h *RoleHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	var req entities.RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	role, err := h.roleService.UpdateRole(ctx, r.PathValue("name"), &req)
+	if err != nil {
+		writeRoleError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, role)
+}
+
+func (h *RoleHandler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	if err := h.roleService.DeleteRole(ctx, r.PathValue("name")); err != nil {
+		writeRoleError(w, err)
+		return
+	}
+
+	utils.WriteResponse(w, 200, "role deleted")
+}
+
+func (h *RoleHandler) ListUserRoles(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	userGuid, err := uuid.Parse(r.PathValue("guid"))
+	if err != nil {
+		utils.WriteResponse(w, 400, "incorrect user guid")
+		return
+	}
+
+	assignments, err := h.roleService.ListUserRoles(ctx, userGuid)
+	if err != nil {
+		writeRoleError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, assignments)
+}
+
+func (h *RoleHandler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	userGuid, err := uuid.Parse(r.PathValue("guid"))
+	if err != nil {
+		utils.WriteResponse(w, 400, "incorrect user guid")
+		return
+	}
+
+	if err := h.roleService.AssignRole(ctx, userGuid, r.PathValue("role")); err != nil {
+		writeRoleError(w, err)
+		return
+	}
+
+	utils.WriteResponse(w, 200, "role assigned")
+}
+
+func (h *RoleHandler) UnassignRole(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	userGuid, err := uuid.Parse(r.PathValue("guid"))
+	if err != nil {
+		utils.WriteResponse(w, 400, "incorrect user guid")
+		return
+	}
+
+	if err := h.roleService.UnassignRole(ctx, userGuid, r.PathValue("role")); err != nil {
+		writeRoleError(w, err)
+		return
+	}
+
+	utils.WriteResponse(w, 200, "role unassigned")
+}
+
+func writeRoleError(w http.ResponseWriter, err error) {
+	var reqErr *service.InvalidRoleRequestError
+	switch {
+	case errors.As(err, &reqErr):
+		utils.WriteResponse(w, 400, err.Error())
+	case errors.Is(err, service.ErrPermissionNotFound), errors.Is(err, service.ErrRoleNotFound), errors.Is(err, service.ErrRoleNotAssigned), errors.Is(err, service.ErrNoUserFound):
+		utils.WriteResponse(w, 404, err.Error())
+	case errors.Is(err, service.ErrRoleAlreadyExists), errors.Is(err, service.ErrPermissionAlreadyExists):
+		utils.WriteResponse(w, 409, err.Error())
+	default:
+		utils.WriteResponse(w, 500, "something went wrong")
+	}
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+)
+
+type SCIMHandler struct {
+	cfg         *config.Config
+	scimService service.SCIMService
+}
+
+func NewSCIMHandler(cfg *config.Config, scimService service.SCIMService) *SCIMHandler {
+	return &SCIMHandler{
+		cfg:         cfg,
+		scimService: scimService,
+	}
+}
+
+func (h *SCIMHandler) ServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	if !h.requireSCIMToken(w, r) {
+		return
+	}
+	writeSCIMJson(w, 200, h.scimService.ServiceProviderConfig())
+}
+
+func (h *SCIMHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !h.requireSCIMToken(w, r) {
+		return
+	}
+	startIndex, count, ok := h.scimPage(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.scimService.ListUsers(ctx, r.URL.Query().Get("filter"), startIndex, count)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	writeSCIMJson(w, 200, resp)
+}
+
+func (h *SCIMHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !h.requireSCIMToken(w, r) {
+		return
+	}
+
+	user, err := h.scimService.GetUser(ctx, r.PathValue("id"))
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	writeSCIMJson(w, 200, user)
+}
+
+func (h *SCIMHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !h.requireSCIMToken(w, r) {
+		return
+	}
+	var req entities.SCIMUser
+	if !decodeSCIMRequest(w, r, &req) {
+		return
+	}
+
+	user, err := h.scimService.CreateUser(ctx, &req)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	w.Header().Set("Location", user.Meta.Location)
+	writeSCIMJson(w, 201, user)
+}
+
+func (h *SCIMHandler) ReplaceUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !h.requireSCIMToken(w, r) {
+		return
+	}
+	var req entities.SCIMUser
+	if !decodeSCIMRequest(w, r, &req) {
+		return
+	}
+
+	user, err := h.scimService.ReplaceUser(ctx, r.PathValue("id"), &req)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	writeSCIMJson(w, 200, user)
+}
+
+func (h *SCIMHandler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !h.requireSCIMToken(w, r) {
+		return
+	}
+	var req entities.SCIMPatchRequest
+	if !decodeSCIMRequest(w, r, &req) {
+		return
+	}
+
+	user, err := h.scimService.PatchUser(ctx, r.PathValue("id"), &req)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	writeSCIMJson(w, 200, user)
+}
+
+func (h *SCIMHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !h.requireSCIMToken(w, r) {
+		return
+	}
+
+	if err := h.scimService.DeleteUser(ctx, r.PathValue("id")); err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *SCIMHandler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !h.requireSCIMToken(w, r) {
+		return
+	}
+	startIndex, count, ok := h.scimPage(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.scimService.ListGroups(ctx, r.URL.Query().Get("filter"), startIndex, count)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	writeSCIMJson(w, 200, resp)
+}
+
+func (h *SCIMHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !h.requireSCIMToken(w, r) {
+		return
+	}
+
+	group, err := h.scimService.GetGroup(ctx, r.PathValue("id"))
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	writeSCIMJson(w, 200, group)
+}
+
+func (h *SCIMHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !h.requireSCIMToken(w, r) {
+		return
+	}
+	var req entities.SCIMGroup
+	if !decodeSCIMRequest(w, r, &req) {
+		return
+	}
+
+	group, err := h.scimService.CreateGroup(ctx, &req)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	w.Header().Set("Location", group.Meta.Location)
+	writeSCIMJson(w, 201, group)
+}
+
+func (h *SCIMHandler) ReplaceGroup(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !h.requireSCIMToken(w, r) {
+		return
+	}
+	var req entities.SCIMGroup
+	if !decodeSCIMRequest(w, r, &req) {
+		return
+	}
+
+	group, err := h.scimService.ReplaceGroup(ctx, r.PathValue("id"), &req)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	writeSCIMJson(w, 200, group)
+}
+
+func (h *SCIMHandler) PatchGroup(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !h.requireSCIMToken(w, r) {
+		return
+	}
+	var req entities.SCIMPatchRequest
+	if !decodeSCIMRequest(w, r, &req) {
+		return
+	}
+
+	group, err := h.scimService.PatchGroup(ctx, r.PathValue("id"), &req)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	writeSCIMJson(w, 200, group)
+}
+
+func (h *SCIMHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !h.requireSCIMToken(w, r) {
+		return
+	}
+
+	if err := h.scimService.DeleteGroup(ctx, r.PathValue("id")); err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checks provisioning bearer token from config, failures are reported as scim errors
+func (h *SCIMHandler) requireSCIMToken(w http.ResponseWriter, r *http.Request) bool {
+	if h.cfg.SCIM.Token == "" {
+		writeSCIMError(w, &service.SCIMError{Status: 403, Detail: "provisioning is disabled"})
+		return false
+	}
+
+	token, ok := utils.GetBearerToken(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+		writeSCIMError(w, &service.SCIMError{Status: 401, Detail: "incorrect token format"})
+		return false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.cfg.SCIM.Token)) != 1 {
+		writeSCIMError(w, &service.SCIMError{Status: 403, Detail: "provisioning token required"})
+		return false
+	}
+	return true
+}
+
+// startIndex and count query parameters, omitted count asks for the largest page
+func (h *SCIMHandler) scimPage(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	query := r.URL.Query()
+	startIndex, count := 1, h.cfg.SCIM.MaxResults
+	var err error
+	if v := query.Get("startIndex"); v != "" {
+		if startIndex, err = strconv.Atoi(v); err != nil {
+			writeSCIMError(w, &service.SCIMError{Status: 400, Type: "invalidValue", Detail: "incorrect startIndex"})
+			return 0, 0, false
+		}
+	}
+	if v := query.Get("count"); v != "" {
+		if count, err = strconv.Atoi(v); err != nil {
+			writeSCIMError(w, &service.SCIMError{Status: 400, Type: "invalidValue", Detail: "incorrect count"})
+			return 0, 0, false
+		}
+	}
+	return startIndex, count, true
+}
+
+func decodeSCIMRequest(w http.ResponseWriter, r *http.Request, req any) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		writeSCIMError(w, &service.SCIMError{Status: 400, Type: "invalidSyntax", Detail: "incorrect request body"})
+		return false
+	}
+	return true
+}
+
+func writeSCIMJson(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// writes RFC 7644 error response, status is a string there
+func writeSCIMError(w http.ResponseWriter, err error) {
+	var scimErr *service.SCIMError
+	if !errors.As(err, &scimErr) {
+		scimErr = &service.SCIMError{Status: 500, Detail: "something went wrong"}
+	}
+	writeSCIMJson(w, scimErr.Status, &entities.SCIMErrorResponse{
+		Schemas:  []string{entities.SCIMSchemaError},
+		Status:   strconv.Itoa(scimErr.Status),
+		ScimType: scimErr.Type,
+		Detail:   scimErr.Detail,
+	})
+}
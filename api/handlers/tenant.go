@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+
+	"github.com/google/uuid"
+)
+
+type TenantHandler struct {
+	cfg           *config.Config
+	tenantService service.TenantService
+}
+
+func NewTenantHandler(cfg *config.Config, tenantService service.TenantService) *TenantHandler {
+	return &TenantHandler{
+		cfg:           cfg,
+		tenantService: tenantService,
+	}
+}
+
+func (h *TenantHandler) CreateTenant(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	var req entities.TenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	tenant, err := h.tenantService.CreateTenant(ctx, &req)
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 201, tenant)
+}
+
+func (h *TenantHandler) ListTenants(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	tenants, err := h.tenantService.ListTenants(ctx)
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, tenants)
+}
+
+func (h *TenantHandler) GetTenant(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	tenant, err := h.tenantService.GetTenant(ctx, r.PathValue("id"))
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, tenant)
+}
+
+func (h *TenantHandler) UpdateTenant(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	var req entities.TenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	tenant, err := h.tenantService.UpdateTenant(ctx, r.PathValue("id"), &req)
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, tenant)
+}
+
+func (h *TenantHandler) DeleteTenant(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	if err := h.tenantService.DeleteTenant(ctx, r.PathValue("id")); err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	utils.WriteResponse(w, 200, "tenant deleted")
+}
+
+func (h *TenantHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	members, err := h.tenantService.ListMembers(ctx, r.PathValue("id"))
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, members)
+}
+
+func (h *TenantHandler) SaveMember(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	userGuid, err := uuid.Parse(r.PathValue("guid"))
+	if err != nil {
+		utils.WriteResponse(w, 400, "incorrect user guid")
+		return
+	}
+
+	// body is optional, members are added with default role without it
+	var req entities.TenantMemberRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteResponse(w, 400, "incorrect request body")
+			return
+		}
+	}
+	req.UserGuid = userGuid
+
+	member, err := h.tenantService.SaveMember(ctx, r.PathValue("id"), &req)
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, member)
+}
+
+func (h *TenantHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	userGuid, err := uuid.Parse(r.PathValue("guid"))
+	if err != nil {
+		utils.WriteResponse(w, 400, "incorrect user guid")
+		return
+	}
+
+	if err := h.tenantService.RemoveMember(ctx, r.PathValue("id"), userGuid); err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	utils.WriteResponse(w, 200, "member removed")
+}
+
+func (h *TenantHandler) CreateSigningKey(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	key, err := h.tenantService.CreateSigningKey(ctx, r.PathValue("id"))
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 201, key)
+}
+
+func (h *TenantHandler) ListSigningKeys(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	keys, err := h.tenantService.ListSigningKeys(ctx, r.PathValue("id"))
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, keys)
+}
+
+func (h *TenantHandler) DeleteSigningKey(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	if !requireAdmin(w, r, h.cfg) {
+		return
+	}
+
+	if err := h.tenantService.DeleteSigningKey(ctx, r.PathValue("id"), r.PathValue("kid")); err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	utils.WriteResponse(w, 200, "signing key deleted")
+}
+
+func writeTenantError(w http.ResponseWriter, err error) {
+	var reqErr *service.InvalidTenantRequestError
+	switch {
+	case errors.As(err, &reqErr):
+		utils.WriteResponse(w, 400, err.Error())
+	case errors.Is(err, service.ErrTenantNotFound), errors.Is(err, service.ErrTenantMemberNotFound), errors.Is(err, service.ErrNoUserFound),
+		errors.Is(err, service.ErrSigningKeyNotFound):
+		utils.WriteResponse(w, 404, err.Error())
+	case errors.Is(err, service.ErrTenantAlreadyExists), errors.Is(err, service.ErrTenantIssuerTaken):
+		utils.WriteResponse(w, 409, err.Error())
+	default:
+		utils.WriteResponse(w, 500, "something went wrong")
+	}
+}
+
+// login was rejected by policy of the tenant session would be created in
+func writeTenantAccessError(w http.ResponseWriter, err error) bool {
+	var accessErr *service.TenantAccessError
+	if !errors.As(err, &accessErr) {
+		return false
+	}
+	utils.WriteResponse(w, 403, accessErr.Error())
+	return true
+}
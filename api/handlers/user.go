@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+
+	"github.com/google/uuid"
+)
+
+type UserHandler struct {
+	cfg                  *config.Config
+	userService          service.UserService
+	introspectionService service.IntrospectionService
+	roleService          service.RoleService
+}
+
+func NewUserHandler(cfg *config.Config, userService service.UserService, introspectionService service.IntrospectionService, roleService service.RoleService) *UserHandler {
+	return &UserHandler{
+		cfg:                  cfg,
+		userService:          userService,
+		introspectionService: introspectionService,
+		roleService:          roleService,
+	}
+}
+
+func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	tenantID, ok := requireUserAdmin(ctx, w, r, h.cfg, h.introspectionService, h.roleService)
+	if !ok {
+		return
+	}
+
+	var req entities.UserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	user, err := h.userService.CreateUser(ctx, tenantID, &req)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 201, user)
+}
+
+func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	tenantID, ok := requireUserAdmin(ctx, w, r, h.cfg, h.introspectionService, h.roleService)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := entities.UserFilter{TenantID: tenantID, Email: query.Get("email")}
+	var err error
+	if v := query.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			utils.WriteResponse(w, 400, "incorrect limit")
+			return
+		}
+	}
+	if v := query.Get("offset"); v != "" {
+		if filter.Offset, err = strconv.Atoi(v); err != nil {
+			utils.WriteResponse(w, 400, "incorrect offset")
+			return
+		}
+	}
+
+	page, err := h.userService.ListUsers(ctx, &filter)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, page)
+}
+
+func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	tenantID, ok := requireUserAdmin(ctx, w, r, h.cfg, h.introspectionService, h.roleService)
+	if !ok {
+		return
+	}
+
+	guid, ok := pathUserGuid(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.userService.GetUser(ctx, tenantID, guid)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, user)
+}
+
+func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	tenantID, ok := requireUserAdmin(ctx, w, r, h.cfg, h.introspectionService, h.roleService)
+	if !ok {
+		return
+	}
+
+	guid, ok := pathUserGuid(w, r)
+	if !ok {
+		return
+	}
+
+	var req entities.UserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	user, err := h.userService.UpdateUser(ctx, tenantID, guid, &req)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, user)
+}
+
+func (h *UserHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	tenantID, ok := requireUserAdmin(ctx, w, r, h.cfg, h.introspectionService, h.roleService)
+	if !ok {
+		return
+	}
+
+	guid, ok := pathUserGuid(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.userService.DisableUser(ctx, tenantID, guid)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, user)
+}
+
+func (h *UserHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	tenantID, ok := requireUserAdmin(ctx, w, r, h.cfg, h.introspectionService, h.roleService)
+	if !ok {
+		return
+	}
+
+	guid, ok := pathUserGuid(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.userService.EnableUser(ctx, tenantID, guid)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	utils.WriteJson(w, 200, user)
+}
+
+func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	tenantID, ok := requireUserAdmin(ctx, w, r, h.cfg, h.introspectionService, h.roleService)
+	if !ok {
+		return
+	}
+
+	guid, ok := pathUserGuid(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.userService.DeleteUser(ctx, tenantID, guid); err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	utils.WriteResponse(w, 200, "user deleted")
+}
+
+func pathUserGuid(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	guid, err := uuid.Parse(r.PathValue("guid"))
+	if err != nil {
+		utils.WriteResponse(w, 404, service.ErrNoUserFound.Error())
+		return uuid.Nil, false
+	}
+	return guid, true
+}
+
+func writeUserError(w http.ResponseWriter, err error) {
+	var reqErr *service.InvalidUserRequestError
+	switch {
+	case errors.As(err, &reqErr), errors.Is(err, service.ErrInvalidPage):
+		utils.WriteResponse(w, 400, err.Error())
+	case errors.Is(err, service.ErrNoUserFound):
+		utils.WriteResponse(w, 404, err.Error())
+	case errors.Is(err, service.ErrUserAlreadyExists):
+		utils.WriteResponse(w, 409, err.Error())
+	default:
+		utils.WriteResponse(w, 500, "something went wrong")
+	}
+}
+
+// login and refresh of disabled users are refused with 403
+func writeUserDisabledError(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, service.ErrUserDisabled) {
+		return false
+	}
+	utils.WriteResponse(w, 403, err.Error())
+	return true
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+
+	"github.com/google/uuid"
+)
+
+type WebAuthnHandler struct {
+	cfg             *config.Config
+	authService     service.AuthService
+	webAuthnService service.WebAuthnService
+}
+
+func NewWebAuthnHandler(cfg *config.Config, authService service.AuthService, webAuthnService service.WebAuthnService) *WebAuthnHandler {
+	return &WebAuthnHandler{
+		cfg:             cfg,
+		authService:     authService,
+		webAuthnService: webAuthnService,
+	}
+}
+
+func (h *WebAuthnHandler) BeginRegistration(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := currentSession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	options, err := h.webAuthnService.BeginRegistration(ctx, *session.UserGuid)
+	if err != nil {
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteJson(w, 200, options)
+}
+
+func (h *WebAuthnHandler) FinishRegistration(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := currentSession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	var req entities.WebAuthnRegistrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	cred, err := h.webAuthnService.FinishRegistration(ctx, *session.UserGuid, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidWebAuthnChallenge) || errors.Is(err, service.ErrWebAuthnVerification) {
+			utils.WriteResponse(w, 400, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrCredentialAlreadyRegistered) {
+			utils.WriteResponse(w, 409, err.Error())
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteJson(w, 201, cred)
+}
+
+func (h *WebAuthnHandler) BeginLogin(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	var req entities.WebAuthnLoginBeginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+
+	options, err := h.webAuthnService.BeginLogin(ctx, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMFAChallenge) {
+			utils.WriteResponse(w, 401, err.Error())
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteJson(w, 200, options)
+}
+
+func (h *WebAuthnHandler) FinishLogin(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	var req entities.WebAuthnLoginFinishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteResponse(w, 400, "incorrect request body")
+		return
+	}
+	req.IpAddr = utils.GetUserIp(r)
+
+	tokenPair, err := h.webAuthnService.FinishLogin(ctx, &req)
+	if err != nil {
+		if writeLockoutError(w, err) {
+			return
+		}
+		if writeTenantAccessError(w, err) {
+			return
+		}
+		if writeUserDisabledError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidWebAuthnChallenge) || errors.Is(err, service.ErrWebAuthnVerification) ||
+			errors.Is(err, service.ErrInvalidMFAChallenge) || errors.Is(err, service.ErrNoUserFound) {
+			utils.WriteResponse(w, 401, err.Error())
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteJson(w, 200, tokenPair)
+}
+
+func (h *WebAuthnHandler) ListCredentials(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := currentSession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	creds, err := h.webAuthnService.ListCredentials(ctx, *session.UserGuid)
+	if err != nil {
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteJson(w, 200, creds)
+}
+
+func (h *WebAuthnHandler) DeleteCredential(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.cfg.Database.Timeout)
+	defer cancel()
+
+	session, ok := currentSession(ctx, w, r, h.authService)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		utils.WriteResponse(w, 400, "incorrect credential id")
+		return
+	}
+
+	err = h.webAuthnService.DeleteCredential(ctx, *session.UserGuid, id)
+	if err != nil {
+		if errors.Is(err, service.ErrCredentialNotFound) {
+			utils.WriteResponse(w, 404, err.Error())
+			return
+		}
+		utils.WriteResponse(w, 500, "something went wrong")
+		return
+	}
+
+	utils.WriteResponse(w, 200, "credential has been deleted")
+}
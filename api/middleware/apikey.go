@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	utils "testovoe_medods/api/handlers/authutils"
+	"testovoe_medods/entities"
+	"testovoe_medods/service"
+	"time"
+)
+
+type apiKeyCtxKey struct{}
+
+// accepts requests carrying valid api key in X-API-Key header or as bearer token,
+// the key must have all listed scopes
+func RequireAPIKey(keys service.APIKeyService, timeout time.Duration, scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw := r.Header.Get("X-API-Key")
+			if raw == "" {
+				raw, _ = utils.GetBearerToken(r)
+			}
+			if raw == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer`)
+				utils.WriteResponse(w, 401, "api key is required")
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			key, err := keys.Verify(ctx, raw)
+			cancel()
+			if err != nil {
+				if errors.Is(err, service.ErrInvalidAPIKey) {
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					utils.WriteResponse(w, 401, err.Error())
+					return
+				}
+				utils.WriteResponse(w, 500, "something went wrong")
+				return
+			}
+
+			for _, scope := range scopes {
+				if !slices.Contains(key.Scopes, scope) {
+					w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+					utils.WriteResponse(w, 403, "api key lacks scope "+scope)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyCtxKey{}, key)))
+		})
+	}
+}
+
+// returns api key verified by RequireAPIKey
+func APIKeyFromContext(ctx context.Context) (*entities.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyCtxKey{}).(*entities.APIKey)
+	return key, ok
+}
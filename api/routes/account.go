@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterAccountRoutes(mux *http.ServeMux, h *handlers.AccountHandler) {
+	mux.HandleFunc("GET /api/account", h.GetAccount)
+	mux.HandleFunc("GET /api/account/sessions", h.ListSessions)
+}
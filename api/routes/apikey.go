@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+// verify authenticates requests with api keys, see middleware.RequireAPIKey
+func RegisterAPIKeyRoutes(mux *http.ServeMux, h *handlers.APIKeyHandler, verify func(http.Handler) http.Handler) {
+	mux.HandleFunc("POST /api/keys", h.Create)
+	mux.HandleFunc("GET /api/keys", h.List)
+	mux.Handle("GET /api/keys/me", verify(http.HandlerFunc(h.Me)))
+	mux.HandleFunc("DELETE /api/keys/{id}", h.Revoke)
+}
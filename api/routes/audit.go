@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterAuditRoutes(mux *http.ServeMux, h *handlers.AuditHandler) {
+	mux.HandleFunc("GET /api/admin/audit-events", h.ListEvents)
+}
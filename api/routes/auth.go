@@ -9,4 +9,5 @@ import (
 func RegisterAuthRoutes(mux *http.ServeMux, h *handlers.AuthHandler) {
 	mux.HandleFunc("/api/authenticate/{guid}", h.Authenticate)
 	mux.HandleFunc("/api/refresh", h.Refresh)
+	mux.HandleFunc("POST /api/mfa/verify", h.VerifyMFA)
 }
\ No newline at end of file
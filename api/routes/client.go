@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterOAuthClientRoutes(mux *http.ServeMux, h *handlers.OAuthClientHandler) {
+	mux.HandleFunc("POST /api/admin/clients", h.Create)
+	mux.HandleFunc("GET /api/admin/clients", h.List)
+	mux.HandleFunc("GET /api/admin/clients/{id}", h.Get)
+	mux.HandleFunc("PUT /api/admin/clients/{id}", h.Update)
+	mux.HandleFunc("POST /api/admin/clients/{id}/secret", h.RotateSecret)
+	mux.HandleFunc("DELETE /api/admin/clients/{id}", h.Delete)
+}
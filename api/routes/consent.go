@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterConsentRoutes(mux *http.ServeMux, h *handlers.ConsentHandler) {
+	mux.HandleFunc("GET /api/consents/request", h.Describe)
+	mux.HandleFunc("POST /api/consents", h.Grant)
+	mux.HandleFunc("GET /api/consents", h.List)
+	mux.HandleFunc("DELETE /api/consents/{client_id}", h.Revoke)
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterDeviceRoutes(mux *http.ServeMux, h *handlers.DeviceHandler) {
+	mux.HandleFunc("POST /device_authorization", h.Authorize)
+	mux.HandleFunc("GET /api/device", h.Lookup)
+	mux.HandleFunc("POST /api/device/verify", h.Verify)
+}
@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterFederationRoutes(mux *http.ServeMux, h *handlers.FederationHandler) {
+	mux.HandleFunc("GET /api/federation/{provider}/login", h.Login)
+	mux.HandleFunc("GET /api/federation/{provider}/callback", h.Callback)
+}
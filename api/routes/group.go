@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterGroupRoutes(mux *http.ServeMux, h *handlers.GroupHandler) {
+	mux.HandleFunc("POST /api/admin/groups", h.CreateGroup)
+	mux.HandleFunc("GET /api/admin/groups", h.ListGroups)
+	mux.HandleFunc("GET /api/admin/groups/{id}", h.GetGroup)
+	mux.HandleFunc("PUT /api/admin/groups/{id}", h.UpdateGroup)
+	mux.HandleFunc("DELETE /api/admin/groups/{id}", h.DeleteGroup)
+	mux.HandleFunc("GET /api/admin/groups/{id}/members", h.ListMembers)
+	mux.HandleFunc("PUT /api/admin/groups/{id}/members/{guid}", h.AddMember)
+	mux.HandleFunc("DELETE /api/admin/groups/{id}/members/{guid}", h.RemoveMember)
+	mux.HandleFunc("GET /api/admin/users/{guid}/groups", h.ListUserGroups)
+}
@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterImpersonationRoutes(mux *http.ServeMux, h *handlers.ImpersonationHandler) {
+	mux.HandleFunc("POST /api/admin/users/{guid}/impersonate", h.Impersonate)
+}
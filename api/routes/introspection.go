@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterIntrospectionRoutes(mux *http.ServeMux, h *handlers.IntrospectionHandler) {
+	mux.HandleFunc("POST /introspect", h.Introspect)
+}
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterInvitationRoutes(mux *http.ServeMux, h *handlers.InvitationHandler) {
+	mux.HandleFunc("POST /api/tenants/{id}/invitations", h.Create)
+	mux.HandleFunc("GET /api/tenants/{id}/invitations", h.List)
+	mux.HandleFunc("DELETE /api/tenants/{id}/invitations/{invitation}", h.Revoke)
+	mux.HandleFunc("POST /api/invitations/accept", h.Accept)
+}
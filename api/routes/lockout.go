@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterLockoutRoutes(mux *http.ServeMux, h *handlers.LockoutHandler) {
+	mux.HandleFunc("GET /api/admin/lockouts/users/{guid}", h.UserStatus)
+	mux.HandleFunc("DELETE /api/admin/lockouts/users/{guid}", h.UnlockUser)
+	mux.HandleFunc("GET /api/admin/lockouts/ips/{ip}", h.IPStatus)
+	mux.HandleFunc("DELETE /api/admin/lockouts/ips/{ip}", h.UnlockIP)
+}
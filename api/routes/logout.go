@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterLogoutRoutes(mux *http.ServeMux, h *handlers.LogoutHandler) {
+	mux.HandleFunc("POST /api/logout", h.Logout)
+}
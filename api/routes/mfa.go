@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterMFARoutes(mux *http.ServeMux, h *handlers.MFAHandler) {
+	mux.HandleFunc("POST /api/mfa/totp/enroll", h.EnrollTOTP)
+	mux.HandleFunc("POST /api/mfa/totp/confirm", h.ConfirmTOTP)
+	mux.HandleFunc("POST /api/mfa/totp/disable", h.DisableTOTP)
+	mux.HandleFunc("POST /api/mfa/recovery-codes", h.RegenerateRecoveryCodes)
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterOAuthRoutes(mux *http.ServeMux, h *handlers.OAuthHandler) {
+	mux.HandleFunc("GET /.well-known/openid-configuration", h.Discovery)
+	mux.HandleFunc("GET /.well-known/jwks.json", h.JWKS)
+	mux.HandleFunc("GET /tenants/{id}/.well-known/openid-configuration", h.TenantDiscovery)
+	mux.HandleFunc("GET /tenants/{id}/.well-known/jwks.json", h.TenantJWKS)
+	mux.HandleFunc("GET /authorize", h.Authorize)
+	mux.HandleFunc("POST /authorize", h.Authorize)
+	mux.HandleFunc("POST /par", h.PushAuthorizationRequest)
+	mux.HandleFunc("POST /token", h.Token)
+	mux.HandleFunc("GET /userinfo", h.UserInfo)
+	mux.HandleFunc("POST /userinfo", h.UserInfo)
+	mux.HandleFunc("POST /api/sso/session", h.CreateSSOSession)
+	mux.HandleFunc("DELETE /api/sso/session", h.DeleteSSOSession)
+}
@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterPasswordRoutes(mux *http.ServeMux, h *handlers.PasswordHandler) {
+	mux.HandleFunc("POST /api/password/forgot", h.ForgotPassword)
+	mux.HandleFunc("POST /api/password/reset", h.ResetPassword)
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterPasswordlessRoutes(mux *http.ServeMux, h *handlers.PasswordlessHandler) {
+	mux.HandleFunc("POST /api/passwordless/start", h.Start)
+	mux.HandleFunc("GET /api/passwordless/link", h.VerifyLink)
+	mux.HandleFunc("POST /api/passwordless/code", h.VerifyCode)
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterRoleRoutes(mux *http.ServeMux, h *handlers.RoleHandler) {
+	mux.HandleFunc("POST /api/admin/permissions", h.CreatePermission)
+	mux.HandleFunc("GET /api/admin/permissions", h.ListPermissions)
+	mux.HandleFunc("DELETE /api/admin/permissions/{name}", h.DeletePermission)
+	mux.HandleFunc("POST /api/admin/roles", h.CreateRole)
+	mux.HandleFunc("GET /api/admin/roles", h.ListRoles)
+	mux.HandleFunc("GET /api/admin/roles/{name}", h.GetRole)
+	mux.HandleFunc("PUT /api/admin/roles/{name}", h.UpdateRole)
+	mux.HandleFunc("DELETE /api/admin/roles/{name}", h.DeleteRole)
+	mux.HandleFunc("GET /api/admin/users/{guid}/roles", h.ListUserRoles)
+	mux.HandleFunc("PUT /api/admin/users/{guid}/roles/{role}", h.AssignRole)
+	mux.HandleFunc("DELETE /api/admin/users/{guid}/roles/{role}", h.UnassignRole)
+}
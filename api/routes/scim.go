@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterSCIMRoutes(mux *http.ServeMux, h *handlers.SCIMHandler) {
+	mux.HandleFunc("GET /scim/v2/ServiceProviderConfig", h.ServiceProviderConfig)
+	mux.HandleFunc("GET /scim/v2/Users", h.ListUsers)
+	mux.HandleFunc("POST /scim/v2/Users", h.CreateUser)
+	mux.HandleFunc("GET /scim/v2/Users/{id}", h.GetUser)
+	mux.HandleFunc("PUT /scim/v2/Users/{id}", h.ReplaceUser)
+	mux.HandleFunc("PATCH /scim/v2/Users/{id}", h.PatchUser)
+	mux.HandleFunc("DELETE /scim/v2/Users/{id}", h.DeleteUser)
+	mux.HandleFunc("GET /scim/v2/Groups", h.ListGroups)
+	mux.HandleFunc("POST /scim/v2/Groups", h.CreateGroup)
+	mux.HandleFunc("GET /scim/v2/Groups/{id}", h.GetGroup)
+	mux.HandleFunc("PUT /scim/v2/Groups/{id}", h.ReplaceGroup)
+	mux.HandleFunc("PATCH /scim/v2/Groups/{id}", h.PatchGroup)
+	mux.HandleFunc("DELETE /scim/v2/Groups/{id}", h.DeleteGroup)
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterTenantRoutes(mux *http.ServeMux, h *handlers.TenantHandler) {
+	mux.HandleFunc("POST /api/admin/tenants", h.CreateTenant)
+	mux.HandleFunc("GET /api/admin/tenants", h.ListTenants)
+	mux.HandleFunc("GET /api/admin/tenants/{id}", h.GetTenant)
+	mux.HandleFunc("PUT /api/admin/tenants/{id}", h.UpdateTenant)
+	mux.HandleFunc("DELETE /api/admin/tenants/{id}", h.DeleteTenant)
+	mux.HandleFunc("GET /api/admin/tenants/{id}/members", h.ListMembers)
+	mux.HandleFunc("PUT /api/admin/tenants/{id}/members/{guid}", h.SaveMember)
+	mux.HandleFunc("DELETE /api/admin/tenants/{id}/members/{guid}", h.RemoveMember)
+	mux.HandleFunc("POST /api/admin/tenants/{id}/keys", h.CreateSigningKey)
+	mux.HandleFunc("GET /api/admin/tenants/{id}/keys", h.ListSigningKeys)
+	mux.HandleFunc("DELETE /api/admin/tenants/{id}/keys/{kid}", h.DeleteSigningKey)
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterUserRoutes(mux *http.ServeMux, h *handlers.UserHandler) {
+	mux.HandleFunc("POST /api/admin/users", h.CreateUser)
+	mux.HandleFunc("GET /api/admin/users", h.ListUsers)
+	mux.HandleFunc("GET /api/admin/users/{guid}", h.GetUser)
+	mux.HandleFunc("PUT /api/admin/users/{guid}", h.UpdateUser)
+	mux.HandleFunc("DELETE /api/admin/users/{guid}", h.DeleteUser)
+	mux.HandleFunc("POST /api/admin/users/{guid}/disable", h.DisableUser)
+	mux.HandleFunc("POST /api/admin/users/{guid}/enable", h.EnableUser)
+}
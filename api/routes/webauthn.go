@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"net/http"
+	"testovoe_medods/api/handlers"
+)
+
+func RegisterWebAuthnRoutes(mux *http.ServeMux, h *handlers.WebAuthnHandler) {
+	mux.HandleFunc("POST /api/webauthn/register/begin", h.BeginRegistration)
+	mux.HandleFunc("POST /api/webauthn/register/finish", h.FinishRegistration)
+	mux.HandleFunc("POST /api/webauthn/login/begin", h.BeginLogin)
+	mux.HandleFunc("POST /api/webauthn/login/finish", h.FinishLogin)
+	mux.HandleFunc("GET /api/webauthn/credentials", h.ListCredentials)
+	mux.HandleFunc("DELETE /api/webauthn/credentials/{id}", h.DeleteCredential)
+}
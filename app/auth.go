@@ -1,11 +1,14 @@
 package app
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"testovoe_medods/api/handlers"
+	"testovoe_medods/api/middleware"
 	"testovoe_medods/api/routes"
 	"testovoe_medods/config"
+	"testovoe_medods/lib/mail"
 	auth "testovoe_medods/repository"
 	auths "testovoe_medods/service"
 
@@ -13,13 +16,141 @@ import (
 )
 
 
-func InitAuthApp(db *sqlx.DB, log *slog.Logger, cfg *config.Config, mux *http.ServeMux) {
+func InitAuthApp(db *sqlx.DB, log *slog.Logger, cfg *config.Config, mux *http.ServeMux, mailer mail.Sender) {
 	authRepo := auth.NewUserAuthRepository(db)
+	userRepo := auth.NewUserRepository(db)
+	mfaRepo := auth.NewMFARepository(db)
+	webAuthnRepo := auth.NewWebAuthnRepository(db)
+	roleRepo := auth.NewRoleRepository(db)
+	tenantRepo := auth.NewTenantRepository(db)
+	groupRepo := auth.NewGroupRepository(db)
+	auditRepo := auth.NewAuditRepository(db)
+	clientRepo := auth.NewOAuthClientRepository(db)
+	mfaService := auths.NewMFAService(
+		log,
+		cfg,
+		mfaRepo,
+		authRepo,
+		webAuthnRepo,
+	)
+	lockoutService := auths.NewLockoutService(log, cfg, auth.NewLockoutRepository(db))
+	tenantService := auths.NewTenantService(log, cfg, tenantRepo, userRepo)
+	if err := tenantService.LoadSigningKeys(context.Background()); err != nil {
+		panic(err)
+	}
+	go tenantService.Run(context.Background())
 	authService := auths.NewUserAuthService(
 		log,
 		cfg,
 		authRepo,
+		mfaService,
+		lockoutService,
+		roleRepo,
+		groupRepo,
+		tenantService,
+		auditRepo,
+		clientRepo,
 	)
 	authHandler := handlers.NewAuthHandler(cfg, authService)
 	routes.RegisterAuthRoutes(mux, authHandler)
+	mfaHandler := handlers.NewMFAHandler(cfg, authService, mfaService)
+	routes.RegisterMFARoutes(mux, mfaHandler)
+	webAuthnService := auths.NewWebAuthnService(
+		log,
+		cfg,
+		webAuthnRepo,
+		authRepo,
+		authService,
+		mfaService,
+		lockoutService,
+	)
+	webAuthnHandler := handlers.NewWebAuthnHandler(cfg, authService, webAuthnService)
+	routes.RegisterWebAuthnRoutes(mux, webAuthnHandler)
+	accountService := auths.NewAccountService(log, cfg, authRepo, mfaService)
+	accountHandler := handlers.NewAccountHandler(cfg, authService, accountService)
+	routes.RegisterAccountRoutes(mux, accountHandler)
+	lockoutHandler := handlers.NewLockoutHandler(cfg, lockoutService)
+	routes.RegisterLockoutRoutes(mux, lockoutHandler)
+	passwordlessService := auths.NewPasswordlessService(
+		log,
+		cfg,
+		auth.NewPasswordlessRepository(db),
+		userRepo,
+		authService,
+		lockoutService,
+		mailer,
+	)
+	passwordlessHandler := handlers.NewPasswordlessHandler(cfg, passwordlessService)
+	routes.RegisterPasswordlessRoutes(mux, passwordlessHandler)
+	federationService := auths.NewFederationService(
+		log,
+		cfg,
+		auth.NewFederationRepository(db),
+		userRepo,
+		authService,
+	)
+	federationHandler := handlers.NewFederationHandler(cfg, federationService, authService)
+	routes.RegisterFederationRoutes(mux, federationHandler)
+	oauthRepo := auth.NewOAuthRepository(db)
+	clientService := auths.NewOAuthClientService(log, cfg, clientRepo, oauthRepo, tenantRepo)
+	clientHandler := handlers.NewOAuthClientHandler(cfg, clientService)
+	routes.RegisterOAuthClientRoutes(mux, clientHandler)
+	deviceService := auths.NewDeviceService(
+		log,
+		cfg,
+		auth.NewDeviceRepository(db),
+		clientService,
+		lockoutService,
+	)
+	deviceHandler := handlers.NewDeviceHandler(cfg, authService, deviceService)
+	routes.RegisterDeviceRoutes(mux, deviceHandler)
+	consentService := auths.NewConsentService(log, cfg, auth.NewConsentRepository(db), clientService)
+	consentHandler := handlers.NewConsentHandler(cfg, authService, consentService)
+	routes.RegisterConsentRoutes(mux, consentHandler)
+	oauthService := auths.NewOAuthService(
+		log,
+		cfg,
+		oauthRepo,
+		authRepo,
+		userRepo,
+		groupRepo,
+		authService,
+		clientService,
+		deviceService,
+		consentService,
+	)
+	oauthHandler := handlers.NewOAuthHandler(cfg, oauthService)
+	routes.RegisterOAuthRoutes(mux, oauthHandler)
+	logoutService := auths.NewLogoutService(log, cfg, auth.NewLogoutRepository(db), clientService)
+	go logoutService.Run(context.Background())
+	logoutHandler := handlers.NewLogoutHandler(cfg, authService, logoutService)
+	routes.RegisterLogoutRoutes(mux, logoutHandler)
+	apiKeyService := auths.NewAPIKeyService(log, cfg, auth.NewAPIKeyRepository(db), authService, clientService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(cfg, apiKeyService)
+	routes.RegisterAPIKeyRoutes(mux, apiKeyHandler, middleware.RequireAPIKey(apiKeyService, cfg.Database.Timeout))
+	introspectionService := auths.NewIntrospectionService(log, cfg, authRepo, clientService, apiKeyService, auditRepo)
+	introspectionHandler := handlers.NewIntrospectionHandler(cfg, introspectionService)
+	routes.RegisterIntrospectionRoutes(mux, introspectionHandler)
+	roleService := auths.NewRoleService(log, cfg, roleRepo, userRepo)
+	userService := auths.NewUserService(log, cfg, userRepo)
+	userHandler := handlers.NewUserHandler(cfg, userService, introspectionService, roleService)
+	routes.RegisterUserRoutes(mux, userHandler)
+	impersonationService := auths.NewImpersonationService(log, cfg, auditRepo, userRepo, roleRepo, groupRepo)
+	impersonationHandler := handlers.NewImpersonationHandler(cfg, authService, impersonationService)
+	routes.RegisterImpersonationRoutes(mux, impersonationHandler)
+	auditHandler := handlers.NewAuditHandler(cfg, auths.NewAuditService(log, cfg, auditRepo), introspectionService, roleService)
+	routes.RegisterAuditRoutes(mux, auditHandler)
+	roleHandler := handlers.NewRoleHandler(cfg, roleService)
+	routes.RegisterRoleRoutes(mux, roleHandler)
+	groupService := auths.NewGroupService(log, cfg, groupRepo, userRepo)
+	groupHandler := handlers.NewGroupHandler(cfg, groupService)
+	routes.RegisterGroupRoutes(mux, groupHandler)
+	scimService := auths.NewSCIMService(log, cfg, auth.NewSCIMRepository(db), userRepo, groupRepo)
+	scimHandler := handlers.NewSCIMHandler(cfg, scimService)
+	routes.RegisterSCIMRoutes(mux, scimHandler)
+	tenantHandler := handlers.NewTenantHandler(cfg, tenantService)
+	routes.RegisterTenantRoutes(mux, tenantHandler)
+	invitationService := auths.NewInvitationService(log, cfg, auth.NewInvitationRepository(db), tenantRepo, mailer)
+	invitationHandler := handlers.NewInvitationHandler(cfg, authService, invitationService)
+	routes.RegisterInvitationRoutes(mux, invitationHandler)
 }
\ No newline at end of file
@@ -0,0 +1,30 @@
+package app
+
+import (
+	"log/slog"
+	"net/http"
+	"testovoe_medods/api/handlers"
+	"testovoe_medods/api/routes"
+	"testovoe_medods/config"
+	"testovoe_medods/lib/mail"
+	repo "testovoe_medods/repository"
+	"testovoe_medods/service"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func InitPasswordApp(db *sqlx.DB, log *slog.Logger, cfg *config.Config, mux *http.ServeMux, mailer mail.Sender) {
+	userRepo := repo.NewUserRepository(db)
+	passwordRepo := repo.NewPasswordRepository(db)
+	lockoutService := service.NewLockoutService(log, cfg, repo.NewLockoutRepository(db))
+	passwordService := service.NewPasswordService(
+		log,
+		cfg,
+		userRepo,
+		passwordRepo,
+		lockoutService,
+		mailer,
+	)
+	passwordHandler := handlers.NewPasswordHandler(cfg, passwordService)
+	routes.RegisterPasswordRoutes(mux, passwordHandler)
+}
@@ -17,6 +17,23 @@ type Config struct {
 	Database Database `yaml:"database" env-required:"true"`
 	HTTPServer Server `yaml:"http_server" env-required:"true"`
 	Token Token `yaml:"token" env-required:"true"`
+	Password Password `yaml:"password"`
+	SMTP SMTP `yaml:"smtp"`
+	MFA MFA `yaml:"mfa"`
+	WebAuthn WebAuthn `yaml:"webauthn"`
+	Lockout Lockout `yaml:"lockout"`
+	Admin Admin `yaml:"admin"`
+	Passwordless Passwordless `yaml:"passwordless"`
+	Federation Federation `yaml:"federation"`
+	OIDC OIDC `yaml:"oidc"`
+	Device Device `yaml:"device"`
+	BackchannelLogout BackchannelLogout `yaml:"backchannel_logout"`
+	APIKeys APIKeys `yaml:"api_keys"`
+	Tenants Tenants `yaml:"tenants"`
+	Invitations Invitations `yaml:"invitations"`
+	Groups Groups `yaml:"groups"`
+	Impersonation Impersonation `yaml:"impersonation"`
+	SCIM SCIM `yaml:"scim"`
 }
 
 type Token struct {
@@ -24,6 +41,157 @@ type Token struct {
 	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl" env-required:"true"`
 }
 
+type Password struct {
+	MinLength int `yaml:"min_length" env-default:"8"`
+	ResetTokenTTL time.Duration `yaml:"reset_token_ttl" env-default:"15m"`
+	ResetURL string `yaml:"reset_url" env-default:"http://localhost:8081/password/reset"`
+}
+
+type MFA struct {
+	Issuer string `yaml:"issuer" env-default:"auth-service"`
+	ChallengeTTL time.Duration `yaml:"challenge_ttl" env-default:"5m"`
+}
+
+type WebAuthn struct {
+	RPID string `yaml:"rp_id" env-default:"localhost"`
+	RPName string `yaml:"rp_name" env-default:"auth-service"`
+	Origins []string `yaml:"origins" env-default:"http://localhost:8081"`
+	ChallengeTTL time.Duration `yaml:"challenge_ttl" env-default:"5m"`
+	RequireUserVerification bool `yaml:"require_user_verification" env-default:"true"`
+}
+
+type Lockout struct {
+	// failures allowed before delays kick in
+	FreeAttempts int `yaml:"free_attempts" env-default:"3"`
+	BaseDelay time.Duration `yaml:"base_delay" env-default:"1s"`
+	MaxDelay time.Duration `yaml:"max_delay" env-default:"5m"`
+	UserThreshold int `yaml:"user_threshold" env-default:"10"`
+	IPThreshold int `yaml:"ip_threshold" env-default:"100"`
+	LockoutDuration time.Duration `yaml:"lockout_duration" env-default:"15m"`
+	// failures older than window are forgotten
+	Window time.Duration `yaml:"window" env-default:"1h"`
+}
+
+type Passwordless struct {
+	TTL time.Duration `yaml:"ttl" env-default:"10m"`
+	LinkURL string `yaml:"link_url" env-default:"http://localhost:8081/api/passwordless/link"`
+	MaxCodeAttempts int `yaml:"max_code_attempts" env-default:"5"`
+	SecureCookie bool `yaml:"secure_cookie" env-default:"true"`
+}
+
+type Federation struct {
+	StateTTL time.Duration `yaml:"state_ttl" env-default:"10m"`
+	SecureCookie bool `yaml:"secure_cookie" env-default:"true"`
+	Providers []FederationProvider `yaml:"providers"`
+}
+
+// upstream OpenID Connect provider
+type FederationProvider struct {
+	Name string `yaml:"name"`
+	Issuer string `yaml:"issuer"`
+	ClientID string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	RedirectURL string `yaml:"redirect_url"`
+	Scopes []string `yaml:"scopes"`
+	// create users for verified emails that don't have an account yet
+	AllowSignup bool `yaml:"allow_signup"`
+	// provider is authoritative for emails it marks verified, so unlinked identities are linked to the
+	// local account with the same email. Otherwise only signed in users can link the provider
+	TrustEmail bool `yaml:"trust_email"`
+}
+
+type OIDC struct {
+	Issuer string `yaml:"issuer" env-default:"http://localhost:8081"`
+	// PEM encoded RSA key for id tokens, ephemeral key is generated when empty
+	SigningKeyPath string `yaml:"signing_key_path" env:"OIDC_SIGNING_KEY_PATH"`
+	// users without session are redirected here with return_to pointing back to /authorize
+	LoginURL string `yaml:"login_url"`
+	AuthCodeTTL time.Duration `yaml:"auth_code_ttl" env-default:"1m"`
+	IDTokenTTL time.Duration `yaml:"id_token_ttl" env-default:"1h"`
+	SecureCookie bool `yaml:"secure_cookie" env-default:"true"`
+	// lifetime of request_uri returned by pushed authorization request endpoint
+	PARRequestTTL time.Duration `yaml:"par_request_ttl" env-default:"90s"`
+	// users are sent here with client_id, scope and return_to when third-party client needs new consent
+	ConsentURL string `yaml:"consent_url"`
+	// clients that may start authorization only with pushed requests
+	RequirePARClients []string `yaml:"require_par_clients"`
+}
+
+type Device struct {
+	CodeTTL time.Duration `yaml:"code_ttl" env-default:"10m"`
+	// minimal polling interval announced to devices
+	Interval time.Duration `yaml:"interval" env-default:"5s"`
+	// page where users enter user code, it talks to /api/device
+	VerificationURL string `yaml:"verification_url" env-default:"http://localhost:3000/device"`
+}
+
+type BackchannelLogout struct {
+	// how often queued logout notifications are picked up
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"5s"`
+	RequestTimeout time.Duration `yaml:"request_timeout" env-default:"5s"`
+	// notification is dropped after this many failed deliveries
+	MaxAttempts int `yaml:"max_attempts" env-default:"8"`
+	TokenTTL time.Duration `yaml:"token_ttl" env-default:"2m"`
+}
+
+type APIKeys struct {
+	// scopes users may put on their personal keys, service accounts use scopes of their client
+	UserScopes []string `yaml:"user_scopes"`
+	MaxPerOwner int `yaml:"max_per_owner" env-default:"25"`
+	// longest allowed key lifetime, keys may be created without expiry when zero
+	MaxTTL time.Duration `yaml:"max_ttl"`
+}
+
+type Tenants struct {
+	// how often tenant issuers and signing keys are reloaded from database
+	KeyReloadInterval time.Duration `yaml:"key_reload_interval" env-default:"1m"`
+}
+
+type Invitations struct {
+	TTL time.Duration `yaml:"ttl" env-default:"72h"`
+	// page that posts token from the link to /api/invitations/accept
+	AcceptURL string `yaml:"accept_url" env-default:"http://localhost:3000/invitations/accept"`
+}
+
+type Groups struct {
+	// "name" or "id", what groups claim is made of
+	ClaimFormat string `yaml:"claim_format" env-default:"name"`
+	// access tokens of users in more groups get groups_overage instead of groups claim
+	MaxTokenGroups int `yaml:"max_token_groups" env-default:"100"`
+}
+
+type Impersonation struct {
+	// permission administrator's roles must grant to open sessions as other users
+	Permission string `yaml:"permission" env-default:"users:impersonate"`
+	// impersonation tokens can't be refreshed, the session ends with the token
+	TokenTTL time.Duration `yaml:"token_ttl" env-default:"15m"`
+}
+
+// provisioning endpoint is disabled while token is empty
+type SCIM struct {
+	Token string `yaml:"token" env:"SCIM_TOKEN"`
+	// largest page returned by list requests
+	MaxResults int `yaml:"max_results" env-default:"200"`
+}
+
+// admin api is disabled while token is empty
+type Admin struct {
+	Token string `yaml:"token" env:"ADMIN_TOKEN"`
+	// permission user needs to manage users of own tenant
+	Permission string `yaml:"permission" env-default:"users:manage"`
+	// scope tokens issued to clients and api keys additionally need for user management
+	Scope string `yaml:"scope" env-default:"admin"`
+}
+
+// when Host is empty emails are written to the log instead of being sent
+type SMTP struct {
+	Host string `yaml:"host"`
+	Port int `yaml:"port" env-default:"587"`
+	Username string `yaml:"username" env:"SMTP_USERNAME"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD"`
+	From string `yaml:"from" env-default:"no-reply@localhost"`
+}
+
 type Database struct {
 	PostgresUser string `yaml:"postgres_user" env-required:"true"`
 	PostgresPassword string `yaml:"postgres_password" env-required:"true"`
@@ -0,0 +1,61 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// long-lived key owned either by user or by oauth client acting as service account
+type APIKey struct {
+	ID            uuid.UUID  `db:"id" json:"id"`
+	KeyDigest     string     `db:"key_digest" json:"-"`
+	DisplayPrefix string     `db:"display_prefix" json:"prefix"`
+	Name          string     `db:"name" json:"name"`
+	UserGuid      *uuid.UUID `db:"user_guid" json:"user_guid,omitempty"`
+	ClientID      *string    `db:"client_id" json:"client_id,omitempty"`
+	Scopes        StringList `db:"scopes" json:"scopes"`
+	ExpiresAt     *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	LastUsedAt    *time.Time `db:"last_used_at" json:"last_used_at,omitempty"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+}
+
+type APIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// seconds, key doesn't expire when empty
+	ExpiresIn *int64 `json:"expires_in"`
+}
+
+// key together with its plain value, returned only once on creation
+type APIKeyCredentials struct {
+	*APIKey
+	Key string `json:"key"`
+}
+
+// principal managing api keys and scopes it may delegate to them
+type APIKeyOwner struct {
+	UserGuid      *uuid.UUID
+	ClientID      *string
+	AllowedScopes []string
+}
+
+// RFC 7662 introspection response
+type IntrospectionResponse struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	Groups    []string `json:"groups,omitempty"`
+	Tenant    string   `json:"tenant,omitempty"`
+	// groups didn't fit into the token, they are available from /userinfo
+	GroupsOverage bool `json:"groups_overage,omitempty"`
+	// actor chain of exchanged tokens
+	Act any `json:"act,omitempty"`
+}
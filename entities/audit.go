@@ -0,0 +1,31 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AuditImpersonationIssued = "impersonation.issued"
+	AuditImpersonationUsed   = "impersonation.used"
+)
+
+type AuditEvent struct {
+	ID    uuid.UUID `db:"id" json:"id"`
+	Event string    `db:"event" json:"event"`
+	// user who performed the action and user it was performed on
+	ActorGuid   *uuid.UUID `db:"actor_guid" json:"actor_guid,omitempty"`
+	SubjectGuid *uuid.UUID `db:"subject_guid" json:"subject_guid,omitempty"`
+	SessionID   *uuid.UUID `db:"session_id" json:"session_id,omitempty"`
+	IpAddress   *string    `db:"ip_address" json:"ip_address,omitempty"`
+	Details     string     `db:"details" json:"details,omitempty"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+}
+
+// events the user took part in either as actor or as subject, zero limit means default page size
+type AuditFilter struct {
+	UserGuid *uuid.UUID
+	Limit    int
+	Offset   int
+}
@@ -0,0 +1,97 @@
+package entities
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	ClientAuthSecretBasic   = "client_secret_basic"
+	ClientAuthSecretPost    = "client_secret_post"
+	ClientAuthPrivateKeyJWT = "private_key_jwt"
+	// public clients, only allowed together with pkce
+	ClientAuthNone = "none"
+)
+
+// list stored as json array in a jsonb column
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]string(l))
+}
+
+func (l *StringList) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	}
+	return errors.New("unsupported type for StringList")
+}
+
+type OAuthClient struct {
+	ID                      string          `db:"id" json:"client_id"`
+	Name                    string          `db:"name" json:"client_name"`
+	SecretHash              *string         `db:"secret_hash" json:"-"`
+	RedirectURIs            StringList      `db:"redirect_uris" json:"redirect_uris"`
+	GrantTypes              StringList      `db:"grant_types" json:"grant_types"`
+	Scopes                  StringList      `db:"scopes" json:"scopes"`
+	TokenEndpointAuthMethod string          `db:"token_endpoint_auth_method" json:"token_endpoint_auth_method"`
+	JWKSURI                 *string         `db:"jwks_uri" json:"jwks_uri,omitempty"`
+	JWKS                    json.RawMessage `db:"jwks" json:"jwks,omitempty"`
+	// path of jwks file on the server, for workloads whose keys are provisioned locally
+	JWKSFile *string `db:"jwks_file" json:"jwks_file,omitempty"`
+	// receives logout tokens when sessions the client took part in are revoked
+	BackchannelLogoutURI *string `db:"backchannel_logout_uri" json:"backchannel_logout_uri,omitempty"`
+	// our own applications, users aren't asked for consent
+	FirstParty bool `db:"first_party" json:"first_party"`
+	// tenant sessions of the client's users are created in
+	TenantID string `db:"tenant_id" json:"tenant_id"`
+	// audiences client may request tokens for with token exchange grant
+	ExchangeAudiences StringList `db:"exchange_audiences" json:"exchange_audiences"`
+	// ttl overrides in seconds, server defaults are used when empty
+	AccessTokenTTL  *int64    `db:"access_token_ttl" json:"access_token_ttl,omitempty"`
+	RefreshTokenTTL *int64    `db:"refresh_token_ttl" json:"refresh_token_ttl,omitempty"`
+	CreatedAt       time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt       time.Time `db:"updated_at" json:"updated_at"`
+}
+
+type OAuthClientRequest struct {
+	// generated when empty on creation, ignored on update
+	ClientID                string          `json:"client_id"`
+	Name                    string          `json:"client_name"`
+	RedirectURIs            []string        `json:"redirect_uris"`
+	GrantTypes              []string        `json:"grant_types"`
+	Scopes                  []string        `json:"scopes"`
+	ExchangeAudiences       []string        `json:"exchange_audiences"`
+	TokenEndpointAuthMethod string          `json:"token_endpoint_auth_method"`
+	JWKSURI                 string          `json:"jwks_uri"`
+	JWKS                    json.RawMessage `json:"jwks"`
+	JWKSFile                string          `json:"jwks_file"`
+	BackchannelLogoutURI    string          `json:"backchannel_logout_uri"`
+	FirstParty              bool            `json:"first_party"`
+	TenantID                string          `json:"tenant_id"`
+	AccessTokenTTL          *int64          `json:"access_token_ttl"`
+	RefreshTokenTTL         *int64          `json:"refresh_token_ttl"`
+}
+
+// client together with plain secret, returned only once on creation and rotation
+type OAuthClientCredentials struct {
+	*OAuthClient
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// token lifetimes requested by the caller, zero values mean server defaults
+type TokenTTL struct {
+	Access  time.Duration
+	Refresh time.Duration
+}
@@ -0,0 +1,30 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// scopes user granted to third-party client
+type OAuthConsent struct {
+	UserGuid   uuid.UUID  `db:"user_guid" json:"-"`
+	ClientID   string     `db:"client_id" json:"client_id"`
+	ClientName string     `db:"client_name" json:"client_name"`
+	Scopes     StringList `db:"scopes" json:"scopes"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+type ConsentRequest struct {
+	ClientID string `json:"client_id"`
+	Scope    string `json:"scope"`
+}
+
+// what consent screen shows before user grants the request
+type ConsentInfo struct {
+	ClientID      string   `json:"client_id"`
+	ClientName    string   `json:"client_name"`
+	Scopes        []string `json:"scopes"`
+	GrantedScopes []string `json:"granted_scopes"`
+}
@@ -0,0 +1,52 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DeviceCodePending  = "pending"
+	DeviceCodeApproved = "approved"
+	DeviceCodeDenied   = "denied"
+)
+
+type DeviceCode struct {
+	DeviceCodeHash string     `db:"device_code_hash"`
+	UserCodeHash   string     `db:"user_code_hash"`
+	ClientID       string     `db:"client_id"`
+	Scope          string     `db:"scope"`
+	Status         string     `db:"status"`
+	UserGuid       *uuid.UUID `db:"user_guid"`
+	IntervalSec    int64      `db:"interval_seconds"`
+	LastPolledAt   *time.Time `db:"last_polled_at"`
+	ExpiresAt      time.Time  `db:"expires_at"`
+}
+
+type DeviceAuthorizationRequest struct {
+	Scope  string
+	Client ClientAuthentication
+}
+
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// shown to the user before approving a device
+type DeviceRequestInfo struct {
+	ClientID   string   `json:"client_id"`
+	ClientName string   `json:"client_name"`
+	Scopes     []string `json:"scopes"`
+}
+
+type DeviceVerifyRequest struct {
+	UserCode string `json:"user_code"`
+	Approve  bool   `json:"approve"`
+	IpAddr   string `json:"-"`
+}
@@ -0,0 +1,35 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type FederatedIdentity struct {
+	ID          uuid.UUID  `db:"id"`
+	Provider    string     `db:"provider"`
+	Subject     string     `db:"subject"`
+	UserGuid    uuid.UUID  `db:"user_guid"`
+	Email       *string    `db:"email"`
+	CreatedAt   time.Time  `db:"created_at"`
+	LastLoginAt *time.Time `db:"last_login_at"`
+}
+
+type FederationState struct {
+	StateHash    string    `db:"state_hash"`
+	Provider     string    `db:"provider"`
+	Nonce        string    `db:"nonce"`
+	CodeVerifier string    `db:"code_verifier"`
+	ExpiresAt    time.Time `db:"expires_at"`
+	// signed in user that started the login to link the provider to their account
+	LinkUserGuid *uuid.UUID `db:"link_user_guid"`
+}
+
+type FederationCallbackRequest struct {
+	Provider    string
+	State       string
+	CookieState string
+	Code        string
+	IpAddr      string
+}
@@ -0,0 +1,37 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	GroupClaimName = "name"
+	GroupClaimID   = "id"
+)
+
+type Group struct {
+	ID          uuid.UUID `db:"id" json:"id"`
+	Name        string    `db:"name" json:"name"`
+	Description string    `db:"description" json:"description"`
+	// members of the group are members of its parent as well
+	ParentID *uuid.UUID `db:"parent_id" json:"parent_id,omitempty"`
+	// id of the group in provisioning system, set through scim
+	ExternalID *string   `db:"external_id" json:"external_id,omitempty"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at" json:"updated_at"`
+}
+
+type GroupRequest struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	ParentID    *uuid.UUID `json:"parent_id"`
+}
+
+type GroupMember struct {
+	GroupID   uuid.UUID `db:"group_id" json:"group_id"`
+	UserGuid  uuid.UUID `db:"user_guid" json:"user_guid"`
+	Email     string    `db:"email" json:"email"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
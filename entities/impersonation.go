@@ -0,0 +1,22 @@
+package entities
+
+import "github.com/google/uuid"
+
+// auth method recorded on sessions opened by administrators as other users
+const AuthMethodImpersonation = "impersonation"
+
+type ImpersonationRequest struct {
+	// why the session is opened, kept in audit trail
+	Reason    string    `json:"reason"`
+	AdminGuid uuid.UUID `json:"-"`
+	UserGuid  uuid.UUID `json:"-"`
+	IpAddr    string    `json:"-"`
+}
+
+// access token only, impersonation sessions can't be refreshed
+type ImpersonationToken struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresIn   int64     `json:"expires_in"`
+	SessionID   uuid.UUID `json:"session_id"`
+}
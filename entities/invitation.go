@@ -0,0 +1,30 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TenantInvitation struct {
+	ID        uuid.UUID  `db:"id" json:"id"`
+	TenantID  string     `db:"tenant_id" json:"tenant_id"`
+	Email     string     `db:"email" json:"email"`
+	Role      string     `db:"role" json:"role"`
+	TokenHash string     `db:"token_hash" json:"-"`
+	InvitedBy *uuid.UUID `db:"invited_by" json:"invited_by,omitempty"`
+	// user the invitation was accepted by, created on acceptance when email had no account
+	AcceptedBy *uuid.UUID `db:"accepted_by" json:"accepted_by,omitempty"`
+	ExpiresAt  time.Time  `db:"expires_at" json:"expires_at"`
+	AcceptedAt *time.Time `db:"accepted_at" json:"accepted_at,omitempty"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+}
+
+type InvitationRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token"`
+}
@@ -0,0 +1,19 @@
+package entities
+
+import "time"
+
+type AuthAttempts struct {
+	Key           string     `db:"key"`
+	Failures      int        `db:"failures"`
+	LastFailureAt time.Time  `db:"last_failure_at"`
+	LockedUntil   *time.Time `db:"locked_until"`
+}
+
+type LockoutStatus struct {
+	Key               string     `json:"key"`
+	Failures          int        `json:"failures"`
+	LastFailureAt     *time.Time `json:"last_failure_at"`
+	LockedUntil       *time.Time `json:"locked_until"`
+	Locked            bool       `json:"locked"`
+	RetryAfterSeconds int64      `json:"retry_after_seconds"`
+}
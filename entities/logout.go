@@ -0,0 +1,12 @@
+package entities
+
+import "github.com/google/uuid"
+
+// queued back-channel logout notification for a relying party
+type BackchannelLogout struct {
+	ID       uuid.UUID  `db:"id"`
+	ClientID string     `db:"client_id"`
+	UserGuid uuid.UUID  `db:"user_guid"`
+	SID      *uuid.UUID `db:"sid"`
+	Attempts int        `db:"attempts"`
+}
@@ -0,0 +1,47 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type UserTOTP struct {
+	UserGuid     uuid.UUID  `db:"user_guid"`
+	SecretEnc    string     `db:"secret_enc"`
+	ConfirmedAt  *time.Time `db:"confirmed_at"`
+	LastUsedStep int64      `db:"last_used_step"`
+}
+
+type TOTPEnrollment struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+	QRCodePNG  []byte `json:"qr_code_png"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+	IpAddr       string `json:"-"`
+}
+
+type RecoveryCodes struct {
+	Codes []string `json:"codes"`
+}
+
+type Account struct {
+	ID                     string   `json:"id"`
+	Email                  string   `json:"email"`
+	MFAMethods             []string `json:"mfa_methods"`
+	RecoveryCodesRemaining int      `json:"recovery_codes_remaining"`
+}
+
+type MFAChallenge struct {
+	MFAToken string   `json:"mfa_token"`
+	Methods  []string `json:"methods"`
+}
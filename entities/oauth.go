@@ -0,0 +1,97 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuthorizationCode struct {
+	CodeHash            string    `db:"code_hash"`
+	ClientID            string    `db:"client_id"`
+	UserGuid            uuid.UUID `db:"user_guid"`
+	RedirectURI         string    `db:"redirect_uri"`
+	Scope               string    `db:"scope"`
+	Nonce               *string   `db:"nonce"`
+	CodeChallenge       string    `db:"code_challenge"`
+	CodeChallengeMethod string    `db:"code_challenge_method"`
+	// browser session that approved the request, becomes sid of issued tokens
+	SSOSessionID *uuid.UUID `db:"sso_session_id"`
+	ExpiresAt    time.Time  `db:"expires_at"`
+}
+
+type AuthorizeRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Prompt              string
+	// reference to pushed authorization request, other parameters are taken from it
+	RequestURI string `json:"-"`
+	// access token of the browser session, empty when user isn't logged in
+	SessionToken string `json:"-"`
+}
+
+// authorization request stored by client before redirecting the browser (RFC 9126)
+type PushedAuthorizationRequest struct {
+	RequestURIHash string          `db:"request_uri_hash"`
+	ClientID       string          `db:"client_id"`
+	Request        json.RawMessage `db:"request"`
+	ExpiresAt      time.Time       `db:"expires_at"`
+}
+
+type PushedAuthorizationResponse struct {
+	RequestURI string `json:"request_uri"`
+	ExpiresIn  int64  `json:"expires_in"`
+}
+
+// client authentication presented at token endpoint
+type ClientAuthentication struct {
+	// one of ClientAuth* methods, detected from the way credentials were sent
+	Method          string
+	ClientID        string
+	ClientSecret    string
+	ClientAssertion string
+}
+
+type TokenRequest struct {
+	GrantType    string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	DeviceCode   string
+	Assertion    string
+	Scope        string
+	Client       ClientAuthentication
+	IpAddr       string
+	// token exchange parameters (RFC 8693), audience also collects resource values
+	SubjectToken       string
+	SubjectTokenType   string
+	ActorToken         string
+	ActorTokenType     string
+	RequestedTokenType string
+	Audience           []string
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	// set only for token exchange responses
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+}
+
+type UserInfo struct {
+	Subject string   `json:"sub"`
+	Email   string   `json:"email,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+}
@@ -0,0 +1,33 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PasswordlessMethodLink = "link"
+	PasswordlessMethodCode = "code"
+)
+
+type PasswordlessLogin struct {
+	ID         uuid.UUID  `db:"id"`
+	UserGuid   uuid.UUID  `db:"user_guid"`
+	StateHash  string     `db:"state_hash"`
+	TokenHash  *string    `db:"token_hash"`
+	CodeHash   *string    `db:"code_hash"`
+	Attempts   int        `db:"attempts"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+	ConsumedAt *time.Time `db:"consumed_at"`
+}
+
+type PasswordlessStartRequest struct {
+	Email  string `json:"email"`
+	Method string `json:"method"`
+	IpAddr string `json:"-"`
+}
+
+type PasswordlessCodeRequest struct {
+	Code string `json:"code"`
+}
@@ -0,0 +1,40 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// named action resource servers check for, granted to users only through roles
+type Permission struct {
+	Name        string    `db:"name" json:"name"`
+	Description string    `db:"description" json:"description"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
+
+type Role struct {
+	Name        string     `db:"name" json:"name"`
+	Description string     `db:"description" json:"description"`
+	Permissions StringList `db:"permissions" json:"permissions"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+type PermissionRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type RoleRequest struct {
+	// ignored on update, roles can't be renamed because tokens carry their names
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type RoleAssignment struct {
+	UserGuid  uuid.UUID `db:"user_guid" json:"user_guid"`
+	Role      string    `db:"role_name" json:"role"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,22 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// session as shown to its owner
+type Session struct {
+	ID         uuid.UUID  `db:"id" json:"id"`
+	ClientID   *string    `db:"client_id" json:"client_id,omitempty"`
+	TenantID   string     `db:"tenant_id" json:"tenant_id"`
+	IpAddress  string     `db:"ip_address" json:"ip_address"`
+	AuthMethod *string    `db:"auth_method" json:"auth_method,omitempty"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	ExpiresAt  *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	// sessions opened by an administrator are flagged so the user notices them
+	Impersonated     bool       `db:"-" json:"impersonated"`
+	ImpersonatorGuid *uuid.UUID `db:"impersonator_guid" json:"impersonator_guid,omitempty"`
+	Current          bool       `db:"-" json:"current"`
+}
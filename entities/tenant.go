@@ -0,0 +1,72 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// tenant every user belongs to implicitly, first-party sessions and clients without tenant live here
+const DefaultTenantID = "default"
+
+// first factor a session was created with, tenants may restrict which ones their users can use
+const (
+	LoginMethodGuid         = "guid"
+	LoginMethodPasskey      = "passkey"
+	LoginMethodPasswordless = "passwordless"
+	LoginMethodFederation   = "federation"
+)
+
+const (
+	TenantRoleMember = "member"
+	// manages members and invitations of the tenant
+	TenantRoleAdmin = "admin"
+)
+
+type Tenant struct {
+	ID   string `db:"id" json:"id"`
+	Name string `db:"name" json:"name"`
+	// issuer of tenant tokens once it has signing keys, derived from server issuer when empty
+	Issuer *string `db:"issuer" json:"issuer,omitempty"`
+	// ttl overrides in seconds for user sessions, client overrides take precedence
+	AccessTokenTTL  *int64 `db:"access_token_ttl" json:"access_token_ttl,omitempty"`
+	RefreshTokenTTL *int64 `db:"refresh_token_ttl" json:"refresh_token_ttl,omitempty"`
+	// empty lists allow every login method and every address
+	LoginMethods StringList `db:"login_methods" json:"login_methods"`
+	AllowedIPs   StringList `db:"allowed_ips" json:"allowed_ips"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+type TenantRequest struct {
+	// ignored on update
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	Issuer          string   `json:"issuer"`
+	AccessTokenTTL  *int64   `json:"access_token_ttl"`
+	RefreshTokenTTL *int64   `json:"refresh_token_ttl"`
+	LoginMethods    []string `json:"login_methods"`
+	AllowedIPs      []string `json:"allowed_ips"`
+}
+
+type TenantMember struct {
+	TenantID  string    `db:"tenant_id" json:"tenant_id"`
+	UserGuid  uuid.UUID `db:"user_guid" json:"user_guid"`
+	Email     string    `db:"email" json:"email"`
+	Role      string    `db:"role" json:"role"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+type TenantMemberRequest struct {
+	UserGuid uuid.UUID `json:"user_guid"`
+	Role     string    `json:"role"`
+}
+
+// RSA key tenant tokens are signed with, the newest key is active and older ones only verify
+type TenantSigningKey struct {
+	ID         string    `db:"id" json:"kid"`
+	TenantID   string    `db:"tenant_id" json:"tenant_id"`
+	PrivateKey string    `db:"private_key" json:"-"`
+	Active     bool      `db:"-" json:"active"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
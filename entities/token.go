@@ -1,12 +1,20 @@
 package entities
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
 type User struct {
-	ID uuid.UUID `db:"id"`
-	Email string `db:"email"`
+	ID uuid.UUID `db:"id" json:"id"`
+	Email string `db:"email" json:"email"`
+	// disabled users can't log in or refresh tokens
+	Disabled bool `db:"disabled" json:"disabled"`
+	// id of the user in provisioning system, set through scim
+	ExternalID *string `db:"external_id" json:"external_id,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
 }
 
 type UserAuthInfo struct {
@@ -14,6 +22,17 @@ type UserAuthInfo struct {
 	UserGuid         *uuid.UUID `db:"user_guid"`
 	RefreshTokenHash *string `db:"refresh_token_hash"`
 	IpAddress        *string `db:"ip_address"`
+	// set for sessions created through authorization endpoint
+	ClientID         *string `db:"client_id"`
+	Scope            *string `db:"scope"`
+	// first-party session the client session was authorized from, revoked together with it
+	SSOSessionID     *uuid.UUID `db:"sso_session_id"`
+	// every lookup of the session is scoped by its tenant
+	TenantID         string `db:"tenant_id"`
+	AuthMethod       *string `db:"auth_method"`
+	// administrator the session was opened by, impersonation sessions end at ExpiresAt and can't be refreshed
+	ImpersonatorGuid *uuid.UUID `db:"impersonator_guid"`
+	ExpiresAt        *time.Time `db:"expires_at"`
 }
 
 type UserWithAuthCreds struct {
@@ -26,9 +45,22 @@ type UserWithAuthCreds struct {
 type AuthenticateRequest struct {
 	Guid string
 	IpAddr string
+	// set by flows that already verified a strong factor (e.g. passkey), skips mfa challenge
+	MFAPassed bool
+	// oauth client and granted scope the session is issued for
+	ClientID string
+	Scope string
+	SSOSessionID *uuid.UUID
+	TTL TokenTTL
+	// default tenant is used when empty
+	TenantID string
+	// first factor the user passed, see LoginMethod constants
+	Method string
 }
 
 type TokenPair struct {
 	AccessToken string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+	// lifetime the access token was issued with, lets oauth responses report expires_in
+	AccessTTL time.Duration `json:"-"`
 }
\ No newline at end of file
@@ -0,0 +1,21 @@
+package entities
+
+type UserRequest struct {
+	Email string `json:"email"`
+}
+
+// filter and page of admin user listing, zero limit means default page size
+type UserFilter struct {
+	// only members of the tenant are listed
+	TenantID string
+	Email    string
+	Limit    int
+	Offset   int
+}
+
+type UserPage struct {
+	Users  []User `json:"users"`
+	Total  int    `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
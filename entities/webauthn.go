@@ -0,0 +1,108 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	WebAuthnCeremonyRegistration = "registration"
+	WebAuthnCeremonyLogin        = "login"
+)
+
+type WebAuthnCredential struct {
+	ID             uuid.UUID  `db:"id" json:"id"`
+	UserGuid       uuid.UUID  `db:"user_guid" json:"-"`
+	CredentialID   string     `db:"credential_id" json:"credential_id"`
+	PublicKey      []byte     `db:"public_key" json:"-"`
+	Alg            int64      `db:"alg" json:"alg"`
+	SignCount      int64      `db:"sign_count" json:"-"`
+	AAGUID         string     `db:"aaguid" json:"aaguid"`
+	AttestationFmt string     `db:"attestation_fmt" json:"attestation_fmt"`
+	Name           string     `db:"name" json:"name"`
+	CreatedAt      time.Time  `db:"created_at" json:"created_at"`
+	LastUsedAt     *time.Time `db:"last_used_at" json:"last_used_at"`
+}
+
+type WebAuthnChallenge struct {
+	Challenge string     `db:"challenge"`
+	UserGuid  *uuid.UUID `db:"user_guid"`
+	Ceremony  string     `db:"ceremony"`
+	ExpiresAt time.Time  `db:"expires_at"`
+}
+
+// json shapes below follow PublicKeyCredential*OptionsJSON from WebAuthn Level 3,
+// binary values are base64url encoded without padding
+
+type WebAuthnRelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type WebAuthnUser struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type WebAuthnCredentialParam struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type WebAuthnCredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type WebAuthnAuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+type CredentialCreationOptions struct {
+	RP                     WebAuthnRelyingParty           `json:"rp"`
+	User                   WebAuthnUser                   `json:"user"`
+	Challenge              string                         `json:"challenge"`
+	PubKeyCredParams       []WebAuthnCredentialParam      `json:"pubKeyCredParams"`
+	Timeout                int64                          `json:"timeout"`
+	ExcludeCredentials     []WebAuthnCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection WebAuthnAuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                         `json:"attestation"`
+}
+
+type CredentialRequestOptions struct {
+	Challenge        string                         `json:"challenge"`
+	Timeout          int64                          `json:"timeout"`
+	RPID             string                         `json:"rpId"`
+	AllowCredentials []WebAuthnCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                         `json:"userVerification"`
+}
+
+type WebAuthnRegistrationRequest struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject"`
+	} `json:"response"`
+}
+
+type WebAuthnLoginBeginRequest struct {
+	MFAToken string `json:"mfa_token"`
+}
+
+type WebAuthnLoginFinishRequest struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	MFAToken string `json:"mfa_token"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+	IpAddr string `json:"-"`
+}
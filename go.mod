@@ -2,7 +2,10 @@ module testovoe_medods
 
 go 1.22.0
 
-require github.com/ilyakaznacheev/cleanenv v1.5.0
+require (
+	github.com/ilyakaznacheev/cleanenv v1.5.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+)
 
 require (
 	github.com/jackc/chunkreader/v2 v2.0.1 // indirect
@@ -25,8 +25,332 @@ func setUpDBData(con *sqlx.DB) error {
 				ip_address VARCHAR NOT NULL,
 			  UNIQUE (user_guid, refresh_token_hash),
 				FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE);
+
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash VARCHAR;
+
+	CREATE TABLE IF NOT EXISTS password_reset_tokens (
+				id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+				user_guid UUID NOT NULL,
+				token_hash VARCHAR UNIQUE NOT NULL,
+				expires_at TIMESTAMPTZ NOT NULL,
+				used_at TIMESTAMPTZ,
+				FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE);
+	
+	CREATE TABLE IF NOT EXISTS users_totp (
+				user_guid UUID PRIMARY KEY,
+				secret_enc VARCHAR NOT NULL,
+				confirmed_at TIMESTAMPTZ,
+				last_used_step BIGINT NOT NULL DEFAULT 0,
+				FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE);
+	
+	CREATE TABLE IF NOT EXISTS webauthn_credentials (
+				id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+				user_guid UUID NOT NULL,
+				credential_id VARCHAR UNIQUE NOT NULL,
+				public_key BYTEA NOT NULL,
+				alg INTEGER NOT NULL,
+				sign_count BIGINT NOT NULL DEFAULT 0,
+				aaguid VARCHAR NOT NULL,
+				attestation_fmt VARCHAR NOT NULL,
+				name VARCHAR NOT NULL,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+				last_used_at TIMESTAMPTZ,
+				FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE);
+
+	CREATE INDEX IF NOT EXISTS webauthn_credentials_user_guid_idx ON webauthn_credentials (user_guid);
+
+	CREATE TABLE IF NOT EXISTS webauthn_challenges (
+				challenge VARCHAR PRIMARY KEY,
+				user_guid UUID,
+				ceremony VARCHAR NOT NULL,
+				expires_at TIMESTAMPTZ NOT NULL,
+				FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE);
+	
+	CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+				id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+				user_guid UUID NOT NULL,
+				code_hash VARCHAR NOT NULL,
+				used_at TIMESTAMPTZ,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+				UNIQUE (user_guid, code_hash),
+				FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE);
+	
+	CREATE TABLE IF NOT EXISTS auth_attempts (
+				key VARCHAR PRIMARY KEY,
+				failures INTEGER NOT NULL,
+				last_failure_at TIMESTAMPTZ NOT NULL,
+				locked_until TIMESTAMPTZ);
+	
+	CREATE TABLE IF NOT EXISTS passwordless_logins (
+				id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+				user_guid UUID NOT NULL,
+				state_hash VARCHAR UNIQUE NOT NULL,
+				token_hash VARCHAR UNIQUE,
+				code_hash VARCHAR,
+				attempts INTEGER NOT NULL DEFAULT 0,
+				expires_at TIMESTAMPTZ NOT NULL,
+				consumed_at TIMESTAMPTZ,
+				FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE);
+	
+	CREATE TABLE IF NOT EXISTS federated_identities (
+				id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+				provider VARCHAR NOT NULL,
+				subject VARCHAR NOT NULL,
+				user_guid UUID NOT NULL,
+				email VARCHAR,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+				last_login_at TIMESTAMPTZ,
+				UNIQUE (provider, subject),
+				FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE);
+
+	CREATE TABLE IF NOT EXISTS federation_states (
+				state_hash VARCHAR PRIMARY KEY,
+				provider VARCHAR NOT NULL,
+				nonce VARCHAR NOT NULL,
+				code_verifier VARCHAR NOT NULL,
+				expires_at TIMESTAMPTZ NOT NULL);
+	
+	CREATE TABLE IF NOT EXISTS oauth_authorization_codes (
+				code_hash VARCHAR PRIMARY KEY,
+				client_id VARCHAR NOT NULL,
+				user_guid UUID NOT NULL,
+				redirect_uri VARCHAR NOT NULL,
+				scope VARCHAR NOT NULL,
+				nonce VARCHAR,
+				code_challenge VARCHAR NOT NULL,
+				code_challenge_method VARCHAR NOT NULL,
+				expires_at TIMESTAMPTZ NOT NULL,
+				FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE);
+
+	ALTER TABLE users_auth_info ADD COLUMN IF NOT EXISTS client_id VARCHAR;
+	ALTER TABLE users_auth_info ADD COLUMN IF NOT EXISTS scope VARCHAR;
+	
+	CREATE TABLE IF NOT EXISTS oauth_clients (
+				id VARCHAR PRIMARY KEY,
+				name VARCHAR NOT NULL DEFAULT '',
+				secret_hash VARCHAR,
+				redirect_uris JSONB NOT NULL DEFAULT '[]',
+				grant_types JSONB NOT NULL DEFAULT '[]',
+				scopes JSONB NOT NULL DEFAULT '[]',
+				token_endpoint_auth_method VARCHAR NOT NULL,
+				jwks_uri VARCHAR,
+				jwks JSONB,
+				access_token_ttl BIGINT,
+				refresh_token_ttl BIGINT,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+				updated_at TIMESTAMPTZ NOT NULL DEFAULT now());
+
+	CREATE TABLE IF NOT EXISTS oauth_assertion_jtis (
+				issuer VARCHAR NOT NULL,
+				jti VARCHAR NOT NULL,
+				expires_at TIMESTAMPTZ NOT NULL,
+				PRIMARY KEY (issuer, jti));
+	
+	CREATE TABLE IF NOT EXISTS device_codes (
+				device_code_hash VARCHAR PRIMARY KEY,
+				user_code_hash VARCHAR UNIQUE NOT NULL,
+				client_id VARCHAR NOT NULL,
+				scope VARCHAR NOT NULL,
+				status VARCHAR NOT NULL,
+				user_guid UUID,
+				interval_seconds BIGINT NOT NULL,
+				last_polled_at TIMESTAMPTZ,
+				expires_at TIMESTAMPTZ NOT NULL,
+				FOREIGN KEY (client_id) REFERENCES oauth_clients(id) ON DELETE CASCADE,
+				FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE);
+	
+	ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS exchange_audiences JSONB NOT NULL DEFAULT '[]';
+	
+	ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS jwks_file VARCHAR;
+	
+	CREATE TABLE IF NOT EXISTS oauth_pushed_requests (
+				request_uri_hash VARCHAR PRIMARY KEY,
+				client_id VARCHAR NOT NULL,
+				request JSONB NOT NULL,
+				expires_at TIMESTAMPTZ NOT NULL,
+				FOREIGN KEY (client_id) REFERENCES oauth_clients(id) ON DELETE CASCADE);
+	
+	ALTER TABLE users_auth_info ADD COLUMN IF NOT EXISTS sso_session_id UUID REFERENCES users_auth_info(id) ON DELETE CASCADE;
+	ALTER TABLE oauth_authorization_codes ADD COLUMN IF NOT EXISTS sso_session_id UUID;
+	ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS backchannel_logout_uri VARCHAR;
+	
+	CREATE TABLE IF NOT EXISTS backchannel_logouts (
+				id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+				client_id VARCHAR NOT NULL,
+				user_guid UUID NOT NULL,
+				sid UUID,
+				attempts INT NOT NULL DEFAULT 0,
+				next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+				last_error VARCHAR,
+				FOREIGN KEY (client_id) REFERENCES oauth_clients(id) ON DELETE CASCADE);
+	
+	ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS first_party BOOLEAN NOT NULL DEFAULT false;
+	
+	CREATE TABLE IF NOT EXISTS oauth_consents (
+				user_guid UUID NOT NULL,
+				client_id VARCHAR NOT NULL,
+				scopes JSONB NOT NULL DEFAULT '[]',
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+				updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+				PRIMARY KEY (user_guid, client_id),
+				FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE,
+				FOREIGN KEY (client_id) REFERENCES oauth_clients(id) ON DELETE CASCADE);
+	
+	CREATE TABLE IF NOT EXISTS api_keys (
+				id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+				key_digest VARCHAR UNIQUE NOT NULL,
+				display_prefix VARCHAR NOT NULL,
+				name VARCHAR NOT NULL DEFAULT '',
+				user_guid UUID,
+				client_id VARCHAR,
+				scopes JSONB NOT NULL DEFAULT '[]',
+				expires_at TIMESTAMPTZ,
+				last_used_at TIMESTAMPTZ,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+				CHECK ((user_guid IS NULL) <> (client_id IS NULL)),
+				FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE,
+				FOREIGN KEY (client_id) REFERENCES oauth_clients(id) ON DELETE CASCADE);
+	
+	CREATE TABLE IF NOT EXISTS permissions (
+				name VARCHAR PRIMARY KEY,
+				description VARCHAR NOT NULL DEFAULT '',
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now());
+	
+	CREATE TABLE IF NOT EXISTS roles (
+				name VARCHAR PRIMARY KEY,
+				description VARCHAR NOT NULL DEFAULT '',
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+				updated_at TIMESTAMPTZ NOT NULL DEFAULT now());
+	
+	CREATE TABLE IF NOT EXISTS role_permissions (
+				role_name VARCHAR NOT NULL,
+				permission VARCHAR NOT NULL,
+				PRIMARY KEY (role_name, permission),
+				FOREIGN KEY (role_name) REFERENCES roles(name) ON DELETE CASCADE,
+				FOREIGN KEY (permission) REFERENCES permissions(name) ON DELETE CASCADE);
+	
+	CREATE TABLE IF NOT EXISTS user_roles (
+				user_guid UUID NOT NULL,
+				role_name VARCHAR NOT NULL,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+				PRIMARY KEY (user_guid, role_name),
+				FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE,
+				FOREIGN KEY (role_name) REFERENCES roles(name) ON DELETE CASCADE);
+	
+	CREATE TABLE IF NOT EXISTS tenants (
+				id VARCHAR PRIMARY KEY,
+				name VARCHAR NOT NULL DEFAULT '',
+				access_token_ttl BIGINT,
+				refresh_token_ttl BIGINT,
+				login_methods JSONB NOT NULL DEFAULT '[]',
+				allowed_ips JSONB NOT NULL DEFAULT '[]',
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+				updated_at TIMESTAMPTZ NOT NULL DEFAULT now());
+	
+	INSERT INTO tenants (id, name) VALUES ('default', 'Default') ON CONFLICT (id) DO NOTHING;
+	
+	CREATE TABLE IF NOT EXISTS tenant_members (
+				tenant_id VARCHAR NOT NULL,
+				user_guid UUID NOT NULL,
+				role VARCHAR NOT NULL DEFAULT 'member',
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+				PRIMARY KEY (tenant_id, user_guid),
+				FOREIGN KEY (tenant_id) REFERENCES tenants(id) ON DELETE CASCADE,
+				FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE);
+	
+	ALTER TABLE users_auth_info ADD COLUMN IF NOT EXISTS tenant_id VARCHAR NOT NULL DEFAULT 'default' REFERENCES tenants(id) ON DELETE CASCADE;
+	ALTER TABLE users_auth_info ADD COLUMN IF NOT EXISTS auth_method VARCHAR;
+	ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS tenant_id VARCHAR NOT NULL DEFAULT 'default' REFERENCES tenants(id) ON DELETE CASCADE;
+	
+	ALTER TABLE tenants ADD COLUMN IF NOT EXISTS issuer VARCHAR UNIQUE;
+	
+	CREATE TABLE IF NOT EXISTS tenant_signing_keys (
+				id VARCHAR PRIMARY KEY,
+				tenant_id VARCHAR NOT NULL,
+				private_key TEXT NOT NULL,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+				FOREIGN KEY (tenant_id) REFERENCES tenants(id) ON DELETE CASCADE);
+	
+	CREATE TABLE IF NOT EXISTS tenant_invitations (
+				id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+				tenant_id VARCHAR NOT NULL,
+				email VARCHAR(255) NOT NULL,
+				role VARCHAR NOT NULL DEFAULT 'member',
+				token_hash VARCHAR UNIQUE NOT NULL,
+				invited_by UUID,
+				accepted_by UUID,
+				expires_at TIMESTAMPTZ NOT NULL,
+				accepted_at TIMESTAMPTZ,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+				FOREIGN KEY (tenant_id) REFERENCES tenants(id) ON DELETE CASCADE,
+				FOREIGN KEY (invited_by) REFERENCES users(id) ON DELETE SET NULL,
+				FOREIGN KEY (accepted_by) REFERENCES users(id) ON DELETE SET NULL);
+	
+	CREATE TABLE IF NOT EXISTS groups (
+				id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+				name VARCHAR UNIQUE NOT NULL,
+				description VARCHAR NOT NULL DEFAULT '',
+				parent_id UUID,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+				updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+				FOREIGN KEY (parent_id) REFERENCES groups(id) ON DELETE SET NULL);
+	
+	CREATE TABLE IF NOT EXISTS group_members (
+				group_id UUID NOT NULL,
+				user_guid UUID NOT NULL,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+				PRIMARY KEY (group_id, user_guid),
+				FOREIGN KEY (group_id) REFERENCES groups(id) ON DELETE CASCADE,
+				FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE);
+	
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT false;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+	
+	ALTER TABLE users_auth_info ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+	ALTER TABLE users_auth_info ADD COLUMN IF NOT EXISTS impersonator_guid UUID REFERENCES users(id) ON DELETE CASCADE;
+	ALTER TABLE users_auth_info ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+	
+	CREATE TABLE IF NOT EXISTS audit_events (
+				id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+				event VARCHAR NOT NULL,
+				actor_guid UUID,
+				subject_guid UUID,
+				session_id UUID,
+				ip_address VARCHAR,
+				details VARCHAR NOT NULL DEFAULT '',
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now());
+	
+	CREATE INDEX IF NOT EXISTS audit_events_subject_idx ON audit_events (subject_guid, created_at);
+	CREATE INDEX IF NOT EXISTS audit_events_actor_idx ON audit_events (actor_guid, created_at);
+	
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS external_id VARCHAR;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+	ALTER TABLE groups ADD COLUMN IF NOT EXISTS external_id VARCHAR;
+	
+	CREATE INDEX IF NOT EXISTS users_external_id_idx ON users (external_id);
+	CREATE INDEX IF NOT EXISTS groups_external_id_idx ON groups (external_id);
+	
+	CREATE TABLE IF NOT EXISTS mfa_used_challenges (
+				jti VARCHAR PRIMARY KEY,
+				expires_at TIMESTAMPTZ NOT NULL);
+	
+	ALTER TABLE federation_states ADD COLUMN IF NOT EXISTS link_user_guid UUID REFERENCES users(id) ON DELETE CASCADE;
+	
+	-- emails used to be unique only case-sensitively, upgrade stops with explanation instead of
+	-- failing on index creation while such duplicates exist. They have to be merged by hand
+	DO $$
+	DECLARE duplicates INTEGER;
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'users_email_lower_key') THEN
+			SELECT count(*) INTO duplicates FROM (SELECT 1 FROM users GROUP BY lower(email) HAVING count(*) > 1) d;
+			IF duplicates > 0 THEN
+				RAISE EXCEPTION '% emails belong to several users differing only by case, merge them before upgrading: SELECT lower(email), array_agg(id) FROM users GROUP BY 1 HAVING count(*) > 1', duplicates;
+			END IF;
+		END IF;
+	END $$;
+	CREATE UNIQUE INDEX IF NOT EXISTS users_email_lower_key ON users (lower(email));
 	
-	INSERT INTO users (email) VALUES ('test@gmail.com') ON CONFLICT (email) DO NOTHING;
+	INSERT INTO users (email) VALUES ('test@gmail.com') ON CONFLICT ((lower(email))) DO NOTHING;
 				
 				`
 	_, err := con.Exec(q)
@@ -0,0 +1,95 @@
+package crypt
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// generates url safe random token of n random bytes
+func GenerateOpaqueToken(n int) (string, error) {
+	const op = "crypt.GenerateOpaqueToken"
+
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// opaque tokens have enough entropy, so unlike passwords they are stored as plain sha256
+// which makes it possible to look them up by hash
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// keyed digest for low entropy secrets (e.g. recovery codes): leaked hashes can't be
+// brute forced without SECRET, while lookup by digest is still possible
+func HMACDigest(value string) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("SECRET")))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// generates human friendly code like "k7f2m-q9x4d" from n random bytes
+func GenerateHumanCode(n int) (string, error) {
+	const op = "crypt.GenerateHumanCode"
+
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+	half := len(code) / 2
+	return code[:half] + "-" + code[half:], nil
+}
+
+// generates uniformly distributed numeric code with given amount of digits
+func GenerateNumericCode(digits int) (string, error) {
+	const op = "crypt.GenerateNumericCode"
+
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return fmt.Sprintf("%0*d", digits, n), nil
+}
+
+// consonants only, so codes can't spell words and are hard to mistype (RFC 8628 6.1)
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+// generates device flow user code like "WDJB-MJHT", 8 characters give ~34 bits of entropy
+func GenerateUserCode() (string, error) {
+	const op = "crypt.GenerateUserCode"
+
+	code := make([]byte, 8)
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code[:4]) + "-" + string(code[4:]), nil
+}
+
+// drops separators and case so users can type code the way they like
+func NormalizeUserCode(code string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(code) {
+		if strings.ContainsRune(userCodeAlphabet, r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var ErrMalformedCiphertext = errors.New("malformed ciphertext")
+
+func sealKey() []byte {
+	key := sha256.Sum256([]byte(os.Getenv("SECRET")))
+	return key[:]
+}
+
+// encrypts secrets that have to be recovered later (e.g. totp seeds) with a key derived from SECRET
+func Seal(plaintext string) (string, error) {
+	const op = "crypt.Seal"
+
+	block, err := aes.NewCipher(sealKey())
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func Open(ciphertext string) (string, error) {
+	const op = "crypt.Open"
+
+	raw, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, ErrMalformedCiphertext)
+	}
+
+	block, err := aes.NewCipher(sealKey())
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(raw) < gcm.NonceSize() {
+		return "", fmt.Errorf("%s: %w", op, ErrMalformedCiphertext)
+	}
+	nonce, sealed := raw[:gcm.NonceSize()], raw[gcm.NonceSize():]
+
+	plain, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return string(plain), nil
+}
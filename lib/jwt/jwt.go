@@ -2,18 +2,49 @@ package jwtp
 
 import (
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 type CustomTokenClaims struct {
 	IsRefresh bool
 	IpAddr    string
+	// set only on short-lived tokens issued between first and second login factor
+	MFAChallenge bool `json:",omitempty"`
+	// set on tokens issued to oauth clients themselves, subject is client id and there is no user session
+	ServiceToken bool `json:",omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	Scope string `json:"scope,omitempty"`
+	// tenant the session or client belongs to
+	Tenant string `json:"tenant,omitempty"`
+	// login method of the first factor, carried by mfa challenge tokens to the session they end in
+	AuthMethod string `json:"auth_method,omitempty"`
+	// effective roles of the user at the moment access token was issued
+	Roles []string `json:"roles,omitempty"`
+	// groups of the user including parents of nested ones
+	Groups []string `json:"groups,omitempty"`
+	// set instead of groups when the user is in too many groups to fit into a token
+	GroupsOverage bool `json:"groups_overage,omitempty"`
+	// party acting on behalf of the subject, set on tokens minted by token exchange and on impersonation tokens
+	Act *Actor `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// authorization data put into access tokens
+type AccessClaims struct {
+	Roles         []string
+	Groups        []string
+	GroupsOverage bool
+}
+
+// RFC 8693 actor claim, nested act keeps the chain of earlier delegations
+type Actor struct {
+	Subject string `json:"sub"`
+	Act     *Actor `json:"act,omitempty"`
+}
+
 type Claims interface {
 	GetAudience() (jwt.ClaimStrings, error)
 	GetExpirationTime() (*jwt.NumericDate, error)
@@ -24,6 +55,10 @@ type Claims interface {
 }
 
 func (c *CustomTokenClaims) ValidateTokenClaims(refresh bool) error {
+	if c.MFAChallenge {
+		return jwt.ErrTokenInvalidClaims
+	}
+
 	if refresh && !c.IsRefresh {
 			return jwt.ErrTokenInvalidClaims
 	} else if !refresh && c.IsRefresh {
@@ -34,6 +69,12 @@ func (c *CustomTokenClaims) ValidateTokenClaims(refresh bool) error {
 		return jwt.ErrTokenInvalidSubject
 	}
 
+	for act := c.Act; act != nil; act = act.Act {
+		if act.Subject == "" {
+			return jwt.ErrTokenInvalidClaims
+		}
+	}
+
 	if c.ExpiresAt.Time.Before(time.Now()) {
 		return jwt.ErrTokenExpired
 	}
@@ -41,10 +82,17 @@ func (c *CustomTokenClaims) ValidateTokenClaims(refresh bool) error {
 	return nil
 }
 
-func tokenClaims(tokenId string, exp *jwt.NumericDate, ipAddr string, isRefresh bool) Claims {
+func tokenClaims(tokenId string, exp *jwt.NumericDate, ipAddr, tenant string, access *AccessClaims, isRefresh bool) *CustomTokenClaims {
+	if access == nil {
+		access = &AccessClaims{}
+	}
 	return &CustomTokenClaims{
-		IsRefresh: isRefresh,
-		IpAddr:    ipAddr,
+		IsRefresh:     isRefresh,
+		IpAddr:        ipAddr,
+		Tenant:        tenant,
+		Roles:         access.Roles,
+		Groups:        access.Groups,
+		GroupsOverage: access.GroupsOverage,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: exp,
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -53,22 +101,21 @@ func tokenClaims(tokenId string, exp *jwt.NumericDate, ipAddr string, isRefresh
 	}
 }
 
-// Generates new jwt token
-func GenerateToken(tokenId, ipAddr string, exp time.Duration, isRefresh bool) (string, error) {
+// Generates new jwt token, roles and groups are put only into access tokens.
+// Tokens of tenants with own key set are signed with its active key and carry its issuer
+func GenerateToken(tokenId, ipAddr, tenant string, access *AccessClaims, exp time.Duration, isRefresh bool) (string, error) {
 	const op = "jwt.GenerateToken"
 
-	secret := []byte(os.Getenv("SECRET"))
 	expTime := jwt.NewNumericDate(time.Now().Add(exp))
-	var claims Claims
+	var claims *CustomTokenClaims
 
 	if isRefresh {
-		claims = tokenClaims(tokenId, expTime, ipAddr, true)
+		claims = tokenClaims(tokenId, expTime, ipAddr, tenant, nil, true)
 	} else {
-		claims = tokenClaims(tokenId, expTime, ipAddr, false)
+		claims = tokenClaims(tokenId, expTime, ipAddr, tenant, access, false)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenStr, err := token.SignedString(secret)
+	tokenStr, err := signTenantToken(claims)
 
 	if err != nil {
 		return "", fmt.Errorf("\n%s: %w", op, err)
@@ -78,9 +125,7 @@ func GenerateToken(tokenId, ipAddr string, exp time.Duration, isRefresh bool) (s
 }
 
 func GetToken(claims Claims, tokenStr string) (*jwt.Token, error) {
-	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (any, error) {
-		return []byte(os.Getenv("SECRET")), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenStr, claims, verificationKey)
 
 	if err != nil {
 		return nil, err
@@ -107,3 +152,127 @@ func GetAndValidateTokenClaims(tokenStr string, isRefresh bool) (*CustomTokenCla
 
 	return claims, err
 }
+
+// Generates short-lived token that proves the first factor was passed for user with guid
+func GenerateMFAChallengeToken(userGuid, ipAddr, tenant, authMethod string, exp time.Duration) (string, error) {
+	const op = "jwt.GenerateMFAChallengeToken"
+
+	claims := &CustomTokenClaims{
+		IpAddr:       ipAddr,
+		MFAChallenge: true,
+		Tenant:       tenant,
+		AuthMethod:   authMethod,
+		RegisteredClaims: jwt.RegisteredClaims{
+			// jti lets the challenge be completed only once
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(exp)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Subject:   userGuid,
+		},
+	}
+
+	tokenStr, err := signTenantToken(claims)
+
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokenStr, nil
+}
+
+func GetAndValidateMFAChallengeClaims(tokenStr string) (*CustomTokenClaims, error) {
+	const op = "jwt.GetAndValidateMFAChallengeClaims"
+
+	token, err := GetToken(&CustomTokenClaims{}, tokenStr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*CustomTokenClaims)
+
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("%s: %w", op, jwt.ErrTokenInvalidClaims)
+	}
+
+	if !claims.MFAChallenge || claims.IsRefresh || claims.Subject == "" || claims.ID == "" {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
+	return claims, nil
+}
+
+// Generates access token for oauth client acting on its own behalf (client_credentials grant)
+func GenerateServiceToken(clientID, tenant, scope string, exp time.Duration) (string, error) {
+	const op = "jwt.GenerateServiceToken"
+
+	claims := &CustomTokenClaims{
+		ServiceToken: true,
+		ClientID:     clientID,
+		Tenant:       tenant,
+		Scope:        scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(exp)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Subject:   clientID,
+		},
+	}
+
+	tokenStr, err := signTenantToken(claims)
+
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokenStr, nil
+}
+
+// Generates access token for another audience on behalf of subject token owner (token exchange grant).
+// Roles and groups of the subject token are carried over as they are, so the audience authorizes the
+// same user the subject token was issued for, they are never recomputed or widened
+func GenerateExchangedToken(subject *CustomTokenClaims, clientID, scope string, audience []string, act *Actor, exp time.Duration) (string, error) {
+	const op = "jwt.GenerateExchangedToken"
+
+	claims := &CustomTokenClaims{
+		IpAddr:        subject.IpAddr,
+		ServiceToken:  subject.ServiceToken,
+		ClientID:      clientID,
+		Tenant:        subject.Tenant,
+		Scope:         scope,
+		Roles:         subject.Roles,
+		Groups:        subject.Groups,
+		GroupsOverage: subject.GroupsOverage,
+		Act:           act,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(exp)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Subject:   subject.Subject,
+			Audience:  audience,
+		},
+	}
+
+	tokenStr, err := signTenantToken(claims)
+
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokenStr, nil
+}
+
+// Generates access token for session administrator opened as the user, act names the administrator.
+// No refresh token is paired with it
+func GenerateImpersonationToken(tokenId, ipAddr, tenant string, access *AccessClaims, act *Actor, exp time.Duration) (string, error) {
+	const op = "jwt.GenerateImpersonationToken"
+
+	claims := tokenClaims(tokenId, jwt.NewNumericDate(time.Now().Add(exp)), ipAddr, tenant, access, false)
+	claims.Act = act
+
+	tokenStr, err := signTenantToken(claims)
+
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokenStr, nil
+}
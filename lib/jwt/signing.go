@@ -0,0 +1,156 @@
+package jwtp
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrNoSigningKey = errors.New("signing key isn't loaded")
+
+// asymmetric key for tokens verified by third parties (id tokens), published via jwks
+type SigningKey struct {
+	ID         string
+	PrivateKey *rsa.PrivateKey
+}
+
+var (
+	signingKeyMu sync.RWMutex
+	signingKey   *SigningKey
+)
+
+// Loads RSA key from PEM file (PKCS#1 or PKCS#8). Empty path generates an ephemeral key,
+// tokens signed with it become unverifiable after restart
+func LoadSigningKey(path string) error {
+	const op = "jwt.LoadSigningKey"
+
+	var key *SigningKey
+	var err error
+	if path == "" {
+		key, err = NewSigningKey()
+	} else {
+		var raw []byte
+		raw, err = os.ReadFile(path)
+		if err == nil {
+			key, err = ParseSigningKey(raw)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	signingKeyMu.Lock()
+	signingKey = key
+	signingKeyMu.Unlock()
+	return nil
+}
+
+// Generates new 2048 bit RSA signing key
+func NewSigningKey() (*SigningKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return newSigningKey(key)
+}
+
+// Parses PEM encoded RSA key (PKCS#1 or PKCS#8)
+func ParseSigningKey(raw []byte) (*SigningKey, error) {
+	key, err := parseRSAPrivateKey(raw)
+	if err != nil {
+		return nil, err
+	}
+	return newSigningKey(key)
+}
+
+// PEM encoding of private key in PKCS#1 form, readable by ParseSigningKey
+func (k *SigningKey) EncodePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k.PrivateKey)})
+}
+
+func newSigningKey(key *rsa.PrivateKey) (*SigningKey, error) {
+	keyID, err := rsaKeyID(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{ID: keyID, PrivateKey: key}, nil
+}
+
+func MustLoadSigningKey(path string) {
+	if err := LoadSigningKey(path); err != nil {
+		panic(err)
+	}
+}
+
+func CurrentSigningKey() (*SigningKey, error) {
+	signingKeyMu.RLock()
+	defer signingKeyMu.RUnlock()
+
+	if signingKey == nil {
+		return nil, ErrNoSigningKey
+	}
+	return signingKey, nil
+}
+
+// Signs arbitrary claims with RS256 and active key of the tenant, current signing key is used
+// for tenants without own key set. kid is put into header
+func GenerateSignedToken(tenant string, claims jwt.Claims) (string, error) {
+	const op = "jwt.GenerateSignedToken"
+
+	var key *SigningKey
+	if set, ok := TenantKeys(tenant); ok {
+		key = set.Keys[0]
+	} else {
+		current, err := CurrentSigningKey()
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+		key = current
+	}
+
+	tokenStr, err := signRS256(key, claims)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return tokenStr, nil
+}
+
+func parseRSAPrivateKey(raw []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no pem block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("signing key must be RSA")
+	}
+	return key, nil
+}
+
+// kid is derived from the public key so it stays stable across restarts with the same key file
+func rsaKeyID(pub *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
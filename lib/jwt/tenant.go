@@ -0,0 +1,135 @@
+package jwtp
+
+import (
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnknownIssuer = errors.New("token issuer isn't known")
+
+// tenant of tokens issued without tenant claim, same as entities.DefaultTenantID
+const defaultTenant = "default"
+
+// signing keys of a tenant with its own issuer, the first key signs new tokens and the rest
+// are kept so tokens signed before rotation stay verifiable
+type TenantKeySet struct {
+	Tenant string
+	Issuer string
+	Keys   []*SigningKey
+}
+
+var (
+	tenantKeysMu sync.RWMutex
+	tenantKeys   = map[string]*TenantKeySet{}
+	issuerKeys   = map[string]*TenantKeySet{}
+)
+
+// Replaces registered tenant key sets, tenants without keys are signed with SECRET and have no issuer
+func SetTenantKeySets(sets []*TenantKeySet) {
+	byTenant := make(map[string]*TenantKeySet, len(sets))
+	byIssuer := make(map[string]*TenantKeySet, len(sets))
+	for _, set := range sets {
+		if len(set.Keys) == 0 {
+			continue
+		}
+		byTenant[set.Tenant] = set
+		byIssuer[set.Issuer] = set
+	}
+
+	tenantKeysMu.Lock()
+	tenantKeys = byTenant
+	issuerKeys = byIssuer
+	tenantKeysMu.Unlock()
+}
+
+func TenantKeys(tenant string) (*TenantKeySet, bool) {
+	tenantKeysMu.RLock()
+	defer tenantKeysMu.RUnlock()
+
+	set, ok := tenantKeys[tenant]
+	return set, ok
+}
+
+func issuerKeySet(issuer string) (*TenantKeySet, bool) {
+	tenantKeysMu.RLock()
+	defer tenantKeysMu.RUnlock()
+
+	set, ok := issuerKeys[issuer]
+	return set, ok
+}
+
+func (set *TenantKeySet) key(kid string) (*SigningKey, bool) {
+	for _, key := range set.Keys {
+		if key.ID == kid {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// Resolves verification key from iss and kid. Tokens without issuer are signed with SECRET,
+// tokens of tenants with own issuer must be signed by one of its keys and belong to that tenant
+func verificationKey(token *jwt.Token) (any, error) {
+	issuer, err := token.Claims.GetIssuer()
+	if err != nil {
+		return nil, err
+	}
+	if issuer == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrTokenSignatureInvalid
+		}
+		// tenant with own key set never gets SECRET signed tokens, dropping iss must not downgrade to it
+		if claims, ok := token.Claims.(*CustomTokenClaims); ok {
+			if _, ok := TenantKeys(claimsTenant(claims)); ok {
+				return nil, jwt.ErrTokenSignatureInvalid
+			}
+		}
+		return []byte(os.Getenv("SECRET")), nil
+	}
+
+	set, ok := issuerKeySet(issuer)
+	if !ok {
+		return nil, ErrUnknownIssuer
+	}
+	if token.Method != jwt.SigningMethodRS256 {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+	if claims, ok := token.Claims.(*CustomTokenClaims); ok && claimsTenant(claims) != set.Tenant {
+		return nil, jwt.ErrTokenInvalidIssuer
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := set.key(kid)
+	if !ok {
+		return nil, jwt.ErrTokenUnverifiable
+	}
+	return &key.PrivateKey.PublicKey, nil
+}
+
+// Signs claims with active key of the tenant and sets its issuer, HS256 with SECRET is used for tenants
+// without own key set
+func signTenantToken(claims *CustomTokenClaims) (string, error) {
+	set, ok := TenantKeys(claimsTenant(claims))
+	if !ok {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(os.Getenv("SECRET")))
+	}
+
+	claims.Issuer = set.Issuer
+	return signRS256(set.Keys[0], claims)
+}
+
+func claimsTenant(claims *CustomTokenClaims) string {
+	if claims.Tenant == "" {
+		return defaultTenant
+	}
+	return claims.Tenant
+}
+
+func signRS256(key *SigningKey, claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
@@ -0,0 +1,78 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"testovoe_medods/config"
+)
+
+type Sender interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+type smtpSender struct {
+	cfg config.SMTP
+}
+
+type logSender struct {
+	log *slog.Logger
+	// bodies carry live reset links and login codes, they are logged only in local environment
+	withBody bool
+}
+
+// returns smtp sender if smtp host is configured, otherwise emails are only logged
+func NewSender(cfg *config.Config, log *slog.Logger) Sender {
+	if cfg.SMTP.Host == "" {
+		return &logSender{log: log, withBody: cfg.Env == "local"}
+	}
+	return &smtpSender{cfg: cfg.SMTP}
+}
+
+func (s *smtpSender) Send(ctx context.Context, to, subject, body string) error {
+	const op = "mail.Send"
+
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return fmt.Errorf("%s: invalid header value", op)
+	}
+
+	msg := "From: " + s.cfg.From + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=\"utf-8\"\r\n" +
+		"\r\n" + body
+
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+
+	addr := s.cfg.Host + ":" + strconv.Itoa(s.cfg.Port)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- smtp.SendMail(addr, auth, s.cfg.From, []string{to}, []byte(msg))
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	}
+}
+
+func (s *logSender) Send(ctx context.Context, to, subject, body string) error {
+	if s.withBody {
+		s.log.Debug("mail.Send", slog.String("to", to), slog.String("subject", subject), slog.String("body", body))
+		return nil
+	}
+	s.log.Info("mail.Send", slog.String("to", to), slog.String("subject", subject))
+	return nil
+}
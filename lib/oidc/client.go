@@ -0,0 +1,241 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidIDToken = errors.New("invalid id token")
+var ErrTokenExchange = errors.New("authorization code exchange failed")
+
+// subset of OpenID Provider Metadata, used both by relying party and by our own provider
+type Discovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint,omitempty"`
+	JWKSURI               string   `json:"jwks_uri"`
+	ScopesSupported       []string `json:"scopes_supported,omitempty"`
+	ResponseTypes         []string `json:"response_types_supported"`
+	SubjectTypes          []string `json:"subject_types_supported"`
+	IDTokenSigningAlgs    []string `json:"id_token_signing_alg_values_supported"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported,omitempty"`
+	GrantTypes            []string `json:"grant_types_supported,omitempty"`
+	TokenEndpointAuth     []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+	ClaimsSupported       []string `json:"claims_supported,omitempty"`
+	DeviceEndpoint        string   `json:"device_authorization_endpoint,omitempty"`
+	PAREndpoint           string   `json:"pushed_authorization_request_endpoint,omitempty"`
+	IntrospectionEndpoint string   `json:"introspection_endpoint,omitempty"`
+	BackchannelLogout     bool     `json:"backchannel_logout_supported,omitempty"`
+	BackchannelLogoutSID  bool     `json:"backchannel_logout_session_supported,omitempty"`
+}
+
+// event type of logout tokens (OpenID Connect Back-Channel Logout 1.0)
+const BackchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+type LogoutTokenClaims struct {
+	SessionID string              `json:"sid,omitempty"`
+	Events    map[string]struct{} `json:"events"`
+	jwt.RegisteredClaims
+}
+
+type IDTokenClaims struct {
+	Nonce           string `json:"nonce,omitempty"`
+	Email           string `json:"email,omitempty"`
+	EmailVerified   bool   `json:"email_verified,omitempty"`
+	AuthorizedParty string `json:"azp,omitempty"`
+	SessionID       string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+type ClientConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// relying party client for a single upstream provider
+type Client struct {
+	cfg       ClientConfig
+	http      *http.Client
+	discovery *Discovery
+	keys      *RemoteKeySet
+}
+
+// signing algorithms accepted in id tokens
+var idTokenAlgs = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "PS256"}
+
+// fetches provider metadata. httpClient is injectable so tests can talk to in-process providers
+func NewClient(ctx context.Context, cfg ClientConfig, httpClient *http.Client) (*Client, error) {
+	const op = "oidc.NewClient"
+
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	discovery, err := FetchDiscovery(ctx, httpClient, cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Client{
+		cfg:       cfg,
+		http:      httpClient,
+		discovery: discovery,
+		keys:      NewRemoteKeySet(discovery.JWKSURI, httpClient),
+	}, nil
+}
+
+func FetchDiscovery(ctx context.Context, httpClient *http.Client, issuer string) (*Discovery, error) {
+	const op = "oidc.FetchDiscovery"
+
+	wellKnown := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status %d", op, resp.StatusCode)
+	}
+
+	var d Discovery
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&d); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// issuer in metadata must be exactly the one we were configured with (OIDC Discovery 4.3)
+	if d.Issuer != issuer {
+		return nil, fmt.Errorf("%s: issuer mismatch %q", op, d.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("%s: incomplete provider metadata", op)
+	}
+	return &d, nil
+}
+
+// builds authorization code request with PKCE S256
+func (c *Client) AuthCodeURL(state, nonce, codeVerifier string) string {
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", c.cfg.ClientID)
+	v.Set("redirect_uri", c.cfg.RedirectURL)
+	v.Set("scope", strings.Join(c.scopes(), " "))
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+	v.Set("code_challenge", S256Challenge(codeVerifier))
+	v.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(c.discovery.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return c.discovery.AuthorizationEndpoint + sep + v.Encode()
+}
+
+// openid scope is mandatory for an id token to be returned
+func (c *Client) scopes() []string {
+	for _, s := range c.cfg.Scopes {
+		if s == "openid" {
+			return c.cfg.Scopes
+		}
+	}
+	return append([]string{"openid"}, c.cfg.Scopes...)
+}
+
+func (c *Client) Exchange(ctx context.Context, code, codeVerifier string) (*TokenResponse, error) {
+	const op = "oidc.Exchange"
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.cfg.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.cfg.ClientID), url.QueryEscape(c.cfg.ClientSecret))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %w: status %d", op, ErrTokenExchange, resp.StatusCode)
+	}
+
+	var tokens TokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%s: %w: id_token is missing", op, ErrTokenExchange)
+	}
+	return &tokens, nil
+}
+
+// verifies id token signature, issuer, audience, expiry and nonce
+func (c *Client) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error) {
+	const op = "oidc.VerifyIDToken"
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(idTokenAlgs),
+		jwt.WithIssuer(c.discovery.Issuer),
+		jwt.WithAudience(c.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+
+	var claims IDTokenClaims
+	_, err := parser.ParseWithClaims(rawIDToken, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return c.keys.Key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", op, ErrInvalidIDToken, err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%s: %w: sub is missing", op, ErrInvalidIDToken)
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != c.cfg.ClientID {
+		return nil, fmt.Errorf("%s: %w: azp mismatch", op, ErrInvalidIDToken)
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%s: %w: nonce mismatch", op, ErrInvalidIDToken)
+	}
+	return &claims, nil
+}
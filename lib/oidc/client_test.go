@@ -0,0 +1,134 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+
+	"testovoe_medods/lib/oidc"
+	"testovoe_medods/lib/oidc/oidctest"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID     = "relying-party"
+	testClientSecret = "s3cr3t:with/escapes"
+	testNonce        = "nonce-value"
+	testVerifier     = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+func newProvider(t *testing.T) (*oidctest.Provider, *oidc.Client) {
+	t.Helper()
+	p, err := oidctest.NewProvider(testClientID, testClientSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+
+	c, err := oidc.NewClient(context.Background(), oidc.ClientConfig{
+		Issuer:       p.Issuer,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  "https://rp.example/callback",
+	}, p.Server.Client())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return p, c
+}
+
+// runs the whole code flow and returns id token issued by the provider
+func login(t *testing.T, p *oidctest.Provider, c *oidc.Client, claims map[string]any) string {
+	t.Helper()
+	code, state, err := p.Authorize(c.AuthCodeURL("state-value", testNonce, testVerifier), "upstream-subject", claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != "state-value" {
+		t.Fatalf("state = %q", state)
+	}
+	tokens, err := c.Exchange(context.Background(), code, testVerifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	return tokens.IDToken
+}
+
+func TestCodeExchange(t *testing.T) {
+	p, c := newProvider(t)
+
+	idToken := login(t, p, c, map[string]any{"email": "user@example.com", "email_verified": true})
+	claims, err := c.VerifyIDToken(context.Background(), idToken, testNonce)
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if claims.Subject != "upstream-subject" || claims.Email != "user@example.com" || !claims.EmailVerified {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+}
+
+func TestCodeExchangeRejectsWrongVerifier(t *testing.T) {
+	p, c := newProvider(t)
+
+	code, _, err := p.Authorize(c.AuthCodeURL("state-value", testNonce, testVerifier), "upstream-subject", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Exchange(context.Background(), code, testVerifier+"x"); !errors.Is(err, oidc.ErrTokenExchange) {
+		t.Fatalf("err = %v, want %v", err, oidc.ErrTokenExchange)
+	}
+}
+
+func TestDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	p, _ := newProvider(t)
+
+	if _, err := oidc.FetchDiscovery(context.Background(), p.Server.Client(), p.Issuer+"/other"); err == nil {
+		t.Fatal("discovery for foreign issuer was accepted")
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	forger, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		nonce string
+		setup func(p *oidctest.Provider)
+	}{
+		{name: "bad signature", nonce: testNonce, setup: func(p *oidctest.Provider) { p.SigningKey = forger }},
+		{name: "nonce mismatch", nonce: "other-nonce"},
+		{name: "aud mismatch", nonce: testNonce, setup: func(p *oidctest.Provider) {
+			p.Claims = func(claims jwt.MapClaims) { claims["aud"] = "other-client" }
+		}},
+		{name: "iss mismatch", nonce: testNonce, setup: func(p *oidctest.Provider) {
+			p.Claims = func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example" }
+		}},
+		{name: "azp mismatch", nonce: testNonce, setup: func(p *oidctest.Provider) {
+			p.Claims = func(claims jwt.MapClaims) {
+				claims["aud"] = []string{testClientID, "other-client"}
+				claims["azp"] = "other-client"
+			}
+		}},
+		{name: "expired", nonce: testNonce, setup: func(p *oidctest.Provider) {
+			p.Claims = func(claims jwt.MapClaims) { claims["exp"] = claims["iat"].(int64) - 3600 }
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, c := newProvider(t)
+			if tt.setup != nil {
+				tt.setup(p)
+			}
+			idToken := login(t, p, c, nil)
+			if _, err := c.VerifyIDToken(context.Background(), idToken, tt.nonce); !errors.Is(err, oidc.ErrInvalidIDToken) {
+				t.Fatalf("err = %v, want %v", err, oidc.ErrInvalidIDToken)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidFilter = errors.New("filter syntax is invalid or not supported")
+var ErrInvalidPath = errors.New("path syntax is invalid or not supported")
+
+// single attribute comparison of RFC 7644 filter, e.g. userName eq "bjensen".
+// Logical operators and grouping aren't supported
+type Filter struct {
+	Attribute string
+	// lower-cased comparison operator
+	Operator string
+	// string value with quotes removed, literals true, false, null and numbers as written
+	Value string
+}
+
+var operators = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"gt": true, "ge": true, "lt": true, "le": true, "pr": true,
+}
+
+func ParseFilter(raw string) (*Filter, error) {
+	raw = strings.TrimSpace(raw)
+	attr, rest, _ := strings.Cut(raw, " ")
+	op, value, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	op = strings.ToLower(op)
+	value = strings.TrimSpace(value)
+
+	if !validAttrPath(attr) || !operators[op] {
+		return nil, ErrInvalidFilter
+	}
+	if op == "pr" {
+		if value != "" {
+			return nil, ErrInvalidFilter
+		}
+		return &Filter{Attribute: attr, Operator: op}, nil
+	}
+
+	if strings.HasPrefix(value, `"`) {
+		var s string
+		if err := json.Unmarshal([]byte(value), &s); err != nil {
+			return nil, ErrInvalidFilter
+		}
+		return &Filter{Attribute: attr, Operator: op, Value: s}, nil
+	}
+	// literals have no spaces, anything else means logical expression we don't support
+	if value == "" || strings.ContainsAny(value, " ()[]") {
+		return nil, ErrInvalidFilter
+	}
+	return &Filter{Attribute: attr, Operator: op, Value: value}, nil
+}
+
+// patch operation target, e.g. members[value eq "2819c223"] or emails[type eq "work"].value
+type Path struct {
+	Attribute string
+	// set when path selects values of multi-valued attribute
+	Filter       *Filter
+	SubAttribute string
+}
+
+func ParsePath(raw string) (*Path, error) {
+	raw = strings.TrimSpace(raw)
+	open := strings.IndexByte(raw, '[')
+	if open < 0 {
+		if !validAttrPath(raw) {
+			return nil, ErrInvalidPath
+		}
+		attr, sub, _ := strings.Cut(raw, ".")
+		// sub-attributes of core schema only, extension urns contain dots themselves
+		if strings.Contains(attr, ":") {
+			return &Path{Attribute: raw}, nil
+		}
+		return &Path{Attribute: attr, SubAttribute: sub}, nil
+	}
+
+	closing := strings.LastIndexByte(raw, ']')
+	if closing < open {
+		return nil, ErrInvalidPath
+	}
+	attr := raw[:open]
+	filter, err := ParseFilter(raw[open+1 : closing])
+	if err != nil || !validAttrPath(attr) {
+		return nil, ErrInvalidPath
+	}
+	path := &Path{Attribute: attr, Filter: filter}
+	if rest := raw[closing+1:]; rest != "" {
+		if !strings.HasPrefix(rest, ".") || !validAttrPath(rest[1:]) {
+			return nil, ErrInvalidPath
+		}
+		path.SubAttribute = rest[1:]
+	}
+	return path, nil
+}
+
+func validAttrPath(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '.', r == ':', r == '_', r == '-', r == '$':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"slices"
+)
+
+// id-fido-gen-ce-aaguid certificate extension
+var oidFIDOAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+// verifies "packed" attestation statement, both basic (x5c) and self attestation.
+// Attestation certificates aren't chained to trust anchors: metadata service isn't used,
+// so only statement integrity and certificate requirements from the spec are checked
+func verifyPackedAttestation(attStmt map[any]any, authData, clientDataHash []byte, ad *AuthenticatorData, credKey crypto.PublicKey, credAlg int64) error {
+	alg, ok := attStmt["alg"].(int64)
+	if !ok {
+		return ErrInvalidAttestation
+	}
+	sig, ok := attStmt["sig"].([]byte)
+	if !ok {
+		return ErrInvalidAttestation
+	}
+	signed := append(append([]byte{}, authData...), clientDataHash...)
+
+	x5c, hasX5C := attStmt["x5c"].([]any)
+	if !hasX5C {
+		// self attestation is signed by the credential key itself
+		if alg != credAlg {
+			return ErrInvalidAttestation
+		}
+		if err := verifySignature(credKey, alg, signed, sig); err != nil {
+			return ErrInvalidAttestation
+		}
+		return nil
+	}
+
+	if len(x5c) == 0 {
+		return ErrInvalidAttestation
+	}
+	der, ok := x5c[0].([]byte)
+	if !ok {
+		return ErrInvalidAttestation
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return ErrInvalidAttestation
+	}
+
+	if err := verifySignature(cert.PublicKey, alg, signed, sig); err != nil {
+		return ErrInvalidAttestation
+	}
+
+	if cert.Version != 3 || cert.IsCA || len(cert.Subject.Country) == 0 || len(cert.Subject.Organization) == 0 ||
+		cert.Subject.CommonName == "" || !slices.Contains(cert.Subject.OrganizationalUnit, "Authenticator Attestation") {
+		return ErrInvalidAttestation
+	}
+
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidFIDOAAGUID) {
+			continue
+		}
+		var aaguid []byte
+		if _, err := asn1.Unmarshal(ext.Value, &aaguid); err != nil || !bytes.Equal(aaguid, ad.AAGUID) {
+			return ErrInvalidAttestation
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var ErrInvalidCBOR = errors.New("invalid cbor")
+
+// maximum nesting accepted from authenticators, real payloads are 2-3 levels deep
+const maxCBORDepth = 16
+
+// decodes single cbor item from the beginning of data and returns it with amount of consumed bytes.
+// Only the subset needed for webauthn is supported: integers, byte/text strings, arrays, maps,
+// tags (ignored) and simple values. Integers are returned as int64, maps as map[any]any
+func decodeCBOR(data []byte) (any, int, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (any, int, error) {
+	if depth > maxCBORDepth || len(data) == 0 {
+		return nil, 0, ErrInvalidCBOR
+	}
+
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+
+	if major == 7 {
+		return decodeCBORSimple(data, info)
+	}
+
+	arg, n, err := cborArgument(data, info)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, 0, ErrInvalidCBOR
+		}
+		return int64(arg), n, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, 0, ErrInvalidCBOR
+		}
+		return -1 - int64(arg), n, nil
+	case 2, 3:
+		if arg > uint64(len(data)-n) {
+			return nil, 0, ErrInvalidCBOR
+		}
+		end := n + int(arg)
+		if major == 2 {
+			b := make([]byte, arg)
+			copy(b, data[n:end])
+			return b, end, nil
+		}
+		return string(data[n:end]), end, nil
+	case 4:
+		if arg > uint64(len(data)) {
+			return nil, 0, ErrInvalidCBOR
+		}
+		arr := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, m, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			arr = append(arr, item)
+			n += m
+		}
+		return arr, n, nil
+	case 5:
+		if arg > uint64(len(data)) {
+			return nil, 0, ErrInvalidCBOR
+		}
+		m := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, kn, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += kn
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, 0, ErrInvalidCBOR
+			}
+			value, vn, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += vn
+			m[key] = value
+		}
+		return m, n, nil
+	case 6:
+		item, m, err := decodeCBORItem(data[n:], depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		return item, n + m, nil
+	}
+	return nil, 0, ErrInvalidCBOR
+}
+
+// indefinite lengths (info 31) are not allowed in ctap2 canonical cbor and are rejected
+func cborArgument(data []byte, info byte) (uint64, int, error) {
+	switch {
+	case info < 24:
+		return uint64(info), 1, nil
+	case info == 24:
+		if len(data) < 2 {
+			return 0, 0, ErrInvalidCBOR
+		}
+		return uint64(data[1]), 2, nil
+	case info == 25:
+		if len(data) < 3 {
+			return 0, 0, ErrInvalidCBOR
+		}
+		return uint64(binary.BigEndian.Uint16(data[1:3])), 3, nil
+	case info == 26:
+		if len(data) < 5 {
+			return 0, 0, ErrInvalidCBOR
+		}
+		return uint64(binary.BigEndian.Uint32(data[1:5])), 5, nil
+	case info == 27:
+		if len(data) < 9 {
+			return 0, 0, ErrInvalidCBOR
+		}
+		return binary.BigEndian.Uint64(data[1:9]), 9, nil
+	}
+	return 0, 0, ErrInvalidCBOR
+}
+
+func decodeCBORSimple(data []byte, info byte) (any, int, error) {
+	switch info {
+	case 20:
+		return false, 1, nil
+	case 21:
+		return true, 1, nil
+	case 22, 23:
+		return nil, 1, nil
+	case 25:
+		// half precision floats never appear in webauthn structures, value is skipped
+		if len(data) < 3 {
+			return nil, 0, ErrInvalidCBOR
+		}
+		return nil, 3, nil
+	case 26:
+		if len(data) < 5 {
+			return nil, 0, ErrInvalidCBOR
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data[1:5]))), 5, nil
+	case 27:
+		if len(data) < 9 {
+			return nil, 0, ErrInvalidCBOR
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(data[1:9])), 9, nil
+	}
+	return nil, 0, ErrInvalidCBOR
+}
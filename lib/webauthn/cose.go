@@ -0,0 +1,105 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers supported for credentials
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+const (
+	coseKeyTypeOKP int64 = 1
+	coseKeyTypeEC2 int64 = 2
+	coseKeyTypeRSA int64 = 3
+
+	coseCurveP256    int64 = 1
+	coseCurveEd25519 int64 = 6
+)
+
+var ErrUnsupportedKey = errors.New("unsupported credential public key")
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// parses COSE_Key and returns go public key with its COSE algorithm
+func ParseCOSEKey(raw []byte) (crypto.PublicKey, int64, error) {
+	const op = "webauthn.ParseCOSEKey"
+
+	item, _, err := decodeCBOR(raw)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	key, ok := item.(map[any]any)
+	if !ok {
+		return nil, 0, fmt.Errorf("%s: %w", op, ErrUnsupportedKey)
+	}
+
+	kty, _ := key[int64(1)].(int64)
+	alg, _ := key[int64(3)].(int64)
+
+	switch kty {
+	case coseKeyTypeEC2:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if alg != AlgES256 || crv != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, fmt.Errorf("%s: %w", op, ErrUnsupportedKey)
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, 0, fmt.Errorf("%s: %w", op, ErrUnsupportedKey)
+		}
+		return pub, alg, nil
+	case coseKeyTypeRSA:
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		if alg != AlgRS256 || len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, fmt.Errorf("%s: %w", op, ErrUnsupportedKey)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, alg, nil
+	case coseKeyTypeOKP:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		if alg != AlgEdDSA || crv != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, fmt.Errorf("%s: %w", op, ErrUnsupportedKey)
+		}
+		return ed25519.PublicKey(x), alg, nil
+	}
+	return nil, 0, fmt.Errorf("%s: %w", op, ErrUnsupportedKey)
+}
+
+func verifySignature(pub crypto.PublicKey, alg int64, data, sig []byte) error {
+	switch alg {
+	case AlgES256:
+		k, ok := pub.(*ecdsa.PublicKey)
+		digest := sha256.Sum256(data)
+		if !ok || !ecdsa.VerifyASN1(k, digest[:], sig) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case AlgRS256:
+		k, ok := pub.(*rsa.PublicKey)
+		digest := sha256.Sum256(data)
+		if !ok || rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+	case AlgEdDSA:
+		k, ok := pub.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(k, data, sig) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+	return ErrUnsupportedKey
+}
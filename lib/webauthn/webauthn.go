@@ -0,0 +1,249 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+const (
+	CeremonyCreate = "webauthn.create"
+	CeremonyGet    = "webauthn.get"
+)
+
+const (
+	flagUserPresent  byte = 1 << 0
+	flagUserVerified byte = 1 << 2
+	flagAttestedData byte = 1 << 6
+)
+
+var (
+	ErrInvalidClientData   = errors.New("invalid client data")
+	ErrChallengeMismatch   = errors.New("challenge mismatch")
+	ErrOriginMismatch      = errors.New("origin isn't allowed")
+	ErrInvalidAuthData     = errors.New("invalid authenticator data")
+	ErrRPIDMismatch        = errors.New("rp id hash mismatch")
+	ErrUserNotPresent      = errors.New("user presence flag isn't set")
+	ErrUserNotVerified     = errors.New("user verification is required")
+	ErrInvalidAttestation  = errors.New("invalid attestation")
+	ErrUnsupportedFormat   = errors.New("unsupported attestation format")
+	ErrSignCountRegression = errors.New("signature counter didn't increase, authenticator may be cloned")
+)
+
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+	// require UV flag, otherwise only user presence is checked
+	RequireUserVerification bool
+}
+
+type ClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type AuthenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+// result of successful registration ceremony
+type Credential struct {
+	ID             []byte
+	PublicKey      []byte
+	Alg            int64
+	SignCount      uint32
+	AAGUID         []byte
+	AttestationFmt string
+}
+
+func ParseClientData(raw []byte) (*ClientData, error) {
+	var cd ClientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return nil, ErrInvalidClientData
+	}
+	if cd.Challenge == "" || cd.Type == "" {
+		return nil, ErrInvalidClientData
+	}
+	return &cd, nil
+}
+
+func ParseAuthenticatorData(raw []byte) (*AuthenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, ErrInvalidAuthData
+	}
+
+	ad := &AuthenticatorData{
+		RPIDHash:  raw[:32],
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+
+	if ad.Flags&flagAttestedData == 0 {
+		return ad, nil
+	}
+
+	rest := raw[37:]
+	if len(rest) < 18 {
+		return nil, ErrInvalidAuthData
+	}
+	ad.AAGUID = rest[:16]
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+		return nil, ErrInvalidAuthData
+	}
+	ad.CredentialID = rest[:idLen]
+	rest = rest[idLen:]
+
+	_, n, err := decodeCBOR(rest)
+	if err != nil {
+		return nil, ErrInvalidAuthData
+	}
+	ad.PublicKey = rest[:n]
+	return ad, nil
+}
+
+func (rp *RelyingParty) verifyClientData(cd *ClientData, ceremony, challenge string) error {
+	if cd.Type != ceremony {
+		return ErrInvalidClientData
+	}
+	if subtle.ConstantTimeCompare([]byte(cd.Challenge), []byte(challenge)) != 1 {
+		return ErrChallengeMismatch
+	}
+	if !slices.Contains(rp.Origins, cd.Origin) {
+		return ErrOriginMismatch
+	}
+	return nil
+}
+
+func (rp *RelyingParty) verifyAuthData(ad *AuthenticatorData) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(ad.RPIDHash, rpIDHash[:]) {
+		return ErrRPIDMismatch
+	}
+	if ad.Flags&flagUserPresent == 0 {
+		return ErrUserNotPresent
+	}
+	if rp.RequireUserVerification && ad.Flags&flagUserVerified == 0 {
+		return ErrUserNotVerified
+	}
+	return nil
+}
+
+// verifies attestation response of navigator.credentials.create() against challenge issued for it
+func (rp *RelyingParty) VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte) (*Credential, error) {
+	const op = "webauthn.VerifyRegistration"
+
+	cd, err := ParseClientData(clientDataJSON)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := rp.verifyClientData(cd, CeremonyCreate, challenge); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	item, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidAttestation)
+	}
+	attObj, ok := item.(map[any]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidAttestation)
+	}
+	format, _ := attObj["fmt"].(string)
+	rawAuthData, _ := attObj["authData"].([]byte)
+	attStmt, _ := attObj["attStmt"].(map[any]any)
+	if rawAuthData == nil || attStmt == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidAttestation)
+	}
+
+	ad, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := rp.verifyAuthData(ad); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if ad.CredentialID == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidAuthData)
+	}
+
+	pub, alg, err := ParseCOSEKey(ad.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	switch format {
+	case "none":
+		if len(attStmt) != 0 {
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidAttestation)
+		}
+	case "packed":
+		if err := verifyPackedAttestation(attStmt, rawAuthData, clientDataHash[:], ad, pub, alg); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	default:
+		return nil, fmt.Errorf("%s: %w", op, ErrUnsupportedFormat)
+	}
+
+	return &Credential{
+		ID:             ad.CredentialID,
+		PublicKey:      ad.PublicKey,
+		Alg:            alg,
+		SignCount:      ad.SignCount,
+		AAGUID:         ad.AAGUID,
+		AttestationFmt: format,
+	}, nil
+}
+
+// verifies assertion response of navigator.credentials.get() with the stored credential key
+// and returns new signature counter
+func (rp *RelyingParty) VerifyAssertion(challenge string, publicKey []byte, storedSignCount uint32, clientDataJSON, authenticatorData, signature []byte) (uint32, error) {
+	const op = "webauthn.VerifyAssertion"
+
+	cd, err := ParseClientData(clientDataJSON)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := rp.verifyClientData(cd, CeremonyGet, challenge); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ad, err := ParseAuthenticatorData(authenticatorData)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := rp.verifyAuthData(ad); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	pub, alg, err := ParseCOSEKey(publicKey)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authenticatorData...), clientDataHash[:]...)
+	if err := verifySignature(pub, alg, signed, signature); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// authenticators that don't implement counters always send 0
+	if (ad.SignCount != 0 || storedSignCount != 0) && ad.SignCount <= storedSignCount {
+		return 0, fmt.Errorf("%s: %w", op, ErrSignCountRegression)
+	}
+	return ad.SignCount, nil
+}
@@ -0,0 +1,225 @@
+package webauthn_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"testovoe_medods/lib/webauthn"
+	"testovoe_medods/lib/webauthn/webauthntest"
+)
+
+const (
+	testRPID      = "example.com"
+	testOrigin    = "https://example.com"
+	testChallenge = "c2VydmVyLWNoYWxsZW5nZQ"
+)
+
+func newRelyingParty() *webauthn.RelyingParty {
+	return &webauthn.RelyingParty{
+		ID:                      testRPID,
+		Name:                    "example",
+		Origins:                 []string{testOrigin},
+		RequireUserVerification: true,
+	}
+}
+
+func register(t *testing.T, rp *webauthn.RelyingParty, a *webauthntest.Authenticator, format string) *webauthn.Credential {
+	t.Helper()
+	clientData, attestation, err := a.Create(format, testRPID, testOrigin, testChallenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := rp.VerifyRegistration(testChallenge, clientData, attestation)
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+	return cred
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	for _, format := range []string{webauthntest.FormatNone, webauthntest.FormatPacked} {
+		t.Run(format, func(t *testing.T) {
+			rp := newRelyingParty()
+			a, err := webauthntest.NewAuthenticator()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cred := register(t, rp, a, format)
+			if !bytes.Equal(cred.ID, a.CredentialID) {
+				t.Fatalf("credential id = %x, want %x", cred.ID, a.CredentialID)
+			}
+			if cred.Alg != webauthn.AlgES256 || cred.AttestationFmt != format {
+				t.Fatalf("alg = %d, fmt = %q", cred.Alg, cred.AttestationFmt)
+			}
+
+			for want := uint32(1); want <= 2; want++ {
+				clientData, authData, sig, err := a.Get(testRPID, testOrigin, testChallenge)
+				if err != nil {
+					t.Fatal(err)
+				}
+				count, err := rp.VerifyAssertion(testChallenge, cred.PublicKey, want-1, clientData, authData, sig)
+				if err != nil {
+					t.Fatalf("VerifyAssertion: %v", err)
+				}
+				if count != want {
+					t.Fatalf("sign count = %d, want %d", count, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAssertionRejectsBadSignature(t *testing.T) {
+	rp := newRelyingParty()
+	a, err := webauthntest.NewAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred := register(t, rp, a, webauthntest.FormatNone)
+
+	clientData, authData, sig, err := a.Get(testRPID, testOrigin, testChallenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := append([]byte{}, authData...)
+	tampered[len(tampered)-1]++
+	if _, err := rp.VerifyAssertion(testChallenge, cred.PublicKey, 0, clientData, tampered, sig); !errors.Is(err, webauthn.ErrInvalidSignature) {
+		t.Fatalf("tampered authenticator data: err = %v, want %v", err, webauthn.ErrInvalidSignature)
+	}
+
+	other, err := webauthntest.NewAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.VerifyAssertion(testChallenge, other.COSEKey(), 0, clientData, authData, sig); !errors.Is(err, webauthn.ErrInvalidSignature) {
+		t.Fatalf("foreign key: err = %v, want %v", err, webauthn.ErrInvalidSignature)
+	}
+}
+
+func TestRegistrationRejectsBadAttestationSignature(t *testing.T) {
+	rp := newRelyingParty()
+	a, err := webauthntest.NewAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientData, attestation, err := a.Create(webauthntest.FormatPacked, testRPID, testOrigin, testChallenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// statement was signed over the original client data
+	forged := bytes.Replace(clientData, []byte(testOrigin), []byte(testOrigin+"/"), 1)
+	rp.Origins = append(rp.Origins, testOrigin+"/")
+	if _, err := rp.VerifyRegistration(testChallenge, forged, attestation); !errors.Is(err, webauthn.ErrInvalidAttestation) {
+		t.Fatalf("err = %v, want %v", err, webauthn.ErrInvalidAttestation)
+	}
+}
+
+func TestRejectsWrongOrigin(t *testing.T) {
+	rp := newRelyingParty()
+	a, err := webauthntest.NewAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientData, attestation, err := a.Create(webauthntest.FormatNone, testRPID, "https://evil.example", testChallenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.VerifyRegistration(testChallenge, clientData, attestation); !errors.Is(err, webauthn.ErrOriginMismatch) {
+		t.Fatalf("registration: err = %v, want %v", err, webauthn.ErrOriginMismatch)
+	}
+
+	cred := register(t, rp, a, webauthntest.FormatNone)
+	clientData, authData, sig, err := a.Get(testRPID, "https://evil.example", testChallenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.VerifyAssertion(testChallenge, cred.PublicKey, 0, clientData, authData, sig); !errors.Is(err, webauthn.ErrOriginMismatch) {
+		t.Fatalf("assertion: err = %v, want %v", err, webauthn.ErrOriginMismatch)
+	}
+}
+
+func TestRejectsWrongRPID(t *testing.T) {
+	rp := newRelyingParty()
+	a, err := webauthntest.NewAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientData, attestation, err := a.Create(webauthntest.FormatNone, "evil.example", testOrigin, testChallenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.VerifyRegistration(testChallenge, clientData, attestation); !errors.Is(err, webauthn.ErrRPIDMismatch) {
+		t.Fatalf("registration: err = %v, want %v", err, webauthn.ErrRPIDMismatch)
+	}
+
+	cred := register(t, rp, a, webauthntest.FormatNone)
+	clientData, authData, sig, err := a.Get("evil.example", testOrigin, testChallenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.VerifyAssertion(testChallenge, cred.PublicKey, 0, clientData, authData, sig); !errors.Is(err, webauthn.ErrRPIDMismatch) {
+		t.Fatalf("assertion: err = %v, want %v", err, webauthn.ErrRPIDMismatch)
+	}
+}
+
+func TestRejectsChallengeMismatch(t *testing.T) {
+	rp := newRelyingParty()
+	a, err := webauthntest.NewAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred := register(t, rp, a, webauthntest.FormatNone)
+
+	clientData, authData, sig, err := a.Get(testRPID, testOrigin, "b3RoZXItY2hhbGxlbmdl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.VerifyAssertion(testChallenge, cred.PublicKey, 0, clientData, authData, sig); !errors.Is(err, webauthn.ErrChallengeMismatch) {
+		t.Fatalf("err = %v, want %v", err, webauthn.ErrChallengeMismatch)
+	}
+}
+
+func TestAssertionRejectsSignCountRegression(t *testing.T) {
+	rp := newRelyingParty()
+	a, err := webauthntest.NewAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred := register(t, rp, a, webauthntest.FormatNone)
+
+	// clone of the authenticator that fell behind the stored counter
+	a.SignCount = 4
+	clientData, authData, sig, err := a.Get(testRPID, testOrigin, testChallenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stored := range []uint32{5, 7} {
+		if _, err := rp.VerifyAssertion(testChallenge, cred.PublicKey, stored, clientData, authData, sig); !errors.Is(err, webauthn.ErrSignCountRegression) {
+			t.Fatalf("stored %d: err = %v, want %v", stored, err, webauthn.ErrSignCountRegression)
+		}
+	}
+}
+
+func TestAssertionRequiresUserVerification(t *testing.T) {
+	rp := newRelyingParty()
+	a, err := webauthntest.NewAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred := register(t, rp, a, webauthntest.FormatNone)
+
+	a.Flags = webauthntest.FlagUserPresent
+	clientData, authData, sig, err := a.Get(testRPID, testOrigin, testChallenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.VerifyAssertion(testChallenge, cred.PublicKey, 0, clientData, authData, sig); !errors.Is(err, webauthn.ErrUserNotVerified) {
+		t.Fatalf("err = %v, want %v", err, webauthn.ErrUserNotVerified)
+	}
+}
@@ -0,0 +1,161 @@
+// Package webauthntest provides software authenticator for driving webauthn ceremonies in tests
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+)
+
+const (
+	FlagUserPresent  byte = 1 << 0
+	FlagUserVerified byte = 1 << 2
+	flagAttestedData byte = 1 << 6
+)
+
+// attestation statement formats Create can produce
+const (
+	FormatNone   = "none"
+	FormatPacked = "packed"
+)
+
+// ES256 authenticator with a single credential, keeps signature counter like a security key does
+type Authenticator struct {
+	Key          *ecdsa.PrivateKey
+	CredentialID []byte
+	AAGUID       []byte
+	SignCount    uint32
+	// flags put into authenticator data, user present and verified by default
+	Flags byte
+}
+
+func NewAuthenticator() (*Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return &Authenticator{
+		Key:          key,
+		CredentialID: id,
+		AAGUID:       make([]byte, 16),
+		Flags:        FlagUserPresent | FlagUserVerified,
+	}, nil
+}
+
+// response of navigator.credentials.create(), packed format uses self attestation
+func (a *Authenticator) Create(format, rpID, origin, challenge string) (clientDataJSON, attestationObject []byte, err error) {
+	clientDataJSON, err = clientData("webauthn.create", origin, challenge)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authData := a.authData(rpID, a.Flags|flagAttestedData)
+	authData = append(authData, a.AAGUID...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.CredentialID)))
+	authData = append(authData, a.CredentialID...)
+	authData = append(authData, a.COSEKey()...)
+
+	var attStmt cborMap
+	switch format {
+	case FormatNone:
+	case FormatPacked:
+		sig, err := a.sign(authData, clientDataJSON)
+		if err != nil {
+			return nil, nil, err
+		}
+		attStmt = cborMap{{"alg", -7}, {"sig", sig}}
+	default:
+		return nil, nil, errors.New("unsupported attestation format")
+	}
+
+	attestationObject = encodeCBOR(cborMap{{"fmt", format}, {"attStmt", attStmt}, {"authData", authData}})
+	return clientDataJSON, attestationObject, nil
+}
+
+// response of navigator.credentials.get(), counter is incremented before signing
+func (a *Authenticator) Get(rpID, origin, challenge string) (clientDataJSON, authenticatorData, signature []byte, err error) {
+	clientDataJSON, err = clientData("webauthn.get", origin, challenge)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	a.SignCount++
+	authenticatorData = a.authData(rpID, a.Flags)
+	signature, err = a.sign(authenticatorData, clientDataJSON)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return clientDataJSON, authenticatorData, signature, nil
+}
+
+// credential public key as COSE_Key
+func (a *Authenticator) COSEKey() []byte {
+	x := a.Key.PublicKey.X.FillBytes(make([]byte, 32))
+	y := a.Key.PublicKey.Y.FillBytes(make([]byte, 32))
+	return encodeCBOR(cborMap{{1, 2}, {3, -7}, {-1, 1}, {-2, x}, {-3, y}})
+}
+
+func (a *Authenticator) authData(rpID string, flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.SignCount)
+}
+
+func (a *Authenticator) sign(authData, clientDataJSON []byte) ([]byte, error) {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	return ecdsa.SignASN1(rand.Reader, a.Key, digest[:])
+}
+
+func clientData(ceremony, origin, challenge string) ([]byte, error) {
+	return json.Marshal(map[string]string{"type": ceremony, "challenge": challenge, "origin": origin})
+}
+
+// map with encoding order of keys preserved
+type cborMap [][2]any
+
+// encodes the subset of cbor webauthn payloads need: integers, byte and text strings and maps
+func encodeCBOR(item any) []byte {
+	switch v := item.(type) {
+	case int:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case cborMap:
+		out := cborHead(5, uint64(len(v)))
+		for _, kv := range v {
+			out = append(out, encodeCBOR(kv[0])...)
+			out = append(out, encodeCBOR(kv[1])...)
+		}
+		return out
+	}
+	panic("webauthntest: unsupported cbor item")
+}
+
+func cborHead(major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return []byte{major<<5 | byte(arg)}
+	case arg <= math.MaxUint8:
+		return []byte{major<<5 | 24, byte(arg)}
+	case arg <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(arg))
+	case arg <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(arg))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, arg)
+}
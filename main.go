@@ -8,14 +8,22 @@ import (
 	"testovoe_medods/config"
 	"testovoe_medods/infra/server"
 	"testovoe_medods/infra/storage"
+	jwtp "testovoe_medods/lib/jwt"
+	"testovoe_medods/lib/mail"
 )
 
 func main() {
 	cfg := config.MustLoadConfig()
 	logger := MustConfigureLogging(cfg.LogLevel, cfg.Env)
 	db := storage.MustStorageInit(cfg, logger)
+	mailer := mail.NewSender(cfg, logger)
+	if cfg.OIDC.SigningKeyPath == "" {
+		logger.Warn("oidc signing key path isn't set, using ephemeral key")
+	}
+	jwtp.MustLoadSigningKey(cfg.OIDC.SigningKeyPath)
 	mux := http.NewServeMux()
-	app.InitAuthApp(db, logger, cfg, mux)
+	app.InitAuthApp(db, logger, cfg, mux, mailer)
+	app.InitPasswordApp(db, logger, cfg, mux, mailer)
 	server.MustRunServer(cfg, logger, mux, db)
 }
 
@@ -0,0 +1,115 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testovoe_medods/entities"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type APIKeyRepository interface {
+	CreateKey(ctx context.Context, data *entities.APIKey) (*entities.APIKey, error)
+	// owner is identified by exactly one of userGuid and clientID
+	ListKeys(ctx context.Context, userGuid *uuid.UUID, clientID *string) ([]entities.APIKey, error)
+	CountKeys(ctx context.Context, userGuid *uuid.UUID, clientID *string) (int, error)
+	DeleteKey(ctx context.Context, id uuid.UUID, userGuid *uuid.UUID, clientID *string) error
+	GetKeyByDigest(ctx context.Context, digest string) (*entities.APIKey, error)
+	// records usage, updates are throttled to one per minute per key
+	TouchKey(ctx context.Context, id uuid.UUID) error
+}
+
+type apiKeyRepository struct {
+	db *sqlx.DB
+}
+
+func NewAPIKeyRepository(db *sqlx.DB) APIKeyRepository {
+	return &apiKeyRepository{
+		db: db,
+	}
+}
+
+const apiKeyColumns = `id, key_digest, display_prefix, name, user_guid, client_id, scopes, expires_at, last_used_at,
+	created_at`
+
+// owner columns are compared with IS NOT DISTINCT FROM, so nil matches keys of the other owner kind only by NULL
+const apiKeyOwnerCond = "user_guid IS NOT DISTINCT FROM $1 AND client_id IS NOT DISTINCT FROM $2"
+
+func (s *apiKeyRepository) CreateKey(ctx context.Context, data *entities.APIKey) (*entities.APIKey, error) {
+	const op = "repo.CreateKey"
+
+	q := `INSERT INTO api_keys (key_digest, display_prefix, name, user_guid, client_id, scopes, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING ` + apiKeyColumns
+	var key entities.APIKey
+	err := s.db.GetContext(ctx, &key, q, data.KeyDigest, data.DisplayPrefix, data.Name, data.UserGuid, data.ClientID,
+		data.Scopes, data.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &key, nil
+}
+
+func (s *apiKeyRepository) ListKeys(ctx context.Context, userGuid *uuid.UUID, clientID *string) ([]entities.APIKey, error) {
+	const op = "repo.ListKeys"
+
+	q := "SELECT " + apiKeyColumns + " FROM api_keys WHERE " + apiKeyOwnerCond + " ORDER BY created_at"
+	keys := []entities.APIKey{}
+	if err := s.db.SelectContext(ctx, &keys, q, userGuid, clientID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return keys, nil
+}
+
+func (s *apiKeyRepository) CountKeys(ctx context.Context, userGuid *uuid.UUID, clientID *string) (int, error) {
+	const op = "repo.CountKeys"
+
+	var count int
+	if err := s.db.GetContext(ctx, &count, "SELECT count(*) FROM api_keys WHERE "+apiKeyOwnerCond, userGuid, clientID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return count, nil
+}
+
+func (s *apiKeyRepository) DeleteKey(ctx context.Context, id uuid.UUID, userGuid *uuid.UUID, clientID *string) error {
+	const op = "repo.DeleteKey"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM api_keys WHERE "+apiKeyOwnerCond+" AND id = $3", userGuid, clientID, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityNotExists
+	}
+	return nil
+}
+
+func (s *apiKeyRepository) GetKeyByDigest(ctx context.Context, digest string) (*entities.APIKey, error) {
+	const op = "repo.GetKeyByDigest"
+
+	// keys of disabled users stop authenticating together with their sessions
+	q := "SELECT " + apiKeyColumns + ` FROM api_keys WHERE key_digest = $1
+	AND NOT EXISTS (SELECT 1 FROM users WHERE users.id = api_keys.user_guid AND users.disabled)`
+	var key entities.APIKey
+	err := s.db.GetContext(ctx, &key, q, digest)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &key, nil
+}
+
+func (s *apiKeyRepository) TouchKey(ctx context.Context, id uuid.UUID) error {
+	const op = "repo.TouchKey"
+
+	q := `UPDATE api_keys SET last_used_at = now()
+	WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')`
+	if _, err := s.db.ExecContext(ctx, q, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
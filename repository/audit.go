@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"testovoe_medods/entities"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type AuditRepository interface {
+	CreateEvent(ctx context.Context, event *entities.AuditEvent) error
+	// newest events first
+	ListEvents(ctx context.Context, filter *entities.AuditFilter) ([]entities.AuditEvent, error)
+	// opens impersonation session and records its issue in one transaction, returns session id
+	CreateImpersonationSession(ctx context.Context, session *entities.UserAuthInfo, event *entities.AuditEvent) (uuid.UUID, error)
+}
+
+type auditRepository struct {
+	db *sqlx.DB
+}
+
+func NewAuditRepository(db *sqlx.DB) AuditRepository {
+	return &auditRepository{
+		db: db,
+	}
+}
+
+const auditEventColumns = `id, event, actor_guid, subject_guid, session_id, ip_address, details, created_at`
+
+const createAuditEventQ = `INSERT INTO audit_events (event, actor_guid, subject_guid, session_id, ip_address, details)
+	VALUES ($1, $2, $3, $4, $5, $6)`
+
+func (s *auditRepository) CreateEvent(ctx context.Context, event *entities.AuditEvent) error {
+	const op = "repo.CreateAuditEvent"
+
+	_, err := s.db.ExecContext(ctx, createAuditEventQ, event.Event, event.ActorGuid, event.SubjectGuid, event.SessionID,
+		event.IpAddress, event.Details)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *auditRepository) ListEvents(ctx context.Context, filter *entities.AuditFilter) ([]entities.AuditEvent, error) {
+	const op = "repo.ListAuditEvents"
+
+	q := "SELECT " + auditEventColumns + ` FROM audit_events
+	WHERE $1::uuid IS NULL OR actor_guid = $1 OR subject_guid = $1
+	ORDER BY created_at DESC, id LIMIT $2 OFFSET $3`
+	events := []entities.AuditEvent{}
+	if err := s.db.SelectContext(ctx, &events, q, filter.UserGuid, filter.Limit, filter.Offset); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return events, nil
+}
+
+func (s *auditRepository) CreateImpersonationSession(ctx context.Context, session *entities.UserAuthInfo, event *entities.AuditEvent) (uuid.UUID, error) {
+	const op = "repo.CreateImpersonationSession"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	q := `INSERT INTO users_auth_info (user_guid, ip_address, tenant_id, auth_method, impersonator_guid, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	var sessionID uuid.UUID
+	err = tx.GetContext(ctx, &sessionID, q, session.UserGuid, session.IpAddress, session.TenantID, session.AuthMethod,
+		session.ImpersonatorGuid, session.ExpiresAt)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, createAuditEventQ, event.Event, event.ActorGuid, event.SubjectGuid, sessionID,
+		event.IpAddress, event.Details)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return sessionID, nil
+}
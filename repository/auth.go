@@ -13,15 +13,20 @@ import (
 )
 
 var ErrEntityNotExists = errors.New("entity doesn't exist")
+var ErrEntityAlreadyExists = errors.New("entity already exists")
 
+// session queries are scoped by tenant, sessions of other tenants look like they don't exist
 type AuthRepository interface {
 	StoreAuthData(ctx context.Context, guid, token string, ip_address net.IP) error
 	UpdateAuthInfo(ctx context.Context, data *entities.UserAuthInfo) error
 	UpdateRefreshTokenHash(ctx context.Context, data *entities.UserAuthInfo) error
 	CreateAuthInfo(ctx context.Context, data *entities.UserAuthInfo) (string, error)
-	GetRefreshTokenHash(ctx context.Context, tokenId string) (string, error)
-	GetAuthInfoByUserGuid(ctx context.Context, guid string) (*entities.UserWithAuthCreds, error)
-	GetRefreshTokenId(ctx context.Context, tHash string) (*int64, error)
+	GetRefreshTokenHash(ctx context.Context, tenantID, tokenId string) (string, error)
+	GetAuthInfoByUserGuid(ctx context.Context, tenantID, guid string) (*entities.UserWithAuthCreds, error)
+	GetRefreshTokenId(ctx context.Context, tenantID, tHash string) (*int64, error)
+	GetAuthInfoById(ctx context.Context, tenantID, id string) (*entities.UserAuthInfo, error)
+	// live sessions of the user in the given tenant, newest first
+	ListSessions(ctx context.Context, tenantID string, userGuid uuid.UUID) ([]entities.Session, error)
 }
 
 type userAuthRepository struct {
@@ -60,13 +65,13 @@ func (s *userAuthRepository) StoreAuthData(ctx context.Context, guid, tokenHash
 	return nil
 }
 
-func (s *userAuthRepository) GetRefreshTokenHash(ctx context.Context, tokenId string) (string, error) {
+func (s *userAuthRepository) GetRefreshTokenHash(ctx context.Context, tenantID, tokenId string) (string, error) {
 	const op = "auth.GetRefreshTokenHash"
 
-	q := "SELECT refresh_token_hash FROM users_auth_info WHERE id = $1"
+	q := "SELECT refresh_token_hash FROM users_auth_info WHERE id = $1 AND tenant_id = $2"
 
 	var tokenHash string
-	err := s.db.GetContext(ctx, &tokenHash, q, tokenId)
+	err := s.db.GetContext(ctx, &tokenHash, q, tokenId, tenantID)
 
 	if err != nil {
 		return "", fmt.Errorf("%s, %w", op, err)
@@ -74,12 +79,13 @@ func (s *userAuthRepository) GetRefreshTokenHash(ctx context.Context, tokenId st
 	return tokenHash, nil
 }
 
-func (s *userAuthRepository) GetAuthInfoByUserGuid(ctx context.Context, guid string) (*entities.UserWithAuthCreds, error) {
+func (s *userAuthRepository) GetAuthInfoByUserGuid(ctx context.Context, tenantID, guid string) (*entities.UserWithAuthCreds, error) {
 	const op = "repo.GetAuthInfoByUserGuid"
 
-	q := "SELECT users.id, email, refresh_token_hash, ip_address FROM users LEFT JOIN users_auth_info ON users.id = users_auth_info.user_guid WHERE users.id = $1"
+	q := `SELECT users.id, email, disabled, refresh_token_hash, ip_address FROM users LEFT JOIN users_auth_info
+	ON users.id = users_auth_info.user_guid AND users_auth_info.tenant_id = $2 WHERE users.id = $1`
 	var authInfo entities.UserWithAuthCreds
-	err := s.db.GetContext(ctx, &authInfo, q, guid)
+	err := s.db.GetContext(ctx, &authInfo, q, guid, tenantID)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrEntityNotExists
 	}
@@ -89,13 +95,14 @@ func (s *userAuthRepository) GetAuthInfoByUserGuid(ctx context.Context, guid str
 	return &authInfo, nil
 }
 
-func(s *userAuthRepository)  GetAuthInfoByRefreshTokenHash(ctx context.Context, tHash string) (*entities.UserWithAuthCreds, error) {
+func(s *userAuthRepository)  GetAuthInfoByRefreshTokenHash(ctx context.Context, tenantID, tHash string) (*entities.UserWithAuthCreds, error) {
 	const op = "repo.GetAuthInfoByRefreshTokenHash"
 
-	q := "SELECT users.id, email, refresh_token_hash, ip_address FROM users LEFT JOIN users_auth_info ON users.id = users_auth_info.user_guid WHERE refresh_token_hash = $1"
+	q := `SELECT users.id, email, disabled, refresh_token_hash, ip_address FROM users JOIN users_auth_info
+	ON users.id = users_auth_info.user_guid WHERE refresh_token_hash = $1 AND users_auth_info.tenant_id = $2`
 
 	var authInfo entities.UserWithAuthCreds
-	err := s.db.GetContext(ctx, &authInfo, q, tHash)
+	err := s.db.GetContext(ctx, &authInfo, q, tHash, tenantID)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrEntityNotExists
 	}
@@ -105,13 +112,13 @@ func(s *userAuthRepository)  GetAuthInfoByRefreshTokenHash(ctx context.Context,
 	return &authInfo, nil
 }
 
-func (s *userAuthRepository) GetRefreshTokenId(ctx context.Context, tHash string) (*int64, error) {
+func (s *userAuthRepository) GetRefreshTokenId(ctx context.Context, tenantID, tHash string) (*int64, error) {
 	const op = "repo.GetRefreshTokenId"
 
 	var tokenId int64 
-	q := "SELECT id FROM users_auth_info WHERE refresh_token_hash = $1"
+	q := "SELECT id FROM users_auth_info WHERE refresh_token_hash = $1 AND tenant_id = $2"
 
-	err := s.db.GetContext(ctx, &tokenId, q, tHash)
+	err := s.db.GetContext(ctx, &tokenId, q, tHash, tenantID)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrEntityNotExists
 	}
@@ -125,8 +132,8 @@ func (s *userAuthRepository) GetRefreshTokenId(ctx context.Context, tHash string
 func (s *userAuthRepository) UpdateAuthInfo(ctx context.Context, data *entities.UserAuthInfo) error {
 	const op = "repo.UpdateAuthInfo"
 
-	updateQ := `UPDATE users_auth_info SET refresh_token_hash=$1, ip_adrress=$2 WHERE guid=$3;`
-	_, err := s.db.ExecContext(ctx, updateQ, data.RefreshTokenHash,data.IpAddress, data.UserGuid)
+	updateQ := `UPDATE users_auth_info SET refresh_token_hash=$1, ip_adrress=$2 WHERE guid=$3 AND tenant_id=$4;`
+	_, err := s.db.ExecContext(ctx, updateQ, data.RefreshTokenHash,data.IpAddress, data.UserGuid, data.TenantID)
 	if err != nil {
 		return fmt.Errorf("%s, %w", op, err)
 	}
@@ -136,8 +143,8 @@ func (s *userAuthRepository) UpdateAuthInfo(ctx context.Context, data *entities.
 func (s *userAuthRepository) UpdateRefreshTokenHash(ctx context.Context, data *entities.UserAuthInfo) error {
 	const op = "repo.UpdateRefreshTokenHash"
 
-	updateQ := "UPDATE users_auth_info SET refresh_token_hash=$1 WHERE id=$2"
-	_, err := s.db.ExecContext(ctx, updateQ, *data.RefreshTokenHash, *data.RefreshId)
+	updateQ := "UPDATE users_auth_info SET refresh_token_hash=$1 WHERE id=$2 AND tenant_id=$3"
+	_, err := s.db.ExecContext(ctx, updateQ, *data.RefreshTokenHash, *data.RefreshId, data.TenantID)
 
 	if err != nil {
 		return fmt.Errorf("%s, %w", op, err)
@@ -149,14 +156,45 @@ func (s *userAuthRepository) UpdateRefreshTokenHash(ctx context.Context, data *e
 func (s *userAuthRepository) CreateAuthInfo(ctx context.Context, data *entities.UserAuthInfo) (string, error)  {
 	const op = "repo.CreateAuthInfo"
 	createQ := `INSERT INTO users_auth_info (user_guid,
-	ip_address) VALUES ($1, $2) RETURNING users_auth_info.id`
+	ip_address, client_id, scope, sso_session_id, tenant_id, auth_method) VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING users_auth_info.id`
 
 	var recordID uuid.UUID
-	err := s.db.QueryRowContext(ctx, createQ, *data.UserGuid, *data.IpAddress).Scan(&recordID)
+	err := s.db.QueryRowContext(ctx, createQ, *data.UserGuid, *data.IpAddress, data.ClientID, data.Scope,
+		data.SSOSessionID, data.TenantID, data.AuthMethod).Scan(&recordID)
 
 	if err != nil {
 		return "", fmt.Errorf("%s, %w", op, err)
 	}
 	
 	return recordID.String(), nil
-}
\ No newline at end of file
+}
+func (s *userAuthRepository) GetAuthInfoById(ctx context.Context, tenantID, id string) (*entities.UserAuthInfo, error) {
+	const op = "repo.GetAuthInfoById"
+
+	// expired impersonation sessions are gone even before their rows are cleaned up
+	q := `SELECT id, user_guid, refresh_token_hash, ip_address, client_id, scope, sso_session_id, tenant_id, auth_method,
+	impersonator_guid, expires_at FROM users_auth_info
+	WHERE id = $1 AND tenant_id = $2 AND (expires_at IS NULL OR expires_at > now())`
+	var authInfo entities.UserAuthInfo
+	err := s.db.GetContext(ctx, &authInfo, q, id, tenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &authInfo, nil
+}
+
+func (s *userAuthRepository) ListSessions(ctx context.Context, tenantID string, userGuid uuid.UUID) ([]entities.Session, error) {
+	const op = "repo.ListSessions"
+
+	q := `SELECT id, client_id, tenant_id, ip_address, auth_method, created_at, expires_at, impersonator_guid
+	FROM users_auth_info WHERE user_guid = $1 AND tenant_id = $2 AND (expires_at IS NULL OR expires_at > now()) ORDER BY created_at DESC`
+	sessions := []entities.Session{}
+	if err := s.db.SelectContext(ctx, &sessions, q, userGuid, tenantID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return sessions, nil
+}
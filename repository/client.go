@@ -0,0 +1,127 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testovoe_medods/entities"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type OAuthClientRepository interface {
+	CreateClient(ctx context.Context, data *entities.OAuthClient) (*entities.OAuthClient, error)
+	GetClient(ctx context.Context, clientID string) (*entities.OAuthClient, error)
+	ListClients(ctx context.Context) ([]entities.OAuthClient, error)
+	UpdateClient(ctx context.Context, data *entities.OAuthClient) (*entities.OAuthClient, error)
+	UpdateClientSecret(ctx context.Context, clientID string, secretHash *string) error
+	DeleteClient(ctx context.Context, clientID string) error
+}
+
+type oauthClientRepository struct {
+	db *sqlx.DB
+}
+
+func NewOAuthClientRepository(db *sqlx.DB) OAuthClientRepository {
+	return &oauthClientRepository{
+		db: db,
+	}
+}
+
+const oauthClientColumns = `id, name, secret_hash, redirect_uris, grant_types, scopes, exchange_audiences, token_endpoint_auth_method,
+	jwks_uri, jwks, jwks_file, backchannel_logout_uri, first_party, tenant_id, access_token_ttl, refresh_token_ttl,
+	created_at, updated_at`
+
+func (s *oauthClientRepository) CreateClient(ctx context.Context, data *entities.OAuthClient) (*entities.OAuthClient, error) {
+	const op = "repo.CreateClient"
+
+	q := `INSERT INTO oauth_clients (id, name, secret_hash, redirect_uris, grant_types, scopes, exchange_audiences,
+	token_endpoint_auth_method, jwks_uri, jwks, jwks_file, backchannel_logout_uri, first_party, tenant_id,
+	access_token_ttl, refresh_token_ttl) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	ON CONFLICT (id) DO NOTHING RETURNING ` + oauthClientColumns
+	var client entities.OAuthClient
+	err := s.db.GetContext(ctx, &client, q, data.ID, data.Name, data.SecretHash, data.RedirectURIs, data.GrantTypes,
+		data.Scopes, data.ExchangeAudiences, data.TokenEndpointAuthMethod, data.JWKSURI, data.JWKS, data.JWKSFile,
+		data.BackchannelLogoutURI, data.FirstParty, data.TenantID, data.AccessTokenTTL, data.RefreshTokenTTL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityAlreadyExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &client, nil
+}
+
+func (s *oauthClientRepository) GetClient(ctx context.Context, clientID string) (*entities.OAuthClient, error) {
+	const op = "repo.GetClient"
+
+	q := "SELECT " + oauthClientColumns + " FROM oauth_clients WHERE id = $1"
+	var client entities.OAuthClient
+	err := s.db.GetContext(ctx, &client, q, clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &client, nil
+}
+
+func (s *oauthClientRepository) ListClients(ctx context.Context) ([]entities.OAuthClient, error) {
+	const op = "repo.ListClients"
+
+	q := "SELECT " + oauthClientColumns + " FROM oauth_clients ORDER BY created_at"
+	clients := []entities.OAuthClient{}
+	if err := s.db.SelectContext(ctx, &clients, q); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return clients, nil
+}
+
+func (s *oauthClientRepository) UpdateClient(ctx context.Context, data *entities.OAuthClient) (*entities.OAuthClient, error) {
+	const op = "repo.UpdateClient"
+
+	q := `UPDATE oauth_clients SET name = $2, redirect_uris = $3, grant_types = $4, scopes = $5, exchange_audiences = $6,
+	token_endpoint_auth_method = $7, jwks_uri = $8, jwks = $9, jwks_file = $10, backchannel_logout_uri = $11,
+	first_party = $12, access_token_ttl = $13, refresh_token_ttl = $14, tenant_id = $15, updated_at = now()
+	WHERE id = $1 RETURNING ` + oauthClientColumns
+	var client entities.OAuthClient
+	err := s.db.GetContext(ctx, &client, q, data.ID, data.Name, data.RedirectURIs, data.GrantTypes, data.Scopes,
+		data.ExchangeAudiences, data.TokenEndpointAuthMethod, data.JWKSURI, data.JWKS, data.JWKSFile,
+		data.BackchannelLogoutURI, data.FirstParty, data.AccessTokenTTL, data.RefreshTokenTTL, data.TenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &client, nil
+}
+
+func (s *oauthClientRepository) UpdateClientSecret(ctx context.Context, clientID string, secretHash *string) error {
+	const op = "repo.UpdateClientSecret"
+
+	q := "UPDATE oauth_clients SET secret_hash = $2, updated_at = now() WHERE id = $1"
+	res, err := s.db.ExecContext(ctx, q, clientID, secretHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityNotExists
+	}
+	return nil
+}
+
+func (s *oauthClientRepository) DeleteClient(ctx context.Context, clientID string) error {
+	const op = "repo.DeleteClient"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM oauth_clients WHERE id = $1", clientID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityNotExists
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testovoe_medods/entities"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type ConsentRepository interface {
+	GetConsent(ctx context.Context, userGuid uuid.UUID, clientID string) (*entities.OAuthConsent, error)
+	ListConsents(ctx context.Context, userGuid uuid.UUID) ([]entities.OAuthConsent, error)
+	// replaces scopes of existing grant or creates a new one
+	SaveConsent(ctx context.Context, userGuid uuid.UUID, clientID string, scopes entities.StringList) error
+	// deletes grant and sessions user has with the client, relying party is queued for back-channel logout
+	RevokeConsent(ctx context.Context, userGuid uuid.UUID, clientID string) error
+}
+
+type consentRepository struct {
+	db *sqlx.DB
+}
+
+func NewConsentRepository(db *sqlx.DB) ConsentRepository {
+	return &consentRepository{
+		db: db,
+	}
+}
+
+const consentColumns = `oauth_consents.user_guid, oauth_consents.client_id, oauth_clients.name AS client_name,
+	oauth_consents.scopes, oauth_consents.created_at, oauth_consents.updated_at`
+
+func (s *consentRepository) GetConsent(ctx context.Context, userGuid uuid.UUID, clientID string) (*entities.OAuthConsent, error) {
+	const op = "repo.GetConsent"
+
+	q := "SELECT " + consentColumns + ` FROM oauth_consents
+	JOIN oauth_clients ON oauth_clients.id = oauth_consents.client_id
+	WHERE oauth_consents.user_guid = $1 AND oauth_consents.client_id = $2`
+	var consent entities.OAuthConsent
+	err := s.db.GetContext(ctx, &consent, q, userGuid, clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &consent, nil
+}
+
+func (s *consentRepository) ListConsents(ctx context.Context, userGuid uuid.UUID) ([]entities.OAuthConsent, error) {
+	const op = "repo.ListConsents"
+
+	q := "SELECT " + consentColumns + ` FROM oauth_consents
+	JOIN oauth_clients ON oauth_clients.id = oauth_consents.client_id
+	WHERE oauth_consents.user_guid = $1 ORDER BY oauth_consents.created_at`
+	consents := []entities.OAuthConsent{}
+	if err := s.db.SelectContext(ctx, &consents, q, userGuid); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return consents, nil
+}
+
+func (s *consentRepository) SaveConsent(ctx context.Context, userGuid uuid.UUID, clientID string, scopes entities.StringList) error {
+	const op = "repo.SaveConsent"
+
+	q := `INSERT INTO oauth_consents (user_guid, client_id, scopes) VALUES ($1, $2, $3)
+	ON CONFLICT (user_guid, client_id) DO UPDATE SET scopes = EXCLUDED.scopes, updated_at = now()`
+	if _, err := s.db.ExecContext(ctx, q, userGuid, clientID, scopes); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *consentRepository) RevokeConsent(ctx context.Context, userGuid uuid.UUID, clientID string) error {
+	const op = "repo.RevokeConsent"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM oauth_consents WHERE user_guid = $1 AND client_id = $2", userGuid, clientID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityNotExists
+	}
+
+	if _, err := tx.ExecContext(ctx, enqueueLogoutsQ+"s.user_guid = $1 AND s.client_id = $2", userGuid, clientID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	q := "DELETE FROM users_auth_info WHERE user_guid = $1 AND client_id = $2"
+	if _, err := tx.ExecContext(ctx, q, userGuid, clientID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testovoe_medods/entities"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type DeviceRepository interface {
+	CreateDeviceCode(ctx context.Context, data *entities.DeviceCode) error
+	GetDeviceCode(ctx context.Context, deviceCodeHash string) (*entities.DeviceCode, error)
+	// returns pending not expired code by user code
+	GetPendingByUserCode(ctx context.Context, userCodeHash string) (*entities.DeviceCode, error)
+	DecideDeviceCode(ctx context.Context, userCodeHash, status string, userGuid uuid.UUID) error
+	// records poll of device code. Returns false and raises interval by slowDownSec when the client polls
+	// faster than the current interval
+	RecordDevicePoll(ctx context.Context, deviceCodeHash string, slowDownSec int64) (bool, error)
+	DeleteDeviceCode(ctx context.Context, deviceCodeHash string) error
+	// deletes approved code and returns it, so tokens are released only once
+	ConsumeApprovedDeviceCode(ctx context.Context, deviceCodeHash string) (*entities.DeviceCode, error)
+}
+
+type deviceRepository struct {
+	db *sqlx.DB
+}
+
+func NewDeviceRepository(db *sqlx.DB) DeviceRepository {
+	return &deviceRepository{
+		db: db,
+	}
+}
+
+const deviceCodeColumns = "device_code_hash, user_code_hash, client_id, scope, status, user_guid, interval_seconds, last_polled_at, expires_at"
+
+func (s *deviceRepository) CreateDeviceCode(ctx context.Context, data *entities.DeviceCode) error {
+	const op = "repo.CreateDeviceCode"
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM device_codes WHERE expires_at < now()"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	q := `INSERT INTO device_codes (device_code_hash, user_code_hash, client_id, scope, status, interval_seconds, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := s.db.ExecContext(ctx, q, data.DeviceCodeHash, data.UserCodeHash, data.ClientID, data.Scope,
+		entities.DeviceCodePending, data.IntervalSec, data.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *deviceRepository) GetDeviceCode(ctx context.Context, deviceCodeHash string) (*entities.DeviceCode, error) {
+	const op = "repo.GetDeviceCode"
+
+	q := "SELECT " + deviceCodeColumns + " FROM device_codes WHERE device_code_hash = $1"
+	var code entities.DeviceCode
+	err := s.db.GetContext(ctx, &code, q, deviceCodeHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &code, nil
+}
+
+func (s *deviceRepository) GetPendingByUserCode(ctx context.Context, userCodeHash string) (*entities.DeviceCode, error) {
+	const op = "repo.GetPendingByUserCode"
+
+	q := "SELECT " + deviceCodeColumns + " FROM device_codes WHERE user_code_hash = $1 AND status = $2 AND expires_at > now()"
+	var code entities.DeviceCode
+	err := s.db.GetContext(ctx, &code, q, userCodeHash, entities.DeviceCodePending)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &code, nil
+}
+
+func (s *deviceRepository) DecideDeviceCode(ctx context.Context, userCodeHash, status string, userGuid uuid.UUID) error {
+	const op = "repo.DecideDeviceCode"
+
+	q := `UPDATE device_codes SET status = $2, user_guid = $3
+	WHERE user_code_hash = $1 AND status = $4 AND expires_at > now()`
+	res, err := s.db.ExecContext(ctx, q, userCodeHash, status, userGuid, entities.DeviceCodePending)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityNotExists
+	}
+	return nil
+}
+
+// interval is checked by the update itself, so parallel polls can't all pass between read and write
+func (s *deviceRepository) RecordDevicePoll(ctx context.Context, deviceCodeHash string, slowDownSec int64) (bool, error) {
+	const op = "repo.RecordDevicePoll"
+
+	q := `UPDATE device_codes SET last_polled_at = now()
+	WHERE device_code_hash = $1 AND (last_polled_at IS NULL OR last_polled_at <= now() - interval_seconds * interval '1 second')`
+	res, err := s.db.ExecContext(ctx, q, deviceCodeHash)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 1 {
+		return true, nil
+	}
+
+	q = "UPDATE device_codes SET last_polled_at = now(), interval_seconds = interval_seconds + $2 WHERE device_code_hash = $1"
+	if _, err := s.db.ExecContext(ctx, q, deviceCodeHash, slowDownSec); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return false, nil
+}
+
+func (s *deviceRepository) DeleteDeviceCode(ctx context.Context, deviceCodeHash string) error {
+	const op = "repo.DeleteDeviceCode"
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM device_codes WHERE device_code_hash = $1", deviceCodeHash); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *deviceRepository) ConsumeApprovedDeviceCode(ctx context.Context, deviceCodeHash string) (*entities.DeviceCode, error) {
+	const op = "repo.ConsumeApprovedDeviceCode"
+
+	q := "DELETE FROM device_codes WHERE device_code_hash = $1 AND status = $2 RETURNING " + deviceCodeColumns
+	var code entities.DeviceCode
+	err := s.db.GetContext(ctx, &code, q, deviceCodeHash, entities.DeviceCodeApproved)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &code, nil
+}
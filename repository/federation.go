@@ -0,0 +1,100 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testovoe_medods/entities"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type FederationRepository interface {
+	CreateState(ctx context.Context, data *entities.FederationState) error
+	ConsumeState(ctx context.Context, stateHash, provider string) (*entities.FederationState, error)
+	GetIdentity(ctx context.Context, provider, subject string) (*entities.FederatedIdentity, error)
+	LinkIdentity(ctx context.Context, data *entities.FederatedIdentity) error
+	TouchIdentity(ctx context.Context, id uuid.UUID, email *string) error
+}
+
+type federationRepository struct {
+	db *sqlx.DB
+}
+
+func NewFederationRepository(db *sqlx.DB) FederationRepository {
+	return &federationRepository{
+		db: db,
+	}
+}
+
+func (s *federationRepository) CreateState(ctx context.Context, data *entities.FederationState) error {
+	const op = "repo.CreateState"
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM federation_states WHERE expires_at < now()"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	q := `INSERT INTO federation_states (state_hash, provider, nonce, code_verifier, expires_at, link_user_guid)
+	VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := s.db.ExecContext(ctx, q, data.StateHash, data.Provider, data.Nonce, data.CodeVerifier, data.ExpiresAt, data.LinkUserGuid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *federationRepository) ConsumeState(ctx context.Context, stateHash, provider string) (*entities.FederationState, error) {
+	const op = "repo.ConsumeState"
+
+	q := `DELETE FROM federation_states WHERE state_hash = $1 AND provider = $2 AND expires_at > now()
+	RETURNING state_hash, provider, nonce, code_verifier, expires_at, link_user_guid`
+	var state entities.FederationState
+	err := s.db.GetContext(ctx, &state, q, stateHash, provider)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &state, nil
+}
+
+func (s *federationRepository) GetIdentity(ctx context.Context, provider, subject string) (*entities.FederatedIdentity, error) {
+	const op = "repo.GetIdentity"
+
+	q := `SELECT id, provider, subject, user_guid, email, created_at, last_login_at
+	FROM federated_identities WHERE provider = $1 AND subject = $2`
+	var identity entities.FederatedIdentity
+	err := s.db.GetContext(ctx, &identity, q, provider, subject)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &identity, nil
+}
+
+func (s *federationRepository) LinkIdentity(ctx context.Context, data *entities.FederatedIdentity) error {
+	const op = "repo.LinkIdentity"
+
+	q := `INSERT INTO federated_identities (provider, subject, user_guid, email, last_login_at)
+	VALUES ($1, $2, $3, $4, now()) RETURNING id, created_at`
+	err := s.db.QueryRowxContext(ctx, q, data.Provider, data.Subject, data.UserGuid, data.Email).Scan(&data.ID, &data.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *federationRepository) TouchIdentity(ctx context.Context, id uuid.UUID, email *string) error {
+	const op = "repo.TouchIdentity"
+
+	_, err := s.db.ExecContext(ctx, "UPDATE federated_identities SET last_login_at = now(), email = COALESCE($2, email) WHERE id = $1", id, email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testovoe_medods/entities"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type GroupRepository interface {
+	CreateGroup(ctx context.Context, data *entities.Group) (*entities.Group, error)
+	GetGroup(ctx context.Context, id uuid.UUID) (*entities.Group, error)
+	ListGroups(ctx context.Context) ([]entities.Group, error)
+	UpdateGroup(ctx context.Context, data *entities.Group) (*entities.Group, error)
+	// subgroups of deleted group become top-level groups
+	DeleteGroup(ctx context.Context, id uuid.UUID) error
+	// ids of the group and all its parents up to the top-level one
+	GetGroupAncestors(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error)
+	// adding existing member is a no-op
+	AddMember(ctx context.Context, groupID, userGuid uuid.UUID) error
+	RemoveMember(ctx context.Context, groupID, userGuid uuid.UUID) error
+	// direct members of the group
+	ListMembers(ctx context.Context, groupID uuid.UUID) ([]entities.GroupMember, error)
+	// groups user is a member of directly or through subgroups, sorted by name
+	GetUserGroups(ctx context.Context, userGuid uuid.UUID) ([]entities.Group, error)
+}
+
+type groupRepository struct {
+	db *sqlx.DB
+}
+
+func NewGroupRepository(db *sqlx.DB) GroupRepository {
+	return &groupRepository{
+		db: db,
+	}
+}
+
+const groupColumns = `id, name, description, parent_id, external_id, created_at, updated_at`
+
+func (s *groupRepository) CreateGroup(ctx context.Context, data *entities.Group) (*entities.Group, error) {
+	const op = "repo.CreateGroup"
+
+	q := `INSERT INTO groups (name, description, parent_id) VALUES ($1, $2, $3)
+	ON CONFLICT (name) DO NOTHING RETURNING ` + groupColumns
+	var group entities.Group
+	err := s.db.GetContext(ctx, &group, q, data.Name, data.Description, data.ParentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityAlreadyExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &group, nil
+}
+
+func (s *groupRepository) GetGroup(ctx context.Context, id uuid.UUID) (*entities.Group, error) {
+	const op = "repo.GetGroup"
+
+	var group entities.Group
+	err := s.db.GetContext(ctx, &group, "SELECT "+groupColumns+" FROM groups WHERE id = $1", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &group, nil
+}
+
+func (s *groupRepository) ListGroups(ctx context.Context) ([]entities.Group, error) {
+	const op = "repo.ListGroups"
+
+	groups := []entities.Group{}
+	if err := s.db.SelectContext(ctx, &groups, "SELECT "+groupColumns+" FROM groups ORDER BY name"); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return groups, nil
+}
+
+func (s *groupRepository) UpdateGroup(ctx context.Context, data *entities.Group) (*entities.Group, error) {
+	const op = "repo.UpdateGroup"
+
+	// name conflict leaves no row to update, so it's told apart from missing group by the first check
+	q := `UPDATE groups SET name = $2, description = $3, parent_id = $4, updated_at = now()
+	WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM groups WHERE name = $2 AND id <> $1) RETURNING ` + groupColumns
+	var group entities.Group
+	err := s.db.GetContext(ctx, &group, q, data.ID, data.Name, data.Description, data.ParentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := s.GetGroup(ctx, data.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrEntityAlreadyExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &group, nil
+}
+
+func (s *groupRepository) DeleteGroup(ctx context.Context, id uuid.UUID) error {
+	const op = "repo.DeleteGroup"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM groups WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityNotExists
+	}
+	return nil
+}
+
+func (s *groupRepository) GetGroupAncestors(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	const op = "repo.GetGroupAncestors"
+
+	q := `WITH RECURSIVE ancestors AS (
+		SELECT id, parent_id FROM groups WHERE id = $1
+		UNION
+		SELECT groups.id, groups.parent_id FROM groups JOIN ancestors ON groups.id = ancestors.parent_id
+	) SELECT id FROM ancestors`
+	var ids []uuid.UUID
+	if err := s.db.SelectContext(ctx, &ids, q, id); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return ids, nil
+}
+
+func (s *groupRepository) AddMember(ctx context.Context, groupID, userGuid uuid.UUID) error {
+	const op = "repo.AddGroupMember"
+
+	q := "INSERT INTO group_members (group_id, user_guid) VALUES ($1, $2) ON CONFLICT DO NOTHING"
+	if _, err := s.db.ExecContext(ctx, q, groupID, userGuid); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *groupRepository) RemoveMember(ctx context.Context, groupID, userGuid uuid.UUID) error {
+	const op = "repo.RemoveGroupMember"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM group_members WHERE group_id = $1 AND user_guid = $2", groupID, userGuid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityNotExists
+	}
+	return nil
+}
+
+func (s *groupRepository) ListMembers(ctx context.Context, groupID uuid.UUID) ([]entities.GroupMember, error) {
+	const op = "repo.ListGroupMembers"
+
+	q := `SELECT group_members.group_id, group_members.user_guid, users.email, group_members.created_at
+	FROM group_members JOIN users ON users.id = group_members.user_guid
+	WHERE group_members.group_id = $1 ORDER BY group_members.created_at`
+	members := []entities.GroupMember{}
+	if err := s.db.SelectContext(ctx, &members, q, groupID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return members, nil
+}
+
+func (s *groupRepository) GetUserGroups(ctx context.Context, userGuid uuid.UUID) ([]entities.Group, error) {
+	const op = "repo.GetUserGroups"
+
+	// UNION rather than UNION ALL stops recursion on cycles
+	q := `WITH RECURSIVE effective AS (
+		SELECT groups.id, groups.parent_id FROM groups
+		JOIN group_members ON group_members.group_id = groups.id WHERE group_members.user_guid = $1
+		UNION
+		SELECT groups.id, groups.parent_id FROM groups JOIN effective ON groups.id = effective.parent_id
+	) SELECT ` + groupColumns + ` FROM groups WHERE id IN (SELECT id FROM effective) ORDER BY name`
+	groups := []entities.Group{}
+	if err := s.db.SelectContext(ctx, &groups, q, userGuid); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return groups, nil
+}
@@ -0,0 +1,137 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testovoe_medods/entities"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type InvitationRepository interface {
+	// stores invitation, pending invitations sent earlier to the same email are replaced
+	CreateInvitation(ctx context.Context, data *entities.TenantInvitation) (*entities.TenantInvitation, error)
+	// invitations that are neither accepted nor expired
+	ListPendingInvitations(ctx context.Context, tenantID string) ([]entities.TenantInvitation, error)
+	DeletePendingInvitation(ctx context.Context, tenantID string, id uuid.UUID) error
+	// consumes invitation, creates user for its email when there is none and adds the user to tenant.
+	// Existing membership keeps its role
+	AcceptInvitation(ctx context.Context, tokenHash string) (*entities.TenantInvitation, error)
+}
+
+type invitationRepository struct {
+	db *sqlx.DB
+}
+
+func NewInvitationRepository(db *sqlx.DB) InvitationRepository {
+	return &invitationRepository{
+		db: db,
+	}
+}
+
+const invitationColumns = `id, tenant_id, email, role, token_hash, invited_by, accepted_by, expires_at, accepted_at, created_at`
+
+func (s *invitationRepository) CreateInvitation(ctx context.Context, data *entities.TenantInvitation) (*entities.TenantInvitation, error) {
+	const op = "repo.CreateInvitation"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	q := `DELETE FROM tenant_invitations WHERE tenant_id = $1 AND lower(email) = lower($2) AND accepted_at IS NULL`
+	if _, err := tx.ExecContext(ctx, q, data.TenantID, data.Email); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	q = `INSERT INTO tenant_invitations (tenant_id, email, role, token_hash, invited_by, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING ` + invitationColumns
+	var invitation entities.TenantInvitation
+	err = tx.GetContext(ctx, &invitation, q, data.TenantID, data.Email, data.Role, data.TokenHash, data.InvitedBy, data.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &invitation, nil
+}
+
+func (s *invitationRepository) ListPendingInvitations(ctx context.Context, tenantID string) ([]entities.TenantInvitation, error) {
+	const op = "repo.ListPendingInvitations"
+
+	q := "SELECT " + invitationColumns + ` FROM tenant_invitations
+	WHERE tenant_id = $1 AND accepted_at IS NULL AND expires_at > now() ORDER BY created_at`
+	invitations := []entities.TenantInvitation{}
+	if err := s.db.SelectContext(ctx, &invitations, q, tenantID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return invitations, nil
+}
+
+func (s *invitationRepository) DeletePendingInvitation(ctx context.Context, tenantID string, id uuid.UUID) error {
+	const op = "repo.DeletePendingInvitation"
+
+	q := "DELETE FROM tenant_invitations WHERE tenant_id = $1 AND id = $2 AND accepted_at IS NULL"
+	res, err := s.db.ExecContext(ctx, q, tenantID, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityNotExists
+	}
+	return nil
+}
+
+func (s *invitationRepository) AcceptInvitation(ctx context.Context, tokenHash string) (*entities.TenantInvitation, error) {
+	const op = "repo.AcceptInvitation"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	q := `UPDATE tenant_invitations SET accepted_at = now()
+	WHERE token_hash = $1 AND accepted_at IS NULL AND expires_at > now() RETURNING ` + invitationColumns
+	var invitation entities.TenantInvitation
+	err = tx.GetContext(ctx, &invitation, q, tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// insert goes first so concurrent acceptance for the same email ends up with one user
+	var userGuid uuid.UUID
+	q = "INSERT INTO users (email) VALUES ($1) ON CONFLICT ((lower(email))) DO NOTHING RETURNING id"
+	err = tx.GetContext(ctx, &userGuid, q, invitation.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = tx.GetContext(ctx, &userGuid, "SELECT id FROM users WHERE lower(email) = lower($1)", invitation.Email)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	q = `INSERT INTO tenant_members (tenant_id, user_guid, role) VALUES ($1, $2, $3)
+	ON CONFLICT (tenant_id, user_guid) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, q, invitation.TenantID, userGuid, invitation.Role); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE tenant_invitations SET accepted_by = $2 WHERE id = $1", invitation.ID, userGuid); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	invitation.AcceptedBy = &userGuid
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &invitation, nil
+}
@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testovoe_medods/entities"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type LockoutRepository interface {
+	GetAttempts(ctx context.Context, key string) (*entities.AuthAttempts, error)
+	// increments failures counter, counter starts over when last failure is older than windowStart.
+	// Key is locked until lockUntil by the same statement once failures reach threshold
+	RegisterFailure(ctx context.Context, key string, windowStart time.Time, threshold int, lockUntil time.Time) (*entities.AuthAttempts, error)
+	Reset(ctx context.Context, key string) error
+}
+
+type lockoutRepository struct {
+	db *sqlx.DB
+}
+
+func NewLockoutRepository(db *sqlx.DB) LockoutRepository {
+	return &lockoutRepository{
+		db: db,
+	}
+}
+
+func (s *lockoutRepository) GetAttempts(ctx context.Context, key string) (*entities.AuthAttempts, error) {
+	const op = "repo.GetAttempts"
+
+	q := "SELECT key, failures, last_failure_at, locked_until FROM auth_attempts WHERE key = $1"
+	var attempts entities.AuthAttempts
+	err := s.db.GetContext(ctx, &attempts, q, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &attempts, nil
+}
+
+func (s *lockoutRepository) RegisterFailure(ctx context.Context, key string, windowStart time.Time, threshold int, lockUntil time.Time) (*entities.AuthAttempts, error) {
+	const op = "repo.RegisterFailure"
+
+	q := `INSERT INTO auth_attempts AS a (key, failures, last_failure_at, locked_until)
+	VALUES ($1, 1, now(), CASE WHEN $3 <= 1 THEN $4::timestamptz END)
+	ON CONFLICT (key) DO UPDATE SET
+		failures = CASE WHEN a.last_failure_at < $2 THEN 1 ELSE a.failures + 1 END,
+		last_failure_at = now(),
+		locked_until = CASE WHEN (CASE WHEN a.last_failure_at < $2 THEN 1 ELSE a.failures + 1 END) >= $3
+			THEN $4::timestamptz ELSE a.locked_until END
+	RETURNING key, failures, last_failure_at, locked_until`
+	var attempts entities.AuthAttempts
+	err := s.db.GetContext(ctx, &attempts, q, key, windowStart, threshold, lockUntil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &attempts, nil
+}
+
+func (s *lockoutRepository) Reset(ctx context.Context, key string) error {
+	const op = "repo.Reset"
+
+	_, err := s.db.ExecContext(ctx, "DELETE FROM auth_attempts WHERE key = $1", key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"testovoe_medods/entities"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// queues logout notification for clients with logout uri whose sessions match condition appended to the query,
+// it has to run before the sessions are deleted
+const enqueueLogoutsQ = `INSERT INTO backchannel_logouts (client_id, user_guid, sid)
+	SELECT DISTINCT s.client_id, s.user_guid, s.sso_session_id FROM users_auth_info s
+	JOIN oauth_clients c ON c.id = s.client_id
+	WHERE c.backchannel_logout_uri IS NOT NULL AND `
+
+type LogoutRepository interface {
+	// deletes session together with client sessions started from it and queues notifications for their clients
+	RevokeSession(ctx context.Context, sessionID uuid.UUID) error
+	// returns due notifications and postpones them by lease, so concurrent workers don't pick the same ones
+	ClaimDueLogouts(ctx context.Context, limit int, lease time.Duration) ([]entities.BackchannelLogout, error)
+	DeleteLogout(ctx context.Context, id uuid.UUID) error
+	RescheduleLogout(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error
+}
+
+type logoutRepository struct {
+	db *sqlx.DB
+}
+
+func NewLogoutRepository(db *sqlx.DB) LogoutRepository {
+	return &logoutRepository{
+		db: db,
+	}
+}
+
+func (s *logoutRepository) RevokeSession(ctx context.Context, sessionID uuid.UUID) error {
+	const op = "repo.RevokeSession"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, enqueueLogoutsQ+"(s.id = $1 OR s.sso_session_id = $1)", sessionID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// client sessions are removed by cascade on sso_session_id
+	res, err := tx.ExecContext(ctx, "DELETE FROM users_auth_info WHERE id = $1", sessionID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityNotExists
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *logoutRepository) ClaimDueLogouts(ctx context.Context, limit int, lease time.Duration) ([]entities.BackchannelLogout, error) {
+	const op = "repo.ClaimDueLogouts"
+
+	q := `UPDATE backchannel_logouts SET attempts = attempts + 1, next_attempt_at = $2
+	WHERE id IN (SELECT id FROM backchannel_logouts WHERE next_attempt_at <= now()
+	ORDER BY next_attempt_at LIMIT $1 FOR UPDATE SKIP LOCKED)
+	RETURNING id, client_id, user_guid, sid, attempts`
+	logouts := []entities.BackchannelLogout{}
+	if err := s.db.SelectContext(ctx, &logouts, q, limit, time.Now().Add(lease)); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return logouts, nil
+}
+
+func (s *logoutRepository) DeleteLogout(ctx context.Context, id uuid.UUID) error {
+	const op = "repo.DeleteLogout"
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM backchannel_logouts WHERE id = $1", id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *logoutRepository) RescheduleLogout(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error {
+	const op = "repo.RescheduleLogout"
+
+	q := "UPDATE backchannel_logouts SET next_attempt_at = $2, last_error = $3 WHERE id = $1"
+	if _, err := s.db.ExecContext(ctx, q, id, nextAttemptAt, lastError); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
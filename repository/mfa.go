@@ -0,0 +1,196 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testovoe_medods/entities"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type MFARepository interface {
+	UpsertPendingTOTP(ctx context.Context, userGuid uuid.UUID, secretEnc string) error
+	GetTOTP(ctx context.Context, userGuid uuid.UUID) (*entities.UserTOTP, error)
+	ConfirmTOTP(ctx context.Context, userGuid uuid.UUID, step int64) error
+	ConsumeTOTPStep(ctx context.Context, userGuid uuid.UUID, step int64) (bool, error)
+	// removes totp secret. Recovery codes are removed as well unless the user still has passkeys
+	// they stay a fallback for
+	DeleteTOTP(ctx context.Context, userGuid uuid.UUID) error
+	ReplaceRecoveryCodes(ctx context.Context, userGuid uuid.UUID, codeHashes []string) error
+	ConsumeRecoveryCode(ctx context.Context, userGuid uuid.UUID, codeHash string) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userGuid uuid.UUID) (int, error)
+	// records jti of completed mfa challenge, returns ErrEntityAlreadyExists when it was already used
+	UseChallenge(ctx context.Context, jti string, expiresAt time.Time) error
+}
+
+type mfaRepository struct {
+	db *sqlx.DB
+}
+
+func NewMFARepository(db *sqlx.DB) MFARepository {
+	return &mfaRepository{
+		db: db,
+	}
+}
+
+// stores new unconfirmed secret. Confirmed secrets are never overwritten
+func (s *mfaRepository) UpsertPendingTOTP(ctx context.Context, userGuid uuid.UUID, secretEnc string) error {
+	const op = "repo.UpsertPendingTOTP"
+
+	q := `INSERT INTO users_totp (user_guid, secret_enc) VALUES ($1, $2)
+	ON CONFLICT (user_guid) DO UPDATE SET secret_enc = EXCLUDED.secret_enc, last_used_step = 0
+	WHERE users_totp.confirmed_at IS NULL`
+	res, err := s.db.ExecContext(ctx, q, userGuid, secretEnc)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityAlreadyExists
+	}
+	return nil
+}
+
+func (s *mfaRepository) GetTOTP(ctx context.Context, userGuid uuid.UUID) (*entities.UserTOTP, error) {
+	const op = "repo.GetTOTP"
+
+	q := "SELECT user_guid, secret_enc, confirmed_at, last_used_step FROM users_totp WHERE user_guid = $1"
+	var totp entities.UserTOTP
+	err := s.db.GetContext(ctx, &totp, q, userGuid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &totp, nil
+}
+
+func (s *mfaRepository) ConfirmTOTP(ctx context.Context, userGuid uuid.UUID, step int64) error {
+	const op = "repo.ConfirmTOTP"
+
+	q := "UPDATE users_totp SET confirmed_at = now(), last_used_step = $2 WHERE user_guid = $1 AND confirmed_at IS NULL"
+	res, err := s.db.ExecContext(ctx, q, userGuid, step)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityNotExists
+	}
+	return nil
+}
+
+// marks totp step as used. Returns false if the step (or a later one) was already used
+func (s *mfaRepository) ConsumeTOTPStep(ctx context.Context, userGuid uuid.UUID, step int64) (bool, error) {
+	const op = "repo.ConsumeTOTPStep"
+
+	q := "UPDATE users_totp SET last_used_step = $2 WHERE user_guid = $1 AND last_used_step < $2"
+	res, err := s.db.ExecContext(ctx, q, userGuid, step)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return n == 1, nil
+}
+
+func (s *mfaRepository) DeleteTOTP(ctx context.Context, userGuid uuid.UUID) error {
+	const op = "repo.DeleteTOTP"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM users_totp WHERE user_guid = $1", userGuid); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	q := `DELETE FROM mfa_recovery_codes WHERE user_guid = $1
+	AND NOT EXISTS (SELECT 1 FROM webauthn_credentials WHERE user_guid = $1)`
+	if _, err := tx.ExecContext(ctx, q, userGuid); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// drops previous set of recovery codes, used or not, and stores the new one
+func (s *mfaRepository) ReplaceRecoveryCodes(ctx context.Context, userGuid uuid.UUID, codeHashes []string) error {
+	const op = "repo.ReplaceRecoveryCodes"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_guid = $1", userGuid); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, hash := range codeHashes {
+		_, err := tx.ExecContext(ctx, "INSERT INTO mfa_recovery_codes (user_guid, code_hash) VALUES ($1, $2)", userGuid, hash)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *mfaRepository) ConsumeRecoveryCode(ctx context.Context, userGuid uuid.UUID, codeHash string) (bool, error) {
+	const op = "repo.ConsumeRecoveryCode"
+
+	q := "UPDATE mfa_recovery_codes SET used_at = now() WHERE user_guid = $1 AND code_hash = $2 AND used_at IS NULL"
+	res, err := s.db.ExecContext(ctx, q, userGuid, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return n == 1, nil
+}
+
+// counts unused recovery codes
+func (s *mfaRepository) CountRecoveryCodes(ctx context.Context, userGuid uuid.UUID) (int, error) {
+	const op = "repo.CountRecoveryCodes"
+
+	var count int
+	q := "SELECT count(*) FROM mfa_recovery_codes WHERE user_guid = $1 AND used_at IS NULL"
+	if err := s.db.GetContext(ctx, &count, q, userGuid); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return count, nil
+}
+
+func (s *mfaRepository) UseChallenge(ctx context.Context, jti string, expiresAt time.Time) error {
+	const op = "repo.UseChallenge"
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM mfa_used_challenges WHERE expires_at < now()"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	q := "INSERT INTO mfa_used_challenges (jti, expires_at) VALUES ($1, $2) ON CONFLICT DO NOTHING"
+	res, err := s.db.ExecContext(ctx, q, jti, expiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityAlreadyExists
+	}
+	return nil
+}
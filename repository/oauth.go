@@ -0,0 +1,130 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testovoe_medods/entities"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type OAuthRepository interface {
+	CreateAuthorizationCode(ctx context.Context, data *entities.AuthorizationCode) error
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*entities.AuthorizationCode, error)
+	// records jti of a jwt assertion, returns ErrEntityAlreadyExists when it was already used
+	UseAssertionJTI(ctx context.Context, issuer, jti string, expiresAt time.Time) error
+	CreatePushedRequest(ctx context.Context, data *entities.PushedAuthorizationRequest) error
+	GetPushedRequest(ctx context.Context, requestURIHash string) (*entities.PushedAuthorizationRequest, error)
+	DeletePushedRequest(ctx context.Context, requestURIHash string) error
+}
+
+type oauthRepository struct {
+	db *sqlx.DB
+}
+
+func NewOAuthRepository(db *sqlx.DB) OAuthRepository {
+	return &oauthRepository{
+		db: db,
+	}
+}
+
+func (s *oauthRepository) CreateAuthorizationCode(ctx context.Context, data *entities.AuthorizationCode) error {
+	const op = "repo.CreateAuthorizationCode"
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM oauth_authorization_codes WHERE expires_at < now()"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	q := `INSERT INTO oauth_authorization_codes (code_hash, client_id, user_guid, redirect_uri, scope, nonce,
+	code_challenge, code_challenge_method, sso_session_id, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err := s.db.ExecContext(ctx, q, data.CodeHash, data.ClientID, data.UserGuid, data.RedirectURI, data.Scope,
+		data.Nonce, data.CodeChallenge, data.CodeChallengeMethod, data.SSOSessionID, data.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// codes are single use, consuming deletes the row so a replayed code is reported as not existing
+func (s *oauthRepository) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*entities.AuthorizationCode, error) {
+	const op = "repo.ConsumeAuthorizationCode"
+
+	q := `DELETE FROM oauth_authorization_codes WHERE code_hash = $1 AND expires_at > now()
+	RETURNING code_hash, client_id, user_guid, redirect_uri, scope, nonce, code_challenge, code_challenge_method,
+	sso_session_id, expires_at`
+	var code entities.AuthorizationCode
+	err := s.db.GetContext(ctx, &code, q, codeHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &code, nil
+}
+
+func (s *oauthRepository) UseAssertionJTI(ctx context.Context, issuer, jti string, expiresAt time.Time) error {
+	const op = "repo.UseAssertionJTI"
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM oauth_assertion_jtis WHERE expires_at < now()"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	q := "INSERT INTO oauth_assertion_jtis (issuer, jti, expires_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING"
+	res, err := s.db.ExecContext(ctx, q, issuer, jti, expiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityAlreadyExists
+	}
+	return nil
+}
+
+func (s *oauthRepository) CreatePushedRequest(ctx context.Context, data *entities.PushedAuthorizationRequest) error {
+	const op = "repo.CreatePushedRequest"
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM oauth_pushed_requests WHERE expires_at < now()"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	q := "INSERT INTO oauth_pushed_requests (request_uri_hash, client_id, request, expires_at) VALUES ($1, $2, $3, $4)"
+	_, err := s.db.ExecContext(ctx, q, data.RequestURIHash, data.ClientID, data.Request, data.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// request stays until the browser completes authorization, it may come back after login
+func (s *oauthRepository) GetPushedRequest(ctx context.Context, requestURIHash string) (*entities.PushedAuthorizationRequest, error) {
+	const op = "repo.GetPushedRequest"
+
+	q := `SELECT request_uri_hash, client_id, request, expires_at FROM oauth_pushed_requests
+	WHERE request_uri_hash = $1 AND expires_at > now()`
+	var pushed entities.PushedAuthorizationRequest
+	err := s.db.GetContext(ctx, &pushed, q, requestURIHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &pushed, nil
+}
+
+func (s *oauthRepository) DeletePushedRequest(ctx context.Context, requestURIHash string) error {
+	const op = "repo.DeletePushedRequest"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM oauth_pushed_requests WHERE request_uri_hash = $1", requestURIHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityNotExists
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testovoe_medods/entities"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type PasswordRepository interface {
+	CreatePasswordResetToken(ctx context.Context, userGuid uuid.UUID, tokenHash string, expiresAt time.Time) error
+	ResetPassword(ctx context.Context, tokenHash, passwordHash string) (uuid.UUID, error)
+}
+
+type passwordRepository struct {
+	db *sqlx.DB
+}
+
+func NewPasswordRepository(db *sqlx.DB) PasswordRepository {
+	return &passwordRepository{
+		db: db,
+	}
+}
+
+func (s *passwordRepository) CreatePasswordResetToken(ctx context.Context, userGuid uuid.UUID, tokenHash string, expiresAt time.Time) error {
+	const op = "repo.CreatePasswordResetToken"
+
+	q := "INSERT INTO password_reset_tokens (user_guid, token_hash, expires_at) VALUES ($1, $2, $3)"
+	_, err := s.db.ExecContext(ctx, q, userGuid, tokenHash, expiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// consumes reset token, sets new password hash and revokes every session of the user in one transaction
+func (s *passwordRepository) ResetPassword(ctx context.Context, tokenHash, passwordHash string) (uuid.UUID, error) {
+	const op = "repo.ResetPassword"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var token entities.PasswordResetToken
+	consumeQ := `UPDATE password_reset_tokens SET used_at = now()
+	WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+	RETURNING id, user_guid, token_hash, expires_at, used_at`
+	err = tx.GetContext(ctx, &token, consumeQ, tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET password_hash = $1 WHERE id = $2", passwordHash, token.UserGuid); err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// other outstanding reset links must not outlive the new password
+	if _, err := tx.ExecContext(ctx, "DELETE FROM password_reset_tokens WHERE user_guid = $1 AND used_at IS NULL", token.UserGuid); err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, enqueueLogoutsQ+"s.user_guid = $1", token.UserGuid); err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM users_auth_info WHERE user_guid = $1", token.UserGuid); err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return token.UserGuid, nil
+}
@@ -0,0 +1,108 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testovoe_medods/entities"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type PasswordlessRepository interface {
+	CreateLogin(ctx context.Context, data *entities.PasswordlessLogin) error
+	GetActiveLoginByStateHash(ctx context.Context, stateHash string) (*entities.PasswordlessLogin, error)
+	ConsumeLoginByToken(ctx context.Context, tokenHash, stateHash string) (uuid.UUID, error)
+	ConsumeLogin(ctx context.Context, id uuid.UUID) (bool, error)
+	// takes one code attempt of active login, false when all maxAttempts were already used
+	UseAttempt(ctx context.Context, id uuid.UUID, maxAttempts int) (bool, error)
+}
+
+type passwordlessRepository struct {
+	db *sqlx.DB
+}
+
+func NewPasswordlessRepository(db *sqlx.DB) PasswordlessRepository {
+	return &passwordlessRepository{
+		db: db,
+	}
+}
+
+func (s *passwordlessRepository) CreateLogin(ctx context.Context, data *entities.PasswordlessLogin) error {
+	const op = "repo.CreateLogin"
+
+	q := `INSERT INTO passwordless_logins (user_guid, state_hash, token_hash, code_hash, expires_at)
+	VALUES ($1, $2, $3, $4, $5)`
+	_, err := s.db.ExecContext(ctx, q, data.UserGuid, data.StateHash, data.TokenHash, data.CodeHash, data.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *passwordlessRepository) GetActiveLoginByStateHash(ctx context.Context, stateHash string) (*entities.PasswordlessLogin, error) {
+	const op = "repo.GetActiveLoginByStateHash"
+
+	q := `SELECT id, user_guid, state_hash, token_hash, code_hash, attempts, expires_at, consumed_at
+	FROM passwordless_logins WHERE state_hash = $1 AND consumed_at IS NULL AND expires_at > now()`
+	var login entities.PasswordlessLogin
+	err := s.db.GetContext(ctx, &login, q, stateHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &login, nil
+}
+
+// consumes magic link only when it's opened in the browser that requested it
+func (s *passwordlessRepository) ConsumeLoginByToken(ctx context.Context, tokenHash, stateHash string) (uuid.UUID, error) {
+	const op = "repo.ConsumeLoginByToken"
+
+	q := `UPDATE passwordless_logins SET consumed_at = now()
+	WHERE token_hash = $1 AND state_hash = $2 AND consumed_at IS NULL AND expires_at > now()
+	RETURNING user_guid`
+	var userGuid uuid.UUID
+	err := s.db.GetContext(ctx, &userGuid, q, tokenHash, stateHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return userGuid, nil
+}
+
+func (s *passwordlessRepository) ConsumeLogin(ctx context.Context, id uuid.UUID) (bool, error) {
+	const op = "repo.ConsumeLogin"
+
+	res, err := s.db.ExecContext(ctx, "UPDATE passwordless_logins SET consumed_at = now() WHERE id = $1 AND consumed_at IS NULL", id)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return n == 1, nil
+}
+
+// counter is checked and incremented in one statement so parallel guesses can't exceed the limit
+func (s *passwordlessRepository) UseAttempt(ctx context.Context, id uuid.UUID, maxAttempts int) (bool, error) {
+	const op = "repo.UseAttempt"
+
+	q := `UPDATE passwordless_logins SET attempts = attempts + 1
+	WHERE id = $1 AND attempts < $2 AND consumed_at IS NULL AND expires_at > now()`
+	res, err := s.db.ExecContext(ctx, q, id, maxAttempts)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,296 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testovoe_medods/entities"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type RoleRepository interface {
+	CreatePermission(ctx context.Context, data *entities.Permission) (*entities.Permission, error)
+	ListPermissions(ctx context.Context) ([]entities.Permission, error)
+	// permission is removed from every role that had it
+	DeletePermission(ctx context.Context, name string) error
+	CreateRole(ctx context.Context, data *entities.Role) (*entities.Role, error)
+	GetRole(ctx context.Context, name string) (*entities.Role, error)
+	ListRoles(ctx context.Context) ([]entities.Role, error)
+	// replaces description and permission set of the role
+	UpdateRole(ctx context.Context, data *entities.Role) (*entities.Role, error)
+	DeleteRole(ctx context.Context, name string) error
+	// assigning role user already has is a no-op
+	AssignRole(ctx context.Context, userGuid uuid.UUID, role string) error
+	UnassignRole(ctx context.Context, userGuid uuid.UUID, role string) error
+	ListAssignments(ctx context.Context, userGuid uuid.UUID) ([]entities.RoleAssignment, error)
+	// names of roles assigned to user, sorted
+	GetUserRoles(ctx context.Context, userGuid uuid.UUID) ([]string, error)
+	// whether any role assigned to user grants permission
+	HasPermission(ctx context.Context, userGuid uuid.UUID, permission string) (bool, error)
+	// permissions granted by every role assigned to user, sorted
+	GetUserPermissions(ctx context.Context, userGuid uuid.UUID) ([]string, error)
+}
+
+type roleRepository struct {
+	db *sqlx.DB
+}
+
+func NewRoleRepository(db *sqlx.DB) RoleRepository {
+	return &roleRepository{
+		db: db,
+	}
+}
+
+const roleColumns = `roles.name, roles.description, roles.created_at, roles.updated_at,
+	COALESCE((SELECT jsonb_agg(permission ORDER BY permission) FROM role_permissions
+		WHERE role_permissions.role_name = roles.name), '[]') AS permissions`
+
+func (s *roleRepository) CreatePermission(ctx context.Context, data *entities.Permission) (*entities.Permission, error) {
+	const op = "repo.CreatePermission"
+
+	q := `INSERT INTO permissions (name, description) VALUES ($1, $2)
+	ON CONFLICT (name) DO NOTHING RETURNING name, description, created_at`
+	var permission entities.Permission
+	err := s.db.GetContext(ctx, &permission, q, data.Name, data.Description)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityAlreadyExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &permission, nil
+}
+
+func (s *roleRepository) ListPermissions(ctx context.Context) ([]entities.Permission, error) {
+	const op = "repo.ListPermissions"
+
+	permissions := []entities.Permission{}
+	q := "SELECT name, description, created_at FROM permissions ORDER BY name"
+	if err := s.db.SelectContext(ctx, &permissions, q); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return permissions, nil
+}
+
+func (s *roleRepository) DeletePermission(ctx context.Context, name string) error {
+	const op = "repo.DeletePermission"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM permissions WHERE name = $1", name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityNotExists
+	}
+	return nil
+}
+
+func (s *roleRepository) CreateRole(ctx context.Context, data *entities.Role) (*entities.Role, error) {
+	const op = "repo.CreateRole"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	q := "INSERT INTO roles (name, description) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING"
+	res, err := tx.ExecContext(ctx, q, data.Name, data.Description)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrEntityAlreadyExists
+	}
+
+	if err := setRolePermissions(ctx, tx, data.Name, data.Permissions); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var role entities.Role
+	if err := tx.GetContext(ctx, &role, "SELECT "+roleColumns+" FROM roles WHERE name = $1", data.Name); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &role, nil
+}
+
+func (s *roleRepository) GetRole(ctx context.Context, name string) (*entities.Role, error) {
+	const op = "repo.GetRole"
+
+	var role entities.Role
+	err := s.db.GetContext(ctx, &role, "SELECT "+roleColumns+" FROM roles WHERE name = $1", name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &role, nil
+}
+
+func (s *roleRepository) ListRoles(ctx context.Context) ([]entities.Role, error) {
+	const op = "repo.ListRoles"
+
+	roles := []entities.Role{}
+	if err := s.db.SelectContext(ctx, &roles, "SELECT "+roleColumns+" FROM roles ORDER BY name"); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return roles, nil
+}
+
+func (s *roleRepository) UpdateRole(ctx context.Context, data *entities.Role) (*entities.Role, error) {
+	const op = "repo.UpdateRole"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	q := "UPDATE roles SET description = $2, updated_at = now() WHERE name = $1"
+	res, err := tx.ExecContext(ctx, q, data.Name, data.Description)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrEntityNotExists
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM role_permissions WHERE role_name = $1", data.Name); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := setRolePermissions(ctx, tx, data.Name, data.Permissions); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var role entities.Role
+	if err := tx.GetContext(ctx, &role, "SELECT "+roleColumns+" FROM roles WHERE name = $1", data.Name); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &role, nil
+}
+
+// unknown permissions are reported as ErrEntityNotExists
+func setRolePermissions(ctx context.Context, tx *sqlx.Tx, role string, permissions []string) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+	q := `INSERT INTO role_permissions (role_name, permission)
+	SELECT $1, name FROM permissions WHERE name = ANY($2) ON CONFLICT DO NOTHING`
+	res, err := tx.ExecContext(ctx, q, role, permissions)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); int(n) != len(permissions) {
+		return ErrEntityNotExists
+	}
+	return nil
+}
+
+func (s *roleRepository) DeleteRole(ctx context.Context, name string) error {
+	const op = "repo.DeleteRole"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM roles WHERE name = $1", name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityNotExists
+	}
+	return nil
+}
+
+func (s *roleRepository) AssignRole(ctx context.Context, userGuid uuid.UUID, role string) error {
+	const op = "repo.AssignRole"
+
+	q := `INSERT INTO user_roles (user_guid, role_name) SELECT users.id, roles.name FROM users, roles
+	WHERE users.id = $1 AND roles.name = $2 ON CONFLICT DO NOTHING RETURNING user_guid`
+	var guid uuid.UUID
+	err := s.db.GetContext(ctx, &guid, q, userGuid, role)
+	if errors.Is(err, sql.ErrNoRows) {
+		// either assignment already exists or user or role is missing
+		var exists bool
+		q = "SELECT EXISTS (SELECT 1 FROM user_roles WHERE user_guid = $1 AND role_name = $2)"
+		if err := s.db.GetContext(ctx, &exists, q, userGuid, role); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if !exists {
+			return ErrEntityNotExists
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *roleRepository) UnassignRole(ctx context.Context, userGuid uuid.UUID, role string) error {
+	const op = "repo.UnassignRole"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM user_roles WHERE user_guid = $1 AND role_name = $2", userGuid, role)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityNotExists
+	}
+	return nil
+}
+
+func (s *roleRepository) ListAssignments(ctx context.Context, userGuid uuid.UUID) ([]entities.RoleAssignment, error) {
+	const op = "repo.ListAssignments"
+
+	assignments := []entities.RoleAssignment{}
+	q := "SELECT user_guid, role_name, created_at FROM user_roles WHERE user_guid = $1 ORDER BY role_name"
+	if err := s.db.SelectContext(ctx, &assignments, q, userGuid); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return assignments, nil
+}
+
+func (s *roleRepository) GetUserRoles(ctx context.Context, userGuid uuid.UUID) ([]string, error) {
+	const op = "repo.GetUserRoles"
+
+	var roles []string
+	q := "SELECT role_name FROM user_roles WHERE user_guid = $1 ORDER BY role_name"
+	if err := s.db.SelectContext(ctx, &roles, q, userGuid); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return roles, nil
+}
+
+func (s *roleRepository) HasPermission(ctx context.Context, userGuid uuid.UUID, permission string) (bool, error) {
+	const op = "repo.HasPermission"
+
+	q := `SELECT EXISTS (SELECT 1 FROM user_roles ur JOIN role_permissions rp ON rp.role_name = ur.role_name
+	WHERE ur.user_guid = $1 AND rp.permission = $2)`
+	var ok bool
+	if err := s.db.GetContext(ctx, &ok, q, userGuid, permission); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return ok, nil
+}
+
+func (s *roleRepository) GetUserPermissions(ctx context.Context, userGuid uuid.UUID) ([]string, error) {
+	const op = "repo.GetUserPermissions"
+
+	permissions := []string{}
+	q := `SELECT DISTINCT rp.permission FROM user_roles ur JOIN role_permissions rp ON rp.role_name = ur.role_name
+	WHERE ur.user_guid = $1 ORDER BY rp.permission`
+	if err := s.db.SelectContext(ctx, &permissions, q, userGuid); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return permissions, nil
+}
@@ -0,0 +1,324 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testovoe_medods/entities"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// writes of scim provisioning, each resource write is one transaction so partial provisioning isn't visible
+type SCIMRepository interface {
+	// page of users matching filter together with total number of matches, nil filter matches everyone
+	ListUsers(ctx context.Context, filter *entities.SCIMFilter, offset, limit int) ([]entities.User, int, error)
+	CreateUser(ctx context.Context, data *entities.User) (*entities.User, error)
+	// replaces email, external id and disabled flag, disabling revokes every session of the user
+	ReplaceUser(ctx context.Context, data *entities.User) (*entities.User, error)
+	ListGroups(ctx context.Context, filter *entities.SCIMFilter, offset, limit int) ([]entities.Group, int, error)
+	// members of all given groups, members are user guids
+	ListGroupMembers(ctx context.Context, groupIDs []string) ([]entities.GroupMember, error)
+	// unknown member guids are reported as ErrEntityNotExists
+	CreateGroup(ctx context.Context, data *entities.Group, members []string) (*entities.Group, error)
+	// replaces name, external id and the whole member list
+	ReplaceGroup(ctx context.Context, data *entities.Group, members []string) (*entities.Group, error)
+}
+
+type scimRepository struct {
+	db *sqlx.DB
+}
+
+func NewSCIMRepository(db *sqlx.DB) SCIMRepository {
+	return &scimRepository{
+		db: db,
+	}
+}
+
+var ErrUnsupportedFilter = errors.New("filter attribute or operator isn't supported")
+
+type scimColumn struct {
+	name string
+	// emails and names are compared case-insensitively, ids exactly
+	caseExact bool
+	// boolean columns support eq and ne only
+	boolean bool
+}
+
+var scimUserColumns = map[string]scimColumn{
+	entities.SCIMAttrID:         {name: "id::text", caseExact: true},
+	entities.SCIMAttrExternalID: {name: "external_id", caseExact: true},
+	entities.SCIMAttrUserName:   {name: "email"},
+	entities.SCIMAttrActive:     {name: "NOT disabled", boolean: true},
+}
+
+var scimGroupColumns = map[string]scimColumn{
+	entities.SCIMAttrID:          {name: "id::text", caseExact: true},
+	entities.SCIMAttrExternalID:  {name: "external_id", caseExact: true},
+	entities.SCIMAttrDisplayName: {name: "name"},
+}
+
+// where condition for filter, the value is always $1
+func scimCondition(filter *entities.SCIMFilter, columns map[string]scimColumn) (string, []any, error) {
+	if filter == nil {
+		return "true", nil, nil
+	}
+	column, ok := columns[filter.Attribute]
+	if !ok {
+		return "", nil, ErrUnsupportedFilter
+	}
+
+	if column.boolean {
+		if filter.Value != "true" && filter.Value != "false" {
+			return "", nil, ErrUnsupportedFilter
+		}
+		switch filter.Operator {
+		case "eq":
+			return "(" + column.name + ") = $1::boolean", []any{filter.Value}, nil
+		case "ne":
+			return "(" + column.name + ") <> $1::boolean", []any{filter.Value}, nil
+		}
+		return "", nil, ErrUnsupportedFilter
+	}
+
+	col, val := column.name, "$1"
+	if !column.caseExact {
+		col, val = "lower("+col+")", "lower($1)"
+	}
+	switch filter.Operator {
+	case "pr":
+		return column.name + " IS NOT NULL", nil, nil
+	case "eq":
+		return col + " = " + val, []any{filter.Value}, nil
+	case "ne":
+		return col + " IS DISTINCT FROM " + val, []any{filter.Value}, nil
+	case "co":
+		return "position(" + val + " in " + col + ") > 0", []any{filter.Value}, nil
+	case "sw":
+		return "starts_with(" + col + ", " + val + ")", []any{filter.Value}, nil
+	case "ew":
+		return "right(" + col + ", length($1)) = " + val, []any{filter.Value}, nil
+	}
+	return "", nil, ErrUnsupportedFilter
+}
+
+func (s *scimRepository) ListUsers(ctx context.Context, filter *entities.SCIMFilter, offset, limit int) ([]entities.User, int, error) {
+	const op = "repo.ListSCIMUsers"
+
+	cond, args, err := scimCondition(filter, scimUserColumns)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var total int
+	if err := s.db.GetContext(ctx, &total, "SELECT count(*) FROM users WHERE "+cond, args...); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n := len(args)
+	q := fmt.Sprintf("SELECT %s FROM users WHERE %s ORDER BY created_at, id LIMIT $%d OFFSET $%d", userColumns, cond, n+1, n+2)
+	users := []entities.User{}
+	if err := s.db.SelectContext(ctx, &users, q, append(args, limit, offset)...); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return users, total, nil
+}
+
+func (s *scimRepository) CreateUser(ctx context.Context, data *entities.User) (*entities.User, error) {
+	const op = "repo.CreateSCIMUser"
+
+	q := `INSERT INTO users (email, external_id, disabled) VALUES ($1, $2, $3)
+	ON CONFLICT ((lower(email))) DO NOTHING RETURNING ` + userColumns
+	var user entities.User
+	err := s.db.GetContext(ctx, &user, q, data.Email, data.ExternalID, data.Disabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityAlreadyExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &user, nil
+}
+
+func (s *scimRepository) ReplaceUser(ctx context.Context, data *entities.User) (*entities.User, error) {
+	const op = "repo.ReplaceSCIMUser"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)", data.ID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		return nil, ErrEntityNotExists
+	}
+
+	q := `UPDATE users SET email = $2, external_id = $3, disabled = $4, updated_at = now()
+	WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM users WHERE lower(email) = lower($2) AND id <> $1) RETURNING ` + userColumns
+	var user entities.User
+	err = tx.GetContext(ctx, &user, q, data.ID, data.Email, data.ExternalID, data.Disabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityAlreadyExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// deprovisioned users lose their sessions right away
+	if data.Disabled {
+		if _, err := tx.ExecContext(ctx, enqueueLogoutsQ+"s.user_guid = $1", data.ID); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM users_auth_info WHERE user_guid = $1", data.ID); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &user, nil
+}
+
+func (s *scimRepository) ListGroups(ctx context.Context, filter *entities.SCIMFilter, offset, limit int) ([]entities.Group, int, error) {
+	const op = "repo.ListSCIMGroups"
+
+	cond, args, err := scimCondition(filter, scimGroupColumns)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var total int
+	if err := s.db.GetContext(ctx, &total, "SELECT count(*) FROM groups WHERE "+cond, args...); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n := len(args)
+	q := fmt.Sprintf("SELECT %s FROM groups WHERE %s ORDER BY created_at, id LIMIT $%d OFFSET $%d", groupColumns, cond, n+1, n+2)
+	groups := []entities.Group{}
+	if err := s.db.SelectContext(ctx, &groups, q, append(args, limit, offset)...); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return groups, total, nil
+}
+
+func (s *scimRepository) ListGroupMembers(ctx context.Context, groupIDs []string) ([]entities.GroupMember, error) {
+	const op = "repo.ListSCIMGroupMembers"
+
+	members := []entities.GroupMember{}
+	if len(groupIDs) == 0 {
+		return members, nil
+	}
+	q := `SELECT group_members.group_id, group_members.user_guid, users.email, group_members.created_at
+	FROM group_members JOIN users ON users.id = group_members.user_guid
+	WHERE group_members.group_id = ANY($1::uuid[]) ORDER BY group_members.created_at`
+	if err := s.db.SelectContext(ctx, &members, q, groupIDs); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return members, nil
+}
+
+func (s *scimRepository) CreateGroup(ctx context.Context, data *entities.Group, members []string) (*entities.Group, error) {
+	const op = "repo.CreateSCIMGroup"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	q := `INSERT INTO groups (name, description, external_id) VALUES ($1, $2, $3)
+	ON CONFLICT (name) DO NOTHING RETURNING ` + groupColumns
+	var group entities.Group
+	err = tx.GetContext(ctx, &group, q, data.Name, data.Description, data.ExternalID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityAlreadyExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := setGroupMembers(ctx, tx, group.ID.String(), members); err != nil {
+		if errors.Is(err, ErrEntityNotExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &group, nil
+}
+
+func (s *scimRepository) ReplaceGroup(ctx context.Context, data *entities.Group, members []string) (*entities.Group, error) {
+	const op = "repo.ReplaceSCIMGroup"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM groups WHERE id = $1)", data.ID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		return nil, ErrEntityNotExists
+	}
+
+	q := `UPDATE groups SET name = $2, external_id = $3, updated_at = now()
+	WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM groups WHERE name = $2 AND id <> $1) RETURNING ` + groupColumns
+	var group entities.Group
+	err = tx.GetContext(ctx, &group, q, data.ID, data.Name, data.ExternalID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityAlreadyExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// memberships that stay keep their creation time, nil would be sent as NULL and keep everyone
+	if members == nil {
+		members = []string{}
+	}
+	q = "DELETE FROM group_members WHERE group_id = $1 AND NOT (user_guid = ANY($2::uuid[]))"
+	if _, err := tx.ExecContext(ctx, q, group.ID, members); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := setGroupMembers(ctx, tx, group.ID.String(), members); err != nil {
+		if errors.Is(err, ErrEntityNotExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &group, nil
+}
+
+// adds members missing from the group, guids have to be unique. Unknown users are reported as ErrEntityNotExists
+func setGroupMembers(ctx context.Context, tx *sqlx.Tx, groupID string, members []string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	var known int
+	if err := tx.GetContext(ctx, &known, "SELECT count(*) FROM users WHERE id = ANY($1::uuid[])", members); err != nil {
+		return err
+	}
+	if known != len(members) {
+		return ErrEntityNotExists
+	}
+	q := `INSERT INTO group_members (group_id, user_guid)
+	SELECT $1, id FROM users WHERE id = ANY($2::uuid[]) ON CONFLICT DO NOTHING`
+	_, err := tx.ExecContext(ctx, q, groupID, members)
+	return err
+}
@@ -0,0 +1,257 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testovoe_medods/entities"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type TenantRepository interface {
+	CreateTenant(ctx context.Context, data *entities.Tenant) (*entities.Tenant, error)
+	GetTenant(ctx context.Context, id string) (*entities.Tenant, error)
+	GetTenantByIssuer(ctx context.Context, issuer string) (*entities.Tenant, error)
+	ListTenants(ctx context.Context) ([]entities.Tenant, error)
+	UpdateTenant(ctx context.Context, data *entities.Tenant) (*entities.Tenant, error)
+	// sessions, clients and memberships of the tenant are deleted with it
+	DeleteTenant(ctx context.Context, id string) error
+	// adds user to tenant or changes role of existing member
+	SaveMember(ctx context.Context, tenantID string, userGuid uuid.UUID, role string) error
+	GetMember(ctx context.Context, tenantID string, userGuid uuid.UUID) (*entities.TenantMember, error)
+	ListMembers(ctx context.Context, tenantID string) ([]entities.TenantMember, error)
+	// removes membership and sessions user has in the tenant
+	RemoveMember(ctx context.Context, tenantID string, userGuid uuid.UUID) error
+	CreateSigningKey(ctx context.Context, data *entities.TenantSigningKey) (*entities.TenantSigningKey, error)
+	// keys of the tenant, newest first
+	ListSigningKeys(ctx context.Context, tenantID string) ([]entities.TenantSigningKey, error)
+	// keys of every tenant ordered by tenant, newest first
+	ListAllSigningKeys(ctx context.Context) ([]entities.TenantSigningKey, error)
+	DeleteSigningKey(ctx context.Context, tenantID, id string) error
+}
+
+type tenantRepository struct {
+	db *sqlx.DB
+}
+
+func NewTenantRepository(db *sqlx.DB) TenantRepository {
+	return &tenantRepository{
+		db: db,
+	}
+}
+
+const tenantColumns = `id, name, issuer, access_token_ttl, refresh_token_ttl, login_methods, allowed_ips, created_at, updated_at`
+
+const tenantMemberColumns = `tenant_members.tenant_id, tenant_members.user_guid, users.email, tenant_members.role,
+	tenant_members.created_at`
+
+func (s *tenantRepository) CreateTenant(ctx context.Context, data *entities.Tenant) (*entities.Tenant, error) {
+	const op = "repo.CreateTenant"
+
+	q := `INSERT INTO tenants (id, name, access_token_ttl, refresh_token_ttl, login_methods, allowed_ips, issuer)
+	VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT DO NOTHING RETURNING ` + tenantColumns
+	var tenant entities.Tenant
+	err := s.db.GetContext(ctx, &tenant, q, data.ID, data.Name, data.AccessTokenTTL, data.RefreshTokenTTL,
+		data.LoginMethods, data.AllowedIPs, data.Issuer)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityAlreadyExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &tenant, nil
+}
+
+func (s *tenantRepository) GetTenant(ctx context.Context, id string) (*entities.Tenant, error) {
+	const op = "repo.GetTenant"
+
+	var tenant entities.Tenant
+	err := s.db.GetContext(ctx, &tenant, "SELECT "+tenantColumns+" FROM tenants WHERE id = $1", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &tenant, nil
+}
+
+func (s *tenantRepository) GetTenantByIssuer(ctx context.Context, issuer string) (*entities.Tenant, error) {
+	const op = "repo.GetTenantByIssuer"
+
+	var tenant entities.Tenant
+	err := s.db.GetContext(ctx, &tenant, "SELECT "+tenantColumns+" FROM tenants WHERE issuer = $1", issuer)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &tenant, nil
+}
+
+func (s *tenantRepository) ListTenants(ctx context.Context) ([]entities.Tenant, error) {
+	const op = "repo.ListTenants"
+
+	tenants := []entities.Tenant{}
+	if err := s.db.SelectContext(ctx, &tenants, "SELECT "+tenantColumns+" FROM tenants ORDER BY created_at"); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return tenants, nil
+}
+
+func (s *tenantRepository) UpdateTenant(ctx context.Context, data *entities.Tenant) (*entities.Tenant, error) {
+	const op = "repo.UpdateTenant"
+
+	q := `UPDATE tenants SET name = $2, access_token_ttl = $3, refresh_token_ttl = $4, login_methods = $5,
+	allowed_ips = $6, issuer = $7, updated_at = now() WHERE id = $1 RETURNING ` + tenantColumns
+	var tenant entities.Tenant
+	err := s.db.GetContext(ctx, &tenant, q, data.ID, data.Name, data.AccessTokenTTL, data.RefreshTokenTTL,
+		data.LoginMethods, data.AllowedIPs, data.Issuer)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &tenant, nil
+}
+
+func (s *tenantRepository) DeleteTenant(ctx context.Context, id string) error {
+	const op = "repo.DeleteTenant"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM tenants WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityNotExists
+	}
+	return nil
+}
+
+func (s *tenantRepository) SaveMember(ctx context.Context, tenantID string, userGuid uuid.UUID, role string) error {
+	const op = "repo.SaveMember"
+
+	q := `INSERT INTO tenant_members (tenant_id, user_guid, role) VALUES ($1, $2, $3)
+	ON CONFLICT (tenant_id, user_guid) DO UPDATE SET role = EXCLUDED.role`
+	if _, err := s.db.ExecContext(ctx, q, tenantID, userGuid, role); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *tenantRepository) GetMember(ctx context.Context, tenantID string, userGuid uuid.UUID) (*entities.TenantMember, error) {
+	const op = "repo.GetMember"
+
+	q := "SELECT " + tenantMemberColumns + ` FROM tenant_members JOIN users ON users.id = tenant_members.user_guid
+	WHERE tenant_members.tenant_id = $1 AND tenant_members.user_guid = $2`
+	var member entities.TenantMember
+	err := s.db.GetContext(ctx, &member, q, tenantID, userGuid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &member, nil
+}
+
+func (s *tenantRepository) ListMembers(ctx context.Context, tenantID string) ([]entities.TenantMember, error) {
+	const op = "repo.ListMembers"
+
+	q := "SELECT " + tenantMemberColumns + ` FROM tenant_members JOIN users ON users.id = tenant_members.user_guid
+	WHERE tenant_members.tenant_id = $1 ORDER BY tenant_members.created_at`
+	members := []entities.TenantMember{}
+	if err := s.db.SelectContext(ctx, &members, q, tenantID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return members, nil
+}
+
+func (s *tenantRepository) RemoveMember(ctx context.Context, tenantID string, userGuid uuid.UUID) error {
+	const op = "repo.RemoveMember"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM tenant_members WHERE tenant_id = $1 AND user_guid = $2", tenantID, userGuid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityNotExists
+	}
+
+	if _, err := tx.ExecContext(ctx, enqueueLogoutsQ+"s.tenant_id = $1 AND s.user_guid = $2", tenantID, userGuid); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	q := "DELETE FROM users_auth_info WHERE tenant_id = $1 AND user_guid = $2"
+	if _, err := tx.ExecContext(ctx, q, tenantID, userGuid); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+const tenantSigningKeyColumns = `id, tenant_id, private_key, created_at`
+
+func (s *tenantRepository) CreateSigningKey(ctx context.Context, data *entities.TenantSigningKey) (*entities.TenantSigningKey, error) {
+	const op = "repo.CreateSigningKey"
+
+	q := `INSERT INTO tenant_signing_keys (id, tenant_id, private_key) VALUES ($1, $2, $3)
+	ON CONFLICT (id) DO NOTHING RETURNING ` + tenantSigningKeyColumns
+	var key entities.TenantSigningKey
+	err := s.db.GetContext(ctx, &key, q, data.ID, data.TenantID, data.PrivateKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityAlreadyExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &key, nil
+}
+
+func (s *tenantRepository) ListSigningKeys(ctx context.Context, tenantID string) ([]entities.TenantSigningKey, error) {
+	const op = "repo.ListSigningKeys"
+
+	q := "SELECT " + tenantSigningKeyColumns + " FROM tenant_signing_keys WHERE tenant_id = $1 ORDER BY created_at DESC"
+	keys := []entities.TenantSigningKey{}
+	if err := s.db.SelectContext(ctx, &keys, q, tenantID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return keys, nil
+}
+
+func (s *tenantRepository) ListAllSigningKeys(ctx context.Context) ([]entities.TenantSigningKey, error) {
+	const op = "repo.ListAllSigningKeys"
+
+	q := "SELECT " + tenantSigningKeyColumns + " FROM tenant_signing_keys ORDER BY tenant_id, created_at DESC"
+	keys := []entities.TenantSigningKey{}
+	if err := s.db.SelectContext(ctx, &keys, q); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return keys, nil
+}
+
+func (s *tenantRepository) DeleteSigningKey(ctx context.Context, tenantID, id string) error {
+	const op = "repo.DeleteSigningKey"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM tenant_signing_keys WHERE tenant_id = $1 AND id = $2", tenantID, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityNotExists
+	}
+	return nil
+}
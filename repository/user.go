@@ -0,0 +1,246 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testovoe_medods/entities"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type UserRepository interface {
+	GetUserByEmail(ctx context.Context, email string) (*entities.User, error)
+	GetUserById(ctx context.Context, guid uuid.UUID) (*entities.User, error)
+	CreateUser(ctx context.Context, email string) (*entities.User, error)
+	// methods below are scoped by tenant: default tenant sees every user, other tenants only their members
+	// creates user and makes it a member of the tenant
+	CreateTenantUser(ctx context.Context, tenantID, email string) (*entities.User, error)
+	GetTenantUser(ctx context.Context, tenantID string, guid uuid.UUID) (*entities.User, error)
+	// page of users matching filter together with total number of matches
+	ListUsers(ctx context.Context, filter *entities.UserFilter) ([]entities.User, int, error)
+	UpdateUser(ctx context.Context, tenantID string, guid uuid.UUID, email string) (*entities.User, error)
+	// disabling also revokes every session of the user
+	SetUserDisabled(ctx context.Context, tenantID string, guid uuid.UUID, disabled bool) (*entities.User, error)
+	DeleteUser(ctx context.Context, tenantID string, guid uuid.UUID) error
+}
+
+type userRepository struct {
+	db *sqlx.DB
+}
+
+func NewUserRepository(db *sqlx.DB) UserRepository {
+	return &userRepository{
+		db: db,
+	}
+}
+
+const userColumns = `id, email, disabled, external_id, created_at, updated_at`
+
+// condition matching users of tenant passed as param
+func tenantUserCond(param string) string {
+	return "(" + param + " = '" + entities.DefaultTenantID + "' OR EXISTS (SELECT 1 FROM tenant_members WHERE " +
+		"tenant_members.tenant_id = " + param + " AND tenant_members.user_guid = users.id))"
+}
+
+func (s *userRepository) GetUserByEmail(ctx context.Context, email string) (*entities.User, error) {
+	const op = "repo.GetUserByEmail"
+
+	q := "SELECT " + userColumns + " FROM users WHERE lower(email) = lower($1)"
+	var user entities.User
+	err := s.db.GetContext(ctx, &user, q, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &user, nil
+}
+
+func (s *userRepository) GetUserById(ctx context.Context, guid uuid.UUID) (*entities.User, error) {
+	const op = "repo.GetUserById"
+
+	q := "SELECT " + userColumns + " FROM users WHERE id = $1"
+	var user entities.User
+	err := s.db.GetContext(ctx, &user, q, guid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &user, nil
+}
+
+func (s *userRepository) CreateUser(ctx context.Context, email string) (*entities.User, error) {
+	const op = "repo.CreateUser"
+
+	q := "INSERT INTO users (email) VALUES ($1) ON CONFLICT ((lower(email))) DO NOTHING RETURNING " + userColumns
+	var user entities.User
+	err := s.db.GetContext(ctx, &user, q, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityAlreadyExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &user, nil
+}
+
+func (s *userRepository) CreateTenantUser(ctx context.Context, tenantID, email string) (*entities.User, error) {
+	const op = "repo.CreateTenantUser"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	q := "INSERT INTO users (email) VALUES ($1) ON CONFLICT ((lower(email))) DO NOTHING RETURNING " + userColumns
+	var user entities.User
+	err = tx.GetContext(ctx, &user, q, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityAlreadyExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tenantID != entities.DefaultTenantID {
+		q = "INSERT INTO tenant_members (tenant_id, user_guid, role) VALUES ($1, $2, $3)"
+		if _, err := tx.ExecContext(ctx, q, tenantID, user.ID, entities.TenantRoleMember); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &user, nil
+}
+
+func (s *userRepository) GetTenantUser(ctx context.Context, tenantID string, guid uuid.UUID) (*entities.User, error) {
+	const op = "repo.GetTenantUser"
+
+	q := "SELECT " + userColumns + " FROM users WHERE id = $1 AND " + tenantUserCond("$2")
+	var user entities.User
+	err := s.db.GetContext(ctx, &user, q, guid, tenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &user, nil
+}
+
+func (s *userRepository) ListUsers(ctx context.Context, filter *entities.UserFilter) ([]entities.User, int, error) {
+	const op = "repo.ListUsers"
+
+	// email filter is a case-insensitive substring match, empty filter matches everyone
+	where := " FROM users WHERE position(lower($1) in lower(email)) > 0 AND " + tenantUserCond("$2")
+
+	var total int
+	if err := s.db.GetContext(ctx, &total, "SELECT count(*)"+where, filter.Email, filter.TenantID); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	q := "SELECT " + userColumns + where + " ORDER BY created_at, id LIMIT $3 OFFSET $4"
+	users := []entities.User{}
+	if err := s.db.SelectContext(ctx, &users, q, filter.Email, filter.TenantID, filter.Limit, filter.Offset); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return users, total, nil
+}
+
+func (s *userRepository) UpdateUser(ctx context.Context, tenantID string, guid uuid.UUID, email string) (*entities.User, error) {
+	const op = "repo.UpdateUser"
+
+	// email conflict leaves no row to update, so it's told apart from missing user by the first check
+	q := `UPDATE users SET email = $2, updated_at = now()
+	WHERE id = $1 AND ` + tenantUserCond("$3") + `
+	AND NOT EXISTS (SELECT 1 FROM users u WHERE lower(u.email) = lower($2) AND u.id <> $1) RETURNING ` + userColumns
+	var user entities.User
+	err := s.db.GetContext(ctx, &user, q, guid, email, tenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := s.GetTenantUser(ctx, tenantID, guid); err != nil {
+			return nil, err
+		}
+		return nil, ErrEntityAlreadyExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &user, nil
+}
+
+func (s *userRepository) SetUserDisabled(ctx context.Context, tenantID string, guid uuid.UUID, disabled bool) (*entities.User, error) {
+	const op = "repo.SetUserDisabled"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var user entities.User
+	q := "UPDATE users SET disabled = $2, updated_at = now() WHERE id = $1 AND " + tenantUserCond("$3") + " RETURNING " + userColumns
+	err = tx.GetContext(ctx, &user, q, guid, disabled, tenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if disabled {
+		if _, err := tx.ExecContext(ctx, enqueueLogoutsQ+"s.user_guid = $1", guid); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM users_auth_info WHERE user_guid = $1", guid); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &user, nil
+}
+
+// sessions and the rest of user data go away by cascade, clients are notified about the sessions first
+func (s *userRepository) DeleteUser(ctx context.Context, tenantID string, guid uuid.UUID) error {
+	const op = "repo.DeleteUser"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	// row is locked first so logouts are enqueued only for user that is deleted
+	var id uuid.UUID
+	err = tx.GetContext(ctx, &id, "SELECT id FROM users WHERE id = $1 AND "+tenantUserCond("$2")+" FOR UPDATE", guid, tenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrEntityNotExists
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, enqueueLogoutsQ+"s.user_guid = $1", guid); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = $1", guid); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
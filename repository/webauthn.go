@@ -0,0 +1,141 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testovoe_medods/entities"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type WebAuthnRepository interface {
+	CreateChallenge(ctx context.Context, data *entities.WebAuthnChallenge) error
+	ConsumeChallenge(ctx context.Context, challenge, ceremony string) (*entities.WebAuthnChallenge, error)
+	CreateCredential(ctx context.Context, data *entities.WebAuthnCredential) error
+	GetCredentialsByUserGuid(ctx context.Context, userGuid uuid.UUID) ([]entities.WebAuthnCredential, error)
+	GetCredentialByCredentialId(ctx context.Context, credentialId string) (*entities.WebAuthnCredential, error)
+	// stores counter only if it grew, ErrEntityNotExists means a concurrent assertion already used it.
+	// Authenticators without counter send 0 every time
+	UpdateSignCount(ctx context.Context, id uuid.UUID, signCount int64) error
+	DeleteCredential(ctx context.Context, userGuid, id uuid.UUID) error
+}
+
+const webAuthnCredentialColumns = "id, user_guid, credential_id, public_key, alg, sign_count, aaguid, attestation_fmt, name, created_at, last_used_at"
+
+type webAuthnRepository struct {
+	db *sqlx.DB
+}
+
+func NewWebAuthnRepository(db *sqlx.DB) WebAuthnRepository {
+	return &webAuthnRepository{
+		db: db,
+	}
+}
+
+func (s *webAuthnRepository) CreateChallenge(ctx context.Context, data *entities.WebAuthnChallenge) error {
+	const op = "repo.CreateChallenge"
+
+	// expired challenges are never consumed, so they are swept on every new ceremony
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM webauthn_challenges WHERE expires_at < now()"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	q := "INSERT INTO webauthn_challenges (challenge, user_guid, ceremony, expires_at) VALUES ($1, $2, $3, $4)"
+	_, err := s.db.ExecContext(ctx, q, data.Challenge, data.UserGuid, data.Ceremony, data.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// deletes challenge so it can't be used twice
+func (s *webAuthnRepository) ConsumeChallenge(ctx context.Context, challenge, ceremony string) (*entities.WebAuthnChallenge, error) {
+	const op = "repo.ConsumeChallenge"
+
+	q := `DELETE FROM webauthn_challenges WHERE challenge = $1 AND ceremony = $2 AND expires_at > now()
+	RETURNING challenge, user_guid, ceremony, expires_at`
+	var data entities.WebAuthnChallenge
+	err := s.db.GetContext(ctx, &data, q, challenge, ceremony)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &data, nil
+}
+
+func (s *webAuthnRepository) CreateCredential(ctx context.Context, data *entities.WebAuthnCredential) error {
+	const op = "repo.CreateCredential"
+
+	q := `INSERT INTO webauthn_credentials (user_guid, credential_id, public_key, alg, sign_count, aaguid, attestation_fmt, name)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT (credential_id) DO NOTHING
+	RETURNING id, created_at`
+	err := s.db.QueryRowxContext(ctx, q, data.UserGuid, data.CredentialID, data.PublicKey, data.Alg,
+		data.SignCount, data.AAGUID, data.AttestationFmt, data.Name).Scan(&data.ID, &data.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrEntityAlreadyExists
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *webAuthnRepository) GetCredentialsByUserGuid(ctx context.Context, userGuid uuid.UUID) ([]entities.WebAuthnCredential, error) {
+	const op = "repo.GetCredentialsByUserGuid"
+
+	q := "SELECT " + webAuthnCredentialColumns + " FROM webauthn_credentials WHERE user_guid = $1 ORDER BY created_at"
+	creds := []entities.WebAuthnCredential{}
+	err := s.db.SelectContext(ctx, &creds, q, userGuid)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return creds, nil
+}
+
+func (s *webAuthnRepository) GetCredentialByCredentialId(ctx context.Context, credentialId string) (*entities.WebAuthnCredential, error) {
+	const op = "repo.GetCredentialByCredentialId"
+
+	q := "SELECT " + webAuthnCredentialColumns + " FROM webauthn_credentials WHERE credential_id = $1"
+	var cred entities.WebAuthnCredential
+	err := s.db.GetContext(ctx, &cred, q, credentialId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntityNotExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &cred, nil
+}
+
+func (s *webAuthnRepository) UpdateSignCount(ctx context.Context, id uuid.UUID, signCount int64) error {
+	const op = "repo.UpdateSignCount"
+
+	q := `UPDATE webauthn_credentials SET sign_count = $2, last_used_at = now()
+	WHERE id = $1 AND (sign_count < $2 OR (sign_count = 0 AND $2 = 0))`
+	res, err := s.db.ExecContext(ctx, q, id, signCount)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityNotExists
+	}
+	return nil
+}
+
+func (s *webAuthnRepository) DeleteCredential(ctx context.Context, userGuid, id uuid.UUID) error {
+	const op = "repo.DeleteCredential"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM webauthn_credentials WHERE id = $1 AND user_guid = $2", id, userGuid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEntityNotExists
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	repo "testovoe_medods/repository"
+
+	"github.com/google/uuid"
+)
+
+type AccountService interface {
+	GetAccount(ctx context.Context, userGuid uuid.UUID) (*entities.Account, error)
+	// sessions of the owner of current one, sessions opened by administrators are flagged as impersonated
+	ListSessions(ctx context.Context, current *entities.UserAuthInfo) ([]entities.Session, error)
+}
+
+type accountService struct {
+	cfg      *config.Config
+	log      *slog.Logger
+	authRepo repo.AuthRepository
+	mfa      MFAService
+}
+
+func NewAccountService(log *slog.Logger, cfg *config.Config, authRepo repo.AuthRepository, mfa MFAService) AccountService {
+	return &accountService{
+		cfg:      cfg,
+		log:      log,
+		authRepo: authRepo,
+		mfa:      mfa,
+	}
+}
+
+func (as *accountService) GetAccount(ctx context.Context, userGuid uuid.UUID) (*entities.Account, error) {
+	const op = "service.GetAccount"
+
+	user, err := as.authRepo.GetAuthInfoByUserGuid(ctx, entities.DefaultTenantID, userGuid.String())
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrNoUserFound
+	}
+	if err != nil {
+		as.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	methods, err := as.mfa.EnabledMethods(ctx, userGuid)
+	if err != nil {
+		as.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	remaining, err := as.mfa.RemainingRecoveryCodes(ctx, userGuid)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &entities.Account{
+		ID:                     userGuid.String(),
+		Email:                  user.Email,
+		MFAMethods:             methods,
+		RecoveryCodesRemaining: remaining,
+	}, nil
+}
+
+func (as *accountService) ListSessions(ctx context.Context, current *entities.UserAuthInfo) ([]entities.Session, error) {
+	const op = "service.ListSessions"
+
+	sessions, err := as.authRepo.ListSessions(ctx, current.TenantID, *current.UserGuid)
+	if err != nil {
+		as.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for i := range sessions {
+		sessions[i].Impersonated = sessions[i].ImpersonatorGuid != nil
+		sessions[i].Current = current.RefreshId != nil && sessions[i].ID == *current.RefreshId
+	}
+	return sessions, nil
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	crypt "testovoe_medods/lib/bcrypt"
+	jwtp "testovoe_medods/lib/jwt"
+	repo "testovoe_medods/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// makes keys recognisable by secret scanners and distinguishes them from jwt access tokens
+const APIKeyPrefix = "tmk_"
+
+// random characters kept in listing to help users tell keys apart
+const apiKeyDisplayChars = 6
+
+var ErrInvalidAPIKey = errors.New("api key is invalid or expired")
+var ErrAPIKeyNotFound = errors.New("api key doesn't exist")
+var ErrTooManyAPIKeys = errors.New("api key limit reached")
+
+// keys are managed only by users through first-party sessions and by service accounts
+var ErrAPIKeyOwnerNotAllowed = errors.New("only first-party sessions and service accounts can manage api keys")
+
+type InvalidAPIKeyRequestError struct {
+	Reason string
+}
+
+func (e *InvalidAPIKeyRequestError) Error() string {
+	return e.Reason
+}
+
+type APIKeyService interface {
+	// resolves principal of access token: user of first-party session or service account of client token
+	Owner(ctx context.Context, accessToken string) (*entities.APIKeyOwner, error)
+	CreateKey(ctx context.Context, owner *entities.APIKeyOwner, req *entities.APIKeyRequest) (*entities.APIKeyCredentials, error)
+	ListKeys(ctx context.Context, owner *entities.APIKeyOwner) ([]entities.APIKey, error)
+	RevokeKey(ctx context.Context, owner *entities.APIKeyOwner, id uuid.UUID) error
+	// verifies presented key and records its usage
+	Verify(ctx context.Context, key string) (*entities.APIKey, error)
+}
+
+type apiKeyService struct {
+	cfg           *config.Config
+	log           *slog.Logger
+	repo          repo.APIKeyRepository
+	authService   AuthService
+	clientService OAuthClientService
+}
+
+func NewAPIKeyService(log *slog.Logger, cfg *config.Config, repo repo.APIKeyRepository, authService AuthService, clientService OAuthClientService) APIKeyService {
+	return &apiKeyService{
+		cfg:           cfg,
+		log:           log,
+		repo:          repo,
+		authService:   authService,
+		clientService: clientService,
+	}
+}
+
+func (ks *apiKeyService) Owner(ctx context.Context, accessToken string) (*entities.APIKeyOwner, error) {
+	claims, err := jwtp.GetAndValidateTokenClaims(accessToken, false)
+	if err != nil {
+		return nil, ErrInvalidAccessToken
+	}
+
+	if claims.ServiceToken {
+		// exchanged tokens are meant for other services
+		if len(claims.Audience) > 0 || claims.Act != nil {
+			return nil, ErrAPIKeyOwnerNotAllowed
+		}
+		client, err := ks.clientService.GetClient(ctx, claims.Subject)
+		if errors.Is(err, ErrClientNotFound) {
+			return nil, ErrInvalidAccessToken
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var scopes []string
+		for _, scope := range client.Scopes {
+			if !slices.Contains(supportedScopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+		return &entities.APIKeyOwner{ClientID: &client.ID, AllowedScopes: scopes}, nil
+	}
+
+	session, err := ks.authService.AuthenticateAccessToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	// api key would outlive impersonation session
+	if session.ClientID != nil || session.ImpersonatorGuid != nil {
+		return nil, ErrAPIKeyOwnerNotAllowed
+	}
+	return &entities.APIKeyOwner{UserGuid: session.UserGuid, AllowedScopes: ks.cfg.APIKeys.UserScopes}, nil
+}
+
+func (ks *apiKeyService) CreateKey(ctx context.Context, owner *entities.APIKeyOwner, req *entities.APIKeyRequest) (*entities.APIKeyCredentials, error) {
+	const op = "service.CreateAPIKey"
+	ks.log.Info(op, slog.String("msg", "Creating api key"))
+
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = owner.AllowedScopes
+	}
+	if len(scopes) == 0 {
+		return nil, &InvalidAPIKeyRequestError{Reason: "no scopes are available for api keys"}
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(owner.AllowedScopes, scope) {
+			return nil, &InvalidAPIKeyRequestError{Reason: "scope " + scope + " isn't allowed"}
+		}
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresIn != nil {
+		if *req.ExpiresIn <= 0 {
+			return nil, &InvalidAPIKeyRequestError{Reason: "expires_in must be positive"}
+		}
+		exp := time.Now().Add(time.Duration(*req.ExpiresIn) * time.Second)
+		expiresAt = &exp
+	}
+	if maxTTL := ks.cfg.APIKeys.MaxTTL; maxTTL > 0 {
+		if expiresAt == nil || expiresAt.After(time.Now().Add(maxTTL)) {
+			return nil, &InvalidAPIKeyRequestError{Reason: fmt.Sprintf("expires_in must not exceed %d seconds", int64(maxTTL.Seconds()))}
+		}
+	}
+
+	count, err := ks.repo.CountKeys(ctx, owner.UserGuid, owner.ClientID)
+	if err != nil {
+		ks.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if count >= ks.cfg.APIKeys.MaxPerOwner {
+		return nil, ErrTooManyAPIKeys
+	}
+
+	secret, err := crypt.GenerateOpaqueToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	plain := APIKeyPrefix + secret
+
+	key, err := ks.repo.CreateKey(ctx, &entities.APIKey{
+		KeyDigest:     crypt.HMACDigest(plain),
+		DisplayPrefix: plain[:len(APIKeyPrefix)+apiKeyDisplayChars],
+		Name:          req.Name,
+		UserGuid:      owner.UserGuid,
+		ClientID:      owner.ClientID,
+		Scopes:        scopes,
+		ExpiresAt:     expiresAt,
+	})
+	if err != nil {
+		ks.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &entities.APIKeyCredentials{APIKey: key, Key: plain}, nil
+}
+
+func (ks *apiKeyService) ListKeys(ctx context.Context, owner *entities.APIKeyOwner) ([]entities.APIKey, error) {
+	const op = "service.ListAPIKeys"
+
+	keys, err := ks.repo.ListKeys(ctx, owner.UserGuid, owner.ClientID)
+	if err != nil {
+		ks.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return keys, nil
+}
+
+func (ks *apiKeyService) RevokeKey(ctx context.Context, owner *entities.APIKeyOwner, id uuid.UUID) error {
+	const op = "service.RevokeAPIKey"
+	ks.log.Info(op, slog.String("msg", "Revoking api key"), slog.String("id", id.String()))
+
+	err := ks.repo.DeleteKey(ctx, id, owner.UserGuid, owner.ClientID)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrAPIKeyNotFound
+	}
+	if err != nil {
+		ks.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (ks *apiKeyService) Verify(ctx context.Context, key string) (*entities.APIKey, error) {
+	const op = "service.VerifyAPIKey"
+
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	apiKey, err := ks.repo.GetKeyByDigest(ctx, crypt.HMACDigest(key))
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		ks.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// usage tracking must not break authentication
+	if err := ks.repo.TouchKey(ctx, apiKey.ID); err != nil {
+		ks.log.Error(op, slog.String("error", err.Error()))
+	}
+	return apiKey, nil
+}
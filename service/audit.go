@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	repo "testovoe_medods/repository"
+)
+
+type AuditService interface {
+	ListEvents(ctx context.Context, filter *entities.AuditFilter) ([]entities.AuditEvent, error)
+}
+
+type auditService struct {
+	cfg  *config.Config
+	log  *slog.Logger
+	repo repo.AuditRepository
+}
+
+func NewAuditService(log *slog.Logger, cfg *config.Config, repo repo.AuditRepository) AuditService {
+	return &auditService{
+		cfg:  cfg,
+		log:  log,
+		repo: repo,
+	}
+}
+
+func (as *auditService) ListEvents(ctx context.Context, filter *entities.AuditFilter) ([]entities.AuditEvent, error) {
+	const op = "service.ListAuditEvents"
+
+	page := *filter
+	limit, err := pageLimit(page.Limit, page.Offset)
+	if err != nil {
+		return nil, err
+	}
+	page.Limit = limit
+
+	events, err := as.repo.ListEvents(ctx, &page)
+	if err != nil {
+		as.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return events, nil
+}
+
+// every use of an impersonation session is recorded, requests aren't served when the record can't be written
+func recordImpersonationUse(ctx context.Context, audit repo.AuditRepository, session *entities.UserAuthInfo, via string) error {
+	if session.ImpersonatorGuid == nil {
+		return nil
+	}
+	return audit.CreateEvent(ctx, &entities.AuditEvent{
+		Event:       entities.AuditImpersonationUsed,
+		ActorGuid:   session.ImpersonatorGuid,
+		SubjectGuid: session.UserGuid,
+		SessionID:   session.RefreshId,
+		Details:     via,
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 	"testovoe_medods/config"
 	"testovoe_medods/entities"
 	crypt "testovoe_medods/lib/bcrypt"
@@ -17,23 +19,43 @@ import (
 
 var ErrNoUserFound = errors.New("user with provided guid wasn't found")
 var ErrInvalidTokenClaims = errors.New("invalid refresh token claims")
+var ErrInvalidAccessToken = errors.New("invalid access token")
+var ErrInvalidMFAChallenge = errors.New("mfa challenge is invalid or expired")
 
 type AuthService interface {
 	ReleaseTokens(ctx context.Context, authReq *entities.AuthenticateRequest) (*entities.TokenPair, error)
-	RefreshToken(ctx context.Context, token string) (*entities.TokenPair, error)
+	RefreshToken(ctx context.Context, token, ipAddr string) (*entities.TokenPair, error)
+	// same as RefreshToken, non-zero ttl overrides server defaults (per-client lifetimes)
+	RefreshTokenWithTTL(ctx context.Context, token, ipAddr string, ttl entities.TokenTTL) (*entities.TokenPair, error)
+	CompleteMFA(ctx context.Context, req *entities.MFAVerifyRequest) (*entities.TokenPair, error)
+	AuthenticateAccessToken(ctx context.Context, token string) (*entities.UserAuthInfo, error)
 }
 
 type userAuthService struct {
 	cfg  *config.Config
 	log  *slog.Logger
 	repo repo.AuthRepository
+	mfa  MFAService
+	lockout LockoutService
+	roles repo.RoleRepository
+	groups repo.GroupRepository
+	tenants TenantService
+	audit repo.AuditRepository
+	clients repo.OAuthClientRepository
 }
 
-func NewUserAuthService(log *slog.Logger, cfg *config.Config, repo repo.AuthRepository) AuthService  {
+func NewUserAuthService(log *slog.Logger, cfg *config.Config, repo repo.AuthRepository, mfa MFAService, lockout LockoutService, roles repo.RoleRepository, groups repo.GroupRepository, tenants TenantService, audit repo.AuditRepository, clients repo.OAuthClientRepository) AuthService  {
 	return &userAuthService{
 		cfg:  cfg,
 		log:  log,
 		repo: repo,
+		mfa:  mfa,
+		lockout: lockout,
+		roles: roles,
+		groups: groups,
+		tenants: tenants,
+		audit: audit,
+		clients: clients,
 	}
 }
 
@@ -42,10 +64,22 @@ func (as *userAuthService) ReleaseTokens(ctx context.Context, authReq *entities.
 	const op = "service.ReleaseTokens"
 	as.log.Info(op, slog.String("msg", "Release tokens"))
 
+	ipKey := IPLockoutKey(authReq.IpAddr)
+	userKey := UserLockoutKey(authReq.Guid)
+	if err := as.lockout.Check(ctx, ipKey, userKey); err != nil {
+		return nil, err
+	}
+
+	tenantID := authReq.TenantID
+	if tenantID == "" {
+		tenantID = entities.DefaultTenantID
+	}
+
 	//check if user with guid exists
-	authInfo, err := as.repo.GetAuthInfoByUserGuid(ctx, authReq.Guid)
+	user, err := as.repo.GetAuthInfoByUserGuid(ctx, tenantID, authReq.Guid)
 
 	if errors.Is(err, repo.ErrEntityNotExists) { 
+		as.lockout.RegisterFailure(ctx, ipKey)
 		return nil, ErrNoUserFound
 	}
 
@@ -54,14 +88,187 @@ func (as *userAuthService) ReleaseTokens(ctx context.Context, authReq *entities.
 		return nil, err
 	}
 
-	userGuid, _ := uuid.Parse(authReq.Guid)
+	if user.Disabled {
+		return nil, ErrUserDisabled
+	}
+
+	userGuid, err := uuid.Parse(authReq.Guid)
+
+	if err != nil {
+		as.lockout.RegisterFailure(ctx, ipKey)
+		return nil, ErrNoUserFound
+	}
+
+	tenant, err := as.tenants.Authorize(ctx, tenantID, userGuid, authReq.IpAddr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !tenantAllowsMethod(tenant, authReq.Method) {
+		return nil, &TenantAccessError{Reason: "login method isn't allowed by tenant policy"}
+	}
+
 	createData := entities.UserAuthInfo{
 		UserGuid: &userGuid,
 		IpAddress: &authReq.IpAddr,
+		TenantID: tenantID,
+		AuthMethod: &authReq.Method,
+	}
+	if authReq.ClientID != "" {
+		createData.ClientID = &authReq.ClientID
+		createData.Scope = &authReq.Scope
+		createData.SSOSessionID = authReq.SSOSessionID
+	}
+	ttl := tenantTokenTTL(tenant, authReq.TTL)
+
+	if authReq.MFAPassed {
+		return as.issueTokenPair(ctx, &createData, ttl)
+	}
+
+	// users with second factor get challenge instead of tokens
+	methods, err := as.mfa.EnabledMethods(ctx, userGuid)
+
+	if err != nil {
+		as.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(methods) > 0 {
+		mfaToken, err := jwtp.GenerateMFAChallengeToken(userGuid.String(), authReq.IpAddr, tenantID, authReq.Method, as.cfg.MFA.ChallengeTTL)
+
+		if err != nil {
+			as.log.Error(op, slog.String("error", err.Error()))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return nil, &MFARequiredError{
+			Challenge: entities.MFAChallenge{
+				MFAToken: mfaToken,
+				Methods:  methods,
+			},
+		}
+	}
+
+	return as.issueTokenPair(ctx, &createData, ttl)
+}
+
+// exchanges mfa challenge token and valid second factor for a pair of tokens
+func (as *userAuthService) CompleteMFA(ctx context.Context, req *entities.MFAVerifyRequest) (*entities.TokenPair, error) {
+	const op = "service.CompleteMFA"
+	as.log.Info(op, slog.String("msg", "Completing mfa"))
+
+	ipKey := IPLockoutKey(req.IpAddr)
+	if err := as.lockout.Check(ctx, ipKey); err != nil {
+		return nil, err
+	}
+
+	claims, err := jwtp.GetAndValidateMFAChallengeClaims(req.MFAToken)
+
+	if err != nil {
+		as.lockout.RegisterFailure(ctx, ipKey)
+		as.log.Info(op, slog.String("error", err.Error()))
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	// challenge is bound to the address the first factor was passed from, like the tokens it ends in
+	if claims.IpAddr != req.IpAddr {
+		as.lockout.RegisterFailure(ctx, ipKey)
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	userGuid, err := uuid.Parse(claims.Subject)
+
+	if err != nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	userKey := UserLockoutKey(userGuid.String())
+	if err := as.lockout.Check(ctx, userKey); err != nil {
+		return nil, err
+	}
+
+	if req.RecoveryCode != "" {
+		err = as.mfa.VerifyRecoveryCode(ctx, userGuid, req.RecoveryCode)
+	} else {
+		err = as.mfa.VerifyCode(ctx, userGuid, req.Code)
+	}
+
+	if errors.Is(err, ErrInvalidMFACode) {
+		as.lockout.RegisterFailure(ctx, ipKey, userKey)
+		return nil, err
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	as.lockout.RegisterSuccess(ctx, userKey)
+
+	// challenge can't be replayed for one more session until it expires
+	if err := as.mfa.ConsumeChallenge(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+
+	// policy is checked again because it may have changed since the first factor
+	tenantID := claimsTenant(claims)
+	if err := as.checkUserEnabled(ctx, tenantID, userGuid); err != nil {
+		return nil, err
+	}
+	tenant, err := as.tenants.Authorize(ctx, tenantID, userGuid, req.IpAddr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return as.issueTokenPair(ctx, &entities.UserAuthInfo{
+		UserGuid: &userGuid,
+		IpAddress: &req.IpAddr,
+		TenantID: tenantID,
+		AuthMethod: &claims.AuthMethod,
+	}, tenantTokenTTL(tenant, entities.TokenTTL{}))
+}
+
+// validates access token and returns session it was issued for
+func (as *userAuthService) AuthenticateAccessToken(ctx context.Context, token string) (*entities.UserAuthInfo, error) {
+	const op = "service.AuthenticateAccessToken"
+
+	claims, err := jwtp.GetAndValidateTokenClaims(token, false)
+
+	// service tokens have no user session behind them, tokens with audience are meant for other services
+	if err != nil || claims.ServiceToken || len(claims.Audience) > 0 {
+		return nil, ErrInvalidAccessToken
+	}
+
+	session, err := as.repo.GetAuthInfoById(ctx, claimsTenant(claims), claims.Subject)
+
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrInvalidAccessToken
+	}
+
+	if err != nil {
+		as.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := recordImpersonationUse(ctx, as.audit, session, "api"); err != nil {
+		as.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	return session, nil
+}
+
+// creates session for user and releases tokens bound to it
+func (as *userAuthService) issueTokenPair(ctx context.Context, createData *entities.UserAuthInfo, ttl entities.TokenTTL) (*entities.TokenPair, error) {
+	const op = "service.issueTokenPair"
+
+	ttl = as.tokenTTL(ttl)
+
+	ipAddr := *createData.IpAddress
+
 	// store user auth info
-	refreshTID, err := as.repo.CreateAuthInfo(ctx, &createData)
+	refreshTID, err := as.repo.CreateAuthInfo(ctx, createData)
 
 	if err != nil {
 		as.log.Error(op, slog.String("error", err.Error()))
@@ -69,7 +276,7 @@ func (as *userAuthService) ReleaseTokens(ctx context.Context, authReq *entities.
 	}
 
 	//generate refresh token
-	refreshT, err := jwtp.GenerateToken(refreshTID, authReq.IpAddr, as.cfg.Token.RefreshTokenTTL, true)
+	refreshT, err := jwtp.GenerateToken(refreshTID, ipAddr, createData.TenantID, nil, ttl.Refresh, true)
 
 	if err != nil {
 		as.log.Info(op, slog.String("error", err.Error()))
@@ -94,13 +301,26 @@ func (as *userAuthService) ReleaseTokens(ctx context.Context, authReq *entities.
 	updateData := entities.UserAuthInfo{
 		RefreshId: &tokenIdToUuuid,
 		RefreshTokenHash: &refeshTokenHash,
+		TenantID: createData.TenantID,
 	}
 
 	// add token hash to user auth data
-	as.repo.UpdateRefreshTokenHash(ctx, &updateData)
+	err = as.repo.UpdateRefreshTokenHash(ctx, &updateData)
 
-	//generate access token
-	accessT, err := jwtp.GenerateToken(authInfo.RefreshId.String(), authReq.IpAddr, as.cfg.Token.AccessTokenTTL, false)
+	if err != nil {
+		as.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	access, err := as.accessClaims(ctx, createData)
+
+	if err != nil {
+		as.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	//generate access token, it's bound to the same session as refresh token
+	accessT, err := jwtp.GenerateToken(refreshTID, ipAddr, createData.TenantID, access, ttl.Access, false)
 
 	if err != nil {
 		as.log.Error(op, slog.String("error", err.Error()))
@@ -110,40 +330,75 @@ func (as *userAuthService) ReleaseTokens(ctx context.Context, authReq *entities.
 	return &entities.TokenPair{
 		AccessToken: accessT,
 		RefreshToken: refreshT,
+		AccessTTL: ttl.Access,
 		}, nil
 	}
 
-func (as *userAuthService) RefreshToken(ctx context.Context, token string) (*entities.TokenPair, error) {
+func (as *userAuthService) RefreshToken(ctx context.Context, token, ipAddr string) (*entities.TokenPair, error) {
+	return as.RefreshTokenWithTTL(ctx, token, ipAddr, entities.TokenTTL{})
+}
+
+func (as *userAuthService) RefreshTokenWithTTL(ctx context.Context, token, ipAddr string, requestedTTL entities.TokenTTL) (*entities.TokenPair, error) {
 	const op = "service.RefreshToken"
 	as.log.Info(op, slog.String("msg", "Refreshing tokens"))
 
+	ipKey := IPLockoutKey(ipAddr)
+	if err := as.lockout.Check(ctx, ipKey); err != nil {
+		return nil, err
+	}
+
 	claims, err := jwtp.GetAndValidateTokenClaims(token, true)
 	
 	if errors.Is(err, jwt.ErrTokenExpired) {
 		return nil, jwt.ErrTokenExpired
 	} else if errors.Is(err, jwt.ErrTokenInvalidSubject) || errors.Is(err, jwt.ErrTokenInvalidClaims) || errors.Is(err, jwt.ErrSignatureInvalid) {
+		as.lockout.RegisterFailure(ctx, ipKey)
 		return nil, ErrInvalidTokenClaims
 	}
 
 	if err != nil {
+		as.lockout.RegisterFailure(ctx, ipKey)
 		as.log.Error(op, slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	
-	tokenHash, err := as.repo.GetRefreshTokenHash(ctx, claims.Subject)
+	tenantID := claimsTenant(claims)
+	session, err := as.repo.GetAuthInfoById(ctx, tenantID, claims.Subject)
+
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		as.lockout.RegisterFailure(ctx, ipKey)
+		return nil, ErrInvalidTokenClaims
+	}
 
 	if err != nil {
 		as.log.Error(op, slog.String("error", err.Error()))
 		return nil, err
 	}
 
-	if isTokenValid := crypt.VerifyToken(token, tokenHash); !isTokenValid {
+	userKey := UserLockoutKey(session.UserGuid.String())
+	if err := as.lockout.Check(ctx, userKey); err != nil {
+		return nil, err
+	}
+
+	if session.RefreshTokenHash == nil || !crypt.VerifyToken(token, *session.RefreshTokenHash) {
+		as.lockout.RegisterFailure(ctx, ipKey, userKey)
 		as.log.Error(op, slog.String("err", "token hash and db token hash don't match"))
-		return nil, fmt.Errorf("%s: %s", op, "token hash and db token hash don't match")
+		return nil, ErrInvalidTokenClaims
+	}
+
+	if err := as.checkUserEnabled(ctx, tenantID, *session.UserGuid); err != nil {
+		return nil, err
+	}
+
+	// membership and address policy apply for the whole life of the session
+	tenant, err := as.tenants.Authorize(ctx, tenantID, *session.UserGuid, ipAddr)
+	if err != nil {
+		return nil, err
 	}
+	ttl := as.tokenTTL(tenantTokenTTL(tenant, requestedTTL))
 
 	//generate new refresh token
-	refreshT, err := jwtp.GenerateToken(claims.Subject, claims.IpAddr, as.cfg.Token.RefreshTokenTTL, true)
+	refreshT, err := jwtp.GenerateToken(claims.Subject, claims.IpAddr, tenantID, nil, ttl.Refresh, true)
 
 	if err != nil {
 		as.log.Error(op, slog.String("\nfailed to generate new refresh token", err.Error()))
@@ -167,6 +422,7 @@ func (as *userAuthService) RefreshToken(ctx context.Context, token string) (*ent
 	data := entities.UserAuthInfo{
 		RefreshId: &TokenidToUuid,
 		RefreshTokenHash: &newRefreshTHash,
+		TenantID: tenantID,
 	}
 
 	fmt.Printf("DATA: %+v", data)
@@ -178,15 +434,83 @@ func (as *userAuthService) RefreshToken(ctx context.Context, token string) (*ent
 		return nil, fmt.Errorf("\n %s: %w", op, err)
 	}
 
+	// roles and groups are reloaded so assignment changes reach the user on the next refresh
+	access, err := as.accessClaims(ctx, session)
+	if err != nil {
+		as.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	//generate access token
-	accessT, err := jwtp.GenerateToken(claims.Subject, claims.IpAddr, as.cfg.Token.AccessTokenTTL, false)
+	accessT, err := jwtp.GenerateToken(claims.Subject, claims.IpAddr, tenantID, access, ttl.Access, false)
 	if err != nil {
 		as.log.Error(op, slog.String("error", err.Error()))
 		return nil, err
 		}
 
+	// lockout counters stay as they are: refreshing own session must not clear failed guesses
 	return &entities.TokenPair{
 		AccessToken: accessT,
 		RefreshToken: refreshT,
+		AccessTTL: ttl.Access,
 		}, nil
 	}
+
+// users disabled after the session started must not get new tokens from it
+func (as *userAuthService) checkUserEnabled(ctx context.Context, tenantID string, userGuid uuid.UUID) error {
+	user, err := as.repo.GetAuthInfoByUserGuid(ctx, tenantID, userGuid.String())
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrNoUserFound
+	}
+	if err != nil {
+		return err
+	}
+	if user.Disabled {
+		return ErrUserDisabled
+	}
+	return nil
+}
+
+// roles are disclosed to third-party clients only when roles scope was granted to them,
+// our own sessions and first-party clients always get them
+func (as *userAuthService) accessClaims(ctx context.Context, session *entities.UserAuthInfo) (*jwtp.AccessClaims, error) {
+	withRoles := session.ClientID == nil || (session.Scope != nil && slices.Contains(strings.Fields(*session.Scope), "roles"))
+	if !withRoles {
+		client, err := as.clients.GetClient(ctx, *session.ClientID)
+		if err != nil && !errors.Is(err, repo.ErrEntityNotExists) {
+			return nil, err
+		}
+		withRoles = client != nil && client.FirstParty
+	}
+	return userAccessClaims(ctx, as.cfg, as.roles, as.groups, *session.UserGuid, withRoles)
+}
+
+// roles and groups of the user for access token
+func userAccessClaims(ctx context.Context, cfg *config.Config, roleRepo repo.RoleRepository, groupRepo repo.GroupRepository, userGuid uuid.UUID, withRoles bool) (*jwtp.AccessClaims, error) {
+	access := &jwtp.AccessClaims{}
+	if withRoles {
+		roles, err := roleRepo.GetUserRoles(ctx, userGuid)
+		if err != nil {
+			return nil, err
+		}
+		access.Roles = roles
+	}
+
+	groups, err := groupRepo.GetUserGroups(ctx, userGuid)
+	if err != nil {
+		return nil, err
+	}
+	access.Groups, access.GroupsOverage = tokenGroupClaims(cfg, groups)
+	return access, nil
+}
+
+// fills zero lifetimes with configured defaults
+func (as *userAuthService) tokenTTL(ttl entities.TokenTTL) entities.TokenTTL {
+	if ttl.Access <= 0 {
+		ttl.Access = as.cfg.Token.AccessTokenTTL
+	}
+	if ttl.Refresh <= 0 {
+		ttl.Refresh = as.cfg.Token.RefreshTokenTTL
+	}
+	return ttl
+}
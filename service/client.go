@@ -0,0 +1,515 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	crypt "testovoe_medods/lib/bcrypt"
+	"testovoe_medods/lib/oidc"
+	repo "testovoe_medods/repository"
+	"time"
+)
+
+var ErrClientNotFound = errors.New("oauth client wasn't found")
+var ErrClientAlreadyExists = errors.New("oauth client with this id already exists")
+
+// returned by admin api when client registration is inconsistent
+type InvalidClientMetadataError struct {
+	Reason string
+}
+
+func (e *InvalidClientMetadataError) Error() string {
+	return "invalid client metadata: " + e.Reason
+}
+
+// grants a client may be registered for
+var supportedGrantTypes = []string{
+	"authorization_code",
+	"refresh_token",
+	"client_credentials",
+	GrantTypeDeviceCode,
+	GrantTypeTokenExchange,
+	GrantTypeJWTBearer,
+}
+
+var supportedClientAuthMethods = []string{
+	entities.ClientAuthSecretBasic,
+	entities.ClientAuthSecretPost,
+	entities.ClientAuthPrivateKeyJWT,
+	entities.ClientAuthNone,
+}
+
+type OAuthClientService interface {
+	CreateClient(ctx context.Context, req *entities.OAuthClientRequest) (*entities.OAuthClientCredentials, error)
+	GetClient(ctx context.Context, clientID string) (*entities.OAuthClient, error)
+	ListClients(ctx context.Context) ([]entities.OAuthClient, error)
+	UpdateClient(ctx context.Context, clientID string, req *entities.OAuthClientRequest) (*entities.OAuthClient, error)
+	RotateSecret(ctx context.Context, clientID string) (*entities.OAuthClientCredentials, error)
+	DeleteClient(ctx context.Context, clientID string) error
+	// authenticates client at token endpoint with the method it was registered for
+	AuthenticateClient(ctx context.Context, auth *entities.ClientAuthentication) (*entities.OAuthClient, error)
+	// client verification keys registered inline, by jwks_uri or as local jwks file
+	ClientKeys(client *entities.OAuthClient) (oidc.KeySet, error)
+	// verifies self-signed assertion of the client (iss and sub are client id) and records its jti,
+	// rejected assertions are reported with oidc.ErrInvalidAssertion
+	VerifyClientAssertion(ctx context.Context, client *entities.OAuthClient, raw string) error
+}
+
+type oauthClientService struct {
+	cfg       *config.Config
+	log       *slog.Logger
+	repo      repo.OAuthClientRepository
+	oauthRepo repo.OAuthRepository
+	tenants   repo.TenantRepository
+
+	mu         sync.Mutex
+	remoteKeys map[string]*oidc.RemoteKeySet
+	fileKeys   map[string]*oidc.FileKeySet
+}
+
+func NewOAuthClientService(log *slog.Logger, cfg *config.Config, repo repo.OAuthClientRepository, oauthRepo repo.OAuthRepository, tenants repo.TenantRepository) OAuthClientService {
+	return &oauthClientService{
+		cfg:        cfg,
+		log:        log,
+		repo:       repo,
+		oauthRepo:  oauthRepo,
+		tenants:    tenants,
+		remoteKeys: map[string]*oidc.RemoteKeySet{},
+		fileKeys:   map[string]*oidc.FileKeySet{},
+	}
+}
+
+func (cs *oauthClientService) CreateClient(ctx context.Context, req *entities.OAuthClientRequest) (*entities.OAuthClientCredentials, error) {
+	const op = "service.CreateClient"
+	cs.log.Info(op, slog.String("msg", "Registering oauth client"))
+
+	client, err := clientFromRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := cs.checkTenant(ctx, client.TenantID); err != nil {
+		return nil, err
+	}
+
+	client.ID = req.ClientID
+	if client.ID == "" {
+		client.ID, err = crypt.GenerateOpaqueToken(16)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	var secret string
+	if usesSecret(client.TokenEndpointAuthMethod) {
+		secret, err = crypt.GenerateOpaqueToken(32)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		secretHash := crypt.HashOpaqueToken(secret)
+		client.SecretHash = &secretHash
+	}
+
+	created, err := cs.repo.CreateClient(ctx, client)
+	if errors.Is(err, repo.ErrEntityAlreadyExists) {
+		return nil, ErrClientAlreadyExists
+	}
+	if err != nil {
+		cs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &entities.OAuthClientCredentials{OAuthClient: created, ClientSecret: secret}, nil
+}
+
+func (cs *oauthClientService) GetClient(ctx context.Context, clientID string) (*entities.OAuthClient, error) {
+	const op = "service.GetClient"
+
+	client, err := cs.repo.GetClient(ctx, clientID)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrClientNotFound
+	}
+	if err != nil {
+		cs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return client, nil
+}
+
+func (cs *oauthClientService) ListClients(ctx context.Context) ([]entities.OAuthClient, error) {
+	const op = "service.ListClients"
+
+	clients, err := cs.repo.ListClients(ctx)
+	if err != nil {
+		cs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return clients, nil
+}
+
+func (cs *oauthClientService) UpdateClient(ctx context.Context, clientID string, req *entities.OAuthClientRequest) (*entities.OAuthClient, error) {
+	const op = "service.UpdateClient"
+	cs.log.Info(op, slog.String("msg", "Updating oauth client"), slog.String("client_id", clientID))
+
+	current, err := cs.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := clientFromRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	client.ID = clientID
+
+	// switching to secret based auth needs a secret, switching away must not leave a usable one
+	if usesSecret(client.TokenEndpointAuthMethod) != usesSecret(current.TokenEndpointAuthMethod) {
+		return nil, &InvalidClientMetadataError{Reason: "token_endpoint_auth_method can't change between secret and non-secret methods"}
+	}
+	// sessions already issued stay in the tenant they were created in
+	if client.TenantID != current.TenantID {
+		return nil, &InvalidClientMetadataError{Reason: "tenant_id can't change"}
+	}
+
+	updated, err := cs.repo.UpdateClient(ctx, client)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrClientNotFound
+	}
+	if err != nil {
+		cs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return updated, nil
+}
+
+func (cs *oauthClientService) RotateSecret(ctx context.Context, clientID string) (*entities.OAuthClientCredentials, error) {
+	const op = "service.RotateSecret"
+	cs.log.Info(op, slog.String("msg", "Rotating client secret"), slog.String("client_id", clientID))
+
+	client, err := cs.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if !usesSecret(client.TokenEndpointAuthMethod) {
+		return nil, &InvalidClientMetadataError{Reason: "client doesn't authenticate with a secret"}
+	}
+
+	secret, err := crypt.GenerateOpaqueToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	secretHash := crypt.HashOpaqueToken(secret)
+
+	err = cs.repo.UpdateClientSecret(ctx, clientID, &secretHash)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrClientNotFound
+	}
+	if err != nil {
+		cs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	client.SecretHash = &secretHash
+	return &entities.OAuthClientCredentials{OAuthClient: client, ClientSecret: secret}, nil
+}
+
+func (cs *oauthClientService) DeleteClient(ctx context.Context, clientID string) error {
+	const op = "service.DeleteClient"
+	cs.log.Info(op, slog.String("msg", "Deleting oauth client"), slog.String("client_id", clientID))
+
+	err := cs.repo.DeleteClient(ctx, clientID)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrClientNotFound
+	}
+	if err != nil {
+		cs.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (cs *oauthClientService) AuthenticateClient(ctx context.Context, auth *entities.ClientAuthentication) (*entities.OAuthClient, error) {
+	const op = "service.AuthenticateClient"
+
+	invalidClient := oauthError("invalid_client", "client authentication failed")
+
+	clientID := auth.ClientID
+	if auth.Method == entities.ClientAuthPrivateKeyJWT {
+		// client_id is optional in the request, the assertion names the client
+		unverified, err := oidc.PeekAssertionIssuer(auth.ClientAssertion)
+		if err != nil || (clientID != "" && clientID != unverified) {
+			return nil, invalidClient
+		}
+		clientID = unverified
+	}
+	if clientID == "" {
+		return nil, invalidClient
+	}
+
+	client, err := cs.repo.GetClient(ctx, clientID)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, invalidClient
+	}
+	if err != nil {
+		cs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if auth.Method != client.TokenEndpointAuthMethod {
+		cs.log.Info(op, slog.String("msg", "client used unregistered auth method"), slog.String("client_id", clientID))
+		return nil, invalidClient
+	}
+
+	switch auth.Method {
+	case entities.ClientAuthSecretBasic, entities.ClientAuthSecretPost:
+		if client.SecretHash == nil || subtle.ConstantTimeCompare([]byte(crypt.HashOpaqueToken(auth.ClientSecret)), []byte(*client.SecretHash)) != 1 {
+			return nil, invalidClient
+		}
+	case entities.ClientAuthPrivateKeyJWT:
+		err := cs.VerifyClientAssertion(ctx, client, auth.ClientAssertion)
+		if errors.Is(err, oidc.ErrInvalidAssertion) {
+			return nil, invalidClient
+		}
+		if err != nil {
+			return nil, err
+		}
+	case entities.ClientAuthNone:
+	default:
+		return nil, invalidClient
+	}
+
+	return client, nil
+}
+
+func (cs *oauthClientService) VerifyClientAssertion(ctx context.Context, client *entities.OAuthClient, raw string) error {
+	const op = "service.VerifyClientAssertion"
+
+	keys, err := cs.ClientKeys(client)
+	if err != nil {
+		return fmt.Errorf("%s: %w: %w", op, oidc.ErrInvalidAssertion, err)
+	}
+	assertion, err := oidc.VerifyAssertion(ctx, raw, keys, cs.assertionAudiences(client))
+	if err != nil || assertion.Issuer != client.ID || assertion.Subject != client.ID {
+		cs.log.Info(op, slog.String("msg", "client assertion rejected"), slog.String("client_id", client.ID))
+		return fmt.Errorf("%s: %w", op, oidc.ErrInvalidAssertion)
+	}
+	err = cs.oauthRepo.UseAssertionJTI(ctx, client.ID, assertion.ID, assertion.ExpiresAt.Time)
+	if errors.Is(err, repo.ErrEntityAlreadyExists) {
+		cs.log.Info(op, slog.String("msg", "client assertion replayed"), slog.String("client_id", client.ID))
+		return fmt.Errorf("%s: %w", op, oidc.ErrInvalidAssertion)
+	}
+	if err != nil {
+		cs.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (cs *oauthClientService) ClientKeys(client *entities.OAuthClient) (oidc.KeySet, error) {
+	if len(client.JWKS) > 0 {
+		var set oidc.JWKS
+		if err := json.Unmarshal(client.JWKS, &set); err != nil {
+			return nil, err
+		}
+		return oidc.NewStaticKeySet(&set), nil
+	}
+	if client.JWKSURI == nil && client.JWKSFile == nil {
+		return nil, oidc.ErrKeyNotFound
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if client.JWKSFile != nil {
+		keys, ok := cs.fileKeys[*client.JWKSFile]
+		if !ok {
+			keys = oidc.NewFileKeySet(*client.JWKSFile)
+			cs.fileKeys[*client.JWKSFile] = keys
+		}
+		return keys, nil
+	}
+
+	keys, ok := cs.remoteKeys[*client.JWKSURI]
+	if !ok {
+		keys = oidc.NewRemoteKeySet(*client.JWKSURI, &http.Client{Timeout: 10 * time.Second})
+		cs.remoteKeys[*client.JWKSURI] = keys
+	}
+	return keys, nil
+}
+
+// assertions may be addressed to the token endpoint or to the issuer itself,
+// clients of tenants with own issuer may use that one as well
+func (cs *oauthClientService) assertionAudiences(client *entities.OAuthClient) []string {
+	issuer := strings.TrimSuffix(cs.cfg.OIDC.Issuer, "/")
+	audiences := []string{issuer + "/token", cs.cfg.OIDC.Issuer}
+	if tenantIssuer := issuerOf(cs.cfg, client.TenantID); tenantIssuer != cs.cfg.OIDC.Issuer {
+		audiences = append(audiences, tenantIssuer)
+	}
+	return audiences
+}
+
+func (cs *oauthClientService) checkTenant(ctx context.Context, tenantID string) error {
+	const op = "service.checkClientTenant"
+
+	_, err := cs.tenants.GetTenant(ctx, tenantID)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return &InvalidClientMetadataError{Reason: "tenant " + tenantID + " doesn't exist"}
+	}
+	if err != nil {
+		cs.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func usesSecret(method string) bool {
+	return method == entities.ClientAuthSecretBasic || method == entities.ClientAuthSecretPost
+}
+
+// validates registration request and converts it to client without id and secret
+func clientFromRequest(req *entities.OAuthClientRequest) (*entities.OAuthClient, error) {
+	client := entities.OAuthClient{
+		Name:                    req.Name,
+		RedirectURIs:            req.RedirectURIs,
+		GrantTypes:              req.GrantTypes,
+		Scopes:                  req.Scopes,
+		ExchangeAudiences:       req.ExchangeAudiences,
+		FirstParty:              req.FirstParty,
+		TenantID:                req.TenantID,
+		TokenEndpointAuthMethod: req.TokenEndpointAuthMethod,
+		AccessTokenTTL:          req.AccessTokenTTL,
+		RefreshTokenTTL:         req.RefreshTokenTTL,
+	}
+
+	if client.TokenEndpointAuthMethod == "" {
+		client.TokenEndpointAuthMethod = entities.ClientAuthSecretBasic
+	}
+	if client.TenantID == "" {
+		client.TenantID = entities.DefaultTenantID
+	}
+	if !slices.Contains(supportedClientAuthMethods, client.TokenEndpointAuthMethod) {
+		return nil, &InvalidClientMetadataError{Reason: "unsupported token_endpoint_auth_method"}
+	}
+
+	if len(client.GrantTypes) == 0 {
+		client.GrantTypes = entities.StringList{"authorization_code", "refresh_token"}
+	}
+	for _, grant := range client.GrantTypes {
+		if !slices.Contains(supportedGrantTypes, grant) {
+			return nil, &InvalidClientMetadataError{Reason: "unsupported grant type " + grant}
+		}
+	}
+
+	if len(client.Scopes) == 0 {
+		client.Scopes = entities.StringList{"openid"}
+	}
+	for _, scope := range client.Scopes {
+		if scope == "" || strings.ContainsAny(scope, " \"\\") {
+			return nil, &InvalidClientMetadataError{Reason: "invalid scope " + scope}
+		}
+	}
+
+	if slices.Contains(client.GrantTypes, "client_credentials") && client.TokenEndpointAuthMethod == entities.ClientAuthNone {
+		return nil, &InvalidClientMetadataError{Reason: "client_credentials grant requires a confidential client"}
+	}
+
+	if slices.Contains(client.GrantTypes, GrantTypeTokenExchange) {
+		if client.TokenEndpointAuthMethod == entities.ClientAuthNone {
+			return nil, &InvalidClientMetadataError{Reason: "token exchange grant requires a confidential client"}
+		}
+		if len(client.ExchangeAudiences) == 0 {
+			return nil, &InvalidClientMetadataError{Reason: "exchange_audiences are required for token exchange grant"}
+		}
+	}
+	for _, aud := range client.ExchangeAudiences {
+		if aud == "" {
+			return nil, &InvalidClientMetadataError{Reason: "exchange audience can't be empty"}
+		}
+	}
+
+	if slices.Contains(client.GrantTypes, "authorization_code") && len(client.RedirectURIs) == 0 {
+		return nil, &InvalidClientMetadataError{Reason: "redirect_uris are required for authorization_code grant"}
+	}
+	for _, redirectURI := range client.RedirectURIs {
+		u, err := url.Parse(redirectURI)
+		if err != nil || !u.IsAbs() || u.Fragment != "" {
+			return nil, &InvalidClientMetadataError{Reason: "redirect uri must be absolute without fragment"}
+		}
+	}
+
+	keySources := 0
+	for _, set := range []bool{req.JWKSURI != "", len(req.JWKS) > 0, req.JWKSFile != ""} {
+		if set {
+			keySources++
+		}
+	}
+	if keySources > 1 {
+		return nil, &InvalidClientMetadataError{Reason: "only one of jwks_uri, jwks and jwks_file can be set"}
+	}
+	if client.TokenEndpointAuthMethod == entities.ClientAuthPrivateKeyJWT && keySources == 0 {
+		return nil, &InvalidClientMetadataError{Reason: "one of jwks_uri, jwks and jwks_file is required for private_key_jwt"}
+	}
+	if slices.Contains(client.GrantTypes, GrantTypeJWTBearer) && keySources == 0 {
+		return nil, &InvalidClientMetadataError{Reason: "one of jwks_uri, jwks and jwks_file is required for jwt-bearer grant"}
+	}
+	if req.JWKSURI != "" {
+		u, err := url.Parse(req.JWKSURI)
+		if err != nil || u.Scheme != "https" {
+			return nil, &InvalidClientMetadataError{Reason: "jwks_uri must be an https url"}
+		}
+		client.JWKSURI = &req.JWKSURI
+	}
+	if len(req.JWKS) > 0 {
+		var set oidc.JWKS
+		if err := json.Unmarshal(req.JWKS, &set); err != nil || len(set.PublicKeys()) == 0 {
+			return nil, &InvalidClientMetadataError{Reason: "jwks must contain at least one signing key"}
+		}
+		client.JWKS = req.JWKS
+	}
+	if req.JWKSFile != "" {
+		if !filepath.IsAbs(req.JWKSFile) {
+			return nil, &InvalidClientMetadataError{Reason: "jwks_file must be an absolute path"}
+		}
+		set, err := oidc.ReadJWKSFile(req.JWKSFile)
+		if err != nil || len(set.PublicKeys()) == 0 {
+			return nil, &InvalidClientMetadataError{Reason: "jwks_file must be readable and contain at least one signing key"}
+		}
+		client.JWKSFile = &req.JWKSFile
+	}
+
+	if req.BackchannelLogoutURI != "" {
+		u, err := url.Parse(req.BackchannelLogoutURI)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.Fragment != "" {
+			return nil, &InvalidClientMetadataError{Reason: "backchannel_logout_uri must be an absolute http(s) url without fragment"}
+		}
+		client.BackchannelLogoutURI = &req.BackchannelLogoutURI
+	}
+
+	if (client.AccessTokenTTL != nil && *client.AccessTokenTTL <= 0) || (client.RefreshTokenTTL != nil && *client.RefreshTokenTTL <= 0) {
+		return nil, &InvalidClientMetadataError{Reason: "token ttl overrides must be positive"}
+	}
+
+	return &client, nil
+}
+
+// lifetimes overridden for client, zero values fall back to server defaults
+func clientTokenTTL(client *entities.OAuthClient) entities.TokenTTL {
+	var ttl entities.TokenTTL
+	if client.AccessTokenTTL != nil {
+		ttl.Access = time.Duration(*client.AccessTokenTTL) * time.Second
+	}
+	if client.RefreshTokenTTL != nil {
+		ttl.Refresh = time.Duration(*client.RefreshTokenTTL) * time.Second
+	}
+	return ttl
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	repo "testovoe_medods/repository"
+
+	"github.com/google/uuid"
+)
+
+var ErrConsentNotFound = errors.New("consent doesn't exist")
+
+// user has to approve scopes requested by third-party client before authorization continues
+type ConsentRequiredError struct {
+	ClientID string
+	Scope    string
+}
+
+func (e *ConsentRequiredError) Error() string {
+	return "consent required"
+}
+
+type ConsentService interface {
+	// reports whether user already granted all scopes to client, first-party clients need no consent
+	HasConsent(ctx context.Context, userGuid uuid.UUID, client *entities.OAuthClient, scopes []string) (bool, error)
+	// describes request for consent screen
+	Describe(ctx context.Context, userGuid uuid.UUID, req *entities.ConsentRequest) (*entities.ConsentInfo, error)
+	// adds requested scopes to the grant user has given to client
+	Grant(ctx context.Context, userGuid uuid.UUID, req *entities.ConsentRequest) error
+	ListConsents(ctx context.Context, userGuid uuid.UUID) ([]entities.OAuthConsent, error)
+	// withdraws grant and revokes sessions issued to client for the user
+	Revoke(ctx context.Context, userGuid uuid.UUID, clientID string) error
+}
+
+type consentService struct {
+	cfg           *config.Config
+	log           *slog.Logger
+	repo          repo.ConsentRepository
+	clientService OAuthClientService
+}
+
+func NewConsentService(log *slog.Logger, cfg *config.Config, repo repo.ConsentRepository, clientService OAuthClientService) ConsentService {
+	return &consentService{
+		cfg:           cfg,
+		log:           log,
+		repo:          repo,
+		clientService: clientService,
+	}
+}
+
+func (cs *consentService) HasConsent(ctx context.Context, userGuid uuid.UUID, client *entities.OAuthClient, scopes []string) (bool, error) {
+	const op = "service.HasConsent"
+
+	if client.FirstParty {
+		return true, nil
+	}
+
+	granted, err := cs.grantedScopes(ctx, userGuid, client.ID)
+	if err != nil {
+		cs.log.Error(op, slog.String("error", err.Error()))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (cs *consentService) Describe(ctx context.Context, userGuid uuid.UUID, req *entities.ConsentRequest) (*entities.ConsentInfo, error) {
+	const op = "service.DescribeConsent"
+
+	client, err := cs.clientService.GetClient(ctx, req.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	scopes, err := clientScopes(client, req.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	granted, err := cs.grantedScopes(ctx, userGuid, client.ID)
+	if err != nil {
+		cs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &entities.ConsentInfo{
+		ClientID:      client.ID,
+		ClientName:    client.Name,
+		Scopes:        scopes,
+		GrantedScopes: granted,
+	}, nil
+}
+
+func (cs *consentService) Grant(ctx context.Context, userGuid uuid.UUID, req *entities.ConsentRequest) error {
+	const op = "service.GrantConsent"
+	cs.log.Info(op, slog.String("msg", "Granting consent"), slog.String("client_id", req.ClientID))
+
+	client, err := cs.clientService.GetClient(ctx, req.ClientID)
+	if err != nil {
+		return err
+	}
+	scopes, err := clientScopes(client, req.Scope)
+	if err != nil {
+		return err
+	}
+
+	granted, err := cs.grantedScopes(ctx, userGuid, client.ID)
+	if err != nil {
+		cs.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	if err := cs.repo.SaveConsent(ctx, userGuid, client.ID, granted); err != nil {
+		cs.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (cs *consentService) ListConsents(ctx context.Context, userGuid uuid.UUID) ([]entities.OAuthConsent, error) {
+	const op = "service.ListConsents"
+
+	consents, err := cs.repo.ListConsents(ctx, userGuid)
+	if err != nil {
+		cs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return consents, nil
+}
+
+func (cs *consentService) Revoke(ctx context.Context, userGuid uuid.UUID, clientID string) error {
+	const op = "service.RevokeConsent"
+	cs.log.Info(op, slog.String("msg", "Revoking consent"), slog.String("client_id", clientID))
+
+	err := cs.repo.RevokeConsent(ctx, userGuid, clientID)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrConsentNotFound
+	}
+	if err != nil {
+		cs.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (cs *consentService) grantedScopes(ctx context.Context, userGuid uuid.UUID, clientID string) (entities.StringList, error) {
+	consent, err := cs.repo.GetConsent(ctx, userGuid, clientID)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return entities.StringList{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return consent.Scopes, nil
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	crypt "testovoe_medods/lib/bcrypt"
+	repo "testovoe_medods/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RFC 8628 grant type polled by devices at token endpoint
+const GrantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+var ErrInvalidUserCode = errors.New("user code is invalid or expired")
+
+// interval increase required after slow_down (RFC 8628 3.5)
+const deviceSlowDownStep = 5 * time.Second
+
+type DeviceService interface {
+	Authorize(ctx context.Context, req *entities.DeviceAuthorizationRequest) (*entities.DeviceAuthorizationResponse, error)
+	Lookup(ctx context.Context, userGuid uuid.UUID, userCode, ipAddr string) (*entities.DeviceRequestInfo, error)
+	Verify(ctx context.Context, userGuid uuid.UUID, req *entities.DeviceVerifyRequest) error
+	// returns approved device code of the client or *OAuthError describing polling state
+	Poll(ctx context.Context, client *entities.OAuthClient, deviceCode string) (*entities.DeviceCode, error)
+}
+
+type deviceService struct {
+	cfg           *config.Config
+	log           *slog.Logger
+	repo          repo.DeviceRepository
+	clientService OAuthClientService
+	lockout       LockoutService
+}
+
+func NewDeviceService(log *slog.Logger, cfg *config.Config, repo repo.DeviceRepository, clientService OAuthClientService, lockout LockoutService) DeviceService {
+	return &deviceService{
+		cfg:           cfg,
+		log:           log,
+		repo:          repo,
+		clientService: clientService,
+		lockout:       lockout,
+	}
+}
+
+func (ds *deviceService) Authorize(ctx context.Context, req *entities.DeviceAuthorizationRequest) (*entities.DeviceAuthorizationResponse, error) {
+	const op = "service.DeviceAuthorize"
+	ds.log.Info(op, slog.String("msg", "Device authorization request"))
+
+	client, err := ds.clientService.AuthenticateClient(ctx, &req.Client)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(client.GrantTypes, GrantTypeDeviceCode) {
+		return nil, oauthError("unauthorized_client", "client isn't allowed to use device code grant")
+	}
+
+	scopes, err := clientScopes(client, req.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceCode, err := crypt.GenerateOpaqueToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	userCode, err := crypt.GenerateUserCode()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	interval := int64(ds.cfg.Device.Interval.Seconds())
+	err = ds.repo.CreateDeviceCode(ctx, &entities.DeviceCode{
+		DeviceCodeHash: crypt.HashOpaqueToken(deviceCode),
+		UserCodeHash:   crypt.HMACDigest(crypt.NormalizeUserCode(userCode)),
+		ClientID:       client.ID,
+		Scope:          strings.Join(scopes, " "),
+		IntervalSec:    interval,
+		ExpiresAt:      time.Now().Add(ds.cfg.Device.CodeTTL),
+	})
+	if err != nil {
+		ds.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &entities.DeviceAuthorizationResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationURI:         ds.cfg.Device.VerificationURL,
+		VerificationURIComplete: appendQuery(ds.cfg.Device.VerificationURL, url.Values{"user_code": {userCode}}),
+		ExpiresIn:               int64(ds.cfg.Device.CodeTTL.Seconds()),
+		Interval:                interval,
+	}, nil
+}
+
+func (ds *deviceService) Lookup(ctx context.Context, userGuid uuid.UUID, userCode, ipAddr string) (*entities.DeviceRequestInfo, error) {
+	const op = "service.DeviceLookup"
+
+	code, err := ds.pendingCode(ctx, userGuid, userCode, ipAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := ds.clientService.GetClient(ctx, code.ClientID)
+	if errors.Is(err, ErrClientNotFound) {
+		return nil, ErrInvalidUserCode
+	}
+	if err != nil {
+		ds.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &entities.DeviceRequestInfo{
+		ClientID:   client.ID,
+		ClientName: client.Name,
+		Scopes:     strings.Fields(code.Scope),
+	}, nil
+}
+
+func (ds *deviceService) Verify(ctx context.Context, userGuid uuid.UUID, req *entities.DeviceVerifyRequest) error {
+	const op = "service.DeviceVerify"
+	ds.log.Info(op, slog.String("msg", "Verifying device"), slog.Bool("approve", req.Approve))
+
+	code, err := ds.pendingCode(ctx, userGuid, req.UserCode, req.IpAddr)
+	if err != nil {
+		return err
+	}
+
+	status := entities.DeviceCodeDenied
+	if req.Approve {
+		status = entities.DeviceCodeApproved
+	}
+
+	err = ds.repo.DecideDeviceCode(ctx, code.UserCodeHash, status, userGuid)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrInvalidUserCode
+	}
+	if err != nil {
+		ds.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (ds *deviceService) Poll(ctx context.Context, client *entities.OAuthClient, deviceCode string) (*entities.DeviceCode, error) {
+	const op = "service.DevicePoll"
+
+	hash := crypt.HashOpaqueToken(deviceCode)
+	code, err := ds.repo.GetDeviceCode(ctx, hash)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, oauthError("invalid_grant", "device code is invalid")
+	}
+	if err != nil {
+		ds.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if code.ClientID != client.ID {
+		return nil, oauthError("invalid_grant", "device code was issued to another client")
+	}
+
+	if time.Now().After(code.ExpiresAt) {
+		return nil, oauthError("expired_token", "device code has expired")
+	}
+
+	allowed, err := ds.repo.RecordDevicePoll(ctx, hash, int64(deviceSlowDownStep.Seconds()))
+	if err != nil {
+		ds.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !allowed {
+		return nil, oauthError("slow_down", "")
+	}
+
+	switch code.Status {
+	case entities.DeviceCodePending:
+		return nil, oauthError("authorization_pending", "")
+	case entities.DeviceCodeDenied:
+		if err := ds.repo.DeleteDeviceCode(ctx, hash); err != nil {
+			ds.log.Error(op, slog.String("error", err.Error()))
+		}
+		return nil, oauthError("access_denied", "user denied the request")
+	}
+
+	approved, err := ds.repo.ConsumeApprovedDeviceCode(ctx, hash)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, oauthError("invalid_grant", "device code was already used")
+	}
+	if err != nil {
+		ds.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return approved, nil
+}
+
+// user codes are short, so guessing them is throttled per user and ip
+func (ds *deviceService) pendingCode(ctx context.Context, userGuid uuid.UUID, userCode, ipAddr string) (*entities.DeviceCode, error) {
+	const op = "service.pendingDeviceCode"
+
+	ipKey := IPLockoutKey(ipAddr)
+	userKey := UserLockoutKey(userGuid.String())
+	if err := ds.lockout.Check(ctx, ipKey, userKey); err != nil {
+		return nil, err
+	}
+
+	code, err := ds.repo.GetPendingByUserCode(ctx, crypt.HMACDigest(crypt.NormalizeUserCode(userCode)))
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		ds.lockout.RegisterFailure(ctx, ipKey, userKey)
+		return nil, ErrInvalidUserCode
+	}
+	if err != nil {
+		ds.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return code, nil
+}
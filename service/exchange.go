@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"testovoe_medods/entities"
+	jwtp "testovoe_medods/lib/jwt"
+	repo "testovoe_medods/repository"
+	"time"
+)
+
+// RFC 8693 grant type used by services to exchange tokens
+const GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+// the only token type accepted and issued by token exchange
+const TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+
+// exchanges user or service access token for a narrower one intended for another audience,
+// the exchanging client (or the actor token presented) is recorded in act claim
+func (oas *oauthService) tokenExchange(ctx context.Context, client *entities.OAuthClient, req *entities.TokenRequest) (*entities.TokenResponse, error) {
+	const op = "service.tokenExchange"
+
+	if client.TokenEndpointAuthMethod == entities.ClientAuthNone {
+		return nil, oauthError("unauthorized_client", "public clients can't exchange tokens")
+	}
+	if req.SubjectToken == "" || req.SubjectTokenType != TokenTypeAccessToken {
+		return nil, oauthError("invalid_request", "subject_token of access_token type is required")
+	}
+	if req.RequestedTokenType != "" && req.RequestedTokenType != TokenTypeAccessToken {
+		return nil, oauthError("invalid_request", "only access tokens can be requested")
+	}
+
+	subject, err := jwtp.GetAndValidateTokenClaims(req.SubjectToken, false)
+	if err != nil {
+		return nil, oauthError("invalid_grant", "subject token is invalid or expired")
+	}
+	// clients of one tenant can't mint tokens for users of another
+	if claimsTenant(subject) != client.TenantID {
+		return nil, oauthError("invalid_grant", "subject token belongs to another tenant")
+	}
+
+	// scope of the subject token bounds the exchanged one, first-party sessions have no scope limit
+	subjectScopes := strings.Fields(subject.Scope)
+	if !subject.ServiceToken {
+		session, err := oas.authRepo.GetAuthInfoById(ctx, claimsTenant(subject), subject.Subject)
+		if errors.Is(err, repo.ErrEntityNotExists) {
+			return nil, oauthError("invalid_grant", "subject token is invalid or expired")
+		}
+		if err != nil {
+			oas.log.Error(op, slog.String("error", err.Error()))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if subject.Scope == "" && session.Scope != nil {
+			subjectScopes = strings.Fields(*session.Scope)
+		}
+	}
+
+	act := &jwtp.Actor{Subject: client.ID, Act: subject.Act}
+	if req.ActorToken != "" {
+		if req.ActorTokenType != TokenTypeAccessToken {
+			return nil, oauthError("invalid_request", "actor_token must be an access token")
+		}
+		// only services can act on behalf of someone else
+		actor, err := jwtp.GetAndValidateTokenClaims(req.ActorToken, false)
+		if err != nil || !actor.ServiceToken {
+			return nil, oauthError("invalid_grant", "actor token is invalid or expired")
+		}
+		// actor token must belong to the exchanging client, otherwise any leaked service token
+		// could be named as the actor
+		if actor.ClientID != client.ID {
+			return nil, oauthError("invalid_grant", "actor token was issued to another client")
+		}
+		act.Subject = actor.Subject
+	}
+
+	audience := req.Audience
+	if len(audience) == 0 {
+		audience = client.ExchangeAudiences
+	}
+	if len(audience) == 0 {
+		return nil, oauthError("invalid_target", "audience is required")
+	}
+	for _, aud := range audience {
+		if !slices.Contains(client.ExchangeAudiences, aud) {
+			return nil, oauthError("invalid_target", "audience "+aud+" isn't allowed for client")
+		}
+	}
+
+	scopes := strings.Fields(req.Scope)
+	if len(scopes) == 0 {
+		scopes = subjectScopes
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return nil, oauthError("invalid_scope", "scope "+scope+" isn't allowed for client")
+		}
+		if len(subjectScopes) > 0 && !slices.Contains(subjectScopes, scope) {
+			return nil, oauthError("invalid_scope", "scope "+scope+" exceeds subject token scope")
+		}
+	}
+	scope := strings.Join(scopes, " ")
+
+	// exchanged token never outlives the subject token
+	ttl := oas.accessTokenTTL(client)
+	if left := time.Until(subject.ExpiresAt.Time); left < ttl {
+		ttl = left
+	}
+
+	accessToken, err := jwtp.GenerateExchangedToken(subject, client.ID, scope, audience, act, ttl)
+	if err != nil {
+		oas.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	oas.log.Info(op, slog.String("msg", "token exchanged"), slog.String("client_id", client.ID),
+		slog.String("actor", act.Subject))
+	return &entities.TokenResponse{
+		AccessToken:     accessToken,
+		TokenType:       "Bearer",
+		ExpiresIn:       int64(ttl.Seconds()),
+		Scope:           scope,
+		IssuedTokenType: TokenTypeAccessToken,
+	}, nil
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	crypt "testovoe_medods/lib/bcrypt"
+	"testovoe_medods/lib/oidc"
+	repo "testovoe_medods/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrUnknownProvider = errors.New("identity provider isn't configured")
+var ErrInvalidFederationState = errors.New("federation state is invalid or expired")
+var ErrFederationFailed = errors.New("identity provider login failed")
+var ErrSignupNotAllowed = errors.New("no account is linked to this identity")
+var ErrFederationConflict = errors.New("identity can't be linked to this account, sign in and link the provider first")
+
+type FederationService interface {
+	// returns url of upstream provider and state that has to be bound to the browser.
+	// When linkUser is set the identity is linked to that signed in user on callback
+	Begin(ctx context.Context, provider string, linkUser *uuid.UUID) (string, string, error)
+	Callback(ctx context.Context, req *entities.FederationCallbackRequest) (*entities.TokenPair, error)
+}
+
+type federationService struct {
+	cfg         *config.Config
+	log         *slog.Logger
+	repo        repo.FederationRepository
+	userRepo    repo.UserRepository
+	authService AuthService
+
+	mu      sync.Mutex
+	clients map[string]*oidc.Client
+}
+
+func NewFederationService(log *slog.Logger, cfg *config.Config, repo repo.FederationRepository, userRepo repo.UserRepository, authService AuthService) FederationService {
+	return &federationService{
+		cfg:         cfg,
+		log:         log,
+		repo:        repo,
+		userRepo:    userRepo,
+		authService: authService,
+		clients:     map[string]*oidc.Client{},
+	}
+}
+
+func (fs *federationService) Begin(ctx context.Context, provider string, linkUser *uuid.UUID) (string, string, error) {
+	const op = "service.FederationBegin"
+	fs.log.Info(op, slog.String("msg", "Starting federated login"), slog.String("provider", provider))
+
+	client, err := fs.client(ctx, provider)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := crypt.GenerateOpaqueToken(32)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	nonce, err := crypt.GenerateOpaqueToken(16)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	verifier, err := crypt.GenerateOpaqueToken(32)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = fs.repo.CreateState(ctx, &entities.FederationState{
+		StateHash:    crypt.HashOpaqueToken(state),
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(fs.cfg.Federation.StateTTL),
+		LinkUserGuid: linkUser,
+	})
+	if err != nil {
+		fs.log.Error(op, slog.String("error", err.Error()))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return client.AuthCodeURL(state, nonce, verifier), state, nil
+}
+
+func (fs *federationService) Callback(ctx context.Context, req *entities.FederationCallbackRequest) (*entities.TokenPair, error) {
+	const op = "service.FederationCallback"
+	fs.log.Info(op, slog.String("msg", "Finishing federated login"), slog.String("provider", req.Provider))
+
+	providerCfg, ok := fs.providerConfig(req.Provider)
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	// state from query must be the one issued to this browser, otherwise it's a login csrf
+	if req.State == "" || subtle.ConstantTimeCompare([]byte(req.State), []byte(req.CookieState)) != 1 {
+		return nil, ErrInvalidFederationState
+	}
+
+	state, err := fs.repo.ConsumeState(ctx, crypt.HashOpaqueToken(req.State), req.Provider)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrInvalidFederationState
+	}
+	if err != nil {
+		fs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	client, err := fs.client(ctx, req.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := client.Exchange(ctx, req.Code, state.CodeVerifier)
+	if err != nil {
+		fs.log.Error(op, slog.String("error", err.Error()))
+		return nil, ErrFederationFailed
+	}
+
+	claims, err := client.VerifyIDToken(ctx, tokens.IDToken, state.Nonce)
+	if err != nil {
+		fs.log.Error(op, slog.String("error", err.Error()))
+		return nil, ErrFederationFailed
+	}
+
+	userGuid, err := fs.resolveUser(ctx, providerCfg, claims, state.LinkUserGuid)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.authService.ReleaseTokens(ctx, &entities.AuthenticateRequest{
+		Guid:   userGuid.String(),
+		IpAddr: req.IpAddr,
+		Method: entities.LoginMethodFederation,
+	})
+}
+
+// finds user linked to external subject. Unlinked subjects are linked to the signed in user that started
+// the login, to account with the same verified email when provider is trusted with emails, or to a new
+// account when signup is allowed for the provider
+func (fs *federationService) resolveUser(ctx context.Context, providerCfg config.FederationProvider, claims *oidc.IDTokenClaims, linkUser *uuid.UUID) (uuid.UUID, error) {
+	const op = "service.resolveUser"
+
+	var email *string
+	if claims.Email != "" && claims.EmailVerified {
+		email = &claims.Email
+	}
+
+	identity, err := fs.repo.GetIdentity(ctx, providerCfg.Name, claims.Subject)
+	if err == nil {
+		if linkUser != nil && *linkUser != identity.UserGuid {
+			return uuid.Nil, ErrFederationConflict
+		}
+		if err := fs.repo.TouchIdentity(ctx, identity.ID, email); err != nil {
+			fs.log.Error(op, slog.String("error", err.Error()))
+		}
+		return identity.UserGuid, nil
+	}
+	if !errors.Is(err, repo.ErrEntityNotExists) {
+		fs.log.Error(op, slog.String("error", err.Error()))
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	userGuid, err := fs.linkTarget(ctx, providerCfg, email, linkUser)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	err = fs.repo.LinkIdentity(ctx, &entities.FederatedIdentity{
+		Provider: providerCfg.Name,
+		Subject:  claims.Subject,
+		UserGuid: userGuid,
+		Email:    email,
+	})
+	if err != nil {
+		fs.log.Error(op, slog.String("error", err.Error()))
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	fs.log.Info(op, slog.String("msg", "external identity linked"), slog.String("provider", providerCfg.Name), slog.String("user_guid", userGuid.String()))
+	return userGuid, nil
+}
+
+// picks account new identity is linked to
+func (fs *federationService) linkTarget(ctx context.Context, providerCfg config.FederationProvider, email *string, linkUser *uuid.UUID) (uuid.UUID, error) {
+	const op = "service.linkTarget"
+
+	if linkUser != nil {
+		return *linkUser, nil
+	}
+
+	// unverified emails can't be trusted to take over existing accounts
+	if email == nil {
+		return uuid.Nil, ErrSignupNotAllowed
+	}
+
+	user, err := fs.userRepo.GetUserByEmail(ctx, *email)
+	if err == nil {
+		// anyone able to register the email upstream would take over the account otherwise
+		if !providerCfg.TrustEmail {
+			return uuid.Nil, ErrFederationConflict
+		}
+		return user.ID, nil
+	}
+	if !errors.Is(err, repo.ErrEntityNotExists) {
+		fs.log.Error(op, slog.String("error", err.Error()))
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !providerCfg.AllowSignup {
+		return uuid.Nil, ErrSignupNotAllowed
+	}
+	user, err = fs.userRepo.CreateUser(ctx, *email)
+	if err != nil {
+		fs.log.Error(op, slog.String("error", err.Error()))
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return user.ID, nil
+}
+
+// provider metadata is discovered lazily and cached, so unavailable providers don't block startup
+func (fs *federationService) client(ctx context.Context, provider string) (*oidc.Client, error) {
+	const op = "service.federationClient"
+
+	providerCfg, ok := fs.providerConfig(provider)
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if client, ok := fs.clients[provider]; ok {
+		return client, nil
+	}
+
+	client, err := oidc.NewClient(ctx, oidc.ClientConfig{
+		Issuer:       providerCfg.Issuer,
+		ClientID:     providerCfg.ClientID,
+		ClientSecret: providerCfg.ClientSecret,
+		RedirectURL:  providerCfg.RedirectURL,
+		Scopes:       providerCfg.Scopes,
+	}, nil)
+	if err != nil {
+		fs.log.Error(op, slog.String("error", err.Error()))
+		return nil, ErrFederationFailed
+	}
+
+	fs.clients[provider] = client
+	return client, nil
+}
+
+func (fs *federationService) providerConfig(provider string) (config.FederationProvider, bool) {
+	for _, p := range fs.cfg.Federation.Providers {
+		if p.Name == provider {
+			return p, true
+		}
+	}
+	return config.FederationProvider{}, false
+}
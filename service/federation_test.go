@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/lib/oidc/oidctest"
+	repo "testovoe_medods/repository"
+
+	"github.com/google/uuid"
+)
+
+type memFederationRepo struct {
+	states     map[string]entities.FederationState
+	identities []entities.FederatedIdentity
+}
+
+func (r *memFederationRepo) CreateState(ctx context.Context, data *entities.FederationState) error {
+	r.states[data.StateHash] = *data
+	return nil
+}
+
+func (r *memFederationRepo) ConsumeState(ctx context.Context, stateHash, provider string) (*entities.FederationState, error) {
+	state, ok := r.states[stateHash]
+	if !ok || state.Provider != provider || state.ExpiresAt.Before(time.Now()) {
+		return nil, repo.ErrEntityNotExists
+	}
+	delete(r.states, stateHash)
+	return &state, nil
+}
+
+func (r *memFederationRepo) GetIdentity(ctx context.Context, provider, subject string) (*entities.FederatedIdentity, error) {
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return &identity, nil
+		}
+	}
+	return nil, repo.ErrEntityNotExists
+}
+
+func (r *memFederationRepo) LinkIdentity(ctx context.Context, data *entities.FederatedIdentity) error {
+	data.ID = uuid.New()
+	r.identities = append(r.identities, *data)
+	return nil
+}
+
+func (r *memFederationRepo) TouchIdentity(ctx context.Context, id uuid.UUID, email *string) error {
+	return nil
+}
+
+type memUserRepo struct {
+	repo.UserRepository
+	users []entities.User
+}
+
+func (r *memUserRepo) GetUserByEmail(ctx context.Context, email string) (*entities.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, repo.ErrEntityNotExists
+}
+
+func (r *memUserRepo) CreateUser(ctx context.Context, email string) (*entities.User, error) {
+	user := entities.User{ID: uuid.New(), Email: email}
+	r.users = append(r.users, user)
+	return &user, nil
+}
+
+type federationFixture struct {
+	svc      FederationService
+	provider *oidctest.Provider
+	cfg      *config.FederationProvider
+	repo     *memFederationRepo
+	users    *memUserRepo
+	auth     *stubAuthService
+	existing uuid.UUID
+}
+
+func newFederationFixture(t *testing.T) *federationFixture {
+	t.Helper()
+	p, err := oidctest.NewProvider("medods", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+
+	cfg := &config.Config{Federation: config.Federation{
+		StateTTL: time.Minute,
+		Providers: []config.FederationProvider{{
+			Name:         "mock",
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  "https://medods.example/api/federation/mock/callback",
+		}},
+	}}
+	f := &federationFixture{
+		provider: p,
+		cfg:      &cfg.Federation.Providers[0],
+		repo:     &memFederationRepo{states: map[string]entities.FederationState{}},
+		users:    &memUserRepo{},
+		auth:     &stubAuthService{},
+		existing: uuid.New(),
+	}
+	f.users.users = append(f.users.users, entities.User{ID: f.existing, Email: "victim@example.com"})
+	f.svc = NewFederationService(testLogger(), cfg, f.repo, f.users, f.auth)
+	return f
+}
+
+// logs in through the mock provider as subject with given email, linkUser is the signed in user if any
+func (f *federationFixture) login(t *testing.T, subject, email string, linkUser *uuid.UUID) (*entities.TokenPair, error) {
+	t.Helper()
+	ctx := context.Background()
+	authURL, state, err := f.svc.Begin(ctx, "mock", linkUser)
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	code, returnedState, err := f.provider.Authorize(authURL, subject, map[string]any{"email": email, "email_verified": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.svc.Callback(ctx, &entities.FederationCallbackRequest{
+		Provider:    "mock",
+		State:       returnedState,
+		CookieState: state,
+		Code:        code,
+		IpAddr:      "127.0.0.1",
+	})
+}
+
+func TestFederationLoginWithLinkedIdentity(t *testing.T) {
+	f := newFederationFixture(t)
+	f.repo.identities = append(f.repo.identities, entities.FederatedIdentity{ID: uuid.New(), Provider: "mock", Subject: "upstream-1", UserGuid: f.existing})
+
+	tokens, err := f.login(t, "upstream-1", "whatever@example.com", nil)
+	if err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+	if tokens.AccessToken != "access-"+f.existing.String() {
+		t.Fatalf("tokens released for wrong user: %q", tokens.AccessToken)
+	}
+	if f.auth.released[0].Method != entities.LoginMethodFederation {
+		t.Fatalf("login released as %+v", f.auth.released[0])
+	}
+}
+
+func TestFederationRefusesLinkingByUntrustedEmail(t *testing.T) {
+	f := newFederationFixture(t)
+
+	if _, err := f.login(t, "attacker", "victim@example.com", nil); !errors.Is(err, ErrFederationConflict) {
+		t.Fatalf("err = %v, want %v", err, ErrFederationConflict)
+	}
+	if len(f.repo.identities) != 0 || len(f.auth.released) != 0 {
+		t.Fatal("identity was linked to existing account")
+	}
+}
+
+func TestFederationLinksByTrustedEmail(t *testing.T) {
+	f := newFederationFixture(t)
+	f.cfg.TrustEmail = true
+
+	if _, err := f.login(t, "upstream-1", "victim@example.com", nil); err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+	if len(f.repo.identities) != 1 || f.repo.identities[0].UserGuid != f.existing {
+		t.Fatalf("identities = %+v", f.repo.identities)
+	}
+}
+
+func TestFederationLinksSignedInUser(t *testing.T) {
+	f := newFederationFixture(t)
+	f.cfg.AllowSignup = true
+
+	// email upstream belongs to nobody here, the identity still goes to the user that started the flow
+	if _, err := f.login(t, "upstream-1", "other@example.com", &f.existing); err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+	if len(f.repo.identities) != 1 || f.repo.identities[0].UserGuid != f.existing {
+		t.Fatalf("identities = %+v", f.repo.identities)
+	}
+	if len(f.users.users) != 1 {
+		t.Fatal("new account was created")
+	}
+
+	// identity can't be moved to another account
+	other := uuid.New()
+	if _, err := f.login(t, "upstream-1", "other@example.com", &other); !errors.Is(err, ErrFederationConflict) {
+		t.Fatalf("err = %v, want %v", err, ErrFederationConflict)
+	}
+}
+
+func TestFederationSignup(t *testing.T) {
+	f := newFederationFixture(t)
+
+	if _, err := f.login(t, "upstream-1", "new@example.com", nil); !errors.Is(err, ErrSignupNotAllowed) {
+		t.Fatalf("err = %v, want %v", err, ErrSignupNotAllowed)
+	}
+
+	f.cfg.AllowSignup = true
+	if _, err := f.login(t, "upstream-1", "new@example.com", nil); err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+	if len(f.users.users) != 2 || f.repo.identities[0].UserGuid != f.users.users[1].ID {
+		t.Fatalf("users = %+v, identities = %+v", f.users.users, f.repo.identities)
+	}
+}
+
+func TestFederationRejectsForgedIDToken(t *testing.T) {
+	f := newFederationFixture(t)
+	f.repo.identities = append(f.repo.identities, entities.FederatedIdentity{ID: uuid.New(), Provider: "mock", Subject: "upstream-1", UserGuid: f.existing})
+	forger, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.provider.SigningKey = forger
+
+	if _, err := f.login(t, "upstream-1", "victim@example.com", nil); !errors.Is(err, ErrFederationFailed) {
+		t.Fatalf("err = %v, want %v", err, ErrFederationFailed)
+	}
+	if len(f.auth.released) != 0 {
+		t.Fatal("tokens were released")
+	}
+}
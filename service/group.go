@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	repo "testovoe_medods/repository"
+
+	"github.com/google/uuid"
+)
+
+var ErrGroupNotFound = errors.New("group doesn't exist")
+var ErrGroupAlreadyExists = errors.New("group with this name already exists")
+var ErrGroupMemberNotFound = errors.New("user isn't a direct member of the group")
+
+type InvalidGroupRequestError struct {
+	Reason string
+}
+
+func (e *InvalidGroupRequestError) Error() string {
+	return e.Reason
+}
+
+type GroupService interface {
+	CreateGroup(ctx context.Context, req *entities.GroupRequest) (*entities.Group, error)
+	GetGroup(ctx context.Context, id uuid.UUID) (*entities.Group, error)
+	ListGroups(ctx context.Context) ([]entities.Group, error)
+	UpdateGroup(ctx context.Context, id uuid.UUID, req *entities.GroupRequest) (*entities.Group, error)
+	DeleteGroup(ctx context.Context, id uuid.UUID) error
+	ListMembers(ctx context.Context, groupID uuid.UUID) ([]entities.GroupMember, error)
+	// changes show up in access tokens issued after the call, tokens already issued keep old groups
+	AddMember(ctx context.Context, groupID, userGuid uuid.UUID) error
+	RemoveMember(ctx context.Context, groupID, userGuid uuid.UUID) error
+	// groups user belongs to directly or through nested groups
+	ListUserGroups(ctx context.Context, userGuid uuid.UUID) ([]entities.Group, error)
+}
+
+type groupService struct {
+	cfg      *config.Config
+	log      *slog.Logger
+	repo     repo.GroupRepository
+	userRepo repo.UserRepository
+}
+
+func NewGroupService(log *slog.Logger, cfg *config.Config, repo repo.GroupRepository, userRepo repo.UserRepository) GroupService {
+	return &groupService{
+		cfg:      cfg,
+		log:      log,
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+func (gs *groupService) CreateGroup(ctx context.Context, req *entities.GroupRequest) (*entities.Group, error) {
+	const op = "service.CreateGroup"
+	gs.log.Info(op, slog.String("msg", "Creating group"), slog.String("name", req.Name))
+
+	// group names end up in token claims just like role names
+	if !roleNameRe.MatchString(req.Name) {
+		return nil, &InvalidGroupRequestError{Reason: "invalid group name"}
+	}
+	if req.ParentID != nil {
+		if _, err := gs.GetGroup(ctx, *req.ParentID); errors.Is(err, ErrGroupNotFound) {
+			return nil, &InvalidGroupRequestError{Reason: "parent group doesn't exist"}
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	group, err := gs.repo.CreateGroup(ctx, &entities.Group{Name: req.Name, Description: req.Description, ParentID: req.ParentID})
+	if errors.Is(err, repo.ErrEntityAlreadyExists) {
+		return nil, ErrGroupAlreadyExists
+	}
+	if err != nil {
+		gs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return group, nil
+}
+
+func (gs *groupService) GetGroup(ctx context.Context, id uuid.UUID) (*entities.Group, error) {
+	const op = "service.GetGroup"
+
+	group, err := gs.repo.GetGroup(ctx, id)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		gs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return group, nil
+}
+
+func (gs *groupService) ListGroups(ctx context.Context) ([]entities.Group, error) {
+	const op = "service.ListGroups"
+
+	groups, err := gs.repo.ListGroups(ctx)
+	if err != nil {
+		gs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return groups, nil
+}
+
+func (gs *groupService) UpdateGroup(ctx context.Context, id uuid.UUID, req *entities.GroupRequest) (*entities.Group, error) {
+	const op = "service.UpdateGroup"
+	gs.log.Info(op, slog.String("msg", "Updating group"), slog.String("id", id.String()))
+
+	if !roleNameRe.MatchString(req.Name) {
+		return nil, &InvalidGroupRequestError{Reason: "invalid group name"}
+	}
+	if req.ParentID != nil {
+		// group can't become a subgroup of itself or of its own subgroup
+		ancestors, err := gs.repo.GetGroupAncestors(ctx, *req.ParentID)
+		if err != nil {
+			gs.log.Error(op, slog.String("error", err.Error()))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if len(ancestors) == 0 {
+			return nil, &InvalidGroupRequestError{Reason: "parent group doesn't exist"}
+		}
+		if slices.Contains(ancestors, id) {
+			return nil, &InvalidGroupRequestError{Reason: "group can't be nested into itself"}
+		}
+	}
+
+	group, err := gs.repo.UpdateGroup(ctx, &entities.Group{ID: id, Name: req.Name, Description: req.Description, ParentID: req.ParentID})
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrGroupNotFound
+	}
+	if errors.Is(err, repo.ErrEntityAlreadyExists) {
+		return nil, ErrGroupAlreadyExists
+	}
+	if err != nil {
+		gs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return group, nil
+}
+
+func (gs *groupService) DeleteGroup(ctx context.Context, id uuid.UUID) error {
+	const op = "service.DeleteGroup"
+	gs.log.Info(op, slog.String("msg", "Deleting group"), slog.String("id", id.String()))
+
+	err := gs.repo.DeleteGroup(ctx, id)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrGroupNotFound
+	}
+	if err != nil {
+		gs.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (gs *groupService) ListMembers(ctx context.Context, groupID uuid.UUID) ([]entities.GroupMember, error) {
+	const op = "service.ListGroupMembers"
+
+	if _, err := gs.GetGroup(ctx, groupID); err != nil {
+		return nil, err
+	}
+
+	members, err := gs.repo.ListMembers(ctx, groupID)
+	if err != nil {
+		gs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return members, nil
+}
+
+func (gs *groupService) AddMember(ctx context.Context, groupID, userGuid uuid.UUID) error {
+	const op = "service.AddGroupMember"
+	gs.log.Info(op, slog.String("msg", "Adding group member"), slog.String("group", groupID.String()), slog.String("user", userGuid.String()))
+
+	if _, err := gs.GetGroup(ctx, groupID); err != nil {
+		return err
+	}
+	if err := gs.checkUser(ctx, userGuid); err != nil {
+		return err
+	}
+
+	if err := gs.repo.AddMember(ctx, groupID, userGuid); err != nil {
+		gs.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (gs *groupService) RemoveMember(ctx context.Context, groupID, userGuid uuid.UUID) error {
+	const op = "service.RemoveGroupMember"
+	gs.log.Info(op, slog.String("msg", "Removing group member"), slog.String("group", groupID.String()), slog.String("user", userGuid.String()))
+
+	err := gs.repo.RemoveMember(ctx, groupID, userGuid)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrGroupMemberNotFound
+	}
+	if err != nil {
+		gs.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (gs *groupService) ListUserGroups(ctx context.Context, userGuid uuid.UUID) ([]entities.Group, error) {
+	const op = "service.ListUserGroups"
+
+	if err := gs.checkUser(ctx, userGuid); err != nil {
+		return nil, err
+	}
+
+	groups, err := gs.repo.GetUserGroups(ctx, userGuid)
+	if err != nil {
+		gs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return groups, nil
+}
+
+func (gs *groupService) checkUser(ctx context.Context, userGuid uuid.UUID) error {
+	const op = "service.checkGroupUser"
+
+	_, err := gs.userRepo.GetUserById(ctx, userGuid)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrNoUserFound
+	}
+	if err != nil {
+		gs.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// values of groups claim in configured format
+func groupClaimValues(cfg *config.Config, groups []entities.Group) []string {
+	values := make([]string, 0, len(groups))
+	for _, group := range groups {
+		if cfg.Groups.ClaimFormat == entities.GroupClaimID {
+			values = append(values, group.ID.String())
+		} else {
+			values = append(values, group.Name)
+		}
+	}
+	return values
+}
+
+// tokens of users in too many groups carry overage flag instead of the list, the full list is in /userinfo
+func tokenGroupClaims(cfg *config.Config, groups []entities.Group) ([]string, bool) {
+	if len(groups) > cfg.Groups.MaxTokenGroups {
+		return nil, true
+	}
+	return groupClaimValues(cfg, groups), false
+}
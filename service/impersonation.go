@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	jwtp "testovoe_medods/lib/jwt"
+	repo "testovoe_medods/repository"
+	"time"
+)
+
+var ErrImpersonationForbidden = errors.New("impersonation permission required")
+var ErrImpersonationTargetForbidden = errors.New("user holds permissions that can't be impersonated")
+
+type InvalidImpersonationRequestError struct {
+	Reason string
+}
+
+func (e *InvalidImpersonationRequestError) Error() string {
+	return e.Reason
+}
+
+// lets support staff act as a user to reproduce issues
+type ImpersonationService interface {
+	// opens session in default tenant as the user and returns access token for it.
+	// Token names administrator in act claim and can't be refreshed, issue and every use end up in audit trail
+	Impersonate(ctx context.Context, req *entities.ImpersonationRequest) (*entities.ImpersonationToken, error)
+}
+
+type impersonationService struct {
+	cfg       *config.Config
+	log       *slog.Logger
+	auditRepo repo.AuditRepository
+	userRepo  repo.UserRepository
+	roleRepo  repo.RoleRepository
+	groupRepo repo.GroupRepository
+}
+
+func NewImpersonationService(log *slog.Logger, cfg *config.Config, auditRepo repo.AuditRepository, userRepo repo.UserRepository, roleRepo repo.RoleRepository, groupRepo repo.GroupRepository) ImpersonationService {
+	return &impersonationService{
+		cfg:       cfg,
+		log:       log,
+		auditRepo: auditRepo,
+		userRepo:  userRepo,
+		roleRepo:  roleRepo,
+		groupRepo: groupRepo,
+	}
+}
+
+func (is *impersonationService) Impersonate(ctx context.Context, req *entities.ImpersonationRequest) (*entities.ImpersonationToken, error) {
+	const op = "service.Impersonate"
+	is.log.Info(op, slog.String("admin", req.AdminGuid.String()), slog.String("user", req.UserGuid.String()))
+
+	if is.cfg.Impersonation.Permission == "" {
+		return nil, ErrImpersonationForbidden
+	}
+	adminPermissions, err := is.roleRepo.GetUserPermissions(ctx, req.AdminGuid)
+	if err != nil {
+		is.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !slices.Contains(adminPermissions, is.cfg.Impersonation.Permission) {
+		return nil, ErrImpersonationForbidden
+	}
+
+	if req.AdminGuid == req.UserGuid {
+		return nil, &InvalidImpersonationRequestError{Reason: "administrator can't impersonate themselves"}
+	}
+	user, err := is.userRepo.GetUserById(ctx, req.UserGuid)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrNoUserFound
+	}
+	if err != nil {
+		is.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if user.Disabled {
+		return nil, ErrUserDisabled
+	}
+
+	// impersonation must not escalate: other impersonators and users with permissions the administrator
+	// lacks are out of reach
+	userPermissions, err := is.roleRepo.GetUserPermissions(ctx, req.UserGuid)
+	if err != nil {
+		is.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, permission := range userPermissions {
+		if permission == is.cfg.Impersonation.Permission || !slices.Contains(adminPermissions, permission) {
+			return nil, ErrImpersonationTargetForbidden
+		}
+	}
+
+	ttl := is.cfg.Impersonation.TokenTTL
+	expiresAt := time.Now().Add(ttl)
+	method := entities.AuthMethodImpersonation
+	// session gets no refresh token hash, so refresh tokens can't be bound to it
+	session := &entities.UserAuthInfo{
+		UserGuid:         &req.UserGuid,
+		IpAddress:        &req.IpAddr,
+		TenantID:         entities.DefaultTenantID,
+		AuthMethod:       &method,
+		ImpersonatorGuid: &req.AdminGuid,
+		ExpiresAt:        &expiresAt,
+	}
+	sessionID, err := is.auditRepo.CreateImpersonationSession(ctx, session, &entities.AuditEvent{
+		Event:       entities.AuditImpersonationIssued,
+		ActorGuid:   &req.AdminGuid,
+		SubjectGuid: &req.UserGuid,
+		IpAddress:   &req.IpAddr,
+		Details:     req.Reason,
+	})
+	if err != nil {
+		is.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	access, err := userAccessClaims(ctx, is.cfg, is.roleRepo, is.groupRepo, req.UserGuid, true)
+	if err != nil {
+		is.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	act := &jwtp.Actor{Subject: req.AdminGuid.String()}
+	token, err := jwtp.GenerateImpersonationToken(sessionID.String(), req.IpAddr, entities.DefaultTenantID, access, act, ttl)
+	if err != nil {
+		is.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &entities.ImpersonationToken{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(ttl.Seconds()),
+		SessionID:   sessionID,
+	}, nil
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	repo "testovoe_medods/repository"
+
+	"github.com/google/uuid"
+)
+
+type memRoleRepo struct {
+	repo.RoleRepository
+	permissions map[uuid.UUID][]string
+}
+
+func (r *memRoleRepo) GetUserPermissions(ctx context.Context, userGuid uuid.UUID) ([]string, error) {
+	return r.permissions[userGuid], nil
+}
+
+func (r *memRoleRepo) GetUserRoles(ctx context.Context, userGuid uuid.UUID) ([]string, error) {
+	return nil, nil
+}
+
+type memGroupRepo struct {
+	repo.GroupRepository
+}
+
+func (r *memGroupRepo) GetUserGroups(ctx context.Context, userGuid uuid.UUID) ([]entities.Group, error) {
+	return nil, nil
+}
+
+type memAuditRepo struct {
+	repo.AuditRepository
+	sessions []entities.UserAuthInfo
+}
+
+func (r *memAuditRepo) CreateImpersonationSession(ctx context.Context, session *entities.UserAuthInfo, event *entities.AuditEvent) (uuid.UUID, error) {
+	r.sessions = append(r.sessions, *session)
+	return uuid.New(), nil
+}
+
+type impersonationUserRepo struct {
+	memUserRepo
+}
+
+func (r *impersonationUserRepo) GetUserById(ctx context.Context, guid uuid.UUID) (*entities.User, error) {
+	for _, user := range r.users {
+		if user.ID == guid {
+			return &user, nil
+		}
+	}
+	return nil, repo.ErrEntityNotExists
+}
+
+func TestImpersonateRefusesPrivilegedTargets(t *testing.T) {
+	t.Setenv("SECRET", "test-secret")
+	support, user := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name        string
+		permissions []string
+		want        error
+	}{
+		{name: "plain user", permissions: []string{"reports:read"}},
+		{name: "impersonator", permissions: []string{"users:impersonate"}, want: ErrImpersonationTargetForbidden},
+		{name: "permission admin lacks", permissions: []string{"reports:read", "users:manage"}, want: ErrImpersonationTargetForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Impersonation: config.Impersonation{Permission: "users:impersonate", TokenTTL: time.Minute}}
+			roles := &memRoleRepo{permissions: map[uuid.UUID][]string{
+				support: {"reports:read", "users:impersonate"},
+				user:    tt.permissions,
+			}}
+			users := &impersonationUserRepo{}
+			users.users = []entities.User{{ID: support}, {ID: user}}
+			audit := &memAuditRepo{}
+			svc := NewImpersonationService(testLogger(), cfg, audit, users, roles, &memGroupRepo{})
+
+			_, err := svc.Impersonate(context.Background(), &entities.ImpersonationRequest{AdminGuid: support, UserGuid: user, IpAddr: "127.0.0.1"})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if tt.want != nil && len(audit.sessions) != 0 {
+				t.Fatal("impersonation session was opened")
+			}
+		})
+	}
+}
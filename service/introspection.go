@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	jwtp "testovoe_medods/lib/jwt"
+	repo "testovoe_medods/repository"
+)
+
+// RFC 7662 endpoint letting resource servers check access tokens and api keys
+type IntrospectionService interface {
+	Introspect(ctx context.Context, auth *entities.ClientAuthentication, token string) (*entities.IntrospectionResponse, error)
+	// same check without client authentication, for endpoints of this server accepting bearer tokens
+	Inspect(ctx context.Context, token string) (*entities.IntrospectionResponse, error)
+}
+
+type introspectionService struct {
+	cfg           *config.Config
+	log           *slog.Logger
+	authRepo      repo.AuthRepository
+	clientService OAuthClientService
+	apiKeyService APIKeyService
+	auditRepo     repo.AuditRepository
+}
+
+func NewIntrospectionService(log *slog.Logger, cfg *config.Config, authRepo repo.AuthRepository, clientService OAuthClientService, apiKeyService APIKeyService, auditRepo repo.AuditRepository) IntrospectionService {
+	return &introspectionService{
+		cfg:           cfg,
+		log:           log,
+		authRepo:      authRepo,
+		clientService: clientService,
+		apiKeyService: apiKeyService,
+		auditRepo:     auditRepo,
+	}
+}
+
+func (is *introspectionService) Introspect(ctx context.Context, auth *entities.ClientAuthentication, token string) (*entities.IntrospectionResponse, error) {
+	client, err := is.clientService.AuthenticateClient(ctx, auth)
+	if err != nil {
+		return nil, err
+	}
+	// only resource servers holding credentials may learn about tokens
+	if client.TokenEndpointAuthMethod == entities.ClientAuthNone {
+		return nil, oauthError("invalid_client", "public clients can't introspect tokens")
+	}
+	return is.inspect(ctx, token, "introspection:"+client.ID, client.TenantID)
+}
+
+func (is *introspectionService) Inspect(ctx context.Context, token string) (*entities.IntrospectionResponse, error) {
+	return is.inspect(ctx, token, "api", "")
+}
+
+// via tells audit trail who looked at impersonation tokens. Tokens of tenants other than tenant,
+// when it's set, are reported inactive so resource servers can't learn about foreign tenants
+func (is *introspectionService) inspect(ctx context.Context, token, via, tenant string) (*entities.IntrospectionResponse, error) {
+	const op = "service.Introspect"
+
+	inactive := &entities.IntrospectionResponse{Active: false}
+	if token == "" {
+		return inactive, nil
+	}
+
+	if strings.HasPrefix(token, APIKeyPrefix) {
+		key, err := is.apiKeyService.Verify(ctx, token)
+		if errors.Is(err, ErrInvalidAPIKey) {
+			return inactive, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return apiKeyIntrospection(key), nil
+	}
+
+	claims, err := jwtp.GetAndValidateTokenClaims(token, false)
+	if err != nil {
+		return inactive, nil
+	}
+	if tenant != "" && claimsTenant(claims) != tenant {
+		return inactive, nil
+	}
+
+	resp := &entities.IntrospectionResponse{
+		Active:        true,
+		Scope:         claims.Scope,
+		ClientID:      claims.ClientID,
+		Subject:       claims.Subject,
+		TokenType:     "Bearer",
+		Issuer:        issuerOf(is.cfg, claimsTenant(claims)),
+		Audience:      claims.Audience,
+		ExpiresAt:     claims.ExpiresAt.Unix(),
+		Roles:         claims.Roles,
+		Groups:        claims.Groups,
+		Tenant:        claims.Tenant,
+		GroupsOverage: claims.GroupsOverage,
+	}
+	if claims.IssuedAt != nil {
+		resp.IssuedAt = claims.IssuedAt.Unix()
+	}
+	if claims.Act != nil {
+		resp.Act = claims.Act
+	}
+	if claims.ServiceToken {
+		return resp, nil
+	}
+
+	// user tokens are bound to sessions which may be revoked before tokens expire
+	session, err := is.authRepo.GetAuthInfoById(ctx, claimsTenant(claims), claims.Subject)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return inactive, nil
+	}
+	if err != nil {
+		is.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := recordImpersonationUse(ctx, is.auditRepo, session, via); err != nil {
+		is.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	resp.Subject = session.UserGuid.String()
+	if resp.ClientID == "" && session.ClientID != nil {
+		resp.ClientID = *session.ClientID
+	}
+	if resp.Scope == "" && session.Scope != nil {
+		resp.Scope = *session.Scope
+	}
+	return resp, nil
+}
+
+func apiKeyIntrospection(key *entities.APIKey) *entities.IntrospectionResponse {
+	resp := &entities.IntrospectionResponse{
+		Active:    true,
+		Scope:     strings.Join(key.Scopes, " "),
+		TokenType: "api_key",
+		IssuedAt:  key.CreatedAt.Unix(),
+	}
+	if key.UserGuid != nil {
+		resp.Subject = key.UserGuid.String()
+	}
+	if key.ClientID != nil {
+		resp.Subject = *key.ClientID
+		resp.ClientID = *key.ClientID
+	}
+	if key.ExpiresAt != nil {
+		resp.ExpiresAt = key.ExpiresAt.Unix()
+	}
+	return resp
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	netmail "net/mail"
+	"net/url"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	crypt "testovoe_medods/lib/bcrypt"
+	"testovoe_medods/lib/mail"
+	repo "testovoe_medods/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvitationNotFound = errors.New("invitation doesn't exist")
+var ErrInvalidInvitation = errors.New("invitation is invalid, expired or already used")
+var ErrNotTenantAdmin = errors.New("only tenant admins may manage invitations")
+
+type InvalidInvitationRequestError struct {
+	Reason string
+}
+
+func (e *InvalidInvitationRequestError) Error() string {
+	return e.Reason
+}
+
+type InvitationService interface {
+	// emails single-use invitation link, actor has to be admin of the tenant
+	CreateInvitation(ctx context.Context, actor uuid.UUID, tenantID string, req *entities.InvitationRequest) (*entities.TenantInvitation, error)
+	ListInvitations(ctx context.Context, actor uuid.UUID, tenantID string) ([]entities.TenantInvitation, error)
+	RevokeInvitation(ctx context.Context, actor uuid.UUID, tenantID string, id uuid.UUID) error
+	// adds owner of invited email to tenant, account is created when email isn't registered yet
+	AcceptInvitation(ctx context.Context, token string) (*entities.TenantMember, error)
+}
+
+type invitationService struct {
+	cfg        *config.Config
+	log        *slog.Logger
+	repo       repo.InvitationRepository
+	tenantRepo repo.TenantRepository
+	mailer     mail.Sender
+}
+
+func NewInvitationService(log *slog.Logger, cfg *config.Config, repo repo.InvitationRepository, tenantRepo repo.TenantRepository, mailer mail.Sender) InvitationService {
+	return &invitationService{
+		cfg:        cfg,
+		log:        log,
+		repo:       repo,
+		tenantRepo: tenantRepo,
+		mailer:     mailer,
+	}
+}
+
+func (is *invitationService) CreateInvitation(ctx context.Context, actor uuid.UUID, tenantID string, req *entities.InvitationRequest) (*entities.TenantInvitation, error) {
+	const op = "service.CreateInvitation"
+	is.log.Info(op, slog.String("msg", "Creating invitation"), slog.String("tenant", tenantID), slog.String("actor", actor.String()))
+
+	tenant, err := is.requireTenantAdmin(ctx, actor, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := netmail.ParseAddress(req.Email)
+	if err != nil || address.Name != "" {
+		return nil, &InvalidInvitationRequestError{Reason: "invalid email"}
+	}
+	role := req.Role
+	if role == "" {
+		role = entities.TenantRoleMember
+	}
+	if role != entities.TenantRoleMember && role != entities.TenantRoleAdmin {
+		return nil, &InvalidInvitationRequestError{Reason: "unsupported member role " + role}
+	}
+
+	token, err := crypt.GenerateOpaqueToken(32)
+	if err != nil {
+		is.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	invitation, err := is.repo.CreateInvitation(ctx, &entities.TenantInvitation{
+		TenantID:  tenantID,
+		Email:     address.Address,
+		Role:      role,
+		TokenHash: crypt.HashOpaqueToken(token),
+		InvitedBy: &actor,
+		ExpiresAt: time.Now().Add(is.cfg.Invitations.TTL),
+	})
+	if err != nil {
+		is.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	link := is.cfg.Invitations.AcceptURL + "?token=" + url.QueryEscape(token)
+	subject := "You are invited to " + tenant.Name
+	body := fmt.Sprintf("You were invited to join %s. Follow the link below to accept the invitation. It expires in %s and works once.\n\n%s\n\nIf you don't expect this invitation, ignore this email.", tenant.Name, is.cfg.Invitations.TTL, link)
+
+	go func(to string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := is.mailer.Send(ctx, to, subject, body); err != nil {
+			is.log.Error(op, slog.String("error", err.Error()))
+		}
+	}(invitation.Email)
+
+	return invitation, nil
+}
+
+func (is *invitationService) ListInvitations(ctx context.Context, actor uuid.UUID, tenantID string) ([]entities.TenantInvitation, error) {
+	const op = "service.ListInvitations"
+
+	if _, err := is.requireTenantAdmin(ctx, actor, tenantID); err != nil {
+		return nil, err
+	}
+
+	invitations, err := is.repo.ListPendingInvitations(ctx, tenantID)
+	if err != nil {
+		is.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return invitations, nil
+}
+
+func (is *invitationService) RevokeInvitation(ctx context.Context, actor uuid.UUID, tenantID string, id uuid.UUID) error {
+	const op = "service.RevokeInvitation"
+	is.log.Info(op, slog.String("msg", "Revoking invitation"), slog.String("tenant", tenantID), slog.String("id", id.String()))
+
+	if _, err := is.requireTenantAdmin(ctx, actor, tenantID); err != nil {
+		return err
+	}
+
+	err := is.repo.DeletePendingInvitation(ctx, tenantID, id)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrInvitationNotFound
+	}
+	if err != nil {
+		is.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (is *invitationService) AcceptInvitation(ctx context.Context, token string) (*entities.TenantMember, error) {
+	const op = "service.AcceptInvitation"
+	is.log.Info(op, slog.String("msg", "Accepting invitation"))
+
+	if token == "" {
+		return nil, ErrInvalidInvitation
+	}
+
+	invitation, err := is.repo.AcceptInvitation(ctx, crypt.HashOpaqueToken(token))
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrInvalidInvitation
+	}
+	if err != nil {
+		is.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	member, err := is.tenantRepo.GetMember(ctx, invitation.TenantID, *invitation.AcceptedBy)
+	if err != nil {
+		is.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return member, nil
+}
+
+// every user belongs to the default tenant, so it has no invitations
+func (is *invitationService) requireTenantAdmin(ctx context.Context, actor uuid.UUID, tenantID string) (*entities.Tenant, error) {
+	const op = "service.requireTenantAdmin"
+
+	if tenantID == entities.DefaultTenantID {
+		return nil, &InvalidInvitationRequestError{Reason: "users can't be invited to the default tenant"}
+	}
+
+	tenant, err := is.tenantRepo.GetTenant(ctx, tenantID)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrTenantNotFound
+	}
+	if err != nil {
+		is.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	member, err := is.tenantRepo.GetMember(ctx, tenantID, actor)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrNotTenantAdmin
+	}
+	if err != nil {
+		is.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if member.Role != entities.TenantRoleAdmin {
+		return nil, ErrNotTenantAdmin
+	}
+	return tenant, nil
+}
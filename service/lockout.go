@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	repo "testovoe_medods/repository"
+	"time"
+)
+
+// returned when attempt is rejected by brute-force protection
+type LockedError struct {
+	// Locked is true for lockouts, false for progressive delays between attempts
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	if e.Locked {
+		return "too many failed attempts, temporarily locked"
+	}
+	return "too many failed attempts, retry later"
+}
+
+// lockouts take precedence over delays, otherwise the longer wait wins
+func (e *LockedError) outweighs(other *LockedError) bool {
+	if other == nil || e.Locked != other.Locked {
+		return other == nil || e.Locked
+	}
+	return e.RetryAfter > other.RetryAfter
+}
+
+func UserLockoutKey(userGuid string) string {
+	return "user:" + userGuid
+}
+
+func IPLockoutKey(ip string) string {
+	return "ip:" + ip
+}
+
+// counts emails requested for address or from ip key. Every request is registered as failure,
+// so sending is throttled by the same backoff and lockout as guessing
+func MailLockoutKey(target string) string {
+	return "mail:" + strings.ToLower(target)
+}
+
+type LockoutService interface {
+	// returns *LockedError if any of keys is locked or still in backoff
+	Check(ctx context.Context, keys ...string) error
+	RegisterFailure(ctx context.Context, keys ...string)
+	RegisterSuccess(ctx context.Context, key string)
+	Status(ctx context.Context, key string) (*entities.LockoutStatus, error)
+	Unlock(ctx context.Context, key string) error
+}
+
+type lockoutService struct {
+	cfg  *config.Config
+	log  *slog.Logger
+	repo repo.LockoutRepository
+}
+
+func NewLockoutService(log *slog.Logger, cfg *config.Config, repo repo.LockoutRepository) LockoutService {
+	return &lockoutService{
+		cfg:  cfg,
+		log:  log,
+		repo: repo,
+	}
+}
+
+func (ls *lockoutService) Check(ctx context.Context, keys ...string) error {
+	const op = "service.LockoutCheck"
+
+	now := time.Now()
+	var worst *LockedError
+	for _, key := range keys {
+		attempts, err := ls.repo.GetAttempts(ctx, key)
+		if errors.Is(err, repo.ErrEntityNotExists) {
+			continue
+		}
+		if err != nil {
+			ls.log.Error(op, slog.String("error", err.Error()))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if lockErr := ls.evaluate(attempts, now); lockErr != nil && lockErr.outweighs(worst) {
+			worst = lockErr
+		}
+	}
+
+	if worst != nil {
+		return worst
+	}
+	return nil
+}
+
+// failures are counted on best effort basis, storage errors must not break the flow that failed
+func (ls *lockoutService) RegisterFailure(ctx context.Context, keys ...string) {
+	const op = "service.RegisterFailure"
+
+	windowStart := time.Now().Add(-ls.cfg.Lockout.Window)
+	lockUntil := time.Now().Add(ls.cfg.Lockout.LockoutDuration)
+	for _, key := range keys {
+		threshold := ls.cfg.Lockout.UserThreshold
+		if strings.HasPrefix(key, "ip:") {
+			threshold = ls.cfg.Lockout.IPThreshold
+		}
+
+		// lock is set by the same statement, parallel failures can't slip past the threshold
+		attempts, err := ls.repo.RegisterFailure(ctx, key, windowStart, threshold, lockUntil)
+		if err != nil {
+			ls.log.Error(op, slog.String("error", err.Error()))
+			continue
+		}
+
+		if attempts.Failures == threshold {
+			ls.log.Warn(op, slog.String("msg", "locked after failed attempts"), slog.String("key", key), slog.Int("failures", attempts.Failures))
+		}
+	}
+}
+
+func (ls *lockoutService) RegisterSuccess(ctx context.Context, key string) {
+	const op = "service.RegisterSuccess"
+
+	if err := ls.repo.Reset(ctx, key); err != nil {
+		ls.log.Error(op, slog.String("error", err.Error()))
+	}
+}
+
+func (ls *lockoutService) Status(ctx context.Context, key string) (*entities.LockoutStatus, error) {
+	const op = "service.LockoutStatus"
+
+	status := entities.LockoutStatus{Key: key}
+	attempts, err := ls.repo.GetAttempts(ctx, key)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return &status, nil
+	}
+	if err != nil {
+		ls.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	status.Failures = attempts.Failures
+	status.LastFailureAt = &attempts.LastFailureAt
+	status.LockedUntil = attempts.LockedUntil
+	if lockErr := ls.evaluate(attempts, time.Now()); lockErr != nil {
+		status.Locked = lockErr.Locked
+		status.RetryAfterSeconds = retryAfterSeconds(lockErr.RetryAfter)
+	}
+	return &status, nil
+}
+
+func (ls *lockoutService) Unlock(ctx context.Context, key string) error {
+	const op = "service.Unlock"
+
+	if err := ls.repo.Reset(ctx, key); err != nil {
+		ls.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (ls *lockoutService) evaluate(attempts *entities.AuthAttempts, now time.Time) *LockedError {
+	if attempts.LockedUntil != nil && attempts.LockedUntil.After(now) {
+		return &LockedError{Locked: true, RetryAfter: attempts.LockedUntil.Sub(now)}
+	}
+
+	// stale counters don't slow anyone down
+	if attempts.LastFailureAt.Before(now.Add(-ls.cfg.Lockout.Window)) {
+		return nil
+	}
+
+	if next := attempts.LastFailureAt.Add(ls.backoff(attempts.Failures)); next.After(now) {
+		return &LockedError{RetryAfter: next.Sub(now)}
+	}
+	return nil
+}
+
+// exponential delay: base * 2^(failures - free attempts), capped by max delay
+func (ls *lockoutService) backoff(failures int) time.Duration {
+	over := failures - ls.cfg.Lockout.FreeAttempts
+	if over < 0 {
+		return 0
+	}
+	delay := float64(ls.cfg.Lockout.BaseDelay) * math.Pow(2, float64(over))
+	if delay > float64(ls.cfg.Lockout.MaxDelay) {
+		return ls.cfg.Lockout.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+func retryAfterSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
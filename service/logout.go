@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	crypt "testovoe_medods/lib/bcrypt"
+	jwtp "testovoe_medods/lib/jwt"
+	"testovoe_medods/lib/oidc"
+	repo "testovoe_medods/repository"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	logoutBatchSize = 50
+	// claimed notifications aren't picked again until delivery had time to finish
+	logoutLease      = time.Minute
+	logoutMaxBackoff = time.Hour
+)
+
+type LogoutService interface {
+	// revokes session and client sessions started from it, relying parties are notified in background
+	Logout(ctx context.Context, session *entities.UserAuthInfo) error
+	// delivers queued logout tokens until ctx is done
+	Run(ctx context.Context)
+}
+
+type logoutService struct {
+	cfg           *config.Config
+	log           *slog.Logger
+	repo          repo.LogoutRepository
+	clientService OAuthClientService
+	httpClient    *http.Client
+	wake          chan struct{}
+}
+
+func NewLogoutService(log *slog.Logger, cfg *config.Config, repo repo.LogoutRepository, clientService OAuthClientService) LogoutService {
+	return &logoutService{
+		cfg:           cfg,
+		log:           log,
+		repo:          repo,
+		clientService: clientService,
+		httpClient:    &http.Client{Timeout: cfg.BackchannelLogout.RequestTimeout},
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+func (ls *logoutService) Logout(ctx context.Context, session *entities.UserAuthInfo) error {
+	const op = "service.Logout"
+	ls.log.Info(op, slog.String("msg", "Revoking session"))
+
+	err := ls.repo.RevokeSession(ctx, *session.RefreshId)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrInvalidAccessToken
+	}
+	if err != nil {
+		ls.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	select {
+	case ls.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (ls *logoutService) Run(ctx context.Context) {
+	ticker := time.NewTicker(ls.cfg.BackchannelLogout.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		ls.deliverDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-ls.wake:
+		}
+	}
+}
+
+func (ls *logoutService) deliverDue(ctx context.Context) {
+	const op = "service.deliverLogouts"
+
+	logouts, err := ls.repo.ClaimDueLogouts(ctx, logoutBatchSize, logoutLease)
+	if err != nil {
+		ls.log.Error(op, slog.String("error", err.Error()))
+		return
+	}
+
+	for i := range logouts {
+		logout := &logouts[i]
+		err := ls.deliver(ctx, logout)
+		if err == nil {
+			if err := ls.repo.DeleteLogout(ctx, logout.ID); err != nil {
+				ls.log.Error(op, slog.String("error", err.Error()))
+			}
+			continue
+		}
+
+		if logout.Attempts >= ls.cfg.BackchannelLogout.MaxAttempts {
+			ls.log.Error(op, slog.String("error", err.Error()), slog.String("client_id", logout.ClientID),
+				slog.String("msg", "giving up logout notification"))
+			if err := ls.repo.DeleteLogout(ctx, logout.ID); err != nil {
+				ls.log.Error(op, slog.String("error", err.Error()))
+			}
+			continue
+		}
+
+		ls.log.Info(op, slog.String("msg", "logout notification failed"), slog.String("client_id", logout.ClientID),
+			slog.Int("attempt", logout.Attempts), slog.String("error", err.Error()))
+		next := time.Now().Add(logoutBackoff(ls.cfg.BackchannelLogout.PollInterval, logout.Attempts))
+		if err := ls.repo.RescheduleLogout(ctx, logout.ID, next, err.Error()); err != nil {
+			ls.log.Error(op, slog.String("error", err.Error()))
+		}
+	}
+}
+
+// sends logout token to relying party, notifications for removed clients or logout uris are dropped
+func (ls *logoutService) deliver(ctx context.Context, logout *entities.BackchannelLogout) error {
+	client, err := ls.clientService.GetClient(ctx, logout.ClientID)
+	if errors.Is(err, ErrClientNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if client.BackchannelLogoutURI == nil {
+		return nil
+	}
+
+	token, err := ls.logoutToken(client, logout)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ls.cfg.BackchannelLogout.RequestTimeout)
+	defer cancel()
+
+	body := url.Values{"logout_token": {token}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, *client.BackchannelLogoutURI, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := ls.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("relying party responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (ls *logoutService) logoutToken(client *entities.OAuthClient, logout *entities.BackchannelLogout) (string, error) {
+	jti, err := crypt.GenerateOpaqueToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := oidc.LogoutTokenClaims{
+		Events: map[string]struct{}{oidc.BackchannelLogoutEvent: {}},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuerOf(ls.cfg, client.TenantID),
+			Subject:   logout.UserGuid.String(),
+			Audience:  jwt.ClaimStrings{logout.ClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ls.cfg.BackchannelLogout.TokenTTL)),
+			ID:        jti,
+		},
+	}
+	if logout.SID != nil {
+		claims.SessionID = logout.SID.String()
+	}
+	return jwtp.GenerateSignedToken(client.TenantID, claims)
+}
+
+// exponential backoff starting from poll interval
+func logoutBackoff(base time.Duration, attempts int) time.Duration {
+	backoff := base
+	for i := 1; i < attempts && backoff < logoutMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, logoutMaxBackoff)
+}
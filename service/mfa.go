@@ -0,0 +1,333 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	crypt "testovoe_medods/lib/bcrypt"
+	"testovoe_medods/lib/totp"
+	repo "testovoe_medods/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const MFAMethodTOTP = "totp"
+const MFAMethodRecoveryCode = "recovery_code"
+
+// size of recovery codes set issued at once
+const recoveryCodesCount = 10
+
+var ErrMFAAlreadyEnabled = errors.New("mfa is already enabled")
+var ErrMFANotEnrolled = errors.New("mfa enrollment wasn't started")
+var ErrInvalidMFACode = errors.New("invalid mfa code")
+var ErrMFANotEnabled = errors.New("mfa isn't enabled")
+
+// returned by ReleaseTokens when user has to pass second factor before tokens are released
+type MFARequiredError struct {
+	Challenge entities.MFAChallenge
+}
+
+func (e *MFARequiredError) Error() string {
+	return "second authentication factor required"
+}
+
+type MFAService interface {
+	EnrollTOTP(ctx context.Context, userGuid uuid.UUID) (*entities.TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userGuid uuid.UUID, code string) error
+	DisableTOTP(ctx context.Context, userGuid uuid.UUID, code string) error
+	// returns methods the user has to pass as a second factor, empty if mfa is off
+	EnabledMethods(ctx context.Context, userGuid uuid.UUID) ([]string, error)
+	VerifyCode(ctx context.Context, userGuid uuid.UUID, code string) error
+	// issues new set of recovery codes, previous set stops working
+	RegenerateRecoveryCodes(ctx context.Context, userGuid uuid.UUID) (*entities.RecoveryCodes, error)
+	VerifyRecoveryCode(ctx context.Context, userGuid uuid.UUID, code string) error
+	RemainingRecoveryCodes(ctx context.Context, userGuid uuid.UUID) (int, error)
+	// marks mfa challenge as completed, second use of the same challenge fails with ErrInvalidMFAChallenge
+	ConsumeChallenge(ctx context.Context, jti string, expiresAt time.Time) error
+}
+
+type mfaService struct {
+	cfg          *config.Config
+	log          *slog.Logger
+	repo         repo.MFARepository
+	authRepo     repo.AuthRepository
+	webAuthnRepo repo.WebAuthnRepository
+}
+
+func NewMFAService(log *slog.Logger, cfg *config.Config, repo repo.MFARepository, authRepo repo.AuthRepository, webAuthnRepo repo.WebAuthnRepository) MFAService {
+	return &mfaService{
+		cfg:          cfg,
+		log:          log,
+		repo:         repo,
+		authRepo:     authRepo,
+		webAuthnRepo: webAuthnRepo,
+	}
+}
+
+func (ms *mfaService) EnrollTOTP(ctx context.Context, userGuid uuid.UUID) (*entities.TOTPEnrollment, error) {
+	const op = "service.EnrollTOTP"
+	ms.log.Info(op, slog.String("msg", "Enrolling totp"))
+
+	user, err := ms.authRepo.GetAuthInfoByUserGuid(ctx, entities.DefaultTenantID, userGuid.String())
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrNoUserFound
+	}
+	if err != nil {
+		ms.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		ms.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	secretEnc, err := crypt.Seal(secret)
+	if err != nil {
+		ms.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = ms.repo.UpsertPendingTOTP(ctx, userGuid, secretEnc)
+	if errors.Is(err, repo.ErrEntityAlreadyExists) {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err != nil {
+		ms.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	uri := totp.URI(ms.cfg.MFA.Issuer, user.Email, secret)
+	png, err := totp.QRCodePNG(uri, 256)
+	if err != nil {
+		ms.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &entities.TOTPEnrollment{
+		Secret:     secret,
+		OtpauthURI: uri,
+		QRCodePNG:  png,
+	}, nil
+}
+
+func (ms *mfaService) ConfirmTOTP(ctx context.Context, userGuid uuid.UUID, code string) error {
+	const op = "service.ConfirmTOTP"
+	ms.log.Info(op, slog.String("msg", "Confirming totp"))
+
+	userTotp, err := ms.repo.GetTOTP(ctx, userGuid)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrMFANotEnrolled
+	}
+	if err != nil {
+		ms.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if userTotp.ConfirmedAt != nil {
+		return ErrMFAAlreadyEnabled
+	}
+
+	step, err := ms.matchCode(userTotp, code)
+	if err != nil {
+		return err
+	}
+
+	err = ms.repo.ConfirmTOTP(ctx, userGuid, step)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrMFAAlreadyEnabled
+	}
+	if err != nil {
+		ms.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (ms *mfaService) DisableTOTP(ctx context.Context, userGuid uuid.UUID, code string) error {
+	const op = "service.DisableTOTP"
+	ms.log.Info(op, slog.String("msg", "Disabling totp"))
+
+	if err := ms.VerifyCode(ctx, userGuid, code); err != nil {
+		return err
+	}
+
+	if err := ms.repo.DeleteTOTP(ctx, userGuid); err != nil {
+		ms.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (ms *mfaService) EnabledMethods(ctx context.Context, userGuid uuid.UUID) ([]string, error) {
+	const op = "service.EnabledMethods"
+
+	methods := []string{}
+	userTotp, err := ms.repo.GetTOTP(ctx, userGuid)
+	if err != nil && !errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err == nil && userTotp.ConfirmedAt != nil {
+		methods = append(methods, MFAMethodTOTP)
+	}
+
+	creds, err := ms.webAuthnRepo.GetCredentialsByUserGuid(ctx, userGuid)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(creds) > 0 {
+		methods = append(methods, MFAMethodWebAuthn)
+	}
+
+	// recovery codes are only a fallback and never enable mfa on their own
+	if len(methods) > 0 {
+		remaining, err := ms.repo.CountRecoveryCodes(ctx, userGuid)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if remaining > 0 {
+			methods = append(methods, MFAMethodRecoveryCode)
+		}
+	}
+	return methods, nil
+}
+
+// checks code against confirmed totp secret. Every code is accepted only once
+func (ms *mfaService) VerifyCode(ctx context.Context, userGuid uuid.UUID, code string) error {
+	const op = "service.VerifyCode"
+
+	userTotp, err := ms.repo.GetTOTP(ctx, userGuid)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrInvalidMFACode
+	}
+	if err != nil {
+		ms.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if userTotp.ConfirmedAt == nil {
+		return ErrInvalidMFACode
+	}
+
+	step, err := ms.matchCode(userTotp, code)
+	if err != nil {
+		return err
+	}
+
+	fresh, err := ms.repo.ConsumeTOTPStep(ctx, userGuid, step)
+	if err != nil {
+		ms.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !fresh {
+		ms.log.Info(op, slog.String("msg", "totp code reuse rejected"))
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (ms *mfaService) RegenerateRecoveryCodes(ctx context.Context, userGuid uuid.UUID) (*entities.RecoveryCodes, error) {
+	const op = "service.RegenerateRecoveryCodes"
+	ms.log.Info(op, slog.String("msg", "Regenerating recovery codes"))
+
+	methods, err := ms.EnabledMethods(ctx, userGuid)
+	if err != nil {
+		ms.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(methods) == 0 {
+		return nil, ErrMFANotEnabled
+	}
+
+	codes := make([]string, 0, recoveryCodesCount)
+	hashes := make([]string, 0, recoveryCodesCount)
+	for i := 0; i < recoveryCodesCount; i++ {
+		code, err := crypt.GenerateHumanCode(7)
+		if err != nil {
+			ms.log.Error(op, slog.String("error", err.Error()))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, crypt.HMACDigest(normalizeRecoveryCode(code)))
+	}
+
+	if err := ms.repo.ReplaceRecoveryCodes(ctx, userGuid, hashes); err != nil {
+		ms.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &entities.RecoveryCodes{Codes: codes}, nil
+}
+
+func (ms *mfaService) VerifyRecoveryCode(ctx context.Context, userGuid uuid.UUID, code string) error {
+	const op = "service.VerifyRecoveryCode"
+
+	normalized := normalizeRecoveryCode(code)
+	if normalized == "" {
+		return ErrInvalidMFACode
+	}
+
+	ok, err := ms.repo.ConsumeRecoveryCode(ctx, userGuid, crypt.HMACDigest(normalized))
+	if err != nil {
+		ms.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	ms.log.Info(op, slog.String("msg", "recovery code used"), slog.String("user_guid", userGuid.String()))
+	return nil
+}
+
+func (ms *mfaService) RemainingRecoveryCodes(ctx context.Context, userGuid uuid.UUID) (int, error) {
+	const op = "service.RemainingRecoveryCodes"
+
+	count, err := ms.repo.CountRecoveryCodes(ctx, userGuid)
+	if err != nil {
+		ms.log.Error(op, slog.String("error", err.Error()))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return count, nil
+}
+
+func (ms *mfaService) ConsumeChallenge(ctx context.Context, jti string, expiresAt time.Time) error {
+	const op = "service.ConsumeChallenge"
+
+	err := ms.repo.UseChallenge(ctx, jti, expiresAt)
+	if errors.Is(err, repo.ErrEntityAlreadyExists) {
+		return ErrInvalidMFAChallenge
+	}
+	if err != nil {
+		ms.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// codes are accepted regardless of case, spaces and dashes
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func (ms *mfaService) matchCode(userTotp *entities.UserTOTP, code string) (int64, error) {
+	const op = "service.matchCode"
+
+	secret, err := crypt.Open(userTotp.SecretEnc)
+	if err != nil {
+		ms.log.Error(op, slog.String("error", err.Error()))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return 0, ErrInvalidMFACode
+	}
+	return step, nil
+}
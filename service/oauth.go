@@ -0,0 +1,773 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	crypt "testovoe_medods/lib/bcrypt"
+	jwtp "testovoe_medods/lib/jwt"
+	"testovoe_medods/lib/oidc"
+	repo "testovoe_medods/repository"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// client or redirect uri can't be trusted, so error must not be redirected back
+var ErrInvalidOAuthClient = errors.New("unknown client or redirect uri")
+
+// user has to log in before authorization request can be answered
+var ErrLoginRequired = errors.New("login required")
+
+// request_uri is unknown, expired, already used or was pushed by another client
+var ErrInvalidRequestURI = errors.New("request_uri is invalid or expired")
+
+// prefix of references returned by pushed authorization request endpoint (RFC 9126 2.2)
+const requestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+
+// error returned to oauth clients in RFC 6749 format
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+func oauthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// RFC 7523 grant type used by workloads presenting self-signed assertions
+const GrantTypeJWTBearer = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+
+// standard scopes understood by the provider itself, clients may additionally register api scopes
+var supportedScopes = []string{"openid", "email", "groups", "roles"}
+
+type OAuthService interface {
+	// returns uri the browser has to be redirected to, it carries either code or error for the client
+	Authorize(ctx context.Context, req *entities.AuthorizeRequest) (string, error)
+	// stores authorization request of authenticated client and returns request_uri referencing it
+	PushAuthorizationRequest(ctx context.Context, auth *entities.ClientAuthentication, req *entities.AuthorizeRequest) (*entities.PushedAuthorizationResponse, error)
+	Token(ctx context.Context, req *entities.TokenRequest) (*entities.TokenResponse, error)
+	UserInfo(ctx context.Context, accessToken string) (*entities.UserInfo, error)
+	// authenticates browser session used for single sign-on, only first-party sessions qualify
+	AuthenticateSSOSession(ctx context.Context, accessToken string) (*entities.UserAuthInfo, error)
+	Discovery() *oidc.Discovery
+	JWKS() (*oidc.JWKS, error)
+	// metadata and keys of tenant with own issuer, ErrTenantNotFound when tenant has no signing keys
+	TenantDiscovery(tenantID string) (*oidc.Discovery, error)
+	TenantJWKS(tenantID string) (*oidc.JWKS, error)
+}
+
+type oauthService struct {
+	cfg            *config.Config
+	log            *slog.Logger
+	repo           repo.OAuthRepository
+	authRepo       repo.AuthRepository
+	userRepo       repo.UserRepository
+	groupRepo      repo.GroupRepository
+	authService    AuthService
+	clientService  OAuthClientService
+	deviceService  DeviceService
+	consentService ConsentService
+}
+
+func NewOAuthService(log *slog.Logger, cfg *config.Config, repo repo.OAuthRepository, authRepo repo.AuthRepository, userRepo repo.UserRepository, groupRepo repo.GroupRepository, authService AuthService, clientService OAuthClientService, deviceService DeviceService, consentService ConsentService) OAuthService {
+	return &oauthService{
+		cfg:            cfg,
+		log:            log,
+		repo:           repo,
+		authRepo:       authRepo,
+		userRepo:       userRepo,
+		groupRepo:      groupRepo,
+		authService:    authService,
+		clientService:  clientService,
+		deviceService:  deviceService,
+		consentService: consentService,
+	}
+}
+
+func (oas *oauthService) Authorize(ctx context.Context, req *entities.AuthorizeRequest) (string, error) {
+	const op = "service.Authorize"
+	oas.log.Info(op, slog.String("msg", "Authorization request"), slog.String("client_id", req.ClientID))
+
+	client, err := oas.clientService.GetClient(ctx, req.ClientID)
+	if errors.Is(err, ErrClientNotFound) {
+		return "", ErrInvalidOAuthClient
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var requestURIHash string
+	if req.RequestURI != "" {
+		requestURIHash = crypt.HashOpaqueToken(strings.TrimPrefix(req.RequestURI, requestURIPrefix))
+		req, err = oas.pushedRequest(ctx, client, requestURIHash, req)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	redirectURI, ok := authorizeRedirectURI(client, req)
+	if !ok {
+		return "", ErrInvalidOAuthClient
+	}
+
+	params := url.Values{}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	params.Set("iss", issuerOf(oas.cfg, client.TenantID))
+
+	fail := func(code, description string) (string, error) {
+		params.Set("error", code)
+		params.Set("error_description", description)
+		return appendQuery(redirectURI, params), nil
+	}
+
+	if requestURIHash == "" && slices.Contains(oas.cfg.OIDC.RequirePARClients, client.ID) {
+		return fail("invalid_request", "client has to use pushed authorization requests")
+	}
+
+	scopes, oauthErr := checkAuthorizeRequest(client, req)
+	if oauthErr != nil {
+		return fail(oauthErr.Code, oauthErr.Description)
+	}
+
+	session, err := oas.AuthenticateSSOSession(ctx, req.SessionToken)
+	if errors.Is(err, ErrInvalidAccessToken) {
+		if req.Prompt == "none" {
+			return fail("login_required", "user isn't logged in")
+		}
+		return "", ErrLoginRequired
+	}
+	if err != nil {
+		return "", err
+	}
+
+	consented, err := oas.consentService.HasConsent(ctx, *session.UserGuid, client, scopes)
+	if err != nil {
+		return "", err
+	}
+	if !consented {
+		if req.Prompt == "none" {
+			return fail("consent_required", "user hasn't granted requested scopes")
+		}
+		return "", &ConsentRequiredError{ClientID: client.ID, Scope: strings.Join(scopes, " ")}
+	}
+
+	// pushed request is single use once the browser has a session to answer it
+	if requestURIHash != "" {
+		err := oas.repo.DeletePushedRequest(ctx, requestURIHash)
+		if errors.Is(err, repo.ErrEntityNotExists) {
+			return "", ErrInvalidRequestURI
+		}
+		if err != nil {
+			oas.log.Error(op, slog.String("error", err.Error()))
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	code, err := crypt.GenerateOpaqueToken(32)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	var nonce *string
+	if req.Nonce != "" {
+		nonce = &req.Nonce
+	}
+
+	err = oas.repo.CreateAuthorizationCode(ctx, &entities.AuthorizationCode{
+		CodeHash:            crypt.HashOpaqueToken(code),
+		ClientID:            client.ID,
+		UserGuid:            *session.UserGuid,
+		SSOSessionID:        session.RefreshId,
+		RedirectURI:         redirectURI,
+		Scope:               strings.Join(scopes, " "),
+		Nonce:               nonce,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		ExpiresAt:           time.Now().Add(oas.cfg.OIDC.AuthCodeTTL),
+	})
+	if err != nil {
+		oas.log.Error(op, slog.String("error", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	params.Set("code", code)
+	return appendQuery(redirectURI, params), nil
+}
+
+func (oas *oauthService) PushAuthorizationRequest(ctx context.Context, auth *entities.ClientAuthentication, req *entities.AuthorizeRequest) (*entities.PushedAuthorizationResponse, error) {
+	const op = "service.PushAuthorizationRequest"
+	oas.log.Info(op, slog.String("msg", "Pushed authorization request"), slog.String("client_id", auth.ClientID))
+
+	client, err := oas.clientService.AuthenticateClient(ctx, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.RequestURI != "" {
+		return nil, oauthError("invalid_request", "request_uri can't be pushed")
+	}
+	if req.ClientID != "" && req.ClientID != client.ID {
+		return nil, oauthError("invalid_request", "client_id doesn't match authenticated client")
+	}
+	req.ClientID = client.ID
+
+	// request is validated now, so the client learns about errors before redirecting the browser
+	if _, ok := authorizeRedirectURI(client, req); !ok {
+		return nil, oauthError("invalid_request", "redirect_uri isn't registered for client")
+	}
+	if _, oauthErr := checkAuthorizeRequest(client, req); oauthErr != nil {
+		return nil, oauthErr
+	}
+
+	request, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	reference, err := crypt.GenerateOpaqueToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = oas.repo.CreatePushedRequest(ctx, &entities.PushedAuthorizationRequest{
+		RequestURIHash: crypt.HashOpaqueToken(reference),
+		ClientID:       client.ID,
+		Request:        request,
+		ExpiresAt:      time.Now().Add(oas.cfg.OIDC.PARRequestTTL),
+	})
+	if err != nil {
+		oas.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &entities.PushedAuthorizationResponse{
+		RequestURI: requestURIPrefix + reference,
+		ExpiresIn:  int64(oas.cfg.OIDC.PARRequestTTL.Seconds()),
+	}, nil
+}
+
+// replaces front-channel parameters with the ones client pushed, only browser session is kept
+func (oas *oauthService) pushedRequest(ctx context.Context, client *entities.OAuthClient, requestURIHash string, req *entities.AuthorizeRequest) (*entities.AuthorizeRequest, error) {
+	const op = "service.pushedRequest"
+
+	if !strings.HasPrefix(req.RequestURI, requestURIPrefix) {
+		return nil, ErrInvalidRequestURI
+	}
+
+	pushed, err := oas.repo.GetPushedRequest(ctx, requestURIHash)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrInvalidRequestURI
+	}
+	if err != nil {
+		oas.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if pushed.ClientID != client.ID {
+		return nil, ErrInvalidRequestURI
+	}
+
+	var stored entities.AuthorizeRequest
+	if err := json.Unmarshal(pushed.Request, &stored); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	stored.RequestURI = req.RequestURI
+	stored.SessionToken = req.SessionToken
+	return &stored, nil
+}
+
+// redirect uri has to be resolved before anything else, errors can be sent only to a registered one
+func authorizeRedirectURI(client *entities.OAuthClient, req *entities.AuthorizeRequest) (string, bool) {
+	redirectURI := req.RedirectURI
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	return redirectURI, slices.Contains(client.RedirectURIs, redirectURI)
+}
+
+// checks authorization request against client registration and returns granted scopes
+func checkAuthorizeRequest(client *entities.OAuthClient, req *entities.AuthorizeRequest) ([]string, *OAuthError) {
+	if req.ResponseType != "code" {
+		return nil, oauthError("unsupported_response_type", "only code response type is supported")
+	}
+	if !slices.Contains(client.GrantTypes, "authorization_code") {
+		return nil, oauthError("unauthorized_client", "client isn't allowed to use authorization code grant")
+	}
+
+	scopes, err := clientScopes(client, req.Scope)
+	var oauthErr *OAuthError
+	if errors.As(err, &oauthErr) {
+		return nil, oauthErr
+	}
+
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return nil, oauthError("invalid_request", "pkce with S256 code challenge is required")
+	}
+	return scopes, nil
+}
+
+func (oas *oauthService) Token(ctx context.Context, req *entities.TokenRequest) (*entities.TokenResponse, error) {
+	const op = "service.Token"
+	oas.log.Info(op, slog.String("msg", "Token request"), slog.String("grant_type", req.GrantType))
+
+	client, err := oas.tokenClient(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(client.GrantTypes, req.GrantType) {
+		if slices.Contains(supportedGrantTypes, req.GrantType) {
+			return nil, oauthError("unauthorized_client", "client isn't allowed to use "+req.GrantType+" grant")
+		}
+		return nil, oauthError("unsupported_grant_type", "")
+	}
+
+	switch req.GrantType {
+	case "authorization_code":
+		return oas.exchangeAuthorizationCode(ctx, client, req)
+	case "refresh_token":
+		return oas.refresh(ctx, client, req)
+	case "client_credentials":
+		return oas.clientCredentials(ctx, client, req)
+	case GrantTypeDeviceCode:
+		return oas.deviceCode(ctx, client, req)
+	case GrantTypeTokenExchange:
+		return oas.tokenExchange(ctx, client, req)
+	case GrantTypeJWTBearer:
+		return oas.jwtBearer(ctx, client, req)
+	}
+	return nil, oauthError("unsupported_grant_type", "")
+}
+
+// authenticates client of token request, workloads using jwt-bearer grant may skip client authentication
+// because the assertion they present is verified with the keys of the client that issued it (RFC 7523 3.1)
+func (oas *oauthService) tokenClient(ctx context.Context, req *entities.TokenRequest) (*entities.OAuthClient, error) {
+	if req.GrantType != GrantTypeJWTBearer || req.Client.Method != entities.ClientAuthNone {
+		return oas.clientService.AuthenticateClient(ctx, &req.Client)
+	}
+
+	issuer, err := oidc.PeekAssertionIssuer(req.Assertion)
+	if err != nil || (req.Client.ClientID != "" && req.Client.ClientID != issuer) {
+		return nil, oauthError("invalid_grant", "assertion is invalid")
+	}
+	client, err := oas.clientService.GetClient(ctx, issuer)
+	if errors.Is(err, ErrClientNotFound) {
+		return nil, oauthError("invalid_grant", "assertion is invalid")
+	}
+	return client, err
+}
+
+func (oas *oauthService) exchangeAuthorizationCode(ctx context.Context, client *entities.OAuthClient, req *entities.TokenRequest) (*entities.TokenResponse, error) {
+	const op = "service.exchangeAuthorizationCode"
+
+	code, err := oas.repo.ConsumeAuthorizationCode(ctx, crypt.HashOpaqueToken(req.Code))
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, oauthError("invalid_grant", "authorization code is invalid or expired")
+	}
+	if err != nil {
+		oas.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if code.ClientID != client.ID || code.RedirectURI != req.RedirectURI {
+		return nil, oauthError("invalid_grant", "authorization code was issued to another client")
+	}
+	if !oidc.VerifyPKCE(req.CodeVerifier, code.CodeChallenge, code.CodeChallengeMethod) {
+		return nil, oauthError("invalid_grant", "code verifier doesn't match")
+	}
+
+	var nonce string
+	if code.Nonce != nil {
+		nonce = *code.Nonce
+	}
+
+	// user already passed all factors when the browser session was created
+	return oas.issueUserTokens(ctx, client, code.UserGuid, code.SSOSessionID, code.Scope, nonce, req.IpAddr)
+}
+
+// device polls until user approves it with an authenticated session
+func (oas *oauthService) deviceCode(ctx context.Context, client *entities.OAuthClient, req *entities.TokenRequest) (*entities.TokenResponse, error) {
+	code, err := oas.deviceService.Poll(ctx, client, req.DeviceCode)
+	if err != nil {
+		return nil, err
+	}
+	return oas.issueUserTokens(ctx, client, *code.UserGuid, nil, code.Scope, "", req.IpAddr)
+}
+
+// creates client bound session for user who already authenticated and returns its tokens,
+// sid links it to the browser session so both end together
+func (oas *oauthService) issueUserTokens(ctx context.Context, client *entities.OAuthClient, userGuid uuid.UUID, sid *uuid.UUID, scope, nonce, ipAddr string) (*entities.TokenResponse, error) {
+	const op = "service.issueUserTokens"
+
+	// client session inherits login method of the browser session for tenant policy
+	var method string
+	if sid != nil {
+		ssoSession, err := oas.authRepo.GetAuthInfoById(ctx, entities.DefaultTenantID, sid.String())
+		if err != nil && !errors.Is(err, repo.ErrEntityNotExists) {
+			oas.log.Error(op, slog.String("error", err.Error()))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if ssoSession != nil && ssoSession.AuthMethod != nil {
+			method = *ssoSession.AuthMethod
+		}
+	}
+
+	tokenPair, err := oas.authService.ReleaseTokens(ctx, &entities.AuthenticateRequest{
+		Guid:         userGuid.String(),
+		IpAddr:       ipAddr,
+		MFAPassed:    true,
+		ClientID:     client.ID,
+		Scope:        scope,
+		SSOSessionID: sid,
+		TTL:          clientTokenTTL(client),
+		TenantID:     client.TenantID,
+		Method:       method,
+	})
+	if errors.Is(err, ErrNoUserFound) {
+		return nil, oauthError("invalid_grant", "user doesn't exist")
+	}
+	if errors.Is(err, ErrUserDisabled) {
+		return nil, oauthError("invalid_grant", err.Error())
+	}
+	var tenantErr *TenantAccessError
+	if errors.As(err, &tenantErr) {
+		return nil, oauthError("invalid_grant", tenantErr.Reason)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// id token is issued only for openid connect requests, plain oauth clients get access tokens only
+	var idToken string
+	if slices.Contains(strings.Fields(scope), "openid") {
+		idToken, err = oas.idToken(ctx, client, userGuid, sid, scope, nonce)
+		if err != nil {
+			oas.log.Error(op, slog.String("error", err.Error()))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return &entities.TokenResponse{
+		AccessToken:  tokenPair.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(tokenPair.AccessTTL.Seconds()),
+		RefreshToken: tokenPair.RefreshToken,
+		IDToken:      idToken,
+		Scope:        scope,
+	}, nil
+}
+
+func (oas *oauthService) refresh(ctx context.Context, client *entities.OAuthClient, req *entities.TokenRequest) (*entities.TokenResponse, error) {
+	const op = "service.oauthRefresh"
+
+	claims, err := jwtp.GetAndValidateTokenClaims(req.RefreshToken, true)
+	if err != nil {
+		return nil, oauthError("invalid_grant", "refresh token is invalid or expired")
+	}
+
+	session, err := oas.authRepo.GetAuthInfoById(ctx, claimsTenant(claims), claims.Subject)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, oauthError("invalid_grant", "refresh token is invalid or expired")
+	}
+	if err != nil {
+		oas.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// refresh tokens are bound to the client they were issued to
+	if session.ClientID == nil || *session.ClientID != client.ID {
+		return nil, oauthError("invalid_grant", "refresh token was issued to another client")
+	}
+
+	tokenPair, err := oas.authService.RefreshTokenWithTTL(ctx, req.RefreshToken, req.IpAddr, clientTokenTTL(client))
+	if errors.Is(err, ErrInvalidTokenClaims) || errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, oauthError("invalid_grant", "refresh token is invalid or expired")
+	}
+	if errors.Is(err, ErrUserDisabled) {
+		return nil, oauthError("invalid_grant", err.Error())
+	}
+	var tenantErr *TenantAccessError
+	if errors.As(err, &tenantErr) {
+		return nil, oauthError("invalid_grant", tenantErr.Reason)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var scope string
+	if session.Scope != nil {
+		scope = *session.Scope
+	}
+
+	return &entities.TokenResponse{
+		AccessToken:  tokenPair.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(tokenPair.AccessTTL.Seconds()),
+		RefreshToken: tokenPair.RefreshToken,
+		Scope:        scope,
+	}, nil
+}
+
+// client acts on its own behalf, token is bound to the client and can't be refreshed
+func (oas *oauthService) clientCredentials(ctx context.Context, client *entities.OAuthClient, req *entities.TokenRequest) (*entities.TokenResponse, error) {
+	// public clients can't keep credentials, so they can't act on their own behalf
+	if client.TokenEndpointAuthMethod == entities.ClientAuthNone {
+		return nil, oauthError("unauthorized_client", "public clients can't use client_credentials grant")
+	}
+	return oas.issueServiceToken(client, req.Scope)
+}
+
+// workload proves its identity with assertion signed by its own key and gets service token
+func (oas *oauthService) jwtBearer(ctx context.Context, client *entities.OAuthClient, req *entities.TokenRequest) (*entities.TokenResponse, error) {
+	err := oas.clientService.VerifyClientAssertion(ctx, client, req.Assertion)
+	if errors.Is(err, oidc.ErrInvalidAssertion) {
+		return nil, oauthError("invalid_grant", "assertion is invalid, expired or already used")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return oas.issueServiceToken(client, req.Scope)
+}
+
+func (oas *oauthService) issueServiceToken(client *entities.OAuthClient, requestedScope string) (*entities.TokenResponse, error) {
+	const op = "service.issueServiceToken"
+
+	scopes := strings.Fields(requestedScope)
+	if len(scopes) == 0 {
+		for _, scope := range client.Scopes {
+			if !slices.Contains(supportedScopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	for _, scope := range scopes {
+		if slices.Contains(supportedScopes, scope) {
+			return nil, oauthError("invalid_scope", "scope "+scope+" requires a user")
+		}
+		if !slices.Contains(client.Scopes, scope) {
+			return nil, oauthError("invalid_scope", "scope "+scope+" isn't allowed for client")
+		}
+	}
+	scope := strings.Join(scopes, " ")
+
+	ttl := oas.accessTokenTTL(client)
+	accessToken, err := jwtp.GenerateServiceToken(client.ID, client.TenantID, scope, ttl)
+	if err != nil {
+		oas.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	oas.log.Info(op, slog.String("msg", "service token issued"), slog.String("client_id", client.ID))
+	return &entities.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(ttl.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+func (oas *oauthService) UserInfo(ctx context.Context, accessToken string) (*entities.UserInfo, error) {
+	const op = "service.UserInfo"
+
+	session, err := oas.authService.AuthenticateAccessToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var scopes []string
+	if session.Scope != nil {
+		scopes = strings.Fields(*session.Scope)
+	}
+	if !slices.Contains(scopes, "openid") {
+		return nil, oauthError("insufficient_scope", "openid scope is required")
+	}
+
+	user, err := oas.userRepo.GetUserById(ctx, *session.UserGuid)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrInvalidAccessToken
+	}
+	if err != nil {
+		oas.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	info := entities.UserInfo{Subject: user.ID.String()}
+	if slices.Contains(scopes, "email") {
+		info.Email = user.Email
+	}
+	// full list regardless of token size limit, clients come here on groups_overage
+	if slices.Contains(scopes, "groups") {
+		groups, err := oas.groupRepo.GetUserGroups(ctx, user.ID)
+		if err != nil {
+			oas.log.Error(op, slog.String("error", err.Error()))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		info.Groups = groupClaimValues(oas.cfg, groups)
+	}
+	return &info, nil
+}
+
+func (oas *oauthService) AuthenticateSSOSession(ctx context.Context, accessToken string) (*entities.UserAuthInfo, error) {
+	if accessToken == "" {
+		return nil, ErrInvalidAccessToken
+	}
+
+	session, err := oas.authService.AuthenticateAccessToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// tokens handed out to clients must not be replayable as a login for other clients,
+	// impersonation must not turn into sso sessions with other clients either
+	if session.ClientID != nil || session.ImpersonatorGuid != nil {
+		return nil, ErrInvalidAccessToken
+	}
+	return session, nil
+}
+
+func (oas *oauthService) Discovery() *oidc.Discovery {
+	issuer := strings.TrimSuffix(oas.cfg.OIDC.Issuer, "/")
+	return oas.discovery(oas.cfg.OIDC.Issuer, issuer+"/.well-known/jwks.json")
+}
+
+// endpoints are served by this server for every tenant, only issuer and keys differ
+func (oas *oauthService) discovery(issuerID, jwksURI string) *oidc.Discovery {
+	issuer := strings.TrimSuffix(oas.cfg.OIDC.Issuer, "/")
+	return &oidc.Discovery{
+		Issuer:                issuerID,
+		AuthorizationEndpoint: issuer + "/authorize",
+		TokenEndpoint:         issuer + "/token",
+		UserinfoEndpoint:      issuer + "/userinfo",
+		JWKSURI:               jwksURI,
+		ScopesSupported:       supportedScopes,
+		ResponseTypes:         []string{"code"},
+		SubjectTypes:          []string{"public"},
+		IDTokenSigningAlgs:    []string{"RS256"},
+		CodeChallengeMethods:  []string{"S256"},
+		GrantTypes:            supportedGrantTypes,
+		TokenEndpointAuth:     supportedClientAuthMethods,
+		ClaimsSupported:       []string{"sub", "iss", "aud", "exp", "iat", "nonce", "azp", "email", "groups", "roles"},
+		DeviceEndpoint:        issuer + "/device_authorization",
+		PAREndpoint:           issuer + "/par",
+		IntrospectionEndpoint: issuer + "/introspect",
+		BackchannelLogout:     true,
+		BackchannelLogoutSID:  true,
+	}
+}
+
+func (oas *oauthService) JWKS() (*oidc.JWKS, error) {
+	const op = "service.JWKS"
+
+	key, err := jwtp.CurrentSigningKey()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	jwk, err := oidc.NewJWK(key.ID, "RS256", &key.PrivateKey.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &oidc.JWKS{Keys: []oidc.JWK{jwk}}, nil
+}
+
+func (oas *oauthService) TenantDiscovery(tenantID string) (*oidc.Discovery, error) {
+	set, ok := jwtp.TenantKeys(tenantID)
+	if !ok {
+		return nil, ErrTenantNotFound
+	}
+	issuer := strings.TrimSuffix(oas.cfg.OIDC.Issuer, "/")
+	return oas.discovery(set.Issuer, issuer+"/tenants/"+tenantID+"/.well-known/jwks.json"), nil
+}
+
+// all keys of the set are published so tokens signed before rotation stay verifiable
+func (oas *oauthService) TenantJWKS(tenantID string) (*oidc.JWKS, error) {
+	const op = "service.TenantJWKS"
+
+	set, ok := jwtp.TenantKeys(tenantID)
+	if !ok {
+		return nil, ErrTenantNotFound
+	}
+
+	jwks := &oidc.JWKS{Keys: make([]oidc.JWK, 0, len(set.Keys))}
+	for _, key := range set.Keys {
+		jwk, err := oidc.NewJWK(key.ID, "RS256", &key.PrivateKey.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks, nil
+}
+
+func (oas *oauthService) idToken(ctx context.Context, client *entities.OAuthClient, userGuid uuid.UUID, sid *uuid.UUID, scope, nonce string) (string, error) {
+	now := time.Now()
+	claims := oidc.IDTokenClaims{
+		Nonce:           nonce,
+		AuthorizedParty: client.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuerOf(oas.cfg, client.TenantID),
+			Subject:   userGuid.String(),
+			Audience:  jwt.ClaimStrings{client.ID},
+			ExpiresAt: jwt.NewNumericDate(now.Add(oas.cfg.OIDC.IDTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	if sid != nil {
+		claims.SessionID = sid.String()
+	}
+
+	if slices.Contains(strings.Fields(scope), "email") {
+		user, err := oas.userRepo.GetUserById(ctx, userGuid)
+		if err != nil {
+			return "", err
+		}
+		claims.Email = user.Email
+	}
+
+	return jwtp.GenerateSignedToken(client.TenantID, claims)
+}
+
+func (oas *oauthService) accessTokenTTL(client *entities.OAuthClient) time.Duration {
+	if ttl := clientTokenTTL(client).Access; ttl > 0 {
+		return ttl
+	}
+	return oas.cfg.Token.AccessTokenTTL
+}
+
+// validates requested scopes against client registration
+func clientScopes(client *entities.OAuthClient, scope string) ([]string, error) {
+	scopes := strings.Fields(scope)
+	if len(scopes) == 0 {
+		return nil, oauthError("invalid_scope", "scope is required")
+	}
+	for _, s := range scopes {
+		if !slices.Contains(client.Scopes, s) {
+			return nil, oauthError("invalid_scope", "scope "+s+" isn't allowed for client")
+		}
+	}
+	return scopes, nil
+}
+
+func appendQuery(rawURL string, params url.Values) string {
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+	}
+	return rawURL + sep + params.Encode()
+}
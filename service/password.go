@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	crypt "testovoe_medods/lib/bcrypt"
+	"testovoe_medods/lib/mail"
+	repo "testovoe_medods/repository"
+	"time"
+)
+
+var ErrInvalidResetToken = errors.New("reset token is invalid or expired")
+var ErrWeakPassword = errors.New("password doesn't satisfy password policy")
+
+type PasswordService interface {
+	ForgotPassword(ctx context.Context, req *entities.ForgotPasswordRequest) error
+	ResetPassword(ctx context.Context, req *entities.ResetPasswordRequest) error
+}
+
+type passwordService struct {
+	cfg      *config.Config
+	log      *slog.Logger
+	userRepo repo.UserRepository
+	repo     repo.PasswordRepository
+	lockout  LockoutService
+	mailer   mail.Sender
+}
+
+func NewPasswordService(log *slog.Logger, cfg *config.Config, userRepo repo.UserRepository, repo repo.PasswordRepository, lockout LockoutService, mailer mail.Sender) PasswordService {
+	return &passwordService{
+		cfg:      cfg,
+		log:      log,
+		userRepo: userRepo,
+		repo:     repo,
+		lockout:  lockout,
+		mailer:   mailer,
+	}
+}
+
+// issues reset token and emails it. Unknown emails are not reported to the caller, the whole
+// lookup runs in background so response time is the same whether the email exists or not
+func (ps *passwordService) ForgotPassword(ctx context.Context, req *entities.ForgotPasswordRequest) error {
+	const op = "service.ForgotPassword"
+	ps.log.Info(op, slog.String("msg", "Password reset requested"))
+
+	// throttled whether the email exists or not
+	keys := []string{MailLockoutKey(req.Email), MailLockoutKey(IPLockoutKey(req.IpAddr))}
+	if err := ps.lockout.Check(ctx, keys...); err != nil {
+		return err
+	}
+	ps.lockout.RegisterFailure(ctx, keys...)
+
+	go ps.sendResetLink(req.Email)
+	return nil
+}
+
+func (ps *passwordService) sendResetLink(email string) {
+	const op = "service.ForgotPassword"
+	ctx, cancel := context.WithTimeout(context.Background(), ps.cfg.Database.Timeout+30*time.Second)
+	defer cancel()
+
+	user, err := ps.userRepo.GetUserByEmail(ctx, email)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return
+	}
+	if err != nil {
+		ps.log.Error(op, slog.String("error", err.Error()))
+		return
+	}
+
+	token, err := crypt.GenerateOpaqueToken(32)
+	if err != nil {
+		ps.log.Error(op, slog.String("error", err.Error()))
+		return
+	}
+
+	expiresAt := time.Now().Add(ps.cfg.Password.ResetTokenTTL)
+	err = ps.repo.CreatePasswordResetToken(ctx, user.ID, crypt.HashOpaqueToken(token), expiresAt)
+	if err != nil {
+		ps.log.Error(op, slog.String("error", err.Error()))
+		return
+	}
+
+	link := ps.cfg.Password.ResetURL + "?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("To reset your password follow the link below. It expires in %s and can be used once.\n\n%s\n\nIf you didn't request a password reset, ignore this email.", ps.cfg.Password.ResetTokenTTL, link)
+	if err := ps.mailer.Send(ctx, user.Email, "Password reset", body); err != nil {
+		ps.log.Error(op, slog.String("error", err.Error()))
+	}
+}
+
+func (ps *passwordService) ResetPassword(ctx context.Context, req *entities.ResetPasswordRequest) error {
+	const op = "service.ResetPassword"
+	ps.log.Info(op, slog.String("msg", "Resetting password"))
+
+	if req.Token == "" {
+		return ErrInvalidResetToken
+	}
+
+	if len(req.Password) < ps.cfg.Password.MinLength {
+		return ErrWeakPassword
+	}
+
+	passwordHash, err := crypt.HashPassword(req.Password)
+	if errors.Is(err, crypt.ErrPasswordTooLong) {
+		return ErrWeakPassword
+	}
+	if err != nil {
+		ps.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	userGuid, err := ps.repo.ResetPassword(ctx, crypt.HashOpaqueToken(req.Token), passwordHash)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		ps.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	ps.log.Info(op, slog.String("msg", "Password has been reset, sessions revoked"), slog.String("user_guid", userGuid.String()))
+	return nil
+}
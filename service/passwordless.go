@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	crypt "testovoe_medods/lib/bcrypt"
+	"testovoe_medods/lib/mail"
+	repo "testovoe_medods/repository"
+	"time"
+)
+
+var ErrInvalidPasswordlessMethod = errors.New("method must be either link or code")
+var ErrInvalidPasswordlessLogin = errors.New("login link or code is invalid or expired")
+
+type PasswordlessService interface {
+	// emails link or code and returns state that binds the login to requesting browser
+	Start(ctx context.Context, req *entities.PasswordlessStartRequest) (string, error)
+	VerifyLink(ctx context.Context, state, token, ipAddr string) (*entities.TokenPair, error)
+	VerifyCode(ctx context.Context, state, code, ipAddr string) (*entities.TokenPair, error)
+}
+
+type passwordlessService struct {
+	cfg         *config.Config
+	log         *slog.Logger
+	repo        repo.PasswordlessRepository
+	userRepo    repo.UserRepository
+	authService AuthService
+	lockout     LockoutService
+	mailer      mail.Sender
+}
+
+func NewPasswordlessService(log *slog.Logger, cfg *config.Config, repo repo.PasswordlessRepository, userRepo repo.UserRepository, authService AuthService, lockout LockoutService, mailer mail.Sender) PasswordlessService {
+	return &passwordlessService{
+		cfg:         cfg,
+		log:         log,
+		repo:        repo,
+		userRepo:    userRepo,
+		authService: authService,
+		lockout:     lockout,
+		mailer:      mailer,
+	}
+}
+
+func (ps *passwordlessService) Start(ctx context.Context, req *entities.PasswordlessStartRequest) (string, error) {
+	const op = "service.PasswordlessStart"
+	ps.log.Info(op, slog.String("msg", "Starting passwordless login"))
+
+	if req.Method != entities.PasswordlessMethodLink && req.Method != entities.PasswordlessMethodCode {
+		return "", ErrInvalidPasswordlessMethod
+	}
+
+	// throttled whether the email exists or not
+	keys := []string{MailLockoutKey(req.Email), MailLockoutKey(IPLockoutKey(req.IpAddr))}
+	if err := ps.lockout.Check(ctx, keys...); err != nil {
+		return "", err
+	}
+	ps.lockout.RegisterFailure(ctx, keys...)
+
+	// state is returned for unknown emails too and the lookup runs in background,
+	// so neither the response nor its timing reveals whether account exists
+	state, err := crypt.GenerateOpaqueToken(32)
+	if err != nil {
+		ps.log.Error(op, slog.String("error", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	go ps.sendLogin(req.Email, req.Method, state)
+	return state, nil
+}
+
+func (ps *passwordlessService) sendLogin(email, method, state string) {
+	const op = "service.PasswordlessStart"
+	ctx, cancel := context.WithTimeout(context.Background(), ps.cfg.Database.Timeout+30*time.Second)
+	defer cancel()
+
+	user, err := ps.userRepo.GetUserByEmail(ctx, email)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return
+	}
+	if err != nil {
+		ps.log.Error(op, slog.String("error", err.Error()))
+		return
+	}
+
+	login := entities.PasswordlessLogin{
+		UserGuid:  user.ID,
+		StateHash: crypt.HashOpaqueToken(state),
+		ExpiresAt: time.Now().Add(ps.cfg.Passwordless.TTL),
+	}
+
+	var subject, body string
+	if method == entities.PasswordlessMethodLink {
+		token, err := crypt.GenerateOpaqueToken(32)
+		if err != nil {
+			ps.log.Error(op, slog.String("error", err.Error()))
+			return
+		}
+		tokenHash := crypt.HashOpaqueToken(token)
+		login.TokenHash = &tokenHash
+
+		link := ps.cfg.Passwordless.LinkURL + "?token=" + url.QueryEscape(token)
+		subject = "Your sign-in link"
+		body = fmt.Sprintf("Follow the link below to sign in. It expires in %s, works once and only in the browser where sign-in was requested.\n\n%s\n\nIf you didn't try to sign in, ignore this email.", ps.cfg.Passwordless.TTL, link)
+	} else {
+		code, err := crypt.GenerateNumericCode(6)
+		if err != nil {
+			ps.log.Error(op, slog.String("error", err.Error()))
+			return
+		}
+		codeHash := crypt.HMACDigest(login.StateHash + ":" + code)
+		login.CodeHash = &codeHash
+
+		subject = "Your sign-in code"
+		body = fmt.Sprintf("Your sign-in code is %s. It expires in %s.\n\nIf you didn't try to sign in, ignore this email.", code, ps.cfg.Passwordless.TTL)
+	}
+
+	if err := ps.repo.CreateLogin(ctx, &login); err != nil {
+		ps.log.Error(op, slog.String("error", err.Error()))
+		return
+	}
+
+	if err := ps.mailer.Send(ctx, user.Email, subject, body); err != nil {
+		ps.log.Error(op, slog.String("error", err.Error()))
+	}
+}
+
+func (ps *passwordlessService) VerifyLink(ctx context.Context, state, token, ipAddr string) (*entities.TokenPair, error) {
+	const op = "service.PasswordlessVerifyLink"
+	ps.log.Info(op, slog.String("msg", "Verifying magic link"))
+
+	ipKey := IPLockoutKey(ipAddr)
+	if err := ps.lockout.Check(ctx, ipKey); err != nil {
+		return nil, err
+	}
+
+	if state == "" || token == "" {
+		return nil, ErrInvalidPasswordlessLogin
+	}
+
+	userGuid, err := ps.repo.ConsumeLoginByToken(ctx, crypt.HashOpaqueToken(token), crypt.HashOpaqueToken(state))
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		ps.lockout.RegisterFailure(ctx, ipKey)
+		return nil, ErrInvalidPasswordlessLogin
+	}
+	if err != nil {
+		ps.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ps.authService.ReleaseTokens(ctx, &entities.AuthenticateRequest{
+		Guid:   userGuid.String(),
+		IpAddr: ipAddr,
+		Method: entities.LoginMethodPasswordless,
+	})
+}
+
+func (ps *passwordlessService) VerifyCode(ctx context.Context, state, code, ipAddr string) (*entities.TokenPair, error) {
+	const op = "service.PasswordlessVerifyCode"
+	ps.log.Info(op, slog.String("msg", "Verifying email code"))
+
+	ipKey := IPLockoutKey(ipAddr)
+	if err := ps.lockout.Check(ctx, ipKey); err != nil {
+		return nil, err
+	}
+
+	if state == "" || code == "" {
+		return nil, ErrInvalidPasswordlessLogin
+	}
+
+	stateHash := crypt.HashOpaqueToken(state)
+	login, err := ps.repo.GetActiveLoginByStateHash(ctx, stateHash)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		ps.lockout.RegisterFailure(ctx, ipKey)
+		return nil, ErrInvalidPasswordlessLogin
+	}
+	if err != nil {
+		ps.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if login.CodeHash == nil {
+		return nil, ErrInvalidPasswordlessLogin
+	}
+
+	// attempt is taken before the comparison, every check counts whether it succeeds or not
+	allowed, err := ps.repo.UseAttempt(ctx, login.ID, ps.cfg.Passwordless.MaxCodeAttempts)
+	if err != nil {
+		ps.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !allowed {
+		return nil, ErrInvalidPasswordlessLogin
+	}
+
+	if subtle.ConstantTimeCompare([]byte(crypt.HMACDigest(stateHash+":"+code)), []byte(*login.CodeHash)) != 1 {
+		ps.lockout.RegisterFailure(ctx, ipKey, UserLockoutKey(login.UserGuid.String()))
+		return nil, ErrInvalidPasswordlessLogin
+	}
+
+	consumed, err := ps.repo.ConsumeLogin(ctx, login.ID)
+	if err != nil {
+		ps.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !consumed {
+		return nil, ErrInvalidPasswordlessLogin
+	}
+
+	return ps.authService.ReleaseTokens(ctx, &entities.AuthenticateRequest{
+		Guid:   login.UserGuid.String(),
+		IpAddr: ipAddr,
+		Method: entities.LoginMethodPasswordless,
+	})
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	repo "testovoe_medods/repository"
+
+	"github.com/google/uuid"
+)
+
+var ErrRoleNotFound = errors.New("role doesn't exist")
+var ErrRoleAlreadyExists = errors.New("role with this name already exists")
+var ErrRoleNotAssigned = errors.New("user doesn't have this role")
+var ErrPermissionNotFound = errors.New("permission doesn't exist")
+var ErrPermissionAlreadyExists = errors.New("permission with this name already exists")
+
+// names end up in token claims, so they are kept short and free of separators
+var roleNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:-]{0,63}$`)
+
+type InvalidRoleRequestError struct {
+	Reason string
+}
+
+func (e *InvalidRoleRequestError) Error() string {
+	return e.Reason
+}
+
+type RoleService interface {
+	CreatePermission(ctx context.Context, req *entities.PermissionRequest) (*entities.Permission, error)
+	ListPermissions(ctx context.Context) ([]entities.Permission, error)
+	DeletePermission(ctx context.Context, name string) error
+	CreateRole(ctx context.Context, req *entities.RoleRequest) (*entities.Role, error)
+	GetRole(ctx context.Context, name string) (*entities.Role, error)
+	ListRoles(ctx context.Context) ([]entities.Role, error)
+	UpdateRole(ctx context.Context, name string, req *entities.RoleRequest) (*entities.Role, error)
+	DeleteRole(ctx context.Context, name string) error
+	ListUserRoles(ctx context.Context, userGuid uuid.UUID) ([]entities.RoleAssignment, error)
+	// changes show up in access tokens issued after the call, tokens already issued keep old roles
+	AssignRole(ctx context.Context, userGuid uuid.UUID, role string) error
+	UnassignRole(ctx context.Context, userGuid uuid.UUID, role string) error
+	// tells whether any role of the user grants permission
+	HasPermission(ctx context.Context, userGuid uuid.UUID, permission string) (bool, error)
+}
+
+type roleService struct {
+	cfg      *config.Config
+	log      *slog.Logger
+	repo     repo.RoleRepository
+	userRepo repo.UserRepository
+}
+
+func NewRoleService(log *slog.Logger, cfg *config.Config, repo repo.RoleRepository, userRepo repo.UserRepository) RoleService {
+	return &roleService{
+		cfg:      cfg,
+		log:      log,
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+func (rs *roleService) CreatePermission(ctx context.Context, req *entities.PermissionRequest) (*entities.Permission, error) {
+	const op = "service.CreatePermission"
+	rs.log.Info(op, slog.String("msg", "Creating permission"), slog.String("name", req.Name))
+
+	if !roleNameRe.MatchString(req.Name) {
+		return nil, &InvalidRoleRequestError{Reason: "invalid permission name"}
+	}
+
+	permission, err := rs.repo.CreatePermission(ctx, &entities.Permission{Name: req.Name, Description: req.Description})
+	if errors.Is(err, repo.ErrEntityAlreadyExists) {
+		return nil, ErrPermissionAlreadyExists
+	}
+	if err != nil {
+		rs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return permission, nil
+}
+
+func (rs *roleService) ListPermissions(ctx context.Context) ([]entities.Permission, error) {
+	const op = "service.ListPermissions"
+
+	permissions, err := rs.repo.ListPermissions(ctx)
+	if err != nil {
+		rs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return permissions, nil
+}
+
+func (rs *roleService) DeletePermission(ctx context.Context, name string) error {
+	const op = "service.DeletePermission"
+	rs.log.Info(op, slog.String("msg", "Deleting permission"), slog.String("name", name))
+
+	err := rs.repo.DeletePermission(ctx, name)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrPermissionNotFound
+	}
+	if err != nil {
+		rs.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (rs *roleService) CreateRole(ctx context.Context, req *entities.RoleRequest) (*entities.Role, error) {
+	const op = "service.CreateRole"
+	rs.log.Info(op, slog.String("msg", "Creating role"), slog.String("name", req.Name))
+
+	if !roleNameRe.MatchString(req.Name) {
+		return nil, &InvalidRoleRequestError{Reason: "invalid role name"}
+	}
+
+	role, err := rs.repo.CreateRole(ctx, rs.roleFromRequest(req.Name, req))
+	if errors.Is(err, repo.ErrEntityAlreadyExists) {
+		return nil, ErrRoleAlreadyExists
+	}
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, &InvalidRoleRequestError{Reason: "role refers to unknown permission"}
+	}
+	if err != nil {
+		rs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return role, nil
+}
+
+func (rs *roleService) GetRole(ctx context.Context, name string) (*entities.Role, error) {
+	const op = "service.GetRole"
+
+	role, err := rs.repo.GetRole(ctx, name)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrRoleNotFound
+	}
+	if err != nil {
+		rs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return role, nil
+}
+
+func (rs *roleService) ListRoles(ctx context.Context) ([]entities.Role, error) {
+	const op = "service.ListRoles"
+
+	roles, err := rs.repo.ListRoles(ctx)
+	if err != nil {
+		rs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return roles, nil
+}
+
+func (rs *roleService) UpdateRole(ctx context.Context, name string, req *entities.RoleRequest) (*entities.Role, error) {
+	const op = "service.UpdateRole"
+	rs.log.Info(op, slog.String("msg", "Updating role"), slog.String("name", name))
+
+	if _, err := rs.GetRole(ctx, name); err != nil {
+		return nil, err
+	}
+
+	// repository can't tell missing role from missing permission, the role was checked above
+	role, err := rs.repo.UpdateRole(ctx, rs.roleFromRequest(name, req))
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, &InvalidRoleRequestError{Reason: "role refers to unknown permission"}
+	}
+	if err != nil {
+		rs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return role, nil
+}
+
+func (rs *roleService) roleFromRequest(name string, req *entities.RoleRequest) *entities.Role {
+	var permissions entities.StringList
+	for _, p := range req.Permissions {
+		if !slices.Contains(permissions, p) {
+			permissions = append(permissions, p)
+		}
+	}
+	return &entities.Role{
+		Name:        name,
+		Description: req.Description,
+		Permissions: permissions,
+	}
+}
+
+func (rs *roleService) DeleteRole(ctx context.Context, name string) error {
+	const op = "service.DeleteRole"
+	rs.log.Info(op, slog.String("msg", "Deleting role"), slog.String("name", name))
+
+	err := rs.repo.DeleteRole(ctx, name)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrRoleNotFound
+	}
+	if err != nil {
+		rs.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (rs *roleService) ListUserRoles(ctx context.Context, userGuid uuid.UUID) ([]entities.RoleAssignment, error) {
+	const op = "service.ListUserRoles"
+
+	_, err := rs.userRepo.GetUserById(ctx, userGuid)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrNoUserFound
+	}
+	if err != nil {
+		rs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	assignments, err := rs.repo.ListAssignments(ctx, userGuid)
+	if err != nil {
+		rs.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return assignments, nil
+}
+
+func (rs *roleService) AssignRole(ctx context.Context, userGuid uuid.UUID, role string) error {
+	const op = "service.AssignRole"
+	rs.log.Info(op, slog.String("msg", "Assigning role"), slog.String("user", userGuid.String()), slog.String("role", role))
+
+	if _, err := rs.GetRole(ctx, role); err != nil {
+		return err
+	}
+
+	err := rs.repo.AssignRole(ctx, userGuid, role)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrNoUserFound
+	}
+	if err != nil {
+		rs.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (rs *roleService) UnassignRole(ctx context.Context, userGuid uuid.UUID, role string) error {
+	const op = "service.UnassignRole"
+	rs.log.Info(op, slog.String("msg", "Unassigning role"), slog.String("user", userGuid.String()), slog.String("role", role))
+
+	err := rs.repo.UnassignRole(ctx, userGuid, role)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrRoleNotAssigned
+	}
+	if err != nil {
+		rs.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (rs *roleService) HasPermission(ctx context.Context, userGuid uuid.UUID, permission string) (bool, error) {
+	const op = "service.HasPermission"
+
+	allowed, err := rs.repo.HasPermission(ctx, userGuid, permission)
+	if err != nil {
+		rs.log.Error(op, slog.String("error", err.Error()))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return allowed, nil
+}
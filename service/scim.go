@@ -0,0 +1,735 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/lib/scim"
+	repo "testovoe_medods/repository"
+
+	"github.com/google/uuid"
+)
+
+// error rendered as scim error response, Type is scimType of RFC 7644 and may be empty
+type SCIMError struct {
+	Status int
+	Type   string
+	Detail string
+}
+
+func (e *SCIMError) Error() string {
+	return e.Detail
+}
+
+func scimError(status int, scimType, detail string) *SCIMError {
+	return &SCIMError{Status: status, Type: scimType, Detail: detail}
+}
+
+var errSCIMUserNotFound = scimError(http.StatusNotFound, "", "user doesn't exist")
+var errSCIMGroupNotFound = scimError(http.StatusNotFound, "", "group doesn't exist")
+
+// SCIM 2.0 provisioning of users and groups by identity providers.
+// Errors are *SCIMError unless something unexpected fails
+type SCIMService interface {
+	ServiceProviderConfig() *entities.SCIMServiceProviderConfig
+	// startIndex is 1-based, count is capped at configured maximum
+	ListUsers(ctx context.Context, filter string, startIndex, count int) (*entities.SCIMListResponse, error)
+	GetUser(ctx context.Context, id string) (*entities.SCIMUser, error)
+	CreateUser(ctx context.Context, req *entities.SCIMUser) (*entities.SCIMUser, error)
+	// deactivated users lose every session and can't log in until activated again
+	ReplaceUser(ctx context.Context, id string, req *entities.SCIMUser) (*entities.SCIMUser, error)
+	PatchUser(ctx context.Context, id string, req *entities.SCIMPatchRequest) (*entities.SCIMUser, error)
+	DeleteUser(ctx context.Context, id string) error
+	ListGroups(ctx context.Context, filter string, startIndex, count int) (*entities.SCIMListResponse, error)
+	GetGroup(ctx context.Context, id string) (*entities.SCIMGroup, error)
+	CreateGroup(ctx context.Context, req *entities.SCIMGroup) (*entities.SCIMGroup, error)
+	ReplaceGroup(ctx context.Context, id string, req *entities.SCIMGroup) (*entities.SCIMGroup, error)
+	PatchGroup(ctx context.Context, id string, req *entities.SCIMPatchRequest) (*entities.SCIMGroup, error)
+	DeleteGroup(ctx context.Context, id string) error
+}
+
+type scimService struct {
+	cfg       *config.Config
+	log       *slog.Logger
+	repo      repo.SCIMRepository
+	userRepo  repo.UserRepository
+	groupRepo repo.GroupRepository
+}
+
+func NewSCIMService(log *slog.Logger, cfg *config.Config, repo repo.SCIMRepository, userRepo repo.UserRepository, groupRepo repo.GroupRepository) SCIMService {
+	return &scimService{
+		cfg:       cfg,
+		log:       log,
+		repo:      repo,
+		userRepo:  userRepo,
+		groupRepo: groupRepo,
+	}
+}
+
+func (ss *scimService) ServiceProviderConfig() *entities.SCIMServiceProviderConfig {
+	return &entities.SCIMServiceProviderConfig{
+		Schemas: []string{entities.SCIMSchemaSPConfig},
+		Patch:   entities.SCIMSupported{Supported: true},
+		Filter:  entities.SCIMFilterSupport{Supported: true, MaxResults: ss.cfg.SCIM.MaxResults},
+		AuthenticationSchemes: []entities.SCIMAuthScheme{{
+			Type:        "oauthbearertoken",
+			Name:        "OAuth Bearer Token",
+			Description: "Provisioning token configured for the service",
+			Primary:     true,
+		}},
+	}
+}
+
+func (ss *scimService) ListUsers(ctx context.Context, filter string, startIndex, count int) (*entities.SCIMListResponse, error) {
+	const op = "service.ListSCIMUsers"
+
+	f, err := scimFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	offset, limit := ss.scimPage(startIndex, count)
+
+	users, total, err := ss.repo.ListUsers(ctx, f, offset, limit)
+	if errors.Is(err, repo.ErrUnsupportedFilter) {
+		return nil, scimError(http.StatusBadRequest, "invalidFilter", "filter attribute or operator isn't supported")
+	}
+	if err != nil {
+		ss.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	resources := make([]*entities.SCIMUser, 0, len(users))
+	for i := range users {
+		resources = append(resources, ss.scimUser(&users[i]))
+	}
+	return &entities.SCIMListResponse{
+		Schemas:      []string{entities.SCIMSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   offset + 1,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}, nil
+}
+
+func (ss *scimService) GetUser(ctx context.Context, id string) (*entities.SCIMUser, error) {
+	user, err := ss.getUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return ss.scimUser(user), nil
+}
+
+func (ss *scimService) CreateUser(ctx context.Context, req *entities.SCIMUser) (*entities.SCIMUser, error) {
+	const op = "service.CreateSCIMUser"
+	ss.log.Info(op, slog.String("msg", "Provisioning user"))
+
+	data, err := scimUserData(req)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := ss.repo.CreateUser(ctx, data)
+	if errors.Is(err, repo.ErrEntityAlreadyExists) {
+		return nil, scimError(http.StatusConflict, "uniqueness", "user with this userName already exists")
+	}
+	if err != nil {
+		ss.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return ss.scimUser(user), nil
+}
+
+func (ss *scimService) ReplaceUser(ctx context.Context, id string, req *entities.SCIMUser) (*entities.SCIMUser, error) {
+	guid, err := scimID(id, errSCIMUserNotFound)
+	if err != nil {
+		return nil, err
+	}
+	data, err := scimUserData(req)
+	if err != nil {
+		return nil, err
+	}
+	data.ID = guid
+	return ss.replaceUser(ctx, data)
+}
+
+func (ss *scimService) PatchUser(ctx context.Context, id string, req *entities.SCIMPatchRequest) (*entities.SCIMUser, error) {
+	user, err := ss.getUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPatchRequest(req); err != nil {
+		return nil, err
+	}
+
+	for _, operation := range req.Operations {
+		if err := patchUser(user, &operation); err != nil {
+			return nil, err
+		}
+	}
+	return ss.replaceUser(ctx, user)
+}
+
+func (ss *scimService) replaceUser(ctx context.Context, data *entities.User) (*entities.SCIMUser, error) {
+	const op = "service.ReplaceSCIMUser"
+	ss.log.Info(op, slog.String("guid", data.ID.String()), slog.Bool("disabled", data.Disabled))
+
+	user, err := ss.repo.ReplaceUser(ctx, data)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, errSCIMUserNotFound
+	}
+	if errors.Is(err, repo.ErrEntityAlreadyExists) {
+		return nil, scimError(http.StatusConflict, "uniqueness", "user with this userName already exists")
+	}
+	if err != nil {
+		ss.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return ss.scimUser(user), nil
+}
+
+func (ss *scimService) DeleteUser(ctx context.Context, id string) error {
+	const op = "service.DeleteSCIMUser"
+
+	guid, err := scimID(id, errSCIMUserNotFound)
+	if err != nil {
+		return err
+	}
+	ss.log.Info(op, slog.String("msg", "Deprovisioning user"), slog.String("guid", guid.String()))
+
+	err = ss.userRepo.DeleteUser(ctx, entities.DefaultTenantID, guid)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return errSCIMUserNotFound
+	}
+	if err != nil {
+		ss.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (ss *scimService) ListGroups(ctx context.Context, filter string, startIndex, count int) (*entities.SCIMListResponse, error) {
+	const op = "service.ListSCIMGroups"
+
+	f, err := scimFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	offset, limit := ss.scimPage(startIndex, count)
+
+	groups, total, err := ss.repo.ListGroups(ctx, f, offset, limit)
+	if errors.Is(err, repo.ErrUnsupportedFilter) {
+		return nil, scimError(http.StatusBadRequest, "invalidFilter", "filter attribute or operator isn't supported")
+	}
+	if err != nil {
+		ss.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ids := make([]string, 0, len(groups))
+	for _, group := range groups {
+		ids = append(ids, group.ID.String())
+	}
+	members, err := ss.repo.ListGroupMembers(ctx, ids)
+	if err != nil {
+		ss.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	resources := make([]*entities.SCIMGroup, 0, len(groups))
+	for i := range groups {
+		resources = append(resources, ss.scimGroup(&groups[i], members))
+	}
+	return &entities.SCIMListResponse{
+		Schemas:      []string{entities.SCIMSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   offset + 1,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}, nil
+}
+
+func (ss *scimService) GetGroup(ctx context.Context, id string) (*entities.SCIMGroup, error) {
+	group, err := ss.getGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return ss.groupResource(ctx, group)
+}
+
+func (ss *scimService) CreateGroup(ctx context.Context, req *entities.SCIMGroup) (*entities.SCIMGroup, error) {
+	const op = "service.CreateSCIMGroup"
+	ss.log.Info(op, slog.String("msg", "Provisioning group"), slog.String("name", req.DisplayName))
+
+	data, members, err := scimGroupData(req)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := ss.repo.CreateGroup(ctx, data, members)
+	if errors.Is(err, repo.ErrEntityAlreadyExists) {
+		return nil, scimError(http.StatusConflict, "uniqueness", "group with this displayName already exists")
+	}
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, scimError(http.StatusBadRequest, "invalidValue", "member doesn't exist")
+	}
+	if err != nil {
+		ss.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return ss.groupResource(ctx, group)
+}
+
+func (ss *scimService) ReplaceGroup(ctx context.Context, id string, req *entities.SCIMGroup) (*entities.SCIMGroup, error) {
+	group, err := ss.getGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	data, members, err := scimGroupData(req)
+	if err != nil {
+		return nil, err
+	}
+	data.ID = group.ID
+	return ss.replaceGroup(ctx, data, members)
+}
+
+func (ss *scimService) PatchGroup(ctx context.Context, id string, req *entities.SCIMPatchRequest) (*entities.SCIMGroup, error) {
+	const op = "service.PatchSCIMGroup"
+
+	group, err := ss.getGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPatchRequest(req); err != nil {
+		return nil, err
+	}
+
+	current, err := ss.repo.ListGroupMembers(ctx, []string{group.ID.String()})
+	if err != nil {
+		ss.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	members := make([]string, 0, len(current))
+	for _, member := range current {
+		members = append(members, member.UserGuid.String())
+	}
+
+	for _, operation := range req.Operations {
+		if members, err = patchGroup(group, members, &operation); err != nil {
+			return nil, err
+		}
+	}
+	if !roleNameRe.MatchString(group.Name) {
+		return nil, scimError(http.StatusBadRequest, "invalidValue", "invalid displayName")
+	}
+	return ss.replaceGroup(ctx, group, members)
+}
+
+func (ss *scimService) replaceGroup(ctx context.Context, data *entities.Group, members []string) (*entities.SCIMGroup, error) {
+	const op = "service.ReplaceSCIMGroup"
+	ss.log.Info(op, slog.String("id", data.ID.String()), slog.Int("members", len(members)))
+
+	// existence of the group was checked by caller, missing entity is a member then
+	group, err := ss.repo.ReplaceGroup(ctx, data, members)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, scimError(http.StatusBadRequest, "invalidValue", "member doesn't exist")
+	}
+	if errors.Is(err, repo.ErrEntityAlreadyExists) {
+		return nil, scimError(http.StatusConflict, "uniqueness", "group with this displayName already exists")
+	}
+	if err != nil {
+		ss.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return ss.groupResource(ctx, group)
+}
+
+func (ss *scimService) DeleteGroup(ctx context.Context, id string) error {
+	const op = "service.DeleteSCIMGroup"
+
+	groupID, err := scimID(id, errSCIMGroupNotFound)
+	if err != nil {
+		return err
+	}
+	ss.log.Info(op, slog.String("msg", "Deprovisioning group"), slog.String("id", groupID.String()))
+
+	err = ss.groupRepo.DeleteGroup(ctx, groupID)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return errSCIMGroupNotFound
+	}
+	if err != nil {
+		ss.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (ss *scimService) getUser(ctx context.Context, id string) (*entities.User, error) {
+	const op = "service.GetSCIMUser"
+
+	guid, err := scimID(id, errSCIMUserNotFound)
+	if err != nil {
+		return nil, err
+	}
+	user, err := ss.userRepo.GetUserById(ctx, guid)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, errSCIMUserNotFound
+	}
+	if err != nil {
+		ss.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return user, nil
+}
+
+func (ss *scimService) getGroup(ctx context.Context, id string) (*entities.Group, error) {
+	const op = "service.GetSCIMGroup"
+
+	groupID, err := scimID(id, errSCIMGroupNotFound)
+	if err != nil {
+		return nil, err
+	}
+	group, err := ss.groupRepo.GetGroup(ctx, groupID)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, errSCIMGroupNotFound
+	}
+	if err != nil {
+		ss.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return group, nil
+}
+
+func (ss *scimService) groupResource(ctx context.Context, group *entities.Group) (*entities.SCIMGroup, error) {
+	const op = "service.GetSCIMGroupMembers"
+
+	members, err := ss.repo.ListGroupMembers(ctx, []string{group.ID.String()})
+	if err != nil {
+		ss.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return ss.scimGroup(group, members), nil
+}
+
+// offset and limit of requested page, out of range values are clamped as RFC 7644 asks
+func (ss *scimService) scimPage(startIndex, count int) (int, int) {
+	offset := max(startIndex-1, 0)
+	if count < 0 {
+		count = 0
+	}
+	return offset, min(count, ss.cfg.SCIM.MaxResults)
+}
+
+func (ss *scimService) location(resource, id string) string {
+	return strings.TrimSuffix(ss.cfg.OIDC.Issuer, "/") + "/scim/v2/" + resource + "/" + id
+}
+
+func (ss *scimService) scimUser(user *entities.User) *entities.SCIMUser {
+	active := !user.Disabled
+	return &entities.SCIMUser{
+		Schemas:    []string{entities.SCIMSchemaUser},
+		ID:         user.ID.String(),
+		ExternalID: user.ExternalID,
+		UserName:   user.Email,
+		Active:     &active,
+		Emails:     []entities.SCIMEmail{{Value: user.Email, Type: "work", Primary: true}},
+		Meta: &entities.SCIMMeta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     ss.location("Users", user.ID.String()),
+		},
+	}
+}
+
+// members may hold members of other groups as well
+func (ss *scimService) scimGroup(group *entities.Group, members []entities.GroupMember) *entities.SCIMGroup {
+	resource := &entities.SCIMGroup{
+		Schemas:     []string{entities.SCIMSchemaGroup},
+		ID:          group.ID.String(),
+		ExternalID:  group.ExternalID,
+		DisplayName: group.Name,
+		Members:     []entities.SCIMMember{},
+		Meta: &entities.SCIMMeta{
+			ResourceType: "Group",
+			Created:      group.CreatedAt,
+			LastModified: group.UpdatedAt,
+			Location:     ss.location("Groups", group.ID.String()),
+		},
+	}
+	for _, member := range members {
+		if member.GroupID != group.ID {
+			continue
+		}
+		resource.Members = append(resource.Members, entities.SCIMMember{
+			Value:   member.UserGuid.String(),
+			Display: member.Email,
+			Ref:     ss.location("Users", member.UserGuid.String()),
+			Type:    "User",
+		})
+	}
+	return resource
+}
+
+// ids that aren't uuids can't name any resource
+func scimID(id string, notFound *SCIMError) (uuid.UUID, error) {
+	guid, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, notFound
+	}
+	return guid, nil
+}
+
+func scimFilter(raw string) (*entities.SCIMFilter, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	f, err := scim.ParseFilter(raw)
+	if err != nil {
+		return nil, scimError(http.StatusBadRequest, "invalidFilter", err.Error())
+	}
+	return &entities.SCIMFilter{Attribute: f.Attribute, Operator: f.Operator, Value: f.Value}, nil
+}
+
+func scimUserData(req *entities.SCIMUser) (*entities.User, error) {
+	email, err := userEmail(req.UserName)
+	if err != nil {
+		return nil, scimError(http.StatusBadRequest, "invalidValue", "userName must be an email address")
+	}
+	// omitted active means the user is active
+	disabled := req.Active != nil && !*req.Active
+	return &entities.User{Email: email, ExternalID: req.ExternalID, Disabled: disabled}, nil
+}
+
+func scimGroupData(req *entities.SCIMGroup) (*entities.Group, []string, error) {
+	// group names end up in token claims just like role names
+	if !roleNameRe.MatchString(req.DisplayName) {
+		return nil, nil, scimError(http.StatusBadRequest, "invalidValue", "invalid displayName")
+	}
+	members, err := memberGuids(req.Members, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &entities.Group{Name: req.DisplayName, ExternalID: req.ExternalID}, members, nil
+}
+
+// appends guids of members not in the list yet
+func memberGuids(members []entities.SCIMMember, list []string) ([]string, error) {
+	if list == nil {
+		list = []string{}
+	}
+	for _, member := range members {
+		guid, err := uuid.Parse(member.Value)
+		if err != nil {
+			return nil, scimError(http.StatusBadRequest, "invalidValue", "member value must be a user id")
+		}
+		if !slices.Contains(list, guid.String()) {
+			list = append(list, guid.String())
+		}
+	}
+	return list, nil
+}
+
+func checkPatchRequest(req *entities.SCIMPatchRequest) error {
+	if !slices.Contains(req.Schemas, entities.SCIMSchemaPatchOp) || len(req.Operations) == 0 {
+		return scimError(http.StatusBadRequest, "invalidSyntax", "patch request must use PatchOp schema and have operations")
+	}
+	for _, operation := range req.Operations {
+		switch strings.ToLower(operation.Op) {
+		case "add", "remove", "replace":
+		default:
+			return scimError(http.StatusBadRequest, "invalidSyntax", "unsupported patch operation "+operation.Op)
+		}
+	}
+	return nil
+}
+
+// applies operation to user, attributes we don't store are ignored so providers can send full profiles.
+// Paths selecting values or sub-attributes are refused since nothing they address is stored
+func patchUser(user *entities.User, operation *entities.SCIMPatchOperation) error {
+	op := strings.ToLower(operation.Op)
+	if operation.Path == "" {
+		if op == "remove" {
+			return scimError(http.StatusBadRequest, "noTarget", "remove operation requires path")
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &values); err != nil {
+			return scimError(http.StatusBadRequest, "invalidValue", "operation without path requires object value")
+		}
+		for attr, value := range values {
+			if err := patchUserAttribute(user, op, attr, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	path, err := scim.ParsePath(operation.Path)
+	if err != nil {
+		return scimError(http.StatusBadRequest, "invalidPath", err.Error())
+	}
+	// stored attributes are single-valued, the rest have no values a filter could select
+	if path.Filter != nil {
+		if userAttrStored(path.Attribute) {
+			return scimError(http.StatusBadRequest, "invalidPath", "filter can't be applied to "+path.Attribute)
+		}
+		return scimError(http.StatusBadRequest, "noTarget", "no values of "+path.Attribute+" match the filter")
+	}
+	if path.SubAttribute != "" {
+		return scimError(http.StatusBadRequest, "invalidPath", "sub-attributes of user can't be patched")
+	}
+	return patchUserAttribute(user, op, path.Attribute, operation.Value)
+}
+
+func userAttrStored(attr string) bool {
+	switch strings.ToLower(attr) {
+	case strings.ToLower(entities.SCIMAttrUserName), strings.ToLower(entities.SCIMAttrExternalID), strings.ToLower(entities.SCIMAttrActive):
+		return true
+	}
+	return false
+}
+
+func patchUserAttribute(user *entities.User, op, attr string, value json.RawMessage) error {
+	switch strings.ToLower(attr) {
+	case strings.ToLower(entities.SCIMAttrUserName):
+		var userName string
+		if op == "remove" || json.Unmarshal(value, &userName) != nil {
+			return scimError(http.StatusBadRequest, "invalidValue", "userName must be an email address")
+		}
+		email, err := userEmail(userName)
+		if err != nil {
+			return scimError(http.StatusBadRequest, "invalidValue", "userName must be an email address")
+		}
+		user.Email = email
+	case strings.ToLower(entities.SCIMAttrExternalID):
+		if op == "remove" {
+			user.ExternalID = nil
+			return nil
+		}
+		var externalID string
+		if json.Unmarshal(value, &externalID) != nil {
+			return scimError(http.StatusBadRequest, "invalidValue", "externalId must be a string")
+		}
+		user.ExternalID = &externalID
+	case strings.ToLower(entities.SCIMAttrActive):
+		active, ok := scimBool(value)
+		if op == "remove" || !ok {
+			return scimError(http.StatusBadRequest, "invalidValue", "active must be a boolean")
+		}
+		user.Disabled = !active
+	}
+	return nil
+}
+
+// some providers send booleans as "True" and "False" strings
+func scimBool(value json.RawMessage) (bool, bool) {
+	var b bool
+	if json.Unmarshal(value, &b) == nil {
+		return b, true
+	}
+	var s string
+	if json.Unmarshal(value, &s) != nil {
+		return false, false
+	}
+	switch strings.ToLower(s) {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	return false, false
+}
+
+// applies operation to group and returns new member list
+func patchGroup(group *entities.Group, members []string, operation *entities.SCIMPatchOperation) ([]string, error) {
+	op := strings.ToLower(operation.Op)
+	if operation.Path == "" {
+		if op == "remove" {
+			return nil, scimError(http.StatusBadRequest, "noTarget", "remove operation requires path")
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &values); err != nil {
+			return nil, scimError(http.StatusBadRequest, "invalidValue", "operation without path requires object value")
+		}
+		for attr, value := range values {
+			var err error
+			if members, err = patchGroupAttribute(group, members, op, attr, nil, value); err != nil {
+				return nil, err
+			}
+		}
+		return members, nil
+	}
+
+	path, err := scim.ParsePath(operation.Path)
+	if err != nil {
+		return nil, scimError(http.StatusBadRequest, "invalidPath", err.Error())
+	}
+	if path.SubAttribute != "" {
+		return nil, scimError(http.StatusBadRequest, "invalidPath", "sub-attributes of group can't be patched")
+	}
+	return patchGroupAttribute(group, members, op, path.Attribute, path.Filter, operation.Value)
+}
+
+func patchGroupAttribute(group *entities.Group, members []string, op, attr string, filter *scim.Filter, value json.RawMessage) ([]string, error) {
+	switch strings.ToLower(attr) {
+	case strings.ToLower(entities.SCIMAttrDisplayName):
+		var name string
+		if op == "remove" || json.Unmarshal(value, &name) != nil {
+			return nil, scimError(http.StatusBadRequest, "invalidValue", "invalid displayName")
+		}
+		group.Name = name
+	case strings.ToLower(entities.SCIMAttrExternalID):
+		if op == "remove" {
+			group.ExternalID = nil
+			return members, nil
+		}
+		var externalID string
+		if json.Unmarshal(value, &externalID) != nil {
+			return nil, scimError(http.StatusBadRequest, "invalidValue", "externalId must be a string")
+		}
+		group.ExternalID = &externalID
+	case "members":
+		return patchMembers(members, op, filter, value)
+	default:
+		return nil, scimError(http.StatusBadRequest, "invalidPath", "unsupported attribute "+attr)
+	}
+	return members, nil
+}
+
+func patchMembers(members []string, op string, filter *scim.Filter, value json.RawMessage) ([]string, error) {
+	if filter != nil {
+		// members[value eq "id"] is the only selector members can be addressed with
+		if op != "remove" || !strings.EqualFold(filter.Attribute, "value") || filter.Operator != "eq" {
+			return nil, scimError(http.StatusBadRequest, "invalidFilter", "only remove of members[value eq \"id\"] is supported")
+		}
+		return slices.DeleteFunc(members, func(m string) bool { return strings.EqualFold(m, filter.Value) }), nil
+	}
+
+	var values []entities.SCIMMember
+	if len(value) != 0 {
+		if err := json.Unmarshal(value, &values); err != nil {
+			return nil, scimError(http.StatusBadRequest, "invalidValue", "members must be a list of user ids")
+		}
+	}
+	switch op {
+	case "add":
+		return memberGuids(values, members)
+	case "replace":
+		return memberGuids(values, nil)
+	}
+	// remove without value drops every member
+	if len(values) == 0 {
+		return []string{}, nil
+	}
+	removed, err := memberGuids(values, nil)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(members, func(m string) bool { return slices.Contains(removed, m) }), nil
+}
@@ -0,0 +1,550 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	jwtp "testovoe_medods/lib/jwt"
+	repo "testovoe_medods/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrTenantNotFound = errors.New("tenant doesn't exist")
+var ErrTenantAlreadyExists = errors.New("tenant with this id already exists")
+var ErrTenantMemberNotFound = errors.New("user isn't a member of the tenant")
+var ErrTenantIssuerTaken = errors.New("issuer is already used by another tenant")
+var ErrSigningKeyNotFound = errors.New("signing key doesn't exist")
+
+// tenant ids end up in token claims and urls
+var tenantIDRe = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+var supportedLoginMethods = []string{
+	entities.LoginMethodGuid,
+	entities.LoginMethodPasskey,
+	entities.LoginMethodPasswordless,
+	entities.LoginMethodFederation,
+}
+
+type InvalidTenantRequestError struct {
+	Reason string
+}
+
+func (e *InvalidTenantRequestError) Error() string {
+	return e.Reason
+}
+
+// user isn't allowed to hold a session in the tenant: not a member, login method or address rejected by its policy
+type TenantAccessError struct {
+	Reason string
+}
+
+func (e *TenantAccessError) Error() string {
+	return e.Reason
+}
+
+type TenantService interface {
+	CreateTenant(ctx context.Context, req *entities.TenantRequest) (*entities.Tenant, error)
+	GetTenant(ctx context.Context, id string) (*entities.Tenant, error)
+	ListTenants(ctx context.Context) ([]entities.Tenant, error)
+	UpdateTenant(ctx context.Context, id string, req *entities.TenantRequest) (*entities.Tenant, error)
+	DeleteTenant(ctx context.Context, id string) error
+	ListMembers(ctx context.Context, tenantID string) ([]entities.TenantMember, error)
+	// adds user to tenant or changes role of existing member
+	SaveMember(ctx context.Context, tenantID string, req *entities.TenantMemberRequest) (*entities.TenantMember, error)
+	// sessions user has in the tenant end together with membership
+	RemoveMember(ctx context.Context, tenantID string, userGuid uuid.UUID) error
+	// checks that user may hold session in tenant when connecting from address and returns tenant settings,
+	// every user is a member of the default tenant
+	Authorize(ctx context.Context, tenantID string, userGuid uuid.UUID, ipAddr string) (*entities.Tenant, error)
+	// generates new active signing key, tenant tokens get its issuer from now on
+	CreateSigningKey(ctx context.Context, tenantID string) (*entities.TenantSigningKey, error)
+	ListSigningKeys(ctx context.Context, tenantID string) ([]entities.TenantSigningKey, error)
+	// tokens signed with the key stop being accepted
+	DeleteSigningKey(ctx context.Context, tenantID, kid string) error
+	// loads issuers and keys of all tenants into token signer
+	LoadSigningKeys(ctx context.Context) error
+	// periodically reloads signing keys so changes made through other instances are picked up
+	Run(ctx context.Context)
+}
+
+type tenantService struct {
+	cfg      *config.Config
+	log      *slog.Logger
+	repo     repo.TenantRepository
+	userRepo repo.UserRepository
+}
+
+func NewTenantService(log *slog.Logger, cfg *config.Config, repo repo.TenantRepository, userRepo repo.UserRepository) TenantService {
+	return &tenantService{
+		cfg:      cfg,
+		log:      log,
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+func (ts *tenantService) CreateTenant(ctx context.Context, req *entities.TenantRequest) (*entities.Tenant, error) {
+	const op = "service.CreateTenant"
+	ts.log.Info(op, slog.String("msg", "Creating tenant"), slog.String("id", req.ID))
+
+	if !tenantIDRe.MatchString(req.ID) {
+		return nil, &InvalidTenantRequestError{Reason: "tenant id must be lowercase letters, digits and dashes"}
+	}
+	tenant, err := tenantFromRequest(req.ID, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := ts.checkIssuer(ctx, tenant); err != nil {
+		return nil, err
+	}
+
+	created, err := ts.repo.CreateTenant(ctx, tenant)
+	if errors.Is(err, repo.ErrEntityAlreadyExists) {
+		return nil, ErrTenantAlreadyExists
+	}
+	if err != nil {
+		ts.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return created, nil
+}
+
+func (ts *tenantService) GetTenant(ctx context.Context, id string) (*entities.Tenant, error) {
+	const op = "service.GetTenant"
+
+	tenant, err := ts.repo.GetTenant(ctx, id)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrTenantNotFound
+	}
+	if err != nil {
+		ts.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return tenant, nil
+}
+
+func (ts *tenantService) ListTenants(ctx context.Context) ([]entities.Tenant, error) {
+	const op = "service.ListTenants"
+
+	tenants, err := ts.repo.ListTenants(ctx)
+	if err != nil {
+		ts.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return tenants, nil
+}
+
+func (ts *tenantService) UpdateTenant(ctx context.Context, id string, req *entities.TenantRequest) (*entities.Tenant, error) {
+	const op = "service.UpdateTenant"
+	ts.log.Info(op, slog.String("msg", "Updating tenant"), slog.String("id", id))
+
+	tenant, err := tenantFromRequest(id, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := ts.checkIssuer(ctx, tenant); err != nil {
+		return nil, err
+	}
+
+	updated, err := ts.repo.UpdateTenant(ctx, tenant)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrTenantNotFound
+	}
+	if err != nil {
+		ts.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	ts.reloadSigningKeys(ctx)
+	return updated, nil
+}
+
+func (ts *tenantService) DeleteTenant(ctx context.Context, id string) error {
+	const op = "service.DeleteTenant"
+	ts.log.Info(op, slog.String("msg", "Deleting tenant"), slog.String("id", id))
+
+	if id == entities.DefaultTenantID {
+		return &InvalidTenantRequestError{Reason: "default tenant can't be deleted"}
+	}
+
+	err := ts.repo.DeleteTenant(ctx, id)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrTenantNotFound
+	}
+	if err != nil {
+		ts.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	ts.reloadSigningKeys(ctx)
+	return nil
+}
+
+func (ts *tenantService) ListMembers(ctx context.Context, tenantID string) ([]entities.TenantMember, error) {
+	const op = "service.ListTenantMembers"
+
+	if _, err := ts.GetTenant(ctx, tenantID); err != nil {
+		return nil, err
+	}
+
+	members, err := ts.repo.ListMembers(ctx, tenantID)
+	if err != nil {
+		ts.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return members, nil
+}
+
+func (ts *tenantService) SaveMember(ctx context.Context, tenantID string, req *entities.TenantMemberRequest) (*entities.TenantMember, error) {
+	const op = "service.SaveTenantMember"
+	ts.log.Info(op, slog.String("msg", "Saving tenant member"), slog.String("tenant", tenantID), slog.String("user", req.UserGuid.String()))
+
+	role := req.Role
+	if role == "" {
+		role = entities.TenantRoleMember
+	}
+	if role != entities.TenantRoleMember && role != entities.TenantRoleAdmin {
+		return nil, &InvalidTenantRequestError{Reason: "unsupported member role " + role}
+	}
+
+	if _, err := ts.GetTenant(ctx, tenantID); err != nil {
+		return nil, err
+	}
+	_, err := ts.userRepo.GetUserById(ctx, req.UserGuid)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrNoUserFound
+	}
+	if err != nil {
+		ts.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := ts.repo.SaveMember(ctx, tenantID, req.UserGuid, role); err != nil {
+		ts.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	member, err := ts.repo.GetMember(ctx, tenantID, req.UserGuid)
+	if err != nil {
+		ts.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return member, nil
+}
+
+func (ts *tenantService) RemoveMember(ctx context.Context, tenantID string, userGuid uuid.UUID) error {
+	const op = "service.RemoveTenantMember"
+	ts.log.Info(op, slog.String("msg", "Removing tenant member"), slog.String("tenant", tenantID), slog.String("user", userGuid.String()))
+
+	err := ts.repo.RemoveMember(ctx, tenantID, userGuid)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrTenantMemberNotFound
+	}
+	if err != nil {
+		ts.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (ts *tenantService) Authorize(ctx context.Context, tenantID string, userGuid uuid.UUID, ipAddr string) (*entities.Tenant, error) {
+	const op = "service.AuthorizeTenant"
+
+	tenant, err := ts.repo.GetTenant(ctx, tenantID)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, &TenantAccessError{Reason: "tenant doesn't exist"}
+	}
+	if err != nil {
+		ts.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tenantID != entities.DefaultTenantID {
+		_, err := ts.repo.GetMember(ctx, tenantID, userGuid)
+		if errors.Is(err, repo.ErrEntityNotExists) {
+			return nil, &TenantAccessError{Reason: "user isn't a member of the tenant"}
+		}
+		if err != nil {
+			ts.log.Error(op, slog.String("error", err.Error()))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if !tenantAllowsIP(tenant, ipAddr) {
+		return nil, &TenantAccessError{Reason: "address isn't allowed by tenant policy"}
+	}
+	return tenant, nil
+}
+
+func (ts *tenantService) CreateSigningKey(ctx context.Context, tenantID string) (*entities.TenantSigningKey, error) {
+	const op = "service.CreateTenantSigningKey"
+	ts.log.Info(op, slog.String("msg", "Creating tenant signing key"), slog.String("tenant", tenantID))
+
+	if tenantID == entities.DefaultTenantID {
+		return nil, &InvalidTenantRequestError{Reason: "default tenant tokens are signed with server keys"}
+	}
+	if _, err := ts.GetTenant(ctx, tenantID); err != nil {
+		return nil, err
+	}
+
+	signingKey, err := jwtp.NewSigningKey()
+	if err != nil {
+		ts.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	key, err := ts.repo.CreateSigningKey(ctx, &entities.TenantSigningKey{
+		ID:         signingKey.ID,
+		TenantID:   tenantID,
+		PrivateKey: string(signingKey.EncodePEM()),
+	})
+	if err != nil {
+		ts.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	key.Active = true
+
+	ts.reloadSigningKeys(ctx)
+	return key, nil
+}
+
+func (ts *tenantService) ListSigningKeys(ctx context.Context, tenantID string) ([]entities.TenantSigningKey, error) {
+	const op = "service.ListTenantSigningKeys"
+
+	if _, err := ts.GetTenant(ctx, tenantID); err != nil {
+		return nil, err
+	}
+
+	keys, err := ts.repo.ListSigningKeys(ctx, tenantID)
+	if err != nil {
+		ts.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(keys) > 0 {
+		keys[0].Active = true
+	}
+	return keys, nil
+}
+
+func (ts *tenantService) DeleteSigningKey(ctx context.Context, tenantID, kid string) error {
+	const op = "service.DeleteTenantSigningKey"
+	ts.log.Info(op, slog.String("msg", "Deleting tenant signing key"), slog.String("tenant", tenantID), slog.String("kid", kid))
+
+	err := ts.repo.DeleteSigningKey(ctx, tenantID, kid)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrSigningKeyNotFound
+	}
+	if err != nil {
+		ts.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	ts.reloadSigningKeys(ctx)
+	return nil
+}
+
+func (ts *tenantService) LoadSigningKeys(ctx context.Context) error {
+	const op = "service.LoadTenantSigningKeys"
+
+	tenants, err := ts.repo.ListTenants(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	keys, err := ts.repo.ListAllSigningKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	sets := make(map[string]*jwtp.TenantKeySet, len(tenants))
+	for i := range tenants {
+		sets[tenants[i].ID] = &jwtp.TenantKeySet{Tenant: tenants[i].ID, Issuer: tenantIssuer(ts.cfg, &tenants[i])}
+	}
+	for _, key := range keys {
+		set, ok := sets[key.TenantID]
+		if !ok {
+			continue
+		}
+		signingKey, err := jwtp.ParseSigningKey([]byte(key.PrivateKey))
+		if err != nil {
+			ts.log.Error(op, slog.String("error", err.Error()), slog.String("kid", key.ID))
+			continue
+		}
+		set.Keys = append(set.Keys, signingKey)
+	}
+
+	list := make([]*jwtp.TenantKeySet, 0, len(sets))
+	for _, set := range sets {
+		list = append(list, set)
+	}
+	jwtp.SetTenantKeySets(list)
+	return nil
+}
+
+func (ts *tenantService) Run(ctx context.Context) {
+	ticker := time.NewTicker(ts.cfg.Tenants.KeyReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ts.reloadSigningKeys(ctx)
+		}
+	}
+}
+
+// failed reload keeps previous keys, the next tick retries
+func (ts *tenantService) reloadSigningKeys(ctx context.Context) {
+	if err := ts.LoadSigningKeys(ctx); err != nil {
+		ts.log.Error("service.reloadTenantSigningKeys", slog.String("error", err.Error()))
+	}
+}
+
+// issuer must identify a single tenant and differ from the server one
+func (ts *tenantService) checkIssuer(ctx context.Context, tenant *entities.Tenant) error {
+	const op = "service.checkTenantIssuer"
+
+	if tenant.Issuer == nil {
+		return nil
+	}
+	if tenant.ID == entities.DefaultTenantID {
+		return &InvalidTenantRequestError{Reason: "default tenant tokens are issued by server issuer"}
+	}
+	if strings.TrimSuffix(*tenant.Issuer, "/") == strings.TrimSuffix(ts.cfg.OIDC.Issuer, "/") {
+		return ErrTenantIssuerTaken
+	}
+
+	other, err := ts.repo.GetTenantByIssuer(ctx, *tenant.Issuer)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil
+	}
+	if err != nil {
+		ts.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if other.ID != tenant.ID {
+		return ErrTenantIssuerTaken
+	}
+	return nil
+}
+
+// issuer of tenant with own signing keys, defaults to path of the tenant under server issuer
+func tenantIssuer(cfg *config.Config, tenant *entities.Tenant) string {
+	if tenant.Issuer != nil {
+		return *tenant.Issuer
+	}
+	return strings.TrimSuffix(cfg.OIDC.Issuer, "/") + "/tenants/" + tenant.ID
+}
+
+// issuer put into id and logout tokens of tenant clients, server issuer unless tenant has own keys
+func issuerOf(cfg *config.Config, tenantID string) string {
+	if set, ok := jwtp.TenantKeys(tenantID); ok {
+		return set.Issuer
+	}
+	return cfg.OIDC.Issuer
+}
+
+func tenantAllowsIP(tenant *entities.Tenant, ipAddr string) bool {
+	if len(tenant.AllowedIPs) == 0 {
+		return true
+	}
+	ip := net.ParseIP(ipAddr)
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range tenant.AllowedIPs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// sessions created without known method (e.g. device flow) are rejected by tenants that restrict methods
+func tenantAllowsMethod(tenant *entities.Tenant, method string) bool {
+	return len(tenant.LoginMethods) == 0 || slices.Contains(tenant.LoginMethods, method)
+}
+
+// fills zero lifetimes with tenant overrides, remaining zeros fall back to server defaults
+func tenantTokenTTL(tenant *entities.Tenant, ttl entities.TokenTTL) entities.TokenTTL {
+	if ttl.Access <= 0 && tenant.AccessTokenTTL != nil {
+		ttl.Access = time.Duration(*tenant.AccessTokenTTL) * time.Second
+	}
+	if ttl.Refresh <= 0 && tenant.RefreshTokenTTL != nil {
+		ttl.Refresh = time.Duration(*tenant.RefreshTokenTTL) * time.Second
+	}
+	return ttl
+}
+
+// tokens issued before tenants were introduced carry no tenant and belong to the default one
+func claimsTenant(claims *jwtp.CustomTokenClaims) string {
+	if claims.Tenant == "" {
+		return entities.DefaultTenantID
+	}
+	return claims.Tenant
+}
+
+// validates tenant settings, single addresses are stored as host networks
+func tenantFromRequest(id string, req *entities.TenantRequest) (*entities.Tenant, error) {
+	tenant := entities.Tenant{
+		ID:              id,
+		Name:            req.Name,
+		AccessTokenTTL:  req.AccessTokenTTL,
+		RefreshTokenTTL: req.RefreshTokenTTL,
+		LoginMethods:    entities.StringList{},
+		AllowedIPs:      entities.StringList{},
+	}
+	if tenant.Name == "" {
+		tenant.Name = id
+	}
+
+	if req.Issuer != "" {
+		issuer, err := url.Parse(req.Issuer)
+		if err != nil || (issuer.Scheme != "https" && issuer.Scheme != "http") || issuer.Host == "" ||
+			issuer.RawQuery != "" || issuer.Fragment != "" {
+			return nil, &InvalidTenantRequestError{Reason: "issuer must be http(s) url without query and fragment"}
+		}
+		tenant.Issuer = &req.Issuer
+	}
+
+	for _, ttl := range []*int64{req.AccessTokenTTL, req.RefreshTokenTTL} {
+		if ttl != nil && *ttl <= 0 {
+			return nil, &InvalidTenantRequestError{Reason: "token ttl must be positive"}
+		}
+	}
+
+	for _, method := range req.LoginMethods {
+		if !slices.Contains(supportedLoginMethods, method) {
+			return nil, &InvalidTenantRequestError{Reason: "unsupported login method " + method}
+		}
+		if !slices.Contains(tenant.LoginMethods, method) {
+			tenant.LoginMethods = append(tenant.LoginMethods, method)
+		}
+	}
+
+	for _, addr := range req.AllowedIPs {
+		if _, network, err := net.ParseCIDR(addr); err == nil {
+			tenant.AllowedIPs = append(tenant.AllowedIPs, network.String())
+			continue
+		}
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return nil, &InvalidTenantRequestError{Reason: "invalid address " + addr}
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		tenant.AllowedIPs = append(tenant.AllowedIPs, (&net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}).String())
+	}
+
+	return &tenant, nil
+}
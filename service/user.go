@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	netmail "net/mail"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	repo "testovoe_medods/repository"
+
+	"github.com/google/uuid"
+)
+
+var ErrUserDisabled = errors.New("user is disabled")
+var ErrUserAlreadyExists = errors.New("user with this email already exists")
+var ErrInvalidPage = errors.New("limit and offset can't be negative")
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+type InvalidUserRequestError struct {
+	Reason string
+}
+
+func (e *InvalidUserRequestError) Error() string {
+	return e.Reason
+}
+
+// user management for administrators, every method works only with users of the given tenant
+type UserService interface {
+	CreateUser(ctx context.Context, tenantID string, req *entities.UserRequest) (*entities.User, error)
+	GetUser(ctx context.Context, tenantID string, guid uuid.UUID) (*entities.User, error)
+	ListUsers(ctx context.Context, filter *entities.UserFilter) (*entities.UserPage, error)
+	UpdateUser(ctx context.Context, tenantID string, guid uuid.UUID, req *entities.UserRequest) (*entities.User, error)
+	// disabled user loses every session and can't log in until enabled again
+	DisableUser(ctx context.Context, tenantID string, guid uuid.UUID) (*entities.User, error)
+	EnableUser(ctx context.Context, tenantID string, guid uuid.UUID) (*entities.User, error)
+	DeleteUser(ctx context.Context, tenantID string, guid uuid.UUID) error
+}
+
+type userService struct {
+	cfg  *config.Config
+	log  *slog.Logger
+	repo repo.UserRepository
+}
+
+func NewUserService(log *slog.Logger, cfg *config.Config, repo repo.UserRepository) UserService {
+	return &userService{
+		cfg:  cfg,
+		log:  log,
+		repo: repo,
+	}
+}
+
+func (us *userService) CreateUser(ctx context.Context, tenantID string, req *entities.UserRequest) (*entities.User, error) {
+	const op = "service.CreateUser"
+	us.log.Info(op, slog.String("msg", "Creating user"))
+
+	email, err := userEmail(req.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := us.repo.CreateTenantUser(ctx, tenantID, email)
+	if errors.Is(err, repo.ErrEntityAlreadyExists) {
+		return nil, ErrUserAlreadyExists
+	}
+	if err != nil {
+		us.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return user, nil
+}
+
+func (us *userService) GetUser(ctx context.Context, tenantID string, guid uuid.UUID) (*entities.User, error) {
+	const op = "service.GetUser"
+
+	user, err := us.repo.GetTenantUser(ctx, tenantID, guid)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrNoUserFound
+	}
+	if err != nil {
+		us.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return user, nil
+}
+
+func (us *userService) ListUsers(ctx context.Context, filter *entities.UserFilter) (*entities.UserPage, error) {
+	const op = "service.ListUsers"
+
+	page := *filter
+	limit, err := pageLimit(page.Limit, page.Offset)
+	if err != nil {
+		return nil, err
+	}
+	page.Limit = limit
+
+	users, total, err := us.repo.ListUsers(ctx, &page)
+	if err != nil {
+		us.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &entities.UserPage{
+		Users:  users,
+		Total:  total,
+		Limit:  page.Limit,
+		Offset: page.Offset,
+	}, nil
+}
+
+func (us *userService) UpdateUser(ctx context.Context, tenantID string, guid uuid.UUID, req *entities.UserRequest) (*entities.User, error) {
+	const op = "service.UpdateUser"
+	us.log.Info(op, slog.String("msg", "Updating user"), slog.String("guid", guid.String()))
+
+	email, err := userEmail(req.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := us.repo.UpdateUser(ctx, tenantID, guid, email)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrNoUserFound
+	}
+	if errors.Is(err, repo.ErrEntityAlreadyExists) {
+		return nil, ErrUserAlreadyExists
+	}
+	if err != nil {
+		us.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return user, nil
+}
+
+func (us *userService) DisableUser(ctx context.Context, tenantID string, guid uuid.UUID) (*entities.User, error) {
+	return us.setDisabled(ctx, tenantID, guid, true)
+}
+
+func (us *userService) EnableUser(ctx context.Context, tenantID string, guid uuid.UUID) (*entities.User, error) {
+	return us.setDisabled(ctx, tenantID, guid, false)
+}
+
+func (us *userService) setDisabled(ctx context.Context, tenantID string, guid uuid.UUID, disabled bool) (*entities.User, error) {
+	const op = "service.SetUserDisabled"
+	us.log.Info(op, slog.String("guid", guid.String()), slog.Bool("disabled", disabled))
+
+	user, err := us.repo.SetUserDisabled(ctx, tenantID, guid, disabled)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrNoUserFound
+	}
+	if err != nil {
+		us.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return user, nil
+}
+
+func (us *userService) DeleteUser(ctx context.Context, tenantID string, guid uuid.UUID) error {
+	const op = "service.DeleteUser"
+	us.log.Info(op, slog.String("msg", "Deleting user"), slog.String("guid", guid.String()))
+
+	err := us.repo.DeleteUser(ctx, tenantID, guid)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrNoUserFound
+	}
+	if err != nil {
+		us.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// email in bare address form, display names aren't stored
+func userEmail(raw string) (string, error) {
+	address, err := netmail.ParseAddress(raw)
+	if err != nil || address.Name != "" || address.Address != raw {
+		return "", &InvalidUserRequestError{Reason: "invalid email"}
+	}
+	return address.Address, nil
+}
+
+// limit of requested page, zero means default page size
+func pageLimit(limit, offset int) (int, error) {
+	if limit < 0 || offset < 0 {
+		return 0, ErrInvalidPage
+	}
+	if limit == 0 {
+		return defaultPageSize, nil
+	}
+	return min(limit, maxPageSize), nil
+}
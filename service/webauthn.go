@@ -0,0 +1,387 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	crypt "testovoe_medods/lib/bcrypt"
+	jwtp "testovoe_medods/lib/jwt"
+	"testovoe_medods/lib/webauthn"
+	repo "testovoe_medods/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const MFAMethodWebAuthn = "webauthn"
+
+var ErrInvalidWebAuthnChallenge = errors.New("webauthn challenge is invalid or expired")
+var ErrWebAuthnVerification = errors.New("webauthn verification failed")
+var ErrCredentialAlreadyRegistered = errors.New("credential is already registered")
+var ErrCredentialNotFound = errors.New("credential wasn't found")
+
+type WebAuthnService interface {
+	BeginRegistration(ctx context.Context, userGuid uuid.UUID) (*entities.CredentialCreationOptions, error)
+	FinishRegistration(ctx context.Context, userGuid uuid.UUID, req *entities.WebAuthnRegistrationRequest) (*entities.WebAuthnCredential, error)
+	BeginLogin(ctx context.Context, req *entities.WebAuthnLoginBeginRequest) (*entities.CredentialRequestOptions, error)
+	FinishLogin(ctx context.Context, req *entities.WebAuthnLoginFinishRequest) (*entities.TokenPair, error)
+	ListCredentials(ctx context.Context, userGuid uuid.UUID) ([]entities.WebAuthnCredential, error)
+	DeleteCredential(ctx context.Context, userGuid, id uuid.UUID) error
+}
+
+type webAuthnService struct {
+	cfg         *config.Config
+	log         *slog.Logger
+	repo        repo.WebAuthnRepository
+	authRepo    repo.AuthRepository
+	authService AuthService
+	mfa         MFAService
+	lockout     LockoutService
+	rp          *webauthn.RelyingParty
+}
+
+func NewWebAuthnService(log *slog.Logger, cfg *config.Config, repo repo.WebAuthnRepository, authRepo repo.AuthRepository, authService AuthService, mfa MFAService, lockout LockoutService) WebAuthnService {
+	return &webAuthnService{
+		cfg:         cfg,
+		log:         log,
+		repo:        repo,
+		authRepo:    authRepo,
+		authService: authService,
+		mfa:         mfa,
+		lockout:     lockout,
+		rp: &webauthn.RelyingParty{
+			ID:                      cfg.WebAuthn.RPID,
+			Name:                    cfg.WebAuthn.RPName,
+			Origins:                 cfg.WebAuthn.Origins,
+			RequireUserVerification: cfg.WebAuthn.RequireUserVerification,
+		},
+	}
+}
+
+func (ws *webAuthnService) BeginRegistration(ctx context.Context, userGuid uuid.UUID) (*entities.CredentialCreationOptions, error) {
+	const op = "service.BeginRegistration"
+	ws.log.Info(op, slog.String("msg", "Starting webauthn registration"))
+
+	user, err := ws.authRepo.GetAuthInfoByUserGuid(ctx, entities.DefaultTenantID, userGuid.String())
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrNoUserFound
+	}
+	if err != nil {
+		ws.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	creds, err := ws.repo.GetCredentialsByUserGuid(ctx, userGuid)
+	if err != nil {
+		ws.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	challenge, err := ws.newChallenge(ctx, &userGuid, entities.WebAuthnCeremonyRegistration)
+	if err != nil {
+		ws.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &entities.CredentialCreationOptions{
+		RP: entities.WebAuthnRelyingParty{
+			ID:   ws.rp.ID,
+			Name: ws.rp.Name,
+		},
+		User: entities.WebAuthnUser{
+			ID:          base64.RawURLEncoding.EncodeToString(userGuid[:]),
+			Name:        user.Email,
+			DisplayName: user.Email,
+		},
+		Challenge: challenge,
+		PubKeyCredParams: []entities.WebAuthnCredentialParam{
+			{Type: "public-key", Alg: webauthn.AlgES256},
+			{Type: "public-key", Alg: webauthn.AlgEdDSA},
+			{Type: "public-key", Alg: webauthn.AlgRS256},
+		},
+		Timeout:            ws.cfg.WebAuthn.ChallengeTTL.Milliseconds(),
+		ExcludeCredentials: descriptors(creds),
+		AuthenticatorSelection: entities.WebAuthnAuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: ws.userVerification(),
+		},
+		Attestation: "direct",
+	}, nil
+}
+
+func (ws *webAuthnService) FinishRegistration(ctx context.Context, userGuid uuid.UUID, req *entities.WebAuthnRegistrationRequest) (*entities.WebAuthnCredential, error) {
+	const op = "service.FinishRegistration"
+	ws.log.Info(op, slog.String("msg", "Finishing webauthn registration"))
+
+	clientDataJSON, err := base64.RawURLEncoding.DecodeString(req.Response.ClientDataJSON)
+	if err != nil {
+		return nil, ErrWebAuthnVerification
+	}
+	attestationObject, err := base64.RawURLEncoding.DecodeString(req.Response.AttestationObject)
+	if err != nil {
+		return nil, ErrWebAuthnVerification
+	}
+
+	challenge, err := ws.consumeChallenge(ctx, clientDataJSON, entities.WebAuthnCeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.UserGuid == nil || *challenge.UserGuid != userGuid {
+		return nil, ErrInvalidWebAuthnChallenge
+	}
+
+	cred, err := ws.rp.VerifyRegistration(challenge.Challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		ws.log.Info(op, slog.String("error", err.Error()))
+		return nil, ErrWebAuthnVerification
+	}
+
+	name := req.Name
+	if name == "" {
+		name = "passkey"
+	}
+
+	data := entities.WebAuthnCredential{
+		UserGuid:       userGuid,
+		CredentialID:   base64.RawURLEncoding.EncodeToString(cred.ID),
+		PublicKey:      cred.PublicKey,
+		Alg:            cred.Alg,
+		SignCount:      int64(cred.SignCount),
+		AAGUID:         hex.EncodeToString(cred.AAGUID),
+		AttestationFmt: cred.AttestationFmt,
+		Name:           name,
+	}
+	err = ws.repo.CreateCredential(ctx, &data)
+	if errors.Is(err, repo.ErrEntityAlreadyExists) {
+		return nil, ErrCredentialAlreadyRegistered
+	}
+	if err != nil {
+		ws.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &data, nil
+}
+
+// starts assertion ceremony. Without mfa_token discoverable credentials are expected and allow list
+// stays empty, so options tell nothing about accounts. With mfa_token the ceremony is used as a second
+// factor for the challenged user
+func (ws *webAuthnService) BeginLogin(ctx context.Context, req *entities.WebAuthnLoginBeginRequest) (*entities.CredentialRequestOptions, error) {
+	const op = "service.BeginLogin"
+	ws.log.Info(op, slog.String("msg", "Starting webauthn login"))
+
+	var userGuid *uuid.UUID
+	if req.MFAToken != "" {
+		claims, err := jwtp.GetAndValidateMFAChallengeClaims(req.MFAToken)
+		if err != nil {
+			return nil, ErrInvalidMFAChallenge
+		}
+		guid, err := uuid.Parse(claims.Subject)
+		if err != nil {
+			return nil, ErrInvalidMFAChallenge
+		}
+		userGuid = &guid
+	}
+
+	allow := []entities.WebAuthnCredentialDescriptor{}
+	if userGuid != nil {
+		creds, err := ws.repo.GetCredentialsByUserGuid(ctx, *userGuid)
+		if err != nil {
+			ws.log.Error(op, slog.String("error", err.Error()))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		allow = descriptors(creds)
+	}
+
+	challenge, err := ws.newChallenge(ctx, userGuid, entities.WebAuthnCeremonyLogin)
+	if err != nil {
+		ws.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &entities.CredentialRequestOptions{
+		Challenge:        challenge,
+		Timeout:          ws.cfg.WebAuthn.ChallengeTTL.Milliseconds(),
+		RPID:             ws.rp.ID,
+		AllowCredentials: allow,
+		UserVerification: ws.userVerification(),
+	}, nil
+}
+
+func (ws *webAuthnService) FinishLogin(ctx context.Context, req *entities.WebAuthnLoginFinishRequest) (*entities.TokenPair, error) {
+	const op = "service.FinishLogin"
+	ws.log.Info(op, slog.String("msg", "Finishing webauthn login"))
+
+	ipKey := IPLockoutKey(req.IpAddr)
+	if err := ws.lockout.Check(ctx, ipKey); err != nil {
+		return nil, err
+	}
+
+	tokenPair, err := ws.finishLogin(ctx, req)
+	if errors.Is(err, ErrWebAuthnVerification) || errors.Is(err, ErrInvalidWebAuthnChallenge) || errors.Is(err, ErrInvalidMFAChallenge) {
+		ws.lockout.RegisterFailure(ctx, ipKey)
+	}
+	return tokenPair, err
+}
+
+func (ws *webAuthnService) finishLogin(ctx context.Context, req *entities.WebAuthnLoginFinishRequest) (*entities.TokenPair, error) {
+	const op = "service.finishLogin"
+
+	clientDataJSON, err := base64.RawURLEncoding.DecodeString(req.Response.ClientDataJSON)
+	if err != nil {
+		return nil, ErrWebAuthnVerification
+	}
+	authenticatorData, err := base64.RawURLEncoding.DecodeString(req.Response.AuthenticatorData)
+	if err != nil {
+		return nil, ErrWebAuthnVerification
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(req.Response.Signature)
+	if err != nil {
+		return nil, ErrWebAuthnVerification
+	}
+	userHandle, err := base64.RawURLEncoding.DecodeString(req.Response.UserHandle)
+	if err != nil {
+		return nil, ErrWebAuthnVerification
+	}
+
+	challenge, err := ws.consumeChallenge(ctx, clientDataJSON, entities.WebAuthnCeremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	cred, err := ws.repo.GetCredentialByCredentialId(ctx, req.ID)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrWebAuthnVerification
+	}
+	if err != nil {
+		ws.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if challenge.UserGuid != nil && *challenge.UserGuid != cred.UserGuid {
+		return nil, ErrWebAuthnVerification
+	}
+	if len(userHandle) > 0 && !bytes.Equal(userHandle, cred.UserGuid[:]) {
+		return nil, ErrWebAuthnVerification
+	}
+	var mfaClaims *jwtp.CustomTokenClaims
+	if req.MFAToken != "" {
+		mfaClaims, err = jwtp.GetAndValidateMFAChallengeClaims(req.MFAToken)
+		if err != nil || mfaClaims.Subject != cred.UserGuid.String() || mfaClaims.IpAddr != req.IpAddr {
+			return nil, ErrInvalidMFAChallenge
+		}
+	}
+
+	signCount, err := ws.rp.VerifyAssertion(challenge.Challenge, cred.PublicKey, uint32(cred.SignCount), clientDataJSON, authenticatorData, signature)
+	if err != nil {
+		ws.log.Info(op, slog.String("error", err.Error()))
+		return nil, ErrWebAuthnVerification
+	}
+
+	// counter that didn't grow in the database means a cloned authenticator won the race
+	err = ws.repo.UpdateSignCount(ctx, cred.ID, int64(signCount))
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		ws.log.Info(op, slog.String("error", "signature counter regression"), slog.String("credential", cred.ID.String()))
+		return nil, ErrWebAuthnVerification
+	}
+	if err != nil {
+		ws.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if mfaClaims != nil {
+		if err := ws.mfa.ConsumeChallenge(ctx, mfaClaims.ID, mfaClaims.ExpiresAt.Time); err != nil {
+			return nil, err
+		}
+	}
+
+	// passkey assertion is phishing resistant on its own, so no additional factor is asked
+	return ws.authService.ReleaseTokens(ctx, &entities.AuthenticateRequest{
+		Guid:      cred.UserGuid.String(),
+		IpAddr:    req.IpAddr,
+		MFAPassed: true,
+		Method:    entities.LoginMethodPasskey,
+	})
+}
+
+func (ws *webAuthnService) ListCredentials(ctx context.Context, userGuid uuid.UUID) ([]entities.WebAuthnCredential, error) {
+	const op = "service.ListCredentials"
+
+	creds, err := ws.repo.GetCredentialsByUserGuid(ctx, userGuid)
+	if err != nil {
+		ws.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return creds, nil
+}
+
+func (ws *webAuthnService) DeleteCredential(ctx context.Context, userGuid, id uuid.UUID) error {
+	const op = "service.DeleteCredential"
+
+	err := ws.repo.DeleteCredential(ctx, userGuid, id)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return ErrCredentialNotFound
+	}
+	if err != nil {
+		ws.log.Error(op, slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (ws *webAuthnService) newChallenge(ctx context.Context, userGuid *uuid.UUID, ceremony string) (string, error) {
+	challenge, err := crypt.GenerateOpaqueToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	err = ws.repo.CreateChallenge(ctx, &entities.WebAuthnChallenge{
+		Challenge: challenge,
+		UserGuid:  userGuid,
+		Ceremony:  ceremony,
+		ExpiresAt: time.Now().Add(ws.cfg.WebAuthn.ChallengeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+func (ws *webAuthnService) consumeChallenge(ctx context.Context, clientDataJSON []byte, ceremony string) (*entities.WebAuthnChallenge, error) {
+	const op = "service.consumeChallenge"
+
+	cd, err := webauthn.ParseClientData(clientDataJSON)
+	if err != nil {
+		return nil, ErrWebAuthnVerification
+	}
+
+	challenge, err := ws.repo.ConsumeChallenge(ctx, cd.Challenge, ceremony)
+	if errors.Is(err, repo.ErrEntityNotExists) {
+		return nil, ErrInvalidWebAuthnChallenge
+	}
+	if err != nil {
+		ws.log.Error(op, slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return challenge, nil
+}
+
+func (ws *webAuthnService) userVerification() string {
+	if ws.rp.RequireUserVerification {
+		return "required"
+	}
+	return "preferred"
+}
+
+func descriptors(creds []entities.WebAuthnCredential) []entities.WebAuthnCredentialDescriptor {
+	res := make([]entities.WebAuthnCredentialDescriptor, 0, len(creds))
+	for _, c := range creds {
+		res = append(res, entities.WebAuthnCredentialDescriptor{Type: "public-key", ID: c.CredentialID})
+	}
+	return res
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"testovoe_medods/config"
+	"testovoe_medods/entities"
+	"testovoe_medods/lib/webauthn/webauthntest"
+	repo "testovoe_medods/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	webAuthnTestRPID   = "example.com"
+	webAuthnTestOrigin = "https://example.com"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+type memWebAuthnRepo struct {
+	challenges map[string]entities.WebAuthnChallenge
+	creds      []entities.WebAuthnCredential
+}
+
+func (r *memWebAuthnRepo) CreateChallenge(ctx context.Context, data *entities.WebAuthnChallenge) error {
+	r.challenges[data.Challenge] = *data
+	return nil
+}
+
+func (r *memWebAuthnRepo) ConsumeChallenge(ctx context.Context, challenge, ceremony string) (*entities.WebAuthnChallenge, error) {
+	c, ok := r.challenges[challenge]
+	if !ok || c.Ceremony != ceremony || c.ExpiresAt.Before(time.Now()) {
+		return nil, repo.ErrEntityNotExists
+	}
+	delete(r.challenges, challenge)
+	return &c, nil
+}
+
+func (r *memWebAuthnRepo) CreateCredential(ctx context.Context, data *entities.WebAuthnCredential) error {
+	for _, c := range r.creds {
+		if c.CredentialID == data.CredentialID {
+			return repo.ErrEntityAlreadyExists
+		}
+	}
+	data.ID = uuid.New()
+	r.creds = append(r.creds, *data)
+	return nil
+}
+
+func (r *memWebAuthnRepo) GetCredentialsByUserGuid(ctx context.Context, userGuid uuid.UUID) ([]entities.WebAuthnCredential, error) {
+	res := []entities.WebAuthnCredential{}
+	for _, c := range r.creds {
+		if c.UserGuid == userGuid {
+			res = append(res, c)
+		}
+	}
+	return res, nil
+}
+
+func (r *memWebAuthnRepo) GetCredentialByCredentialId(ctx context.Context, credentialId string) (*entities.WebAuthnCredential, error) {
+	for _, c := range r.creds {
+		if c.CredentialID == credentialId {
+			return &c, nil
+		}
+	}
+	return nil, repo.ErrEntityNotExists
+}
+
+func (r *memWebAuthnRepo) UpdateSignCount(ctx context.Context, id uuid.UUID, signCount int64) error {
+	for i := range r.creds {
+		if r.creds[i].ID == id && (r.creds[i].SignCount < signCount || r.creds[i].SignCount == 0 && signCount == 0) {
+			r.creds[i].SignCount = signCount
+			return nil
+		}
+	}
+	return repo.ErrEntityNotExists
+}
+
+func (r *memWebAuthnRepo) DeleteCredential(ctx context.Context, userGuid, id uuid.UUID) error {
+	return repo.ErrEntityNotExists
+}
+
+type stubAuthRepo struct {
+	repo.AuthRepository
+	users map[uuid.UUID]string
+}
+
+func (r *stubAuthRepo) GetAuthInfoByUserGuid(ctx context.Context, tenantID, guid string) (*entities.UserWithAuthCreds, error) {
+	id, err := uuid.Parse(guid)
+	if err != nil || r.users[id] == "" {
+		return nil, repo.ErrEntityNotExists
+	}
+	return &entities.UserWithAuthCreds{User: entities.User{ID: id, Email: r.users[id]}}, nil
+}
+
+type stubAuthService struct {
+	AuthService
+	released []entities.AuthenticateRequest
+}
+
+func (s *stubAuthService) ReleaseTokens(ctx context.Context, authReq *entities.AuthenticateRequest) (*entities.TokenPair, error) {
+	s.released = append(s.released, *authReq)
+	return &entities.TokenPair{AccessToken: "access-" + authReq.Guid}, nil
+}
+
+type stubLockout struct {
+	LockoutService
+	failures int
+}
+
+func (l *stubLockout) Check(ctx context.Context, keys ...string) error {
+	return nil
+}
+
+func (l *stubLockout) RegisterFailure(ctx context.Context, keys ...string) {
+	l.failures++
+}
+
+type webAuthnFixture struct {
+	svc      WebAuthnService
+	repo     *memWebAuthnRepo
+	auth     *stubAuthService
+	lockout  *stubLockout
+	userGuid uuid.UUID
+	key      *webauthntest.Authenticator
+}
+
+func newWebAuthnFixture(t *testing.T) *webAuthnFixture {
+	t.Helper()
+	cfg := &config.Config{WebAuthn: config.WebAuthn{
+		RPID:                    webAuthnTestRPID,
+		RPName:                  "example",
+		Origins:                 []string{webAuthnTestOrigin},
+		ChallengeTTL:            time.Minute,
+		RequireUserVerification: true,
+	}}
+	key, err := webauthntest.NewAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &webAuthnFixture{
+		repo:     &memWebAuthnRepo{challenges: map[string]entities.WebAuthnChallenge{}},
+		auth:     &stubAuthService{},
+		lockout:  &stubLockout{},
+		userGuid: uuid.New(),
+		key:      key,
+	}
+	authRepo := &stubAuthRepo{users: map[uuid.UUID]string{f.userGuid: "user@example.com"}}
+	f.svc = NewWebAuthnService(testLogger(), cfg, f.repo, authRepo, f.auth, nil, f.lockout)
+	return f
+}
+
+func (f *webAuthnFixture) register(t *testing.T, origin, rpID string) (*entities.WebAuthnCredential, error) {
+	t.Helper()
+	ctx := context.Background()
+	opts, err := f.svc.BeginRegistration(ctx, f.userGuid)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+	clientData, attestation, err := f.key.Create(webauthntest.FormatPacked, rpID, origin, opts.Challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &entities.WebAuthnRegistrationRequest{ID: b64(f.key.CredentialID), Type: "public-key"}
+	req.Response.ClientDataJSON = b64(clientData)
+	req.Response.AttestationObject = b64(attestation)
+	return f.svc.FinishRegistration(ctx, f.userGuid, req)
+}
+
+func (f *webAuthnFixture) login(t *testing.T, origin, rpID string, tamper func(sig []byte)) (*entities.TokenPair, error) {
+	t.Helper()
+	ctx := context.Background()
+	opts, err := f.svc.BeginLogin(ctx, &entities.WebAuthnLoginBeginRequest{})
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	clientData, authData, sig, err := f.key.Get(rpID, origin, opts.Challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tamper != nil {
+		tamper(sig)
+	}
+	req := &entities.WebAuthnLoginFinishRequest{ID: b64(f.key.CredentialID), Type: "public-key", IpAddr: "127.0.0.1"}
+	req.Response.ClientDataJSON = b64(clientData)
+	req.Response.AuthenticatorData = b64(authData)
+	req.Response.Signature = b64(sig)
+	req.Response.UserHandle = b64(f.userGuid[:])
+	return f.svc.FinishLogin(ctx, req)
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestWebAuthnRegistrationAndLogin(t *testing.T) {
+	f := newWebAuthnFixture(t)
+
+	cred, err := f.register(t, webAuthnTestOrigin, webAuthnTestRPID)
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+	if cred.CredentialID != b64(f.key.CredentialID) || cred.AttestationFmt != webauthntest.FormatPacked {
+		t.Fatalf("unexpected credential %+v", cred)
+	}
+
+	for i := 1; i <= 2; i++ {
+		tokens, err := f.login(t, webAuthnTestOrigin, webAuthnTestRPID, nil)
+		if err != nil {
+			t.Fatalf("FinishLogin #%d: %v", i, err)
+		}
+		if tokens.AccessToken != "access-"+f.userGuid.String() {
+			t.Fatalf("tokens released for wrong user: %q", tokens.AccessToken)
+		}
+	}
+	if got := f.repo.creds[0].SignCount; got != 2 {
+		t.Fatalf("stored sign count = %d, want 2", got)
+	}
+	last := f.auth.released[len(f.auth.released)-1]
+	if !last.MFAPassed || last.Method != entities.LoginMethodPasskey {
+		t.Fatalf("passkey login released as %+v", last)
+	}
+}
+
+func TestWebAuthnRegistrationRejectsWrongOriginAndRPID(t *testing.T) {
+	for name, target := range map[string][2]string{
+		"origin": {"https://evil.example", webAuthnTestRPID},
+		"rp id":  {webAuthnTestOrigin, "evil.example"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f := newWebAuthnFixture(t)
+			if _, err := f.register(t, target[0], target[1]); !errors.Is(err, ErrWebAuthnVerification) {
+				t.Fatalf("err = %v, want %v", err, ErrWebAuthnVerification)
+			}
+			if len(f.repo.creds) != 0 {
+				t.Fatal("credential was stored")
+			}
+		})
+	}
+}
+
+func TestWebAuthnLoginRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		origin string
+		rpID   string
+		tamper func(sig []byte)
+	}{
+		{name: "bad signature", origin: webAuthnTestOrigin, rpID: webAuthnTestRPID, tamper: func(sig []byte) { sig[len(sig)-1]++ }},
+		{name: "wrong origin", origin: "https://evil.example", rpID: webAuthnTestRPID},
+		{name: "wrong rp id", origin: webAuthnTestOrigin, rpID: "evil.example"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newWebAuthnFixture(t)
+			if _, err := f.register(t, webAuthnTestOrigin, webAuthnTestRPID); err != nil {
+				t.Fatalf("FinishRegistration: %v", err)
+			}
+			if _, err := f.login(t, tt.origin, tt.rpID, tt.tamper); !errors.Is(err, ErrWebAuthnVerification) {
+				t.Fatalf("err = %v, want %v", err, ErrWebAuthnVerification)
+			}
+			if len(f.auth.released) != 0 {
+				t.Fatal("tokens were released")
+			}
+			if f.lockout.failures != 1 {
+				t.Fatalf("lockout failures = %d, want 1", f.lockout.failures)
+			}
+		})
+	}
+}
+
+func TestWebAuthnLoginRejectsSignCountRegression(t *testing.T) {
+	f := newWebAuthnFixture(t)
+	if _, err := f.register(t, webAuthnTestOrigin, webAuthnTestRPID); err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+	if _, err := f.login(t, webAuthnTestOrigin, webAuthnTestRPID, nil); err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+
+	// cloned authenticator replays the counter the original already used
+	f.key.SignCount = 0
+	if _, err := f.login(t, webAuthnTestOrigin, webAuthnTestRPID, nil); !errors.Is(err, ErrWebAuthnVerification) {
+		t.Fatalf("err = %v, want %v", err, ErrWebAuthnVerification)
+	}
+	if len(f.auth.released) != 1 {
+		t.Fatalf("released %d token pairs, want 1", len(f.auth.released))
+	}
+}
@@ -2,14 +2,349 @@ CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 
 CREATE TABLE users (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
-    email VARCHAR(255) UNIQUE NOT NULL
+    email VARCHAR(255) UNIQUE NOT NULL,
+    password_hash VARCHAR,
+    disabled BOOLEAN NOT NULL DEFAULT false,
+    external_id VARCHAR,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
 );
 
+-- existing databases may hold emails differing only by case, they have to be merged before the index
+-- is created: SELECT lower(email), array_agg(id) FROM users GROUP BY 1 HAVING count(*) > 1
+CREATE UNIQUE INDEX users_email_lower_key ON users (lower(email));
+
+CREATE TABLE tenants (
+    id VARCHAR PRIMARY KEY,
+    name VARCHAR NOT NULL DEFAULT '',
+    issuer VARCHAR UNIQUE,
+    access_token_ttl BIGINT,
+    refresh_token_ttl BIGINT,
+    login_methods JSONB NOT NULL DEFAULT '[]',
+    allowed_ips JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO tenants (id, name) VALUES ('default', 'Default');
+
 CREATE TABLE users_auth_info (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     user_guid UUID,
     refresh_token_hash VARCHAR,
     ip_address VARCHAR NOT NULL,
+    client_id VARCHAR,
+    scope VARCHAR,
+    sso_session_id UUID,
+    tenant_id VARCHAR NOT NULL DEFAULT 'default',
+    auth_method VARCHAR,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    impersonator_guid UUID,
+    expires_at TIMESTAMPTZ,
 	UNIQUE (user_guid, refresh_token_hash),
+    FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (impersonator_guid) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (sso_session_id) REFERENCES users_auth_info(id) ON DELETE CASCADE,
+    FOREIGN KEY (tenant_id) REFERENCES tenants(id) ON DELETE CASCADE
+);
+
+CREATE TABLE password_reset_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_guid UUID NOT NULL,
+    token_hash VARCHAR UNIQUE NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE users_totp (
+    user_guid UUID PRIMARY KEY,
+    secret_enc VARCHAR NOT NULL,
+    confirmed_at TIMESTAMPTZ,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE webauthn_credentials (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_guid UUID NOT NULL,
+    credential_id VARCHAR UNIQUE NOT NULL,
+    public_key BYTEA NOT NULL,
+    alg INTEGER NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    aaguid VARCHAR NOT NULL,
+    attestation_fmt VARCHAR NOT NULL,
+    name VARCHAR NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at TIMESTAMPTZ,
+    FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX webauthn_credentials_user_guid_idx ON webauthn_credentials (user_guid);
+
+CREATE TABLE webauthn_challenges (
+    challenge VARCHAR PRIMARY KEY,
+    user_guid UUID,
+    ceremony VARCHAR NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE mfa_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_guid UUID NOT NULL,
+    code_hash VARCHAR NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_guid, code_hash),
+    FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE auth_attempts (
+    key VARCHAR PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ
+);
+
+CREATE TABLE passwordless_logins (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_guid UUID NOT NULL,
+    state_hash VARCHAR UNIQUE NOT NULL,
+    token_hash VARCHAR UNIQUE,
+    code_hash VARCHAR,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    consumed_at TIMESTAMPTZ,
+    FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE federated_identities (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    provider VARCHAR NOT NULL,
+    subject VARCHAR NOT NULL,
+    user_guid UUID NOT NULL,
+    email VARCHAR,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_login_at TIMESTAMPTZ,
+    UNIQUE (provider, subject),
+    FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE federation_states (
+    state_hash VARCHAR PRIMARY KEY,
+    provider VARCHAR NOT NULL,
+    nonce VARCHAR NOT NULL,
+    code_verifier VARCHAR NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    link_user_guid UUID REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE oauth_authorization_codes (
+    code_hash VARCHAR PRIMARY KEY,
+    client_id VARCHAR NOT NULL,
+    user_guid UUID NOT NULL,
+    redirect_uri VARCHAR NOT NULL,
+    scope VARCHAR NOT NULL,
+    nonce VARCHAR,
+    code_challenge VARCHAR NOT NULL,
+    code_challenge_method VARCHAR NOT NULL,
+    sso_session_id UUID,
+    expires_at TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE oauth_clients (
+    id VARCHAR PRIMARY KEY,
+    name VARCHAR NOT NULL DEFAULT '',
+    secret_hash VARCHAR,
+    redirect_uris JSONB NOT NULL DEFAULT '[]',
+    grant_types JSONB NOT NULL DEFAULT '[]',
+    scopes JSONB NOT NULL DEFAULT '[]',
+    exchange_audiences JSONB NOT NULL DEFAULT '[]',
+    token_endpoint_auth_method VARCHAR NOT NULL,
+    jwks_uri VARCHAR,
+    jwks JSONB,
+    jwks_file VARCHAR,
+    backchannel_logout_uri VARCHAR,
+    first_party BOOLEAN NOT NULL DEFAULT false,
+    access_token_ttl BIGINT,
+    refresh_token_ttl BIGINT,
+    tenant_id VARCHAR NOT NULL DEFAULT 'default',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    FOREIGN KEY (tenant_id) REFERENCES tenants(id) ON DELETE CASCADE
+);
+
+CREATE TABLE oauth_assertion_jtis (
+    issuer VARCHAR NOT NULL,
+    jti VARCHAR NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (issuer, jti)
+);
+
+CREATE TABLE device_codes (
+    device_code_hash VARCHAR PRIMARY KEY,
+    user_code_hash VARCHAR UNIQUE NOT NULL,
+    client_id VARCHAR NOT NULL,
+    scope VARCHAR NOT NULL,
+    status VARCHAR NOT NULL,
+    user_guid UUID,
+    interval_seconds BIGINT NOT NULL,
+    last_polled_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (client_id) REFERENCES oauth_clients(id) ON DELETE CASCADE,
     FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE oauth_pushed_requests (
+    request_uri_hash VARCHAR PRIMARY KEY,
+    client_id VARCHAR NOT NULL,
+    request JSONB NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (client_id) REFERENCES oauth_clients(id) ON DELETE CASCADE
+);
+
+CREATE TABLE backchannel_logouts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    client_id VARCHAR NOT NULL,
+    user_guid UUID NOT NULL,
+    sid UUID,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error VARCHAR,
+    FOREIGN KEY (client_id) REFERENCES oauth_clients(id) ON DELETE CASCADE
+);
+
+CREATE TABLE oauth_consents (
+    user_guid UUID NOT NULL,
+    client_id VARCHAR NOT NULL,
+    scopes JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_guid, client_id),
+    FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (client_id) REFERENCES oauth_clients(id) ON DELETE CASCADE
+);
+
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    key_digest VARCHAR UNIQUE NOT NULL,
+    display_prefix VARCHAR NOT NULL,
+    name VARCHAR NOT NULL DEFAULT '',
+    user_guid UUID,
+    client_id VARCHAR,
+    scopes JSONB NOT NULL DEFAULT '[]',
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK ((user_guid IS NULL) <> (client_id IS NULL)),
+    FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (client_id) REFERENCES oauth_clients(id) ON DELETE CASCADE
+);
+
+CREATE TABLE permissions (
+    name VARCHAR PRIMARY KEY,
+    description VARCHAR NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE roles (
+    name VARCHAR PRIMARY KEY,
+    description VARCHAR NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE role_permissions (
+    role_name VARCHAR NOT NULL,
+    permission VARCHAR NOT NULL,
+    PRIMARY KEY (role_name, permission),
+    FOREIGN KEY (role_name) REFERENCES roles(name) ON DELETE CASCADE,
+    FOREIGN KEY (permission) REFERENCES permissions(name) ON DELETE CASCADE
+);
+
+CREATE TABLE user_roles (
+    user_guid UUID NOT NULL,
+    role_name VARCHAR NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_guid, role_name),
+    FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (role_name) REFERENCES roles(name) ON DELETE CASCADE
+);
+
+CREATE TABLE tenant_members (
+    tenant_id VARCHAR NOT NULL,
+    user_guid UUID NOT NULL,
+    role VARCHAR NOT NULL DEFAULT 'member',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (tenant_id, user_guid),
+    FOREIGN KEY (tenant_id) REFERENCES tenants(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE tenant_signing_keys (
+    id VARCHAR PRIMARY KEY,
+    tenant_id VARCHAR NOT NULL,
+    private_key TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    FOREIGN KEY (tenant_id) REFERENCES tenants(id) ON DELETE CASCADE
+);
+
+CREATE TABLE tenant_invitations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id VARCHAR NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR NOT NULL DEFAULT 'member',
+    token_hash VARCHAR UNIQUE NOT NULL,
+    invited_by UUID,
+    accepted_by UUID,
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    FOREIGN KEY (tenant_id) REFERENCES tenants(id) ON DELETE CASCADE,
+    FOREIGN KEY (invited_by) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (accepted_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE TABLE groups (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR UNIQUE NOT NULL,
+    description VARCHAR NOT NULL DEFAULT '',
+    parent_id UUID,
+    external_id VARCHAR,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    FOREIGN KEY (parent_id) REFERENCES groups(id) ON DELETE SET NULL
+);
+
+CREATE TABLE group_members (
+    group_id UUID NOT NULL,
+    user_guid UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (group_id, user_guid),
+    FOREIGN KEY (group_id) REFERENCES groups(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_guid) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE audit_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    event VARCHAR NOT NULL,
+    actor_guid UUID,
+    subject_guid UUID,
+    session_id UUID,
+    ip_address VARCHAR,
+    details VARCHAR NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_events_subject_idx ON audit_events (subject_guid, created_at);
+CREATE INDEX audit_events_actor_idx ON audit_events (actor_guid, created_at);
+
+CREATE INDEX users_external_id_idx ON users (external_id);
+CREATE INDEX groups_external_id_idx ON groups (external_id);
+
+CREATE TABLE mfa_used_challenges (
+    jti VARCHAR PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
 );
\ No newline at end of file